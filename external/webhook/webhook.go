@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Timeout bounds how long a webhook receiver can hold up delivery.
+const Timeout = 5 * time.Second
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the body,
+// keyed by the webhook's secret, so receivers can tell it came from us.
+const SignatureHeader = "X-Signature"
+
+// receivers are user supplied, so they can't be inside our network
+var blocked = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+var blockedNets = func() []*net.IPNet {
+	nets := make([]*net.IPNet, len(blocked))
+	for i, cidr := range blocked {
+		_, nets[i], _ = net.ParseCIDR(cidr)
+	}
+	return nets
+}()
+
+var client = &http.Client{
+	Timeout: Timeout,
+	Transport: &http.Transport{
+		// check the address actually dialed, so a host can't resolve
+		// to a public address when it's verified and a private one
+		// when it's delivered to
+		DialContext: (&net.Dialer{
+			Timeout: Timeout,
+			Control: control,
+		}).DialContext,
+		TLSHandshakeTimeout: Timeout,
+	},
+	// receivers are user supplied, so don't follow them elsewhere
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Verify checks the url is https, and that its host only resolves to
+// public addresses.
+func Verify(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("webhook url must be an https url")
+	}
+
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("webhook host %v does not resolve", u.Hostname())
+	}
+
+	for _, ip := range ips {
+		if !public(ip) {
+			return fmt.Errorf("webhook host %v is not a public address", u.Hostname())
+		}
+	}
+
+	return nil
+}
+
+// Sign returns the signature of the body with the secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post sends the payload to the url as JSON, signed with the secret.
+// Any response outside of 2xx is an error.
+func Post(url, secret string, payload interface{}) error {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(secret, buf))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+
+	return nil
+}
+
+func control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !public(ip) {
+		return fmt.Errorf("webhook address %v is not public", host)
+	}
+
+	return nil
+}
+
+func public(ip net.IP) bool {
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	assert.Nil(t, Verify("https://203.0.113.10/hook"))
+	assert.Nil(t, Verify("https://[2001:db8::1]:8443/hook"))
+
+	for _, rawurl := range []string{
+		"http://203.0.113.10/hook",
+		"https:///hook",
+		"https://localhost/hook",
+		"https://127.0.0.1/hook",
+		"https://10.1.2.3/hook",
+		"https://172.16.0.1/hook",
+		"https://192.168.1.1/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://100.64.0.1/hook",
+		"https://[::1]/hook",
+		"https://[fe80::1]/hook",
+		"https://[::ffff:127.0.0.1]/hook",
+	} {
+		assert.NotNil(t, Verify(rawurl), rawurl)
+	}
+}
+
+func TestPost(t *testing.T) {
+	called := false
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	// a host which resolves to a private address at delivery is
+	// refused, whatever it resolved to when it was verified
+	err := Post(srv.URL, "secret", map[string]string{"event": "test"})
+	require.NotNil(t, err)
+	assert.False(t, called)
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t,
+		"f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign("key", []byte("The quick brown fox jumps over the lazy dog")))
+
+	var (
+		body      []byte
+		signature string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer srv.Close()
+
+	// deliver to the local server without the address check
+	defer func(c *http.Client) { client = c }(client)
+	client = srv.Client()
+
+	require.Nil(t, Post(srv.URL, "secret", map[string]string{"event": "test"}))
+	assert.Equal(t, `{"event":"test"}`, string(body))
+	assert.Equal(t, Sign("secret", body), signature)
+}
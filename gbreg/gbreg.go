@@ -2,20 +2,28 @@ package gbreg
 
 import (
 	"encoding/json"
+	"sync"
 
 	"github.com/alpacahq/gobroker/service/accesskey"
+	"github.com/alpacahq/gobroker/service/alert"
+	"github.com/alpacahq/gobroker/service/algo"
 	"github.com/alpacahq/gobroker/service/asset"
 	"github.com/alpacahq/gobroker/service/assetcache"
 	"github.com/alpacahq/gobroker/service/bar"
 	"github.com/alpacahq/gobroker/service/fundamental"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/service/order"
+	"github.com/alpacahq/gobroker/service/performance"
 	"github.com/alpacahq/gobroker/service/portfolio"
 	"github.com/alpacahq/gobroker/service/position"
 	"github.com/alpacahq/gobroker/service/profitloss"
 	"github.com/alpacahq/gobroker/service/quote"
 	"github.com/alpacahq/gobroker/service/registry"
+	"github.com/alpacahq/gobroker/service/snapshot"
 	"github.com/alpacahq/gobroker/service/tradeaccount"
+	"github.com/alpacahq/gobroker/service/watchlist"
 	"github.com/alpacahq/gopaca/env"
+	"github.com/alpacahq/gopaca/log"
 	"github.com/alpacahq/gopaca/rmq"
 	"github.com/gofrs/uuid"
 )
@@ -25,6 +33,11 @@ var (
 	OrderRequests = env.GetVar("ORDER_REQUESTS_QUEUE")
 )
 
+var (
+	marketData     marketdata.MarketData
+	marketDataOnce sync.Once
+)
+
 type gbRegistry struct{}
 
 func (r *gbRegistry) Account() tradeaccount.TradeAccountService {
@@ -47,16 +60,32 @@ func (r *gbRegistry) Fundamental() fundamental.FundamentalService {
 	return fundamental.Service()
 }
 
+// MarketData is loaded once, since the local store is read from disk.
+func (r *gbRegistry) MarketData() marketdata.MarketData {
+	marketDataOnce.Do(func() {
+		md, err := marketdata.FromEnv()
+		if err != nil {
+			log.Panic("failed to load market data", "error", err)
+		}
+		marketData = md
+	})
+	return marketData
+}
+
 func (r *gbRegistry) Quote() quote.QuoteService {
-	return quote.Service(r.AssetCache())
+	return quote.Service(r.AssetCache(), r.MarketData())
 }
 
 func (r *gbRegistry) Bar() bar.BarService {
-	return bar.Service(r.AssetCache())
+	return bar.Service(r.AssetCache(), r.MarketData())
+}
+
+func (r *gbRegistry) Snapshot() snapshot.SnapshotService {
+	return snapshot.Service(r.AssetCache(), r.MarketData(), r.Bar())
 }
 
 func (r *gbRegistry) Position() position.PositionService {
-	return position.Service(r.AssetCache())
+	return position.Service(r.AssetCache(), r.MarketData())
 }
 
 func (r *gbRegistry) Order() order.OrderService {
@@ -64,6 +93,7 @@ func (r *gbRegistry) Order() order.OrderService {
 		submitTrade,
 		r.Position(),
 		tradeaccount.Service(),
+		r.MarketData(),
 	)
 }
 
@@ -72,15 +102,39 @@ func (r *gbRegistry) Portfolio() portfolio.PortfolioService {
 		r.AssetCache(),
 		r.Account(),
 		r.Position(),
+		r.MarketData(),
 	)
 }
 
 func (r *gbRegistry) ProfitLoss() profitloss.ProfitLossService {
 	return profitloss.Service(
 		r.AssetCache(),
+		r.MarketData(),
 	)
 }
 
+func (r *gbRegistry) Performance() performance.PerformanceService {
+	return performance.Service(
+		r.AssetCache(),
+		r.Bar(),
+	)
+}
+
+func (r *gbRegistry) Watchlist() watchlist.WatchlistService {
+	return watchlist.Service(
+		r.AssetCache(),
+		r.Quote(),
+	)
+}
+
+func (r *gbRegistry) Alert() alert.AlertService {
+	return alert.Service(r.AssetCache())
+}
+
+func (r *gbRegistry) Algo() algo.AlgoService {
+	return algo.Service(r.Order(), r.Bar())
+}
+
 func init() {
 	Services = &gbRegistry{}
 }
@@ -44,6 +44,10 @@ const (
 	MicroDepositSuccess MailType = "microdeposit_success"
 	MicroDepositFail    MailType = "microdeposit_fail"
 	RelinkBankMFA       MailType = "relink_bank_mfa"
+	RecurringPaused     MailType = "recurring_transfer_paused"
+	TransferReturned    MailType = "transfer_returned"
+	OrdersAdjusted      MailType = "orders_adjusted"
+	PriceAlert          MailType = "price_alert"
 	// internal
 	MonthlySettlement MailType = "monthly_settlement"
 )
@@ -502,3 +506,214 @@ func SendBalanceLow(name, email string) (err error) {
 	}
 	return
 }
+
+func SendRecurringTransferPaused(acct, givenName, email string, amount decimal.Decimal, reason string) (err error) {
+	if reason == "" {
+		reason = "Unknown Reason"
+	}
+
+	amt, _ := amount.Float64()
+
+	tmplData := struct {
+		Name    string
+		Account string
+		Amount  string
+		Reason  string
+	}{
+		Name:    givenName,
+		Account: MaskApexAccount(acct),
+		Amount:  humanize.CommafWithDigits(amt, 2),
+		Reason:  reason,
+	}
+
+	html, err := templates.ExecuteTemplate(layouts.Base(), partials.RecurringTransferPaused, tmplData)
+	if err != nil {
+		return err
+	}
+
+	msg := mailgun.Email{
+		Sender:    getSender(),
+		Subject:   "Your Recurring Deposit Has Been Paused",
+		HTML:      html,
+		Recipient: email,
+		DeliverAt: nil,
+		Bcc:       getBcc(),
+	}
+
+	if err = mailgun.Send(msg); err != nil {
+		log.Error(
+			"mailer send error",
+			"type", RecurringPaused,
+			"account", acct,
+			"error", err)
+	}
+
+	return
+}
+
+// TransferReturnNotice describes a returned transfer, and the
+// restrictions placed on the account because of it.
+type TransferReturnNotice struct {
+	Direction            apex.TransferDirection
+	Amount               decimal.Decimal
+	Reason               string
+	Reversed             bool
+	DepositsBlockedUntil *time.Time
+	RelationshipCanceled bool
+	WithdrawalsBlocked   bool
+}
+
+func SendTransferReturned(acct, givenName, email string, notice TransferReturnNotice) (err error) {
+	if notice.Reason == "" {
+		notice.Reason = "Unknown Reason"
+	}
+
+	amt, _ := notice.Amount.Float64()
+
+	direction := "deposit"
+	if notice.Direction == apex.Outgoing {
+		direction = "withdrawal"
+	}
+
+	var blockedUntil string
+	if notice.DepositsBlockedUntil != nil {
+		blockedUntil = notice.DepositsBlockedUntil.Format(dateFormat)
+	}
+
+	tmplData := struct {
+		Name                 string
+		Account              string
+		Direction            string
+		Amount               string
+		Reason               string
+		Reversed             bool
+		DepositsBlockedUntil string
+		RelationshipCanceled bool
+		WithdrawalsBlocked   bool
+	}{
+		Name:                 givenName,
+		Account:              MaskApexAccount(acct),
+		Direction:            direction,
+		Amount:               humanize.CommafWithDigits(amt, 2),
+		Reason:               notice.Reason,
+		Reversed:             notice.Reversed,
+		DepositsBlockedUntil: blockedUntil,
+		RelationshipCanceled: notice.RelationshipCanceled,
+		WithdrawalsBlocked:   notice.WithdrawalsBlocked,
+	}
+
+	html, err := templates.ExecuteTemplate(layouts.Base(), partials.TransferReturned, tmplData)
+	if err != nil {
+		return err
+	}
+
+	msg := mailgun.Email{
+		Sender:    getSender(),
+		Subject:   "Your Transfer Was Returned",
+		HTML:      html,
+		Recipient: email,
+		DeliverAt: nil,
+		Bcc:       getBcc(),
+	}
+
+	if err = mailgun.Send(msg); err != nil {
+		log.Error(
+			"mailer send error",
+			"type", TransferReturned,
+			"account", acct,
+			"error", err)
+	}
+
+	return
+}
+
+// OrderAdjustment describes an open order which was canceled, or
+// replaced, because of a corporate action.
+type OrderAdjustment struct {
+	Symbol    string
+	Side      string
+	Qty       decimal.Decimal
+	Canceled  bool
+	NewSymbol string
+	NewQty    decimal.Decimal
+	Reason    string
+}
+
+func SendOrdersAdjusted(acct, givenName, email string, orders []OrderAdjustment) (err error) {
+	tmplData := struct {
+		Name    string
+		Account string
+		Orders  []OrderAdjustment
+	}{
+		Name:    givenName,
+		Account: MaskApexAccount(acct),
+		Orders:  orders,
+	}
+
+	html, err := templates.ExecuteTemplate(layouts.Base(), partials.OrdersAdjusted, tmplData)
+	if err != nil {
+		return err
+	}
+
+	msg := mailgun.Email{
+		Sender:    getSender(),
+		Subject:   "Your Open Orders Have Been Adjusted",
+		HTML:      html,
+		Recipient: email,
+		DeliverAt: nil,
+		Bcc:       getBcc(),
+	}
+
+	if err = mailgun.Send(msg); err != nil {
+		log.Error(
+			"mailer send error",
+			"type", OrdersAdjusted,
+			"account", acct,
+			"error", err)
+	}
+
+	return
+}
+
+// SendPriceAlert tells the account owner their alert fired. The
+// condition describes what the alert was waiting for, such as
+// "AAPL rose above $200.00".
+func SendPriceAlert(acct, givenName, email, symbol, condition string, price decimal.Decimal) (err error) {
+	px, _ := price.Float64()
+
+	tmplData := struct {
+		Name      string
+		Symbol    string
+		Condition string
+		Price     string
+	}{
+		Name:      givenName,
+		Symbol:    symbol,
+		Condition: condition,
+		Price:     humanize.CommafWithDigits(px, 2),
+	}
+
+	html, err := templates.ExecuteTemplate(layouts.Base(), partials.PriceAlert, tmplData)
+	if err != nil {
+		return err
+	}
+
+	msg := mailgun.Email{
+		Sender:    getSender(),
+		Subject:   fmt.Sprintf("Price Alert: %v", symbol),
+		HTML:      html,
+		Recipient: email,
+		DeliverAt: nil,
+		Bcc:       getBcc(),
+	}
+
+	if err = mailgun.Send(msg); err != nil {
+		log.Error(
+			"mailer send error",
+			"type", PriceAlert,
+			"account", acct,
+			"error", err)
+	}
+
+	return
+}
@@ -0,0 +1,23 @@
+package partials
+
+var OrdersAdjusted Partial = `
+{{ define "content" }}
+	<div style='text-align:left;'>
+		Hello {{ .Name }},<br>
+		<br>
+		A corporate action taking effect today affects open orders in account {{ .Account }}. The following orders have been changed before the market opens:<br>
+		<br>
+		{{ range .Orders }}
+		{{ .Side }} {{ .Qty }} {{ .Symbol }}{{ if .Canceled }}: canceled{{ else }}: replaced with {{ .Side }} {{ .NewQty }} {{ .NewSymbol }}{{ end }} ({{ .Reason }})<br>
+		{{ end }}
+		<br>
+		You can review your open orders and place new ones on your <a href="https://app.alpaca.markets" style="text-decoration: none; color: #bfa100;">Account Page</a>.<br>
+		<br><br>
+		If you have any questions or concerns, please reach out to support@alpaca.markets.<br><br><br>
+		Sincerely,<br>
+		<br>
+		The Alpaca Team<br>
+		<a href="https://alpaca.markets" style="text-decoration: none; color: #bfa100;">https://alpaca.markets</a>
+	</div>
+{{ end }}
+`
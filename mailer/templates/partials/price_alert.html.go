@@ -0,0 +1,19 @@
+package partials
+
+var PriceAlert Partial = `
+{{ define "content" }}
+	<div style='text-align:left;'>
+		Hello {{ .Name }},<br>
+		<br>
+		Your price alert for {{ .Symbol }} has been triggered. {{ .Condition }}, and it last traded at ${{ .Price }}.<br>
+		<br>
+		The alert is now inactive. You can set up new alerts on your <a href="https://app.alpaca.markets" style="text-decoration: none; color: #bfa100;">Dashboard</a>.<br>
+		<br><br>
+		If you have any questions or concerns, please reach out to support@alpaca.markets.<br><br><br>
+		Sincerely,<br>
+		<br>
+		The Alpaca Team<br>
+		<a href="https://alpaca.markets" style="text-decoration: none; color: #bfa100;">https://alpaca.markets</a>
+	</div>
+{{ end }}
+`
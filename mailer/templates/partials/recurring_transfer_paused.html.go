@@ -0,0 +1,22 @@
+package partials
+
+var RecurringTransferPaused Partial = `
+{{ define "content" }}
+	<img src="https://files.alpaca.markets/webassets/email/failure@2x.png" width="128" height="128" style="margin: auto; display: block">
+	<div style='text-align:left;'>
+		Hello {{ .Name }},<br>
+		<br>
+		Your recurring deposit of ${{ .Amount }} into account {{ .Account }} has been paused because your bank returned the latest transfer with the following reason:<br>
+		<br>
+		{{ .Reason }}<br>
+		<br>
+		No further deposits will be made on this schedule. Once the issue has been resolved with your bank, you can resume it on your <a href="https://app.alpaca.markets" style="text-decoration: none; color: #bfa100;">Account Page</a>.<br>
+		<br><br>
+		If you have any questions or concerns, please reach out to support@alpaca.markets.<br><br><br>
+		Sincerely,<br>
+		<br>
+		The Alpaca Team<br>
+		<a href="https://alpaca.markets" style="text-decoration: none; color: #bfa100;">https://alpaca.markets</a>
+	</div>
+{{ end }}
+`
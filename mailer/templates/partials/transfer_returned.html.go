@@ -0,0 +1,24 @@
+package partials
+
+var TransferReturned Partial = `
+{{ define "content" }}
+	<img src="https://files.alpaca.markets/webassets/email/failure@2x.png" width="128" height="128" style="margin: auto; display: block">
+	<div style='text-align:left;'>
+		Hello {{ .Name }},<br>
+		<br>
+		Your bank returned your {{ .Direction }} of ${{ .Amount }} for account {{ .Account }} with the following reason:<br>
+		<br>
+		{{ .Reason }}<br>
+		<br>
+		{{ if .Reversed }}The funds from this deposit have been removed from your buying power.<br><br>{{ end }}
+		{{ if .DepositsBlockedUntil }}New deposits to your account are blocked until {{ .DepositsBlockedUntil }}.<br><br>{{ end }}
+		{{ if .RelationshipCanceled }}The bank account used for this transfer has been unlinked. Please link a different bank account on your <a href="https://app.alpaca.markets" style="text-decoration: none; color: #bfa100;">Account Page</a> to continue transferring funds.<br><br>{{ end }}
+		{{ if .WithdrawalsBlocked }}Withdrawals from your account have been frozen while our team reviews the transfer. We will reach out to you if we need any further information.<br><br>{{ end }}
+		If you have any questions or concerns, please reach out to support@alpaca.markets.<br><br><br>
+		Sincerely,<br>
+		<br>
+		The Alpaca Team<br>
+		<a href="https://alpaca.markets" style="text-decoration: none; color: #bfa100;">https://alpaca.markets</a>
+	</div>
+{{ end }}
+`
@@ -5,6 +5,7 @@ import (
 
 	"github.com/alpacahq/apex"
 	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/jinzhu/gorm"
 	gormigrate "gopkg.in/gormigrate.v1"
 )
@@ -942,5 +943,405 @@ func Migration(db *gorm.DB) *gormigrate.Gormigrate {
 				return nil
 			},
 		},
+		{
+			ID: "201901151000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.AdminAction{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.DropTable("admin_actions").Error
+			},
+		},
+		{
+			ID: "201901181200",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.AuditLog{}).Error; err != nil {
+					return err
+				}
+				// audit logs are append-only, so anything else fails
+				if err := tx.Exec(`CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+					BEGIN
+						RAISE EXCEPTION 'audit_logs is append-only';
+					END;
+					$$ LANGUAGE plpgsql`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`CREATE TRIGGER audit_logs_append_only
+					BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_logs
+					FOR EACH STATEMENT EXECUTE PROCEDURE audit_logs_append_only()`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.DropTable("audit_logs").Error; err != nil {
+					return err
+				}
+				return tx.Exec("DROP FUNCTION IF EXISTS audit_logs_append_only()").Error
+			},
+		},
+		{
+			ID: "201901221400",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.WireBeneficiary{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE transfers ADD COLUMN beneficiary_id uuid").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Transfer{}).DropColumn("beneficiary_id").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return tx.DropTable("wire_beneficiaries").Error
+			},
+		},
+		{
+			ID: "201901251100",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.RecurringTransfer{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE transfers ADD COLUMN recurring_transfer_id uuid").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Transfer{}).DropColumn("recurring_transfer_id").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return tx.DropTable("recurring_transfers").Error
+			},
+		},
+		{
+			ID: "201901281000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.TransferLimitOverride{}).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE transfers ADD COLUMN hold_reasons json").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Transfer{}).DropColumn("hold_reasons").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return tx.DropTable("transfer_limit_overrides").Error
+			},
+		},
+		{
+			ID: "201901301500",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN deposits_blocked_until timestamp with time zone").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN withdrawals_blocked boolean NOT NULL DEFAULT FALSE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Account{}).DropColumn("deposits_blocked_until").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Account{}).DropColumn("withdrawals_blocked").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902041000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Journal{}, &models.JournalEntry{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.DropTable("journal_entries", "journals").Error
+			},
+		},
+		{
+			ID: "201902061000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN protect_good_faith boolean NOT NULL DEFAULT TRUE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN settled_cash_only_until date").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE positions ADD COLUMN funds_settle_date date").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return tx.AutoMigrate(&models.GoodFaithViolation{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Account{}).DropColumn("protect_good_faith").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Account{}).DropColumn("settled_cash_only_until").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Position{}).DropColumn("funds_settle_date").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return tx.DropTable("good_faith_violations").Error
+			},
+		},
+		{
+			ID: "201902071000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN lot_method varchar(4) NOT NULL DEFAULT 'fifo'").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN lot_ids integer[]").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Account{}).DropColumn("lot_method").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).DropColumn("lot_ids").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902081000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.EquitySnapshot{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.DropTable("equity_snapshots").Error
+			},
+		},
+		{
+			ID: "201902111000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE assets ADD COLUMN name text").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE assets ADD COLUMN easy_to_borrow boolean NOT NULL DEFAULT 'f'").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE assets ADD COLUMN marginable boolean NOT NULL DEFAULT 'f'").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"name", "easy_to_borrow", "marginable"} {
+					if err := tx.Model(&models.Asset{}).DropColumn(column).Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902121000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Watchlist{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.DropTable("watchlists").Error
+			},
+		},
+		{
+			ID: "201902131000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Alert{}).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.DropTable("alerts").Error
+			},
+		},
+		{
+			ID: "201902141000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN extended_hours BOOLEAN NOT NULL DEFAULT 'f'").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Order{}).DropColumn("extended_hours").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902151000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN release_at TIMESTAMP WITH TIME ZONE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Order{}).DropColumn("release_at").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902161000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN parent_id UUID").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN strategy TEXT").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN start_at TIMESTAMP WITH TIME ZONE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN end_at TIMESTAMP WITH TIME ZONE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN max_participation DECIMAL").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).AddIndex("idx_order_parent_id", "parent_id").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Order{}).RemoveIndex("idx_order_parent_id").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).DropColumn("parent_id").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).DropColumn("strategy").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).DropColumn("start_at").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).DropColumn("end_at").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Order{}).DropColumn("max_participation").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902171000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE executions ADD COLUMN commission DECIMAL").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Execution{}).DropColumn("commission").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902171100",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE corporate_actions ADD COLUMN cusip TEXT").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.CorporateAction{}).DropColumn("cusip").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902171200",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE ach_relationships ADD COLUMN unlinked_at TIMESTAMP WITH TIME ZONE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				// the accounts flagged for risky transfers are held by the
+				// velocity check on the banks they unlinked instead
+				if err := tx.Exec(`UPDATE ach_relationships SET unlinked_at = updated_at
+					WHERE status = ? AND unlinked_at IS NULL AND account_id IN (
+						SELECT id FROM accounts WHERE risky_transfers)`, enum.RelationshipCanceled).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.Account{}).DropColumn("risky_transfers").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				if err := tx.Model(&models.Account{}).DropColumn("marked_risky_transfers_at").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN risky_transfers BOOLEAN NOT NULL DEFAULT FALSE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN marked_risky_transfers_at DATE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				if err := tx.Model(&models.ACHRelationship{}).DropColumn("unlinked_at").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902171300",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE accounts ADD COLUMN margin BOOLEAN NOT NULL DEFAULT FALSE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Account{}).DropColumn("margin").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902171400",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE orders ADD COLUMN corporate_action_date DATE").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Order{}).DropColumn("corporate_action_date").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
+		{
+			ID: "201902171500",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec("ALTER TABLE alerts ADD COLUMN webhook_secret TEXT").Error; err != nil && !strings.Contains(err.Error(), "already exists") {
+					return err
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Model(&models.Alert{}).DropColumn("webhook_secret").Error; err != nil && !strings.Contains(err.Error(), "does not exist") {
+					return err
+				}
+				return nil
+			},
+		},
 	})
 }
@@ -33,9 +33,13 @@ type Account struct {
 	MarkedPatternDayTraderAt *date.Date              `json:"marked_pattern_day_trader_at" sql:"type:date"`
 	AccReqResult             string                  `json:"acc_req_result" sql:"type:text"`
 	TradingBlocked           bool                    `json:"trading_blocked"`
-	RiskyTransfers           bool                    `json:"risky_transfers"` // Risky Business
-	MarkedRiskyTransfersAt   *date.Date              `json:"marked_risky_transfers_at" sql:"type:date"`
 	AccountBlocked           bool                    `json:"account_blocked"`
+	DepositsBlockedUntil     *time.Time              `json:"deposits_blocked_until"`
+	WithdrawalsBlocked       bool                    `json:"withdrawals_blocked" gorm:"not null" sql:"default:FALSE"`
+	LotMethod                enum.LotMethod          `json:"lot_method" gorm:"type:varchar(4);not null" sql:"default:'fifo'"`
+	ProtectGoodFaith         bool                    `json:"-" gorm:"not null" sql:"default:TRUE"`
+	Margin                   bool                    `json:"margin" gorm:"not null" sql:"default:FALSE"`
+	SettledCashOnlyUntil     *date.Date              `json:"settled_cash_only_until" sql:"type:date"`
 	TradeSuspendedByUser     bool                    `json:"trade_suspended_by_user" gorm:"not null" sql:"default:FALSE"`
 	Positions                []Position              `json:"-" gorm:"ForeignKey:AccountID"`
 	Relationships            []ACHRelationship       `json:"-" gorm:"ForeignKey:AccountID"`
@@ -54,6 +58,7 @@ func (a *Account) ToTradeAccount() (*TradeAccount, error) {
 	acc := TradeAccount{
 		ID:                       a.ID,
 		Status:                   a.Status,
+		Plan:                     a.Plan,
 		Currency:                 a.Currency,
 		Cash:                     a.Cash,
 		CashWithdrawable:         a.CashWithdrawable,
@@ -62,6 +67,10 @@ func (a *Account) ToTradeAccount() (*TradeAccount, error) {
 		ProtectPatternDayTrader:  a.ProtectPatternDayTrader,
 		PatternDayTrader:         a.PatternDayTrader,
 		MarkedPatternDayTraderAt: a.MarkedPatternDayTraderAt,
+		ProtectGoodFaith:         a.ProtectGoodFaith,
+		Margin:                   a.Margin,
+		LotMethod:                a.LotMethod,
+		SettledCashOnlyUntil:     a.SettledCashOnlyUntil,
 		TradingBlocked:           a.TradingBlocked,
 		AccountBlocked:           a.AccountBlocked,
 		CreatedAt:                a.CreatedAt,
@@ -14,4 +14,6 @@ type CorporateAction struct {
 	StockFactor *decimal.Decimal `json:"stock_factor" gorm:"type:decimal;"`
 	// $ amount per share to pay out to to account holding position
 	CashFactor *decimal.Decimal `json:"cash_factor" gorm:"type:decimal;"`
+	// cusip of the security after the action
+	Cusip string `json:"cusip" sql:"type:text"`
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -56,3 +57,28 @@ type AdminEmail struct {
 	AdminID   string         `json:"admin_id" gorm:"not null;index" sql:"type:uuid;"`
 	Admin     *Administrator `json:"-" gorm:"ForeignKey:AdminID"`
 }
+
+type AdminActionType string
+
+const (
+	AdminUnmaskDetails   AdminActionType = "UNMASK_DETAILS"
+	AdminSetFlags        AdminActionType = "SET_FLAGS"
+	AdminCreateNote      AdminActionType = "CREATE_NOTE"
+	AdminApproveTransfer AdminActionType = "APPROVE_TRANSFER"
+	AdminCancelOrder     AdminActionType = "CANCEL_ORDER"
+	AdminViewWire        AdminActionType = "VIEW_WIRE"
+	AdminSetLimits       AdminActionType = "SET_TRANSFER_LIMITS"
+	AdminClearLimits     AdminActionType = "CLEAR_TRANSFER_LIMITS"
+)
+
+// AdminAction records every mutation (or sensitive read) performed
+// by an administrator through the admin API. Rows are never updated
+// or deleted.
+type AdminAction struct {
+	ID        uint            `json:"id" gorm:"primary_key"`
+	CreatedAt time.Time       `json:"created_at"`
+	AdminID   string          `json:"admin_id" gorm:"not null;index" sql:"type:uuid;"`
+	AccountID *string         `json:"account_id" gorm:"index" sql:"type:uuid;"`
+	Type      AdminActionType `json:"type" sql:"type:text;not null;"`
+	Payload   json.RawMessage `json:"payload" sql:"type:json"`
+}
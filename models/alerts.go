@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// Alert notifies an account when an asset's price crosses a level, or
+// moves by a percent in a day. Alerts fire once, and are deactivated
+// when they do.
+type Alert struct {
+	ID             string           `json:"id" gorm:"primary_key" sql:"type:uuid;"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+	AccountID      string           `json:"account_id" gorm:"not null;index" sql:"type:uuid;"`
+	AssetID        string           `json:"asset_id" gorm:"not null" sql:"type:uuid;"`
+	Symbol         string           `json:"symbol" gorm:"not null"`
+	Type           enum.AlertType   `json:"type" gorm:"type:varchar(16);not null"`
+	Price          *decimal.Decimal `json:"price" gorm:"type:decimal"`
+	Percent        *decimal.Decimal `json:"percent" gorm:"type:decimal"`
+	WebhookURL     *string          `json:"webhook_url" sql:"type:text"`
+	WebhookSecret  *string          `json:"-" sql:"type:text"`
+	Active         bool             `json:"active" gorm:"not null;index"`
+	TriggeredAt    *time.Time       `json:"triggered_at"`
+	TriggeredPrice *decimal.Decimal `json:"triggered_price" gorm:"type:decimal"`
+}
+
+func (a *Alert) BeforeCreate(scope *gorm.Scope) error {
+	if a.ID == "" {
+		a.ID = uuid.Must(uuid.NewV4()).String()
+	}
+	return scope.SetColumn("id", a.ID)
+}
+
+func (a *Alert) AccountIDAsUUID() uuid.UUID {
+	id, _ := uuid.FromString(a.AccountID)
+	return id
+}
+
+// Crossed returns true if a trade at px meets the alert's condition.
+// Percent changes are signed, so -5 fires on a 5% drop from the
+// previous close, and 5 on a 5% rise.
+func (a *Alert) Crossed(px, prevClose decimal.Decimal) bool {
+	switch a.Type {
+	case enum.AlertAbove:
+		return a.Price != nil && px.GreaterThanOrEqual(*a.Price)
+	case enum.AlertBelow:
+		return a.Price != nil && px.LessThanOrEqual(*a.Price)
+	case enum.AlertPctChange:
+		if a.Percent == nil || !prevClose.IsPositive() {
+			return false
+		}
+
+		change := px.Sub(prevClose).Div(prevClose).Mul(decimal.New(100, 0))
+
+		if a.Percent.IsNegative() {
+			return change.LessThanOrEqual(*a.Percent)
+		}
+		return change.GreaterThanOrEqual(*a.Percent)
+	default:
+		return false
+	}
+}
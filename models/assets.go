@@ -9,18 +9,21 @@ import (
 )
 
 type Asset struct {
-	ID        string           `json:"id" gorm:"primary_key" sql:"type:uuid;"`
-	CreatedAt time.Time        `json:"-"`
-	UpdatedAt time.Time        `json:"-"`
-	Class     enum.AssetClass  `json:"asset_class" gorm:"unique_index:idx_asset_exchange_symbol" sql:"type:text"`
-	Exchange  string           `json:"exchange" gorm:"unique_index:idx_asset_exchange_symbol" sql:"type:text"`
-	Symbol    string           `json:"symbol" gorm:"unique_index:idx_asset_exchange_symbol" sql:"type:text"`
-	SymbolOld string           `json:"-" sql:"type:text"`
-	CUSIP     string           `json:"-" gorm:"column:cusip" sql:"type:text"`
-	CUSIPOld  string           `json:"-" gorm:"column:cusip_old" sql:"type:text"`
-	Status    enum.AssetStatus `json:"status" sql:"type:text"`
-	Tradable  bool             `json:"tradable"`
-	Shortable bool             `json:"-"`
+	ID           string           `json:"id" gorm:"primary_key" sql:"type:uuid;"`
+	CreatedAt    time.Time        `json:"-"`
+	UpdatedAt    time.Time        `json:"-"`
+	Class        enum.AssetClass  `json:"asset_class" gorm:"unique_index:idx_asset_exchange_symbol" sql:"type:text"`
+	Exchange     string           `json:"exchange" gorm:"unique_index:idx_asset_exchange_symbol" sql:"type:text"`
+	Symbol       string           `json:"symbol" gorm:"unique_index:idx_asset_exchange_symbol" sql:"type:text"`
+	SymbolOld    string           `json:"-" sql:"type:text"`
+	CUSIP        string           `json:"-" gorm:"column:cusip" sql:"type:text"`
+	CUSIPOld     string           `json:"-" gorm:"column:cusip_old" sql:"type:text"`
+	Name         string           `json:"name" sql:"type:text"`
+	Status       enum.AssetStatus `json:"status" sql:"type:text"`
+	Tradable     bool             `json:"tradable"`
+	Shortable    bool             `json:"shortable"`
+	EasyToBorrow bool             `json:"easy_to_borrow"`
+	Marginable   bool             `json:"marginable"`
 }
 
 func (a *Asset) BeforeCreate(scope *gorm.Scope) error {
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditActorType string
+
+const (
+	ActorCognito   AuditActorType = "cognito"
+	ActorAccessKey AuditActorType = "access_key"
+	ActorAdmin     AuditActorType = "admin"
+	ActorWorker    AuditActorType = "worker"
+	ActorAnonymous AuditActorType = "anonymous"
+)
+
+type AuditResource string
+
+const (
+	AuditAccount           AuditResource = "account"
+	AuditOwnerDetails      AuditResource = "owner_details"
+	AuditACHRelationship   AuditResource = "ach_relationship"
+	AuditTransfer          AuditResource = "transfer"
+	AuditWireBeneficiary   AuditResource = "wire_beneficiary"
+	AuditRecurringTransfer AuditResource = "recurring_transfer"
+)
+
+type AuditOperation string
+
+const (
+	AuditCreate AuditOperation = "create"
+	AuditUpdate AuditOperation = "update"
+)
+
+// AuditLog is an append-only record of a change to sensitive
+// account data. The table rejects updates and deletes at the
+// database level, so rows must only ever be created. Diff holds
+// the changed fields as {"field": {"before": x, "after": y}},
+// with PII values redacted.
+type AuditLog struct {
+	ID         uint            `json:"id" gorm:"primary_key"`
+	CreatedAt  time.Time       `json:"created_at"`
+	AccountID  *string         `json:"account_id" gorm:"index" sql:"type:uuid;"`
+	ActorID    *string         `json:"actor_id" sql:"type:uuid;"`
+	ActorType  AuditActorType  `json:"actor_type" sql:"type:text;not null;"`
+	RequestID  *string         `json:"request_id" sql:"type:text"`
+	Resource   AuditResource   `json:"resource" sql:"type:text;not null;"`
+	ResourceID string          `json:"resource_id" gorm:"index" sql:"type:text;not null;"`
+	Operation  AuditOperation  `json:"operation" sql:"type:text;not null;"`
+	Diff       json.RawMessage `json:"diff" sql:"type:json"`
+}
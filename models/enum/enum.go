@@ -85,13 +85,17 @@ const (
 	StopLimit     OrderType = "stop_limit"
 	MarketOnClose OrderType = "market_on_close"
 	LimitOnClose  OrderType = "limit_on_close"
+	// Algo is a parent order which is worked by sending child orders
+	// over a window, and is never sent to the order queue itself
+	Algo OrderType = "algo"
 )
 
 func ValidOrderType(oType OrderType) bool {
 	return oType == Market ||
 		oType == Limit ||
 		oType == Stop ||
-		oType == StopLimit
+		oType == StopLimit ||
+		oType == Algo
 }
 
 type Side string
@@ -140,6 +144,8 @@ const (
 	OrderExpired            OrderStatus = "expired"
 	OrderAcceptedForBidding OrderStatus = "accepted_for_bidding"
 	OrderPendingReplace     OrderStatus = "pending_replace"
+	OrderQueued             OrderStatus = "queued"  // Our own status for orders held until the market opens.
+	OrderWorking            OrderStatus = "working" // Our own status for algo orders while they send child orders.
 )
 
 var OrderOpen = []OrderStatus{
@@ -153,6 +159,7 @@ var OrderOpen = []OrderStatus{
 	OrderPendingReplace,
 	OrderAcceptedForBidding,
 	OrderReplaced,
+	OrderQueued,
 }
 
 var OrderClosed = []OrderStatus{
@@ -167,7 +174,10 @@ var OrderClosed = []OrderStatus{
 func OrderStatusFromJSON(status string) []OrderStatus {
 	switch status {
 	case "open":
-		return OrderOpen
+		// working algo orders are open, but left out of OrderOpen
+		// so they don't hold shares or buying power on top of
+		// their children
+		return append([]OrderStatus{OrderWorking}, OrderOpen...)
 	case "closed":
 		return OrderClosed
 	default:
@@ -427,6 +437,55 @@ const (
 	RelationshipCanceled RelationshipStatus = "CANCELED" // apex
 )
 
+type BeneficiaryStatus string
+
+const (
+	BeneficiaryActive   BeneficiaryStatus = "ACTIVE"
+	BeneficiaryCanceled BeneficiaryStatus = "CANCELED"
+)
+
+type RecurringFrequency string
+
+const (
+	Weekly   RecurringFrequency = "weekly"
+	Biweekly RecurringFrequency = "biweekly"
+	Monthly  RecurringFrequency = "monthly"
+)
+
+type JournalType string
+
+const (
+	JournalFill            JournalType = "fill"
+	JournalFee             JournalType = "fee"
+	JournalDividend        JournalType = "dividend"
+	JournalTransfer        JournalType = "transfer"
+	JournalTransferReturn  JournalType = "transfer_return"
+	JournalCorporateAction JournalType = "corporate_action"
+	JournalAdjustment      JournalType = "adjustment"
+)
+
+// LedgerAccount is a book in the cash ledger. Every journal debits
+// and credits the customer's cash against one of the others.
+type LedgerAccount string
+
+const (
+	LedgerCash             LedgerAccount = "cash"
+	LedgerSecurities       LedgerAccount = "securities"
+	LedgerFees             LedgerAccount = "fees"
+	LedgerIncome           LedgerAccount = "income"
+	LedgerBank             LedgerAccount = "bank"
+	LedgerCorporateActions LedgerAccount = "corporate_actions"
+	LedgerSuspense         LedgerAccount = "suspense"
+)
+
+type RecurringTransferStatus string
+
+const (
+	RecurringActive   RecurringTransferStatus = "ACTIVE"
+	RecurringPaused   RecurringTransferStatus = "PAUSED"
+	RecurringCanceled RecurringTransferStatus = "CANCELED"
+)
+
 type TransferStatus string
 
 const (
@@ -486,3 +545,52 @@ var (
 	AccessKeyActive   AccessKeyStatus = "ACTIVE"
 	AccessKeyDisabled AccessKeyStatus = "DISABLED"
 )
+
+// LotMethod is how the lots of a position are picked to be closed
+// when it is sold.
+type LotMethod string
+
+const (
+	// LotFIFO closes the oldest lots first
+	LotFIFO LotMethod = "fifo"
+	// LotLIFO closes the newest lots first
+	LotLIFO LotMethod = "lifo"
+	// LotHIFO closes the lots with the highest cost first
+	LotHIFO LotMethod = "hifo"
+)
+
+func ValidLotMethod(m LotMethod) bool {
+	return m == LotFIFO || m == LotLIFO || m == LotHIFO
+}
+
+// AlertType is the condition a price alert fires on.
+type AlertType string
+
+const (
+	// AlertAbove fires when the price trades at or above the alert price
+	AlertAbove AlertType = "above"
+	// AlertBelow fires when the price trades at or below the alert price
+	AlertBelow AlertType = "below"
+	// AlertPctChange fires when the price has moved by the alert percent
+	// from the previous close
+	AlertPctChange AlertType = "pct_change"
+)
+
+func ValidAlertType(t AlertType) bool {
+	return t == AlertAbove || t == AlertBelow || t == AlertPctChange
+}
+
+// AlgoStrategy is how an algo order's quantity is spread over its
+// window.
+type AlgoStrategy string
+
+const (
+	// TWAP sends even slices over the window
+	TWAP AlgoStrategy = "twap"
+	// VWAP sends slices following the typical intraday volume curve
+	VWAP AlgoStrategy = "vwap"
+)
+
+func ValidAlgoStrategy(s AlgoStrategy) bool {
+	return s == TWAP || s == VWAP
+}
@@ -45,6 +45,7 @@ type Execution struct {
 	Fee3              *decimal.Decimal   `json:"fee3" gorm:"type:decimal"`
 	Fee4              *decimal.Decimal   `json:"fee4" gorm:"type:decimal"`
 	Fee5              *decimal.Decimal   `json:"fee5" gorm:"type:decimal"`
+	Commission        *decimal.Decimal   `json:"commission" gorm:"type:decimal"`
 }
 
 func (e *Execution) BeforeCreate(scope *gorm.Scope) error {
@@ -56,7 +57,7 @@ func (e *Execution) BeforeCreate(scope *gorm.Scope) error {
 
 func (e *Execution) HasFee() bool {
 	fees := []*decimal.Decimal{
-		e.FeeSec, e.FeeMisc, e.Fee1, e.Fee2, e.Fee3, e.Fee4, e.Fee5,
+		e.FeeSec, e.FeeMisc, e.Fee1, e.Fee2, e.Fee3, e.Fee4, e.Fee5, e.Commission,
 	}
 	for i := range fees {
 		if fees[i] != nil {
@@ -67,6 +68,16 @@ func (e *Execution) HasFee() bool {
 }
 
 func (e *Execution) TotalFee() decimal.Decimal {
+	fee := e.ClearingFee()
+	if e.Commission != nil {
+		fee = fee.Add(*e.Commission)
+	}
+	return fee
+}
+
+// ClearingFee returns the fees in the columns the clearing broker
+// reports, which leaves out our own commission.
+func (e *Execution) ClearingFee() decimal.Decimal {
 	fee := decimal.Zero
 
 	fees := []*decimal.Decimal{
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/utils/date"
+)
+
+type GoodFaithSource string
+
+const (
+	// detected when the sell was filled
+	GoodFaithSourceFill GoodFaithSource = "fill"
+	// reported by the clearing broker's margin call file
+	GoodFaithSourceApex GoodFaithSource = "apex"
+)
+
+// GoodFaithViolation is a sale of a position, before the funds used
+// to buy it had settled. Violations are counted by trade date, so at
+// most one is recorded per account per day no matter the source.
+type GoodFaithViolation struct {
+	ID              uint            `json:"-" gorm:"primary_key"`
+	CreatedAt       time.Time       `json:"created_at"`
+	AccountID       string          `json:"account_id" gorm:"not null;unique_index:uix_good_faith_violations" sql:"type:uuid references accounts(id);"`
+	TradeDate       date.Date       `json:"trade_date" gorm:"not null;unique_index:uix_good_faith_violations" sql:"type:date"`
+	Source          GoodFaithSource `json:"source" gorm:"type:varchar(8);not null"`
+	OrderID         *string         `json:"order_id" sql:"type:uuid"`
+	Symbol          *string         `json:"symbol" sql:"type:text"`
+	FundsSettleDate *date.Date      `json:"funds_settle_date" sql:"type:date"`
+}
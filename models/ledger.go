@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// Journal is a single cash movement for an account, recorded as a
+// set of entries that net to zero. The reference identifies what
+// caused it (execution, transfer, dividend...) so posting the same
+// activity twice is a no-op.
+type Journal struct {
+	ID          string           `json:"id" gorm:"primary_key" sql:"type:uuid;"`
+	CreatedAt   time.Time        `json:"created_at"`
+	AccountID   string           `json:"account_id" gorm:"not null;index" sql:"type:uuid references accounts(id);"`
+	Type        enum.JournalType `json:"type" gorm:"type:varchar(24);not null;unique_index:uix_journals"`
+	ReferenceID string           `json:"reference_id" gorm:"not null;unique_index:uix_journals" sql:"type:text"`
+	Description string           `json:"description" sql:"type:text"`
+	TradeDate   date.Date        `json:"trade_date" gorm:"not null" sql:"type:date"`
+	SettleDate  date.Date        `json:"settle_date" gorm:"not null" sql:"type:date"`
+	Entries     []JournalEntry   `json:"entries" gorm:"ForeignKey:JournalID"`
+}
+
+func (j *Journal) BeforeCreate(scope *gorm.Scope) error {
+	if j.ID == "" {
+		j.ID = uuid.Must(uuid.NewV4()).String()
+	}
+	return scope.SetColumn("id", j.ID)
+}
+
+// Balanced returns true if the journal's entries net to zero.
+func (j *Journal) Balanced() bool {
+	total := decimal.Zero
+	for _, e := range j.Entries {
+		total = total.Add(e.Amount)
+	}
+	return total.Equal(decimal.Zero)
+}
+
+// JournalEntry is one side of a journal. Amounts are signed from the
+// account holder's point of view, so a positive cash entry is money
+// into the account. The dates are copied from the journal to keep
+// balance queries off of the join.
+type JournalEntry struct {
+	ID         uint               `json:"-" gorm:"primary_key"`
+	JournalID  string             `json:"-" gorm:"not null;index" sql:"type:uuid references journals(id);"`
+	AccountID  string             `json:"-" gorm:"not null;index:idx_journal_entries_account_ledger" sql:"type:uuid;"`
+	Ledger     enum.LedgerAccount `json:"ledger" gorm:"type:varchar(24);not null;index:idx_journal_entries_account_ledger"`
+	Amount     decimal.Decimal    `json:"amount" gorm:"type:decimal;not null"`
+	TradeDate  date.Date          `json:"-" gorm:"not null" sql:"type:date"`
+	SettleDate date.Date          `json:"-" gorm:"not null" sql:"type:date"`
+}
+
+// CashBalances are an account's cash, split by whether it has
+// settled as of a given date.
+type CashBalances struct {
+	Cash         decimal.Decimal `json:"cash"`
+	Settled      decimal.Decimal `json:"settled"`
+	Unsettled    decimal.Decimal `json:"unsettled"`
+	Withdrawable decimal.Decimal `json:"withdrawable"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/shopspring/decimal"
+)
+
+// TransferLimitOverride replaces the tiered transfer limits for an
+// account in one direction. Nil limits fall back to the tier, and
+// SkipVelocity lets transfers through without being held for review.
+type TransferLimitOverride struct {
+	ID              uint                   `json:"id" gorm:"primary_key"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	AccountID       string                 `json:"account_id" gorm:"not null;unique_index:uix_transfer_limit_overrides" sql:"type:uuid;"`
+	Direction       apex.TransferDirection `json:"direction" gorm:"type:varchar(8);not null;unique_index:uix_transfer_limit_overrides"`
+	AdminID         string                 `json:"admin_id" gorm:"not null" sql:"type:uuid;"`
+	Daily           *decimal.Decimal       `json:"daily" gorm:"type:decimal"`
+	Rolling30Day    *decimal.Decimal       `json:"rolling_30_day" gorm:"type:decimal"`
+	PerRelationship *decimal.Decimal       `json:"per_relationship" gorm:"type:decimal"`
+	SkipVelocity    bool                   `json:"skip_velocity" gorm:"not null"`
+	ExpiresAt       *time.Time             `json:"expires_at"`
+}
+
+func (o *TransferLimitOverride) Expired() bool {
+	return o.ExpiresAt != nil && clock.Now().After(*o.ExpiresAt)
+}
@@ -3,15 +3,15 @@ package models
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/price"
-	"github.com/alpacahq/polycache/rest/client"
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 )
 
@@ -22,6 +22,7 @@ type Order struct {
 	UpdatedAt          time.Time           `json:"updated_at"`
 	DeletedAt          *time.Time          `json:"deleted_at"`
 	SubmittedAt        time.Time           `json:"submitted_at" gorm:"index"`
+	ReleaseAt          *time.Time          `json:"release_at"` // when a queued order is released to the order queue
 	FilledAt           *time.Time          `json:"filled_at"`
 	ExpiredAt          *time.Time          `json:"expired_at"`
 	CanceledAt         *time.Time          `json:"canceled_at"`
@@ -54,6 +55,21 @@ type Order struct {
 	TraderInitials     string              `fix:"116" json:"trader_initials" gorm:"type:text"`
 	Fee                *decimal.Decimal    `json:"fee" gorm:"type:decimal"` // Only for sell orders we expected to have fee.
 	IsCorrection       bool                `json:"-" gorm:"not null" sql:"default:'FALSE'"`
+	ExtendedHours      bool                `json:"extended_hours" gorm:"not null" sql:"default:'FALSE'"`
+	LotIDs             pq.Int64Array       `json:"lot_ids" gorm:"type:integer[]"` // specific lots to close for sells
+	Warnings           []string            `json:"-" gorm:"-"`                    // returned when the order is created, not stored
+
+	// the date of the corporate actions the order was canceled for,
+	// which its replacement is adjusted for once the cancel is confirmed
+	CorporateActionDate *date.Date `json:"-" sql:"type:date"`
+
+	// Algo orders, and the algo order a child order was sent for
+	ParentID         *string            `json:"parent_id" sql:"type:uuid;"`
+	Strategy         *enum.AlgoStrategy `json:"strategy" sql:"type:text"`
+	StartAt          *time.Time         `json:"start_at"`
+	EndAt            *time.Time         `json:"end_at"`
+	MaxParticipation *decimal.Decimal   `json:"max_participation" gorm:"type:decimal"`
+
 	// Relations
 	Executions    []Execution    `json:"-" gorm:"ForeignKey:OrderID"`
 	TradeFailures []TradeFailure `json:"-" gorm:"ForeignKey:OrderID"`
@@ -153,16 +169,47 @@ func (o *Order) Update(e *Execution) *Order {
 	return o
 }
 
-func ToLimit(o *Order, buyingPower decimal.Decimal) error {
-	if o.Type == enum.Limit || o.Type == enum.StopLimit || o.Side == enum.Sell {
-		return nil
+// RollUp sets an algo order's fills from its child orders, and marks
+// it filled once they've filled all of it.
+func (o *Order) RollUp(children []Order) *Order {
+	qty := decimal.Zero
+	notional := decimal.Zero
+
+	for _, c := range children {
+		if c.FilledQty == nil || c.FilledAvgPrice == nil {
+			continue
+		}
+
+		qty = qty.Add(*c.FilledQty)
+		notional = notional.Add(c.FilledQty.Mul(*c.FilledAvgPrice))
+
+		if c.FilledAt != nil && (o.FilledAt == nil || c.FilledAt.After(*o.FilledAt)) {
+			o.FilledAt = c.FilledAt
+		}
+	}
+
+	if qty.IsZero() {
+		return o
 	}
-	prices, err := client.GetTrades([]string{o.GetSymbol()})
-	if err != nil || prices == nil {
-		return fmt.Errorf("%v price not found", o.GetSymbol())
+
+	avg := notional.Div(qty)
+
+	o.FilledQty = &qty
+	o.FilledAvgPrice = &avg
+
+	if o.Status == enum.OrderWorking && qty.GreaterThanOrEqual(o.Qty) {
+		o.Status = enum.OrderFilled
 	}
 
-	px := decimal.NewFromFloat(prices[o.GetSymbol()].Price)
+	return o
+}
+
+// ToLimit converts market and stop buys to limit orders 5% above px,
+// the last trade.
+func ToLimit(o *Order, buyingPower, px decimal.Decimal) error {
+	if o.Type == enum.Limit || o.Type == enum.StopLimit || o.Side == enum.Sell {
+		return nil
+	}
 
 	limit, _ := price.FormatForOrder(px.Mul(decimal.NewFromFloat(1.05)))
 	if o.Qty.GreaterThan(buyingPower.Div(limit)) {
@@ -171,6 +171,55 @@ func (od *OwnerDetails) Replacement() (repl OwnerDetails) {
 	return
 }
 
+// Masked returns a copy of the details with the personally
+// identifiable fields obscured, for display to administrators.
+func (od *OwnerDetails) Masked() (m OwnerDetails) {
+	copier.Copy(&m, od)
+
+	mask := func(s *string, keep int) *string {
+		if s == nil {
+			return nil
+		}
+		masked := strings.Repeat("x", len(*s))
+		if keep > 0 && len(*s) > keep {
+			masked = masked[:len(*s)-keep] + (*s)[len(*s)-keep:]
+		}
+		return &masked
+	}
+
+	// names keep their initial so the owner can still be told apart
+	initial := func(s *string) *string {
+		if s == nil || *s == "" {
+			return s
+		}
+		r := []rune(*s)
+		masked := string(r[0]) + strings.Repeat("x", len(r)-1)
+		return &masked
+	}
+
+	m.LegalName = initial(od.LegalName)
+	m.GivenName = initial(od.GivenName)
+	m.FamilyName = initial(od.FamilyName)
+	m.DateOfBirth = mask(od.DateOfBirth, 0)
+	m.PhoneNumber = mask(od.PhoneNumber, 4)
+	m.Unit = mask(od.Unit, 0)
+	m.PostalCode = mask(od.PostalCode, 0)
+	m.VisaExpirationDate = mask(od.VisaExpirationDate, 0)
+
+	if od.StreetAddress != nil {
+		m.StreetAddress = make(address.Address, len(od.StreetAddress))
+		for i, line := range od.StreetAddress {
+			m.StreetAddress[i] = strings.Repeat("x", len(line))
+		}
+	}
+
+	if od.HashSSN != nil {
+		m.MaskedSSN = "xxx-xx-xxxx"
+	}
+
+	return
+}
+
 func (od *OwnerDetails) DataSources() []string {
 	srcs := []string{string(sources.IEX)}
 
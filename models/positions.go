@@ -1,8 +1,11 @@
 package models
 
 import (
+	"sort"
 	"time"
 
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/gofrs/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -41,9 +44,56 @@ type Position struct {
 	ExitTimestamp      *time.Time       `json:"exit_timestamp" gorm:"type:timestamp with time zone"`
 	GrossProfitLoss    *decimal.Decimal `json:"gross_profit_loss" gorm:"type:decimal"`
 	MarkedForSplitAt   *string          `json:"-" sql:"type:date"`
+	FundsSettleDate    *date.Date       `json:"-" sql:"type:date"` // set when bought with unsettled proceeds
 	Asset              Asset            `json:"-" gorm:"ForeignKey:AssetID"`
 }
 
+// LotID identifies the tax lot the position belongs to, which stays
+// the same when the position is split by partial sells.
+func (p *Position) LotID() uint {
+	if p.OriginalPositionID != nil {
+		return *p.OriginalPositionID
+	}
+	return p.ID
+}
+
+// SortLots orders open positions in the order they should be closed
+// by a sell. The specific lots named by the order come first, in the
+// order given, followed by the rest according to the method.
+func SortLots(positions []Position, method enum.LotMethod, lotIDs []int64) {
+	specific := map[uint]int{}
+	for i, id := range lotIDs {
+		specific[uint(id)] = i
+	}
+
+	sort.SliceStable(positions, func(i, j int) bool {
+		pi, pj := positions[i], positions[j]
+
+		si, iok := specific[pi.LotID()]
+		sj, jok := specific[pj.LotID()]
+
+		switch {
+		case iok && jok:
+			return si < sj
+		case iok != jok:
+			return iok
+		}
+
+		// split positions are recreated, so lots are aged by
+		// when they were bought rather than created_at
+		switch method {
+		case enum.LotLIFO:
+			return pi.EntryTimestamp.After(pj.EntryTimestamp)
+		case enum.LotHIFO:
+			if !pi.EntryPrice.Equal(pj.EntryPrice) {
+				return pi.EntryPrice.GreaterThan(pj.EntryPrice)
+			}
+		}
+
+		return pi.EntryTimestamp.Before(pj.EntryTimestamp)
+	})
+}
+
 // Day's profit loss snapshot
 type DayPLSnapshot struct {
 	ID         uint            `json:"id" gorm:"primary_key"`
@@ -61,3 +111,15 @@ func (s DayPLSnapshot) DateString() string {
 func (DayPLSnapshot) TableName() string {
 	return "day_pl_snapshots"
 }
+
+// EquitySnapshot is an account's equity (cash and the market value of its
+// positions) at a point in time during market hours.
+type EquitySnapshot struct {
+	AccountID string          `json:"account_id" gorm:"primary_key" sql:"type:uuid;"`
+	Time      time.Time       `json:"time" gorm:"primary_key"`
+	Equity    decimal.Decimal `json:"equity" gorm:"not null;type:decimal"`
+}
+
+func (EquitySnapshot) TableName() string {
+	return "equity_snapshots"
+}
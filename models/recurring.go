@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// RecurringTransfer is a schedule of deposits from an ACH
+// relationship. Each run creates a regular transfer, on the
+// first business day on or after the scheduled date.
+type RecurringTransfer struct {
+	ID             string                       `json:"id" gorm:"primary_key" sql:"type:uuid;"`
+	CreatedAt      time.Time                    `json:"created_at"`
+	UpdatedAt      time.Time                    `json:"updated_at"`
+	DeletedAt      *time.Time                   `json:"-"`
+	AccountID      string                       `json:"account_id" gorm:"not null;index" sql:"type:uuid;"`
+	RelationshipID string                       `json:"relationship_id" gorm:"not null" sql:"type:uuid;"`
+	Amount         decimal.Decimal              `json:"amount" gorm:"type:decimal;not null"`
+	Frequency      enum.RecurringFrequency      `json:"frequency" gorm:"type:varchar(16);not null"`
+	Status         enum.RecurringTransferStatus `json:"status" gorm:"type:varchar(16);not null"`
+	StartDate      date.Date                    `json:"start_date" gorm:"not null" sql:"type:date"`
+	Runs           int                          `json:"runs" gorm:"not null"`
+	NextRunDate    date.Date                    `json:"next_run_date" gorm:"not null;index" sql:"type:date"`
+	LastRunAt      *time.Time                   `json:"last_run_at"`
+	Reason         string                       `json:"reason" sql:"type:text"`
+}
+
+func (rt *RecurringTransfer) BeforeCreate(scope *gorm.Scope) error {
+	if rt.ID == "" {
+		rt.ID = uuid.Must(uuid.NewV4()).String()
+	}
+	return scope.SetColumn("id", rt.ID)
+}
+
+func (rt *RecurringTransfer) AccountIDAsUUID() uuid.UUID {
+	id, _ := uuid.FromString(rt.AccountID)
+	return id
+}
+
+// ScheduledDate returns the nominal date of the nth run, which
+// may fall on a weekend or holiday. Monthly schedules starting
+// late in the month run on the last day of shorter months.
+func (rt *RecurringTransfer) ScheduledDate(n int) date.Date {
+	start := rt.StartDate.Date
+
+	switch rt.Frequency {
+	case enum.Weekly:
+		return date.Date{Date: start.AddDays(7 * n)}
+	case enum.Biweekly:
+		return date.Date{Date: start.AddDays(14 * n)}
+	default:
+		first := time.Date(start.Year, start.Month+time.Month(n), 1, 0, 0, 0, 0, calendar.NY)
+		last := first.AddDate(0, 1, -1).Day()
+
+		day := start.Day
+		if day > last {
+			day = last
+		}
+
+		return date.DateOf(first.AddDate(0, 0, day-1))
+	}
+}
+
+// RunDate returns the business day the nth run executes on.
+func (rt *RecurringTransfer) RunDate(n int) date.Date {
+	t := rt.ScheduledDate(n).In(calendar.NY)
+
+	// the trading date before midnight of d, then the next
+	// one is the first trading date on or after d
+	next := tradingdate.Last(t).Next().MarketOpen()
+
+	return date.DateOf(next)
+}
+
+// Advance moves the schedule to the first run which executes after
+// asof, so missed runs are skipped rather than caught up on.
+func (rt *RecurringTransfer) Advance(asof date.Date) {
+	for !rt.NextRunDate.After(asof.Date) {
+		rt.Runs++
+		rt.NextRunDate = rt.RunDate(rt.Runs)
+	}
+}
@@ -34,6 +34,8 @@ type ACHRelationship struct {
 	Reason             string                  `json:"reason" sql:"type:text"`
 	MicroDepositID     string                  `json:"micro_deposit_id" sql:"type:text"`
 	MicroDepositStatus enum.TransferStatus     `json:"micro_deposit_status" sql:"type:text"`
+	// set when the account owner unlinks the bank
+	UnlinkedAt *time.Time `json:"-"`
 }
 
 type BankInfo struct {
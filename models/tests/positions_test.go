@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PositionsSuite struct {
+	suite.Suite
+}
+
+func TestPositionsSuite(t *testing.T) {
+	suite.Run(t, new(PositionsSuite))
+}
+
+func (s *PositionsSuite) lots() []models.Position {
+	t := time.Date(2018, 1, 25, 10, 0, 0, 0, time.UTC)
+	split := uint(1)
+
+	return []models.Position{
+		{ID: 1, EntryPrice: decimal.NewFromFloat(100), EntryTimestamp: t},
+		{ID: 2, EntryPrice: decimal.NewFromFloat(120), EntryTimestamp: t.Add(time.Hour)},
+		{ID: 3, EntryPrice: decimal.NewFromFloat(90), EntryTimestamp: t.Add(2 * time.Hour)},
+		// remainder of lot 1 after a partial sell
+		{ID: 4, OriginalPositionID: &split, EntryPrice: decimal.NewFromFloat(100), EntryTimestamp: t},
+	}
+}
+
+func ids(positions []models.Position) []uint {
+	out := make([]uint, len(positions))
+	for i := range positions {
+		out[i] = positions[i].ID
+	}
+	return out
+}
+
+func (s *PositionsSuite) TestSortLots() {
+	{
+		p := s.lots()
+		models.SortLots(p, enum.LotFIFO, nil)
+		assert.Equal(s.T(), []uint{1, 4, 2, 3}, ids(p))
+	}
+
+	{
+		p := s.lots()
+		models.SortLots(p, enum.LotLIFO, nil)
+		assert.Equal(s.T(), []uint{3, 2, 1, 4}, ids(p))
+	}
+
+	{
+		p := s.lots()
+		models.SortLots(p, enum.LotHIFO, nil)
+		assert.Equal(s.T(), []uint{2, 1, 4, 3}, ids(p))
+	}
+
+	// specific lots first, then the method for the rest
+	{
+		p := s.lots()
+		models.SortLots(p, enum.LotFIFO, []int64{3, 1})
+		assert.Equal(s.T(), []uint{3, 1, 4, 2}, ids(p))
+	}
+
+	// accounts without a method use FIFO
+	{
+		p := s.lots()
+		models.SortLots(p, "", nil)
+		assert.Equal(s.T(), []uint{1, 4, 2, 3}, ids(p))
+	}
+}
@@ -15,11 +15,16 @@ type TradeAccount struct {
 	ApexAccount              *string
 	LegalName                string
 	Status                   enum.AccountStatus
+	Plan                     enum.AccountPlan
 	Currency                 string
 	ApexApprovalStatus       enum.ApexApprovalStatus
 	ProtectPatternDayTrader  bool
 	PatternDayTrader         bool
 	MarkedPatternDayTraderAt *date.Date
+	ProtectGoodFaith         bool
+	Margin                   bool
+	LotMethod                enum.LotMethod
+	SettledCashOnlyUntil     *date.Date
 	TradingBlocked           bool
 	TransfersBlocked         bool
 	AccountBlocked           bool
@@ -33,6 +38,18 @@ func (a *TradeAccount) IDAsUUID() uuid.UUID {
 	return uuid.FromStringOrNil(a.ID)
 }
 
+// SettledCashOnly returns true if the account is restricted to buying
+// with settled cash on the given date, following good faith violations.
+func (a *TradeAccount) SettledCashOnly(on date.Date) bool {
+	return a.SettledCashOnlyUntil != nil && a.SettledCashOnlyUntil.After(on.Date)
+}
+
+// CashAccount returns true unless the account trades on margin. Good
+// faith violations only apply to cash accounts.
+func (a *TradeAccount) CashAccount() bool {
+	return !a.Margin
+}
+
 func (a *TradeAccount) Tradable() bool {
 	// should be same as Account.Tradable()
 	return (a.ApexAccount != nil &&
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/alpacahq/apex"
@@ -28,6 +29,10 @@ type Transfer struct {
 	BatchProcessedAt            *string                `json:"batch_processed_at" sql:"type:date"`
 	ExpiresAt                   *time.Time             `json:"expires_at"`
 	Relationship                *ACHRelationship       `json:"-" gorm:"ForeignKey:RelationshipID"`
+	BeneficiaryID               *string                `json:"beneficiary_id" sql:"type:uuid;"`
+	Beneficiary                 *WireBeneficiary       `json:"-" gorm:"ForeignKey:BeneficiaryID"`
+	RecurringTransferID         *string                `json:"recurring_transfer_id" sql:"type:uuid;"`
+	HoldReasons                 json.RawMessage        `json:"hold_reasons,omitempty" sql:"type:json"`
 	Reason                      string                 `json:"reason" sql:"type:text"`
 	ReasonCode                  string                 `json:"reason_code" sql:"type:text"`
 }
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// Watchlist is a named list of assets an account follows, in the
+// order they were added.
+type Watchlist struct {
+	ID        string         `json:"id" gorm:"primary_key" sql:"type:uuid;"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	AccountID string         `json:"account_id" gorm:"not null;unique_index:idx_watchlist_account_name" sql:"type:uuid;"`
+	Name      string         `json:"name" gorm:"not null;unique_index:idx_watchlist_account_name" sql:"type:text"`
+	AssetIDs  pq.StringArray `json:"-" gorm:"type:uuid[]"`
+}
+
+func (w *Watchlist) BeforeCreate(scope *gorm.Scope) error {
+	if w.ID == "" {
+		w.ID = uuid.Must(uuid.NewV4()).String()
+	}
+	return scope.SetColumn("id", w.ID)
+}
+
+// Has returns true if the asset is on the watchlist.
+func (w *Watchlist) Has(assetID string) bool {
+	for _, id := range w.AssetIDs {
+		if id == assetID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gopaca/encryption"
+	"github.com/alpacahq/gopaca/env"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// WireBeneficiary is a bank account that outbound wires
+// can be sent to. The bank details are stored encrypted,
+// the same as for ACH relationships.
+type WireBeneficiary struct {
+	ID           string                 `json:"id" gorm:"primary_key" sql:"type:uuid;"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	DeletedAt    *time.Time             `json:"-"`
+	AccountID    string                 `json:"account_id" gorm:"not null;index" sql:"type:uuid;"`
+	Status       enum.BeneficiaryStatus `json:"status" gorm:"type:text;not null"`
+	Nickname     *string                `json:"nickname" sql:"type:text"`
+	BankName     string                 `json:"bank_name" sql:"type:text;not null"`
+	Mask         string                 `json:"mask" gorm:"type:varchar(4)"`
+	HashBankInfo []byte                 `json:"-" gorm:"type:bytea"`
+}
+
+type WireBankInfo struct {
+	BeneficiaryName string `json:"beneficiary_name"`
+	AccountNumber   string `json:"account_number"`
+	// ABA routing number for domestic wires
+	RoutingNumber string `json:"routing_number,omitempty"`
+	// SWIFT/BIC code for international wires
+	SwiftCode     string `json:"swift_code,omitempty"`
+	BankAddress   string `json:"bank_address"`
+	FurtherCredit string `json:"further_credit,omitempty"`
+}
+
+func (wb *WireBeneficiary) BeforeCreate(scope *gorm.Scope) error {
+	if wb.ID == "" {
+		wb.ID = uuid.Must(uuid.NewV4()).String()
+	}
+	return scope.SetColumn("id", wb.ID)
+}
+
+func (wb *WireBeneficiary) GetBankInfo() (*WireBankInfo, error) {
+	buf, err := encryption.DecryptWithkey(wb.HashBankInfo, []byte(env.GetVar("BROKER_SECRET")))
+	if err != nil {
+		return nil, err
+	}
+
+	bankInfo := &WireBankInfo{}
+
+	if err = json.Unmarshal(buf, &bankInfo); err != nil {
+		return nil, err
+	}
+
+	return bankInfo, nil
+}
+
+func (wb *WireBeneficiary) SetBankInfo(info WireBankInfo) error {
+	buf, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	wb.HashBankInfo, err = encryption.EncryptWithKey(
+		buf, []byte(env.GetVar("BROKER_SECRET")))
+
+	if err != nil {
+		return err
+	}
+
+	if l := len(info.AccountNumber); l > 4 {
+		wb.Mask = info.AccountNumber[l-4:]
+	} else {
+		wb.Mask = info.AccountNumber
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/service/registry"
 	"github.com/alpacahq/gopaca/log"
+	"github.com/gofrs/uuid"
 	"github.com/kataras/iris"
 )
 
@@ -37,6 +38,16 @@ func (api *API) acquire(original iris.Context) Context {
 	ctx.txClosed.Store(true)
 	ctx.Context = original
 	ctx.services = api.services
+
+	// tag each request so that audit records and logs
+	// can be traced back to it
+	requestID := original.GetHeader("X-Request-ID")
+	if requestID == "" {
+		requestID = uuid.Must(uuid.NewV4()).String()
+	}
+	original.Values().Set("request_id", requestID)
+	original.Header("X-Request-ID", requestID)
+
 	return ctx
 }
 
@@ -5,10 +5,13 @@ import (
 	"github.com/alpacahq/gobroker/rest/api/controller/accesskey"
 	"github.com/alpacahq/gobroker/rest/api/controller/account"
 	"github.com/alpacahq/gobroker/rest/api/controller/account/configurations"
+	"github.com/alpacahq/gobroker/rest/api/controller/admin"
 	"github.com/alpacahq/gobroker/rest/api/controller/affiliate"
 	"github.com/alpacahq/gobroker/rest/api/controller/agreements"
+	"github.com/alpacahq/gobroker/rest/api/controller/alert"
 	"github.com/alpacahq/gobroker/rest/api/controller/asset"
 	"github.com/alpacahq/gobroker/rest/api/controller/bar"
+	"github.com/alpacahq/gobroker/rest/api/controller/beneficiary"
 	"github.com/alpacahq/gobroker/rest/api/controller/calendar"
 	"github.com/alpacahq/gobroker/rest/api/controller/clock"
 	"github.com/alpacahq/gobroker/rest/api/controller/corporateaction"
@@ -16,22 +19,29 @@ import (
 	"github.com/alpacahq/gobroker/rest/api/controller/documents"
 	"github.com/alpacahq/gobroker/rest/api/controller/email"
 	"github.com/alpacahq/gobroker/rest/api/controller/fundamental"
+	"github.com/alpacahq/gobroker/rest/api/controller/gainloss"
+	"github.com/alpacahq/gobroker/rest/api/controller/goodfaith"
 	"github.com/alpacahq/gobroker/rest/api/controller/institution"
 	intraAsset "github.com/alpacahq/gobroker/rest/api/controller/intra/asset"
+	"github.com/alpacahq/gobroker/rest/api/controller/ledger"
 	"github.com/alpacahq/gobroker/rest/api/controller/order"
 	"github.com/alpacahq/gobroker/rest/api/controller/owner"
 	"github.com/alpacahq/gobroker/rest/api/controller/ownerdetails"
 	paperAccessKeys "github.com/alpacahq/gobroker/rest/api/controller/paper/accesskey"
 	paperAccount "github.com/alpacahq/gobroker/rest/api/controller/paper/account"
 	"github.com/alpacahq/gobroker/rest/api/controller/paper/proxy"
+	"github.com/alpacahq/gobroker/rest/api/controller/performance"
 	"github.com/alpacahq/gobroker/rest/api/controller/polygon"
 	"github.com/alpacahq/gobroker/rest/api/controller/portfolio/history"
 	"github.com/alpacahq/gobroker/rest/api/controller/position"
 	"github.com/alpacahq/gobroker/rest/api/controller/profitloss"
 	"github.com/alpacahq/gobroker/rest/api/controller/quote"
 	"github.com/alpacahq/gobroker/rest/api/controller/relationship"
+	"github.com/alpacahq/gobroker/rest/api/controller/snapshot"
+	"github.com/alpacahq/gobroker/rest/api/controller/trade"
 	"github.com/alpacahq/gobroker/rest/api/controller/transfer"
 	"github.com/alpacahq/gobroker/rest/api/controller/trustedcontact"
+	"github.com/alpacahq/gobroker/rest/api/controller/watchlist"
 	"github.com/alpacahq/gobroker/rest/api/middleware/httplogger"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/iris-contrib/middleware/cors"
@@ -95,9 +105,26 @@ func Internal(api *api.API, r iris.Party) {
 	// trade account & portfolio info
 	r.Get("/accounts/{account_id}/trade_account", api.AuthenticateWithAll(account.GetForTrading))
 	r.Get("/accounts/{account_id}/profitloss", api.AuthenticateWithAll(profitloss.Get))
+	r.Get("/accounts/{account_id}/performance", api.AuthenticateWithAll(performance.Get))
+	r.Get("/accounts/{account_id}/allocation", api.AuthenticateWithAll(fundamental.GetSectorAllocation))
 	r.Get("/accounts/{account_id}/portfolio/history", api.AuthenticateWithAll(history.Get))
 	r.Patch("/accounts/{account_id}/configurations", api.AuthenticateWithAll(configurations.Patch))
 
+	// watchlists
+	r.Get("/accounts/{account_id}/watchlists", api.AuthenticateWithAll(watchlist.List))
+	r.Post("/accounts/{account_id}/watchlists", api.AuthenticateWithAll(watchlist.Create, utils.StandBy()))
+	r.Get("/accounts/{account_id}/watchlists/{watchlist_id}", api.AuthenticateWithAll(watchlist.Get))
+	r.Put("/accounts/{account_id}/watchlists/{watchlist_id}", api.AuthenticateWithAll(watchlist.Update, utils.StandBy()))
+	r.Post("/accounts/{account_id}/watchlists/{watchlist_id}", api.AuthenticateWithAll(watchlist.AddAsset, utils.StandBy()))
+	r.Delete("/accounts/{account_id}/watchlists/{watchlist_id}", api.AuthenticateWithAll(watchlist.Delete, utils.StandBy()))
+	r.Delete("/accounts/{account_id}/watchlists/{watchlist_id}/{symbol}", api.AuthenticateWithAll(watchlist.RemoveAsset, utils.StandBy()))
+
+	// alerts
+	r.Get("/accounts/{account_id}/alerts", api.AuthenticateWithAll(alert.List))
+	r.Post("/accounts/{account_id}/alerts", api.AuthenticateWithAll(alert.Create, utils.StandBy()))
+	r.Get("/accounts/{account_id}/alerts/{alert_id}", api.AuthenticateWithAll(alert.Get))
+	r.Delete("/accounts/{account_id}/alerts/{alert_id}", api.AuthenticateWithAll(alert.Delete, utils.StandBy()))
+
 	// owner details
 	r.Get("/accounts/{account_id}/details", api.AuthenticateWithAll(ownerdetails.Get))
 	r.Patch("/accounts/{account_id}/details", api.AuthenticateWithAll(ownerdetails.Patch, utils.StandBy()))
@@ -130,12 +157,41 @@ func Internal(api *api.API, r iris.Party) {
 	r.Post("/accounts/{account_id}/transfers", api.AuthenticateWithAll(transfer.Create, utils.StandBy()))
 	r.Delete("/accounts/{account_id}/transfers/{transfer_id}", api.AuthenticateWithAll(transfer.Delete, utils.StandBy()))
 
+	// wire beneficiaries
+	r.Get("/accounts/{account_id}/wire_beneficiaries", api.AuthenticateWithAll(beneficiary.List))
+	r.Post("/accounts/{account_id}/wire_beneficiaries", api.AuthenticateWithAll(beneficiary.Create, utils.StandBy()))
+	r.Delete("/accounts/{account_id}/wire_beneficiaries/{beneficiary_id}", api.AuthenticateWithAll(beneficiary.Delete, utils.StandBy()))
+
+	// wire transfers
+	r.Post("/accounts/{account_id}/transfers/wire", api.AuthenticateWithAll(transfer.CreateWire, utils.StandBy()))
+
+	// recurring transfers
+	r.Get("/accounts/{account_id}/transfers/recurring", api.AuthenticateWithAll(transfer.ListRecurring))
+	r.Post("/accounts/{account_id}/transfers/recurring", api.AuthenticateWithAll(transfer.CreateRecurring, utils.StandBy()))
+	r.Post("/accounts/{account_id}/transfers/recurring/{recurring_transfer_id}/pause", api.AuthenticateWithAll(transfer.PauseRecurring, utils.StandBy()))
+	r.Post("/accounts/{account_id}/transfers/recurring/{recurring_transfer_id}/resume", api.AuthenticateWithAll(transfer.ResumeRecurring, utils.StandBy()))
+	r.Delete("/accounts/{account_id}/transfers/recurring/{recurring_transfer_id}", api.AuthenticateWithAll(transfer.DeleteRecurring, utils.StandBy()))
+
+	// cash ledger
+	r.Get("/accounts/{account_id}/cash", api.AuthenticateWithAll(ledger.GetCash))
+	r.Get("/accounts/{account_id}/cash/journals", api.AuthenticateWithAll(ledger.ListJournals))
+
+	// good faith violations
+	r.Get("/accounts/{account_id}/good_faith", api.AuthenticateWithAll(goodfaith.Get))
+
+	// realized gains
+	r.Get("/accounts/{account_id}/realized_gains", api.AuthenticateWithAll(gainloss.Get))
+	r.Get("/accounts/{account_id}/realized_gains/1099b", api.AuthenticateWithAll(gainloss.Get1099B))
+
 	// positions
 	r.Get("/accounts/{account_id}/positions", api.AuthenticateWithAll(position.List))
 	r.Get("/accounts/{account_id}/positions/{symbol}", api.AuthenticateWithAll(position.Get))
+	r.Get("/accounts/{account_id}/positions/{symbol}/lots", api.AuthenticateWithAll(position.ListLots))
 	r.Get("/accounts/{account_id}/orders", api.AuthenticateWithAll(order.List))
 	r.Get("/accounts/{account_id}/orders/{order_id}", api.AuthenticateWithAll(order.Get))
 	r.Post("/accounts/{account_id}/orders", api.AuthenticateWithAll(order.Create, utils.StandBy()))
+	r.Post("/accounts/{account_id}/orders:preview", api.AuthenticateWithAll(order.Preview))
+	r.Patch("/accounts/{account_id}/orders/{order_id}", api.AuthenticateWithAll(order.Replace, utils.StandBy()))
 	r.Delete("/accounts/{account_id}/orders/{order_id}", api.AuthenticateWithAll(order.Delete, utils.StandBy()))
 
 	// api keys
@@ -205,24 +261,45 @@ func Trade(api APIHandler, r iris.Party) {
 	// account
 	r.Get("/account", api.Authenticate(account.GetForTrading))
 	r.Patch("/account/configurations", api.Authenticate(configurations.Patch))
+	r.Get("/account/performance", api.Authenticate(performance.Get))
+	r.Get("/account/allocation", api.Authenticate(fundamental.GetSectorAllocation))
 
 	// positions
 	r.Get("/positions", api.Authenticate(position.List))
 	r.Get("/positions/{symbol}", api.Authenticate(position.Get))
+	r.Get("/positions/{symbol}/lots", api.Authenticate(position.ListLots))
 
 	// orders
 	r.Get("/orders", api.Authenticate(order.List))
 	r.Get("/orders/{order_id}", api.Authenticate(order.Get))
 	r.Get("/orders:by_client_order_id", api.Authenticate(order.GetByClientOrderID))
 	r.Post("/orders", api.Authenticate(order.Create, utils.StandBy()))
+	r.Post("/orders:preview", api.Authenticate(order.Preview))
+	r.Patch("/orders/{order_id}", api.Authenticate(order.Replace, utils.StandBy()))
 	r.Delete("/orders/{order_id}", api.Authenticate(order.Delete, utils.StandBy()))
 
+	// watchlists
+	r.Get("/watchlists", api.Authenticate(watchlist.List))
+	r.Post("/watchlists", api.Authenticate(watchlist.Create, utils.StandBy()))
+	r.Get("/watchlists/{watchlist_id}", api.Authenticate(watchlist.Get))
+	r.Put("/watchlists/{watchlist_id}", api.Authenticate(watchlist.Update, utils.StandBy()))
+	r.Post("/watchlists/{watchlist_id}", api.Authenticate(watchlist.AddAsset, utils.StandBy()))
+	r.Delete("/watchlists/{watchlist_id}", api.Authenticate(watchlist.Delete, utils.StandBy()))
+	r.Delete("/watchlists/{watchlist_id}/{symbol}", api.Authenticate(watchlist.RemoveAsset, utils.StandBy()))
+
+	// alerts
+	r.Get("/alerts", api.Authenticate(alert.List))
+	r.Post("/alerts", api.Authenticate(alert.Create, utils.StandBy()))
+	r.Get("/alerts/{alert_id}", api.Authenticate(alert.Get))
+	r.Delete("/alerts/{alert_id}", api.Authenticate(alert.Delete, utils.StandBy()))
+
 	// assets
 	r.Get("/assets", api.Authenticate(asset.List))
 	r.Get("/assets/{symbol}", api.Authenticate(asset.Get))
 
 	// fundamentals
 	r.Get("/fundamentals", api.Authenticate(fundamental.List))
+	r.Get("/screener", api.Authenticate(fundamental.Screen))
 	r.Get("/assets/{symbol}/fundamental", api.Authenticate(fundamental.Get))
 
 	// quotes
@@ -233,6 +310,13 @@ func Trade(api APIHandler, r iris.Party) {
 	r.Get("/bars", api.Authenticate(bar.List))
 	r.Get("/assets/{symbol}/bars", api.Authenticate(bar.Get))
 
+	// trades
+	r.Get("/trades/latest", api.Authenticate(trade.ListLatest))
+	r.Get("/assets/{symbol}/trades/latest", api.Authenticate(trade.Latest))
+
+	// snapshots
+	r.Get("/snapshots", api.Authenticate(snapshot.List))
+
 	// market clock & calendar
 	r.Get("/clock", api.Authenticate(clock.Get))
 	r.Get("/calendar", api.Authenticate(calendar.Get))
@@ -253,3 +337,38 @@ func Polygon(api *api.API, r iris.Party) {
 	r.Post("/auth", api.AuthenticatePolygon(polygon.Auth))
 	r.Post("/keys", api.AuthenticatePolygon(polygon.List))
 }
+
+// Admin binds the administrative API handlers to their
+// respective endpoints
+func Admin(api *api.API, r iris.Party) {
+	// ---------------------------------
+	// 	  Admin API
+	// ---------------------------------
+	r.Use(httplogger.New())
+
+	// accounts
+	r.Get("/admins/{admin_id}/accounts", api.AuthenticateAdmin(admin.ListAccounts))
+	r.Get("/admins/{admin_id}/accounts/{account_id}", api.AuthenticateAdmin(admin.GetAccount))
+	r.Patch("/admins/{admin_id}/accounts/{account_id}", api.AuthenticateAdmin(admin.PatchAccount))
+	r.Get("/admins/{admin_id}/accounts/{account_id}/details", api.AuthenticateAdmin(admin.GetDetails))
+	r.Get("/admins/{admin_id}/accounts/{account_id}/actions", api.AuthenticateAdmin(admin.ListActions))
+
+	// notes
+	r.Get("/admins/{admin_id}/accounts/{account_id}/notes", api.AuthenticateAdmin(admin.ListNotes))
+	r.Post("/admins/{admin_id}/accounts/{account_id}/notes", api.AuthenticateAdmin(admin.CreateNote))
+
+	// transfers & orders
+	r.Post("/admins/{admin_id}/accounts/{account_id}/transfers/{transfer_id}/approve", api.AuthenticateAdmin(admin.ApproveTransfer))
+	r.Get("/admins/{admin_id}/accounts/{account_id}/transfers/{transfer_id}/wire", api.AuthenticateAdmin(admin.GetWireInstructions))
+	r.Get("/admins/{admin_id}/accounts/{account_id}/transfer_limits", api.AuthenticateAdmin(admin.GetTransferLimits))
+	r.Put("/admins/{admin_id}/accounts/{account_id}/transfer_limits", api.AuthenticateAdmin(admin.SetTransferLimits))
+	r.Delete("/admins/{admin_id}/accounts/{account_id}/transfer_limits/{direction}", api.AuthenticateAdmin(admin.ClearTransferLimits))
+	r.Delete("/admins/{admin_id}/accounts/{account_id}/orders/{order_id}", api.AuthenticateAdmin(admin.CancelOrder))
+
+	// audit logs
+	r.Get("/admins/{admin_id}/audit_logs", api.AuthenticateAdmin(admin.ListAuditLogs))
+	r.Get("/admins/{admin_id}/accounts/{account_id}/audit_logs", api.AuthenticateAdmin(admin.ListAuditLogs))
+
+	// SoD batch errors
+	r.Get("/admins/{admin_id}/batch_errors", api.AuthenticateAdmin(admin.ListBatchErrors))
+}
@@ -6,6 +6,8 @@ import (
 	"sync/atomic"
 
 	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/service/audit"
 	"github.com/alpacahq/gobroker/service/registry"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/alpacahq/gopaca/db"
@@ -34,6 +36,7 @@ const (
 	MIMETextPlain                     = "text/plain"
 	MIMETextPlainCharsetUTF8          = MIMETextPlain + "; " + charsetUTF8
 	MIMEApplicationPDF                = "application/pdf"
+	MIMETextCSV                       = "text/csv"
 )
 
 type Permission string
@@ -152,12 +155,40 @@ func (ctx *context) Tx() *gorm.DB {
 			ctx.tx = nil
 			log.Panic("unrecoverable BEGIN failure", "error", err)
 		}
+		ctx.tx = audit.WithActor(ctx.tx, ctx.actor())
 		ctx.txClosed.Store(false)
 	}
 
 	return ctx.tx
 }
 
+// actor identifies the caller for audit records written
+// through the request's transaction
+func (ctx *context) actor() audit.Actor {
+	actor := audit.Actor{
+		Type:      models.ActorAnonymous,
+		RequestID: ctx.Values().GetString("request_id"),
+	}
+
+	if ctx.session == nil {
+		return actor
+	}
+
+	id := ctx.session.ID
+	actor.ID = &id
+
+	switch ctx.session.Permission {
+	case PermissionAll:
+		actor.Type = models.ActorCognito
+	case PermissionTrading:
+		actor.Type = models.ActorAccessKey
+	case PermissionAdmin:
+		actor.Type = models.ActorAdmin
+	}
+
+	return actor
+}
+
 func (ctx *context) RepeatableTx() *gorm.DB {
 	return ctx.Tx().Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ")
 }
@@ -115,7 +115,7 @@ func Create(ctx api.Context) {
 	}
 
 	// create the default paper account as well
-	paperSvc := paper.Service().WithTx(ctx.Tx())
+	paperSvc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	// $100k by default
 	if _, err = paperSvc.Create(acc.IDAsUUID(), decimal.New(100000, 0)); err != nil {
@@ -0,0 +1,420 @@
+package admin
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/admin"
+	"github.com/alpacahq/gobroker/service/audit"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/gofrs/uuid"
+	"github.com/kataras/iris"
+)
+
+// NoteRequest is the body for creating an admin note
+type NoteRequest struct {
+	Body string `json:"body"`
+}
+
+func service(ctx api.Context) admin.AdminService {
+	return admin.Service(ctx.Services().Order()).WithTx(ctx.Tx())
+}
+
+func ListAccounts(ctx api.Context) {
+	query := account.AccountQuery{
+		Page: ctx.URLParamIntDefault("page", 1),
+		Per:  ctx.URLParamIntDefault("per", 50),
+	}
+
+	if query.Page < 1 || query.Per < 1 || query.Per > 500 {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("invalid pagination"))
+		return
+	}
+
+	if q := ctx.URLParam("email"); q != "" {
+		query.Email = &q
+	}
+
+	if q := ctx.URLParam("apex_account"); q != "" {
+		query.ApexAccount = &q
+	}
+
+	if q := ctx.URLParam("account_id"); q != "" {
+		id, err := uuid.FromString(q)
+		if err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("invalid account_id"))
+			return
+		}
+		query.AccountID = &id
+	}
+
+	if q := ctx.URLParam("status"); q != "" {
+		for _, status := range strings.Split(q, ",") {
+			query.AccountStatus = append(query.AccountStatus, enum.AccountStatus(status))
+		}
+	}
+
+	accounts, meta, err := service(ctx).ListAccounts(query)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Header("X-Total-Count", strconv.FormatInt(meta.TotalCount, 10))
+
+	resp := make([]interface{}, len(accounts))
+	for i := range accounts {
+		resp[i] = accounts[i].ForJSON()
+	}
+
+	ctx.Respond(resp)
+}
+
+func GetAccount(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	accounts, _, err := service(ctx).ListAccounts(account.AccountQuery{
+		AccountID: &accountID,
+		Page:      1,
+		Per:       1,
+	})
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	if len(accounts) == 0 {
+		ctx.RespondError(gberrors.NotFound.WithMsg("account not found"))
+		return
+	}
+
+	ctx.Respond(accounts[0].ForJSON())
+}
+
+func PatchAccount(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	flags := admin.AccountFlags{}
+	if err := ctx.Read(&flags); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	acct, err := service(ctx).SetFlags(adminID, accountID, flags)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(acct.ForJSON())
+}
+
+func GetDetails(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	unmask, _ := ctx.URLParamBool("unmask")
+
+	details, err := service(ctx).GetOwnerDetails(adminID, accountID, unmask)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(details)
+}
+
+func ListNotes(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	notes, err := service(ctx).ListNotes(accountID)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(notes)
+}
+
+func CreateNote(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	nReq := NoteRequest{}
+	if err := ctx.Read(&nReq); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	note, err := service(ctx).CreateNote(adminID, accountID, nReq.Body)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(note)
+}
+
+func ListActions(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	actions, err := service(ctx).ListActions(accountID)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(actions)
+}
+
+func ListAuditLogs(ctx api.Context) {
+	query := audit.AuditQuery{
+		Limit: ctx.URLParamIntDefault("limit", 100),
+	}
+
+	// the account can be scoped either by path or query
+	id := ctx.Params().Get("account_id")
+	if id == "" {
+		id = ctx.URLParam("account_id")
+	}
+
+	if id != "" {
+		accountID, err := uuid.FromString(id)
+		if err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("invalid account_id"))
+			return
+		}
+		query.AccountID = &accountID
+	}
+
+	if q := ctx.URLParam("actor_id"); q != "" {
+		actorID, err := uuid.FromString(q)
+		if err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("invalid actor_id"))
+			return
+		}
+		query.ActorID = &actorID
+	}
+
+	if q := ctx.URLParam("resource"); q != "" {
+		resource := models.AuditResource(q)
+		query.Resource = &resource
+	}
+
+	if q := ctx.URLParam("resource_id"); q != "" {
+		query.ResourceID = &q
+	}
+
+	if q := ctx.URLParam("request_id"); q != "" {
+		query.RequestID = &q
+	}
+
+	if q := ctx.URLParam("after"); q != "" {
+		t, err := parameter.ParseTimestamp(q, "after")
+		if err != nil {
+			ctx.RespondError(err)
+			return
+		}
+		query.After = t
+	}
+
+	if q := ctx.URLParam("until"); q != "" {
+		t, err := parameter.ParseTimestamp(q, "until")
+		if err != nil {
+			ctx.RespondError(err)
+			return
+		}
+		query.Until = t
+	}
+
+	if query.Limit < 1 || query.Limit > 500 {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("limit must be between 1 and 500"))
+		return
+	}
+
+	logs, err := audit.Service().WithTx(ctx.Tx()).List(query)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(logs)
+}
+
+func ApproveTransfer(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	transferID := ctx.Params().Get("transfer_id")
+	if transferID == "" {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("transfer_id is required"))
+		return
+	}
+
+	xfer, err := service(ctx).ApproveTransfer(adminID, accountID, transferID)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(xfer)
+}
+
+func GetWireInstructions(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	transferID := ctx.Params().Get("transfer_id")
+	if transferID == "" {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("transfer_id is required"))
+		return
+	}
+
+	instructions, err := service(ctx).GetWireInstructions(adminID, accountID, transferID)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(instructions)
+}
+
+func GetTransferLimits(ctx api.Context) {
+	_, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	if limits, err := service(ctx).GetTransferLimits(accountID); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(limits)
+	}
+}
+
+func SetTransferLimits(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	override := models.TransferLimitOverride{}
+	if err := ctx.Read(&override); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	if o, err := service(ctx).SetTransferLimits(adminID, accountID, override); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(o)
+	}
+}
+
+func ClearTransferLimits(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	dir := apex.TransferDirection(ctx.Params().Get("direction"))
+
+	if err := service(ctx).ClearTransferLimits(adminID, accountID, dir); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.RespondWithStatus(nil, iris.StatusNoContent)
+	}
+}
+
+func CancelOrder(ctx api.Context) {
+	adminID, accountID, err := params(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	orderID, err := uuid.FromString(ctx.Params().Get("order_id"))
+	if err != nil {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("order_id is missing"))
+		return
+	}
+
+	if err := service(ctx).CancelOrder(adminID, accountID, orderID); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.RespondWithStatus(nil, iris.StatusNoContent)
+	}
+}
+
+func ListBatchErrors(ctx api.Context) {
+	// default to the most recently processed batch, which is
+	// the trading day prior to today
+	now := clock.Now().In(calendar.NY)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, calendar.NY)
+
+	processDate := ctx.URLParamDefault("date", tradingdate.Last(today).String())
+
+	var fileCode *string
+	if q := ctx.URLParam("file_code"); q != "" {
+		fileCode = &q
+	}
+
+	errs, err := service(ctx).ListBatchErrors(processDate, fileCode)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Respond(errs)
+}
+
+func params(ctx api.Context) (adminID, accountID uuid.UUID, err error) {
+	if adminID, err = parameter.GetParamAdminID(ctx); err != nil {
+		return
+	}
+
+	accountID, err = parameter.GetParamAccountID(ctx)
+
+	return
+}
@@ -0,0 +1,97 @@
+package alert
+
+import (
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/alert"
+	"github.com/kataras/iris"
+)
+
+// List returns the account's alerts, newest first. ?active=true or
+// ?active=false filters on whether they have fired.
+func List(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	var active *bool
+
+	if ctx.URLParam("active") != "" {
+		b, err := ctx.URLParamBool("active")
+		if err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("active must be true or false"))
+			return
+		}
+		active = &b
+	}
+
+	srv := ctx.Services().Alert().WithTx(ctx.Tx())
+
+	if alerts, err := srv.List(accountID, active); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(alerts)
+	}
+}
+
+func Get(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := ctx.Services().Alert().WithTx(ctx.Tx())
+
+	if a, err := srv.GetByID(accountID, ctx.Params().Get("alert_id")); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(a)
+	}
+}
+
+func Create(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	req := alert.AlertRequest{}
+	if err := ctx.Read(&req); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	srv := ctx.Services().Alert().WithTx(ctx.Tx())
+
+	if a, err := srv.Create(accountID, req); err != nil {
+		ctx.RespondError(err)
+	} else {
+		// the webhook secret is only shown once, to verify payloads with
+		ctx.Respond(struct {
+			*models.Alert
+			WebhookSecret *string `json:"webhook_secret,omitempty"`
+		}{a, a.WebhookSecret})
+	}
+}
+
+func Delete(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := ctx.Services().Alert().WithTx(ctx.Tx())
+
+	if err := srv.Delete(accountID, ctx.Params().Get("alert_id")); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.RespondWithStatus(nil, iris.StatusNoContent)
+	}
+}
@@ -2,12 +2,21 @@ package asset
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/rest/api"
 )
 
+const (
+	// DefaultSearchLimit is the number of matches a search returns
+	DefaultSearchLimit = 20
+	// MaxSearchLimit is the most matches a search can ask for
+	MaxSearchLimit = 100
+)
+
 func Get(ctx api.Context) {
 	assetKey := ctx.Params().Get("symbol")
 	if assetKey == "" {
@@ -42,6 +51,11 @@ func List(ctx api.Context) {
 		class = &s
 	}
 
+	if q := ctx.URLParam("search"); q != "" {
+		search(ctx, q, class, status)
+		return
+	}
+
 	srv := ctx.Services().Asset().WithTx(ctx.Tx())
 
 	assets, err := srv.List(class, status)
@@ -53,3 +67,40 @@ func List(ctx api.Context) {
 
 	ctx.Respond(assets)
 }
+
+// search responds with the best matches of the query on symbol and
+// name from the asset cache, filtered like the full list.
+func search(ctx api.Context, query string, class *enum.AssetClass, status *enum.AssetStatus) {
+	limit := DefaultSearchLimit
+
+	if q := ctx.URLParam("limit"); q != "" {
+		parsed, err := strconv.Atoi(q)
+		if err != nil || parsed <= 0 || parsed > MaxSearchLimit {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("limit must be between 1 and %v", MaxSearchLimit)))
+			return
+		}
+		limit = parsed
+	}
+
+	if class == nil {
+		c := enum.AssetClassUSEquity
+		class = &c
+	}
+
+	assets := []*models.Asset{}
+
+	for _, asset := range ctx.Services().AssetCache().Search(query) {
+		if asset.Class != *class || (status != nil && asset.Status != *status) {
+			continue
+		}
+
+		assets = append(assets, asset)
+
+		if len(assets) == limit {
+			break
+		}
+	}
+
+	ctx.Respond(assets)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/rest/api"
 	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/bar"
 )
 
 func List(ctx api.Context) {
@@ -51,7 +52,13 @@ func List(ctx api.Context) {
 		limit = &parsed
 	}
 
-	srv := ctx.Services().Bar().WithTx(ctx.Tx())
+	adjustment, err := bar.ParseAdjustment(params["adjustment"])
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := ctx.Services().Bar().WithTx(ctx.Tx()).WithAdjustment(adjustment)
 
 	assetBarsList, err := srv.GetByIDs(assetIDs, timeframe, start, end, limit)
 
@@ -104,7 +111,13 @@ func Get(ctx api.Context) {
 		limit = &parsed
 	}
 
-	service := ctx.Services().Bar().WithTx(ctx.Tx())
+	adjustment, err := bar.ParseAdjustment(params["adjustment"])
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	service := ctx.Services().Bar().WithTx(ctx.Tx()).WithAdjustment(adjustment)
 
 	assetBars, err := service.GetByID(asset.IDAsUUID(), timeframe, start, end, limit)
 
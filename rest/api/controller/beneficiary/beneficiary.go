@@ -0,0 +1,70 @@
+package beneficiary
+
+import (
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/beneficiary"
+	"github.com/gofrs/uuid"
+	"github.com/kataras/iris"
+)
+
+func List(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := beneficiary.Service().WithTx(ctx.Tx())
+
+	if beneficiaries, err := srv.List(accountID); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(beneficiaries)
+	}
+}
+
+func Create(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	info := beneficiary.BeneficiaryInfo{}
+	if err := ctx.Read(&info); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	srv := beneficiary.Service().WithTx(ctx.Tx())
+
+	if b, err := srv.Create(accountID, info); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(b)
+	}
+}
+
+func Delete(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	beneficiaryID, err := uuid.FromString(ctx.Params().Get("beneficiary_id"))
+	if err != nil {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("beneficiary_id must be a valid uuid"))
+		return
+	}
+
+	srv := beneficiary.Service().WithTx(ctx.Tx())
+
+	if err := srv.Cancel(accountID, beneficiaryID.String()); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.RespondWithStatus(nil, iris.StatusNoContent)
+	}
+}
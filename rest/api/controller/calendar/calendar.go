@@ -5,9 +5,18 @@ import (
 
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
 	"github.com/alpacahq/gopaca/calendar"
 )
 
+// TradingDay is a day of the market calendar with the bounds of its
+// extended hours sessions.
+type TradingDay struct {
+	calendar.TradingDay
+	SessionOpen  string `json:"session_open"`
+	SessionClose string `json:"session_close"`
+}
+
 var history calendar.MarketHistory
 
 func init() {
@@ -47,5 +56,24 @@ func Get(ctx api.Context) {
 		return
 	}
 
-	ctx.Respond(slc)
+	days := make([]TradingDay, len(slc))
+
+	for i, day := range slc {
+		days[i] = TradingDay{
+			TradingDay:   day,
+			SessionOpen:  shift(day.Open, -tradingdate.PreMarketHours),
+			SessionClose: shift(day.Close, tradingdate.AfterHoursHours),
+		}
+	}
+
+	ctx.Respond(days)
+}
+
+// shift moves a HH:MM time of day by d
+func shift(hhmm string, d time.Duration) string {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return hhmm
+	}
+	return t.Add(d).Format("15:04")
 }
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 )
@@ -13,6 +14,12 @@ type ClockReading struct {
 	IsOpen    bool      `json:"is_open"`
 	NextOpen  time.Time `json:"next_open"`
 	NextClose time.Time `json:"next_close"`
+	// Session is the current session, including the extended ones, and
+	// the next session open and close bound the whole trading day from
+	// the pre-market open to the after-hours close.
+	Session          tradingdate.Session `json:"session"`
+	NextSessionOpen  time.Time           `json:"next_session_open"`
+	NextSessionClose time.Time           `json:"next_session_close"`
 }
 
 func Get(ctx api.Context) {
@@ -21,10 +28,29 @@ func Get(ctx api.Context) {
 
 	ctx.Respond(
 		ClockReading{
-			Timestamp: now,
-			IsOpen:    calendar.IsMarketOpen(now),
-			NextOpen:  calendar.NextOpen(now),
-			NextClose: calendar.NextClose(now),
+			Timestamp:        now,
+			IsOpen:           calendar.IsMarketOpen(now),
+			NextOpen:         calendar.NextOpen(now),
+			NextClose:        calendar.NextClose(now),
+			Session:          tradingdate.SessionAt(now),
+			NextSessionOpen:  nextSessionOpen(now),
+			NextSessionClose: nextSessionClose(now),
 		},
 	)
 }
+
+func nextSessionOpen(now time.Time) time.Time {
+	d := tradingdate.TradeDate(now)
+	if !now.Before(d.PreMarketOpen()) {
+		d = d.Next()
+	}
+	return d.PreMarketOpen()
+}
+
+func nextSessionClose(now time.Time) time.Time {
+	d := tradingdate.TradeDate(now)
+	if !now.Before(d.AfterHoursClose()) {
+		d = d.Next()
+	}
+	return d.AfterHoursClose()
+}
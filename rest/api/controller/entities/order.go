@@ -30,6 +30,7 @@ type OrderEntity struct {
 	CreatedAt      time.Time        `json:"created_at"`
 	UpdatedAt      time.Time        `json:"updated_at"`
 	SubmittedAt    time.Time        `json:"submitted_at"`
+	ReleaseAt      *time.Time       `json:"release_at"`
 	FilledAt       *time.Time       `json:"filled_at"`
 	ExpiredAt      *time.Time       `json:"expired_at"`
 	CanceledAt     *time.Time       `json:"canceled_at"`
@@ -40,6 +41,7 @@ type OrderEntity struct {
 	Qty            decimal.Decimal  `json:"qty"`
 	FilledQty      decimal.Decimal  `json:"filled_qty"`
 	FilledAvgPrice *decimal.Decimal `json:"filled_avg_price"`
+	ExtendedHours  bool             `json:"extended_hours"`
 	// TODO: remove this field, only for compatibility
 	OrderType   enum.OrderType   `json:"order_type"`
 	Type        enum.OrderType   `json:"type"`
@@ -48,6 +50,13 @@ type OrderEntity struct {
 	LimitPrice  *decimal.Decimal `json:"limit_price"`
 	StopPrice   *decimal.Decimal `json:"stop_price"`
 	Status      string           `json:"status"`
+	LotIDs      []int64          `json:"lot_ids,omitempty"`
+	// algo orders, and the algo order of a child order
+	ParentID         *string            `json:"parent_id,omitempty"`
+	Strategy         *enum.AlgoStrategy `json:"strategy,omitempty"`
+	StartAt          *time.Time         `json:"start_at,omitempty"`
+	EndAt            *time.Time         `json:"end_at,omitempty"`
+	MaxParticipation *decimal.Decimal   `json:"max_participation,omitempty"`
 }
 
 func OrderToEntity(o *models.Order, asset *models.Asset) *OrderEntity {
@@ -63,6 +72,7 @@ func OrderToEntity(o *models.Order, asset *models.Asset) *OrderEntity {
 		CreatedAt:      o.CreatedAt,
 		UpdatedAt:      o.UpdatedAt,
 		SubmittedAt:    o.SubmittedAt,
+		ReleaseAt:      o.ReleaseAt,
 		FilledAt:       o.FilledAt,
 		ExpiredAt:      o.ExpiredAt,
 		CanceledAt:     o.CanceledAt,
@@ -80,6 +90,14 @@ func OrderToEntity(o *models.Order, asset *models.Asset) *OrderEntity {
 		LimitPrice:     o.LimitForJSON(),
 		StopPrice:      o.StopPrice,
 		Status:         string(o.Status),
+		LotIDs:         o.LotIDs,
+		ExtendedHours:  o.ExtendedHours,
+
+		ParentID:         o.ParentID,
+		Strategy:         o.Strategy,
+		StartAt:          o.StartAt,
+		EndAt:            o.EndAt,
+		MaxParticipation: o.MaxParticipation,
 	}
 }
 
@@ -22,9 +22,10 @@ func NewPortfolioHistoriesRequest(r *http.Request) PortfolioHistoriesRequest {
 
 	req.Timeframe = calendar.RangeFreq(params.Get("timeframe"))
 	if req.Timeframe == "" {
-		if req.Period == "intraday" {
+		switch req.Period {
+		case "intraday", "1D", "1W":
 			req.Timeframe = calendar.Min5
-		} else {
+		default:
 			req.Timeframe = calendar.D1
 		}
 	}
@@ -25,3 +25,19 @@ func (r *TransferRequest) Verify() error {
 
 	return nil
 }
+
+type WireTransferRequest struct {
+	BeneficiaryID string          `json:"beneficiary_id"`
+	Amount        decimal.Decimal `json:"amount"`
+}
+
+func (r *WireTransferRequest) Verify() error {
+	if r.BeneficiaryID == "" {
+		return gberrors.InvalidRequestParam.WithMsg("beneficiary_id is required")
+	}
+	if r.Amount.LessThanOrEqual(decimal.Zero) {
+		return gberrors.InvalidRequestParam.WithMsg("amount must be > 0")
+	}
+
+	return nil
+}
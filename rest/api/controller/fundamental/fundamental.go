@@ -1,8 +1,14 @@
 package fundamental
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/rest/api"
 	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/fundamental"
 )
 
 func Get(ctx api.Context) {
@@ -36,3 +42,100 @@ func List(ctx api.Context) {
 		ctx.Respond(fundamentals)
 	}
 }
+
+// Screen filters the fundamentals of tradable assets. Any screenable
+// field takes {field}_min and {field}_max bounds, and sector and
+// industry take comma separated lists.
+func Screen(ctx api.Context) {
+	query := fundamental.ScreenQuery{
+		Ranges:    map[string]fundamental.Range{},
+		OrderBy:   ctx.URLParam("order_by"),
+		Direction: strings.ToLower(ctx.URLParam("direction")),
+		Page:      ctx.URLParamIntDefault("page", 1),
+		Per:       ctx.URLParamIntDefault("per", 50),
+	}
+
+	if query.Page < 1 || query.Per < 1 || query.Per > 500 {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("invalid pagination"))
+		return
+	}
+
+	if query.Direction != "" && query.Direction != "asc" && query.Direction != "desc" {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("direction can only be \"asc\" or \"desc\""))
+		return
+	}
+
+	for field := range fundamental.ScreenFields {
+		min, err := bound(ctx, field+"_min")
+		if err != nil {
+			ctx.RespondError(err)
+			return
+		}
+
+		max, err := bound(ctx, field+"_max")
+		if err != nil {
+			ctx.RespondError(err)
+			return
+		}
+
+		if min != nil || max != nil {
+			query.Ranges[field] = fundamental.Range{Min: min, Max: max}
+		}
+	}
+
+	if q := ctx.URLParam("sector"); q != "" {
+		query.Sectors = strings.Split(q, ",")
+	}
+
+	if q := ctx.URLParam("industry"); q != "" {
+		query.Industries = strings.Split(q, ",")
+	}
+
+	srv := ctx.Services().Fundamental().WithTx(ctx.Tx())
+
+	fundamentals, total, err := srv.Screen(query)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	ctx.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	ctx.Respond(fundamentals)
+}
+
+func bound(ctx api.Context, param string) (*float64, error) {
+	v := ctx.URLParam(param)
+	if v == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, gberrors.InvalidRequestParam.WithMsg(fmt.Sprintf("%v must be a number", param))
+	}
+
+	return &f, nil
+}
+
+// GetSectorAllocation breaks the account's positions down by sector.
+func GetSectorAllocation(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	positions, err := ctx.Services().Position().WithTx(ctx.Tx()).List(accountID)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := ctx.Services().Fundamental().WithTx(ctx.Tx())
+
+	if allocation, err := srv.GetSectorAllocation(positions); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(allocation)
+	}
+}
@@ -0,0 +1,99 @@
+package gainloss
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/gainloss"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+)
+
+// Get returns the realized gains and losses for lots sold in a date
+// range, year to date by default. Passing format=csv downloads the
+// lots sold as CSV.
+func Get(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	until := date.DateOf(clock.Now().In(calendar.NY))
+	if q := ctx.URLParam("until"); q != "" {
+		if until, err = date.ParseDate(q); err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("until must be YYYY-MM-DD"))
+			return
+		}
+	}
+
+	since, _ := date.ParseDate(fmt.Sprintf("%04d-01-01", until.Year))
+	if q := ctx.URLParam("since"); q != "" {
+		if since, err = date.ParseDate(q); err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("since must be YYYY-MM-DD"))
+			return
+		}
+	}
+
+	if since.After(until.Date) {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("since must be before until"))
+		return
+	}
+
+	srv := gainloss.Service().WithTx(ctx.Tx())
+
+	report, err := srv.Report(accountID, since, until)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	switch ctx.URLParam("format") {
+	case "", "json":
+		ctx.Respond(report)
+	case "csv":
+		buf := &bytes.Buffer{}
+		if err = report.WriteCSV(buf); err != nil {
+			ctx.RespondError(gberrors.InternalServerError.WithError(err))
+			return
+		}
+
+		ctx.Header(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=realized_gains_%v_%v.csv", since, until))
+		ctx.RespondWithContent(api.MIMETextCSV, buf.Bytes())
+	default:
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("format must be json or csv"))
+	}
+}
+
+// Get1099B returns the realized gains for a tax year laid out like
+// form 1099-B, for last year by default.
+func Get1099B(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	year := clock.Now().In(calendar.NY).Year() - 1
+	if q := ctx.URLParam("year"); q != "" {
+		if year, err = strconv.Atoi(q); err != nil || year < 1900 {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("year must be YYYY"))
+			return
+		}
+	}
+
+	srv := gainloss.Service().WithTx(ctx.Tx())
+
+	if form, err := srv.Form1099B(accountID, year); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(form)
+	}
+}
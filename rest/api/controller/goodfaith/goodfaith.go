@@ -0,0 +1,31 @@
+package goodfaith
+
+import (
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/goodfaith"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+)
+
+// Get returns the account's good faith violation history, whether it
+// is restricted to settled cash, and which positions can't be sold
+// yet without a violation.
+func Get(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := goodfaith.Service().WithTx(ctx.Tx())
+
+	today := date.DateOf(clock.Now().In(calendar.NY))
+
+	if status, err := srv.Status(accountID, today); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(status)
+	}
+}
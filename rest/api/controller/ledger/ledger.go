@@ -0,0 +1,70 @@
+package ledger
+
+import (
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+)
+
+// GetCash returns the account's settled, unsettled and withdrawable
+// cash as of right now.
+func GetCash(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := ledger.Service().WithTx(ctx.Tx())
+
+	today := date.DateOf(clock.Now().In(calendar.NY))
+
+	if balances, err := srv.Balances(accountID, today); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(balances)
+	}
+}
+
+// ListJournals returns the cash journals booked for the account,
+// for the last 30 days by default.
+func ListJournals(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	until := date.DateOf(clock.Now().In(calendar.NY))
+	if q := ctx.URLParam("until"); q != "" {
+		if until, err = date.ParseDate(q); err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("until must be YYYY-MM-DD"))
+			return
+		}
+	}
+
+	since := date.Date{Date: until.AddDays(-30)}
+	if q := ctx.URLParam("since"); q != "" {
+		if since, err = date.ParseDate(q); err != nil {
+			ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("since must be YYYY-MM-DD"))
+			return
+		}
+	}
+
+	if since.After(until.Date) {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("since must be before until"))
+		return
+	}
+
+	srv := ledger.Service().WithTx(ctx.Tx())
+
+	if journals, err := srv.Journals(accountID, since, until); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(journals)
+	}
+}
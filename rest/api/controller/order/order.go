@@ -13,7 +13,7 @@ import (
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/rest/api"
 	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
-	"github.com/alpacahq/gopaca/calendar"
+	orderService "github.com/alpacahq/gobroker/service/order"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/alpacahq/gopaca/env"
@@ -40,6 +40,7 @@ type OrderEntity struct {
 	CreatedAt      time.Time        `json:"created_at"`
 	UpdatedAt      time.Time        `json:"updated_at"`
 	SubmittedAt    time.Time        `json:"submitted_at"`
+	ReleaseAt      *time.Time       `json:"release_at"`
 	FilledAt       *time.Time       `json:"filled_at"`
 	ExpiredAt      *time.Time       `json:"expired_at"`
 	CanceledAt     *time.Time       `json:"canceled_at"`
@@ -50,6 +51,7 @@ type OrderEntity struct {
 	Qty            decimal.Decimal  `json:"qty"`
 	FilledQty      decimal.Decimal  `json:"filled_qty"`
 	FilledAvgPrice *decimal.Decimal `json:"filled_avg_price"`
+	ExtendedHours  bool             `json:"extended_hours"`
 	// TODO: remove this field, only for compatibility
 	OrderType   enum.OrderType   `json:"order_type"`
 	Type        enum.OrderType   `json:"type"`
@@ -58,6 +60,14 @@ type OrderEntity struct {
 	LimitPrice  *decimal.Decimal `json:"limit_price"`
 	StopPrice   *decimal.Decimal `json:"stop_price"`
 	Status      string           `json:"status"`
+	LotIDs      []int64          `json:"lot_ids,omitempty"`
+	Warnings    []string         `json:"warnings,omitempty"`
+	// algo orders, and the algo order of a child order
+	ParentID         *string            `json:"parent_id,omitempty"`
+	Strategy         *enum.AlgoStrategy `json:"strategy,omitempty"`
+	StartAt          *time.Time         `json:"start_at,omitempty"`
+	EndAt            *time.Time         `json:"end_at,omitempty"`
+	MaxParticipation *decimal.Decimal   `json:"max_participation,omitempty"`
 }
 
 func OrderToEntity(o *models.Order, asset *models.Asset) *OrderEntity {
@@ -73,6 +83,7 @@ func OrderToEntity(o *models.Order, asset *models.Asset) *OrderEntity {
 		CreatedAt:      o.CreatedAt,
 		UpdatedAt:      o.UpdatedAt,
 		SubmittedAt:    o.SubmittedAt,
+		ReleaseAt:      o.ReleaseAt,
 		FilledAt:       o.FilledAt,
 		ExpiredAt:      o.ExpiredAt,
 		CanceledAt:     o.CanceledAt,
@@ -90,6 +101,15 @@ func OrderToEntity(o *models.Order, asset *models.Asset) *OrderEntity {
 		LimitPrice:     o.LimitForJSON(),
 		StopPrice:      o.StopPrice,
 		Status:         string(o.Status),
+		LotIDs:         o.LotIDs,
+		ExtendedHours:  o.ExtendedHours,
+		Warnings:       o.Warnings,
+
+		ParentID:         o.ParentID,
+		Strategy:         o.Strategy,
+		StartAt:          o.StartAt,
+		EndAt:            o.EndAt,
+		MaxParticipation: o.MaxParticipation,
 	}
 }
 
@@ -233,15 +253,6 @@ func Delete(ctx api.Context) {
 		return
 	}
 
-	if !queueOrders() &&
-		!accountQueueable(accountID.String()) &&
-		!calendar.IsMarketOpen(clock.Now()) {
-
-		ctx.RespondError(
-			gberrors.Forbidden.WithMsg("market is closed"))
-		return
-	}
-
 	srv := ctx.Services().Order().WithTx(ctx.Tx())
 
 	orderID, err := uuid.FromString(ctx.Params().Get("order_id"))
@@ -250,6 +261,26 @@ func Delete(ctx api.Context) {
 		return
 	}
 
+	order, err := srv.GetByID(accountID, orderID)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	// queued and algo orders can be canceled any time, as they aren't
+	// at the order queue, and extended hours orders outside the regular
+	// session
+	if order.Status != enum.OrderQueued &&
+		order.Type != enum.Algo &&
+		!queueOrders() &&
+		!accountQueueable(accountID.String()) &&
+		!marketOpen(order.ExtendedHours) {
+
+		ctx.RespondError(
+			gberrors.Forbidden.WithMsg("market is closed"))
+		return
+	}
+
 	if err := srv.Cancel(accountID, orderID); err != nil {
 		ctx.RespondError(err)
 	} else {
@@ -257,6 +288,11 @@ func Delete(ctx api.Context) {
 	}
 }
 
+// marketOpen returns true if an order can go out to the order queue now
+func marketOpen(extendedHours bool) bool {
+	return orderService.SessionOpen(extendedHours, clock.Now())
+}
+
 type CreateOrderRequest struct {
 	AccountID     string           `json:"-"`
 	AssetKey      *string          `json:"symbol"`
@@ -267,6 +303,13 @@ type CreateOrderRequest struct {
 	LimitPrice    *decimal.Decimal `json:"limit_price"`
 	StopPrice     *decimal.Decimal `json:"stop_price"`
 	ClientOrderID string           `json:"client_order_id"`
+	LotIDs        []int64          `json:"lot_ids"`
+	ExtendedHours bool             `json:"extended_hours"`
+	// algo orders only
+	Strategy         *enum.AlgoStrategy `json:"strategy"`
+	StartAt          *time.Time         `json:"start_at"`
+	EndAt            *time.Time         `json:"end_at"`
+	MaxParticipation *decimal.Decimal   `json:"max_participation"`
 }
 
 func (req *CreateOrderRequest) ToOrder(asset *models.Asset) *models.Order {
@@ -278,6 +321,13 @@ func (req *CreateOrderRequest) ToOrder(asset *models.Asset) *models.Order {
 		TimeInForce:   req.TimeInForce,
 		ClientOrderID: req.ClientOrderID,
 		OrderCapacity: enum.Agency,
+		LotIDs:        req.LotIDs,
+		ExtendedHours: req.ExtendedHours,
+
+		Strategy:         req.Strategy,
+		StartAt:          req.StartAt,
+		EndAt:            req.EndAt,
+		MaxParticipation: req.MaxParticipation,
 	}
 
 	if req.LimitPrice != nil {
@@ -328,6 +378,16 @@ func (req *CreateOrderRequest) verify() error {
 		return gberrors.InvalidRequestParam.WithMsg("invalid time_in_force")
 	}
 
+	if len(req.LotIDs) > 0 && req.Side != enum.Sell {
+		return gberrors.InvalidRequestParam.WithMsg("lot_ids are only allowed for sell orders")
+	}
+
+	if req.Type != enum.Algo &&
+		(req.Strategy != nil || req.StartAt != nil || req.EndAt != nil || req.MaxParticipation != nil) {
+		return gberrors.InvalidRequestParam.WithMsg(
+			"strategy, start_at, end_at and max_participation are only for algo orders")
+	}
+
 	return nil
 }
 
@@ -338,59 +398,110 @@ func Create(ctx api.Context) {
 		return
 	}
 
-	orderRequest := &CreateOrderRequest{}
-	if err = ctx.Read(orderRequest); err != nil {
-		ctx.RespondError(gberrors.RequestBodyLoadFailure)
+	orderRequest, asset, err := readOrder(ctx, accountID)
+	if err != nil {
+		ctx.RespondError(err)
 		return
 	}
 
-	// Is a new order prohibited by user request?
-	// For now, do it strightforward... optmize it using cache for later.
-	acct, err := ctx.Services().Account().WithTx(db.DB()).GetByID(accountID)
+	// orders placed while the market is closed are queued until it opens,
+	// except algo orders, which send their child orders in their window
+	queued := orderRequest.Type != enum.Algo && !marketOpen(orderRequest.ExtendedHours)
+
+	if queued &&
+		!queueOrders() &&
+		!accountQueueable(accountID.String()) {
+
+		ctx.RespondError(
+			gberrors.Forbidden.WithMsg("market is closed"))
+		return
+	}
+
+	// need to use db.DB instead of ctx.Tx(), because it requires more than 2 tx.
+	srv := ctx.Services().Order().WithTx(db.DB())
+
+	var order *models.Order
+	if queued {
+		order, err = srv.Queue(accountID, orderRequest.ToOrder(asset))
+	} else {
+		order, err = srv.Create(accountID, orderRequest.ToOrder(asset))
+	}
+
+	if err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(OrderToEntity(order, ctx.Services().AssetCache().Get(order.AssetID)))
+	}
+}
+
+// Replace swaps a queued order for a new one, before it's released.
+func Replace(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
 	if err != nil {
 		ctx.RespondError(err)
 		return
 	}
 
-	if acct.TradeSuspendedByUser {
-		ctx.RespondError(
-			gberrors.Forbidden.WithMsg("new orders are rejected by user request"))
+	orderID, err := uuid.FromString(ctx.Params().Get("order_id"))
+	if err != nil {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("order_id is missing"))
 		return
 	}
 
-	if err = orderRequest.verify(); err != nil {
+	orderRequest, asset, err := readOrder(ctx, accountID)
+	if err != nil {
 		ctx.RespondError(err)
 		return
 	}
 
+	// the service manages its own transaction, same as Create
+	srv := ctx.Services().Order().WithTx(db.DB())
+
+	order, err := srv.Replace(accountID, orderID, orderRequest.ToOrder(asset))
+	if err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(OrderToEntity(order, ctx.Services().AssetCache().Get(order.AssetID)))
+	}
+}
+
+// readOrder loads and checks a new order from the request body.
+func readOrder(ctx api.Context, accountID uuid.UUID) (*CreateOrderRequest, *models.Asset, error) {
+	orderRequest := &CreateOrderRequest{}
+	if err := ctx.Read(orderRequest); err != nil {
+		return nil, nil, gberrors.RequestBodyLoadFailure
+	}
+
+	// Is a new order prohibited by user request?
+	// For now, do it strightforward... optmize it using cache for later.
+	acct, err := ctx.Services().Account().WithTx(db.DB()).GetByID(accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if acct.TradeSuspendedByUser {
+		return nil, nil, gberrors.Forbidden.WithMsg("new orders are rejected by user request")
+	}
+
+	if err = orderRequest.verify(); err != nil {
+		return nil, nil, err
+	}
+
 	asset := ctx.Services().AssetCache().Get(*orderRequest.AssetKey)
 	if asset == nil {
-		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg(
-			fmt.Sprintf("could not find asset \"%s\"", *orderRequest.AssetKey)))
-		return
+		return nil, nil, gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("could not find asset \"%s\"", *orderRequest.AssetKey))
 	}
 
 	// only process the order if this is an active, tradable asset
 	if !asset.Tradable || asset.Status != enum.AssetActive {
-		ctx.RespondError(
-			gberrors.InvalidRequestParam.WithMsg(
-				fmt.Sprintf("asset %v is not tradable", asset.Symbol)))
-		return
-	}
-
-	if !queueOrders() &&
-		!accountQueueable(accountID.String()) &&
-		!calendar.IsMarketOpen(clock.Now()) {
-
-		ctx.RespondError(
-			gberrors.Forbidden.WithMsg("market is closed"))
-		return
+		return nil, nil, gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("asset %v is not tradable", asset.Symbol))
 	}
 
 	if len(orderRequest.ClientOrderID) > 50 {
-		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg(
-			"client_order_id must be no more than 50 characters"))
-		return
+		return nil, nil, gberrors.InvalidRequestParam.WithMsg(
+			"client_order_id must be no more than 50 characters")
 	}
 
 	orderRequest.AccountID = accountID.String()
@@ -399,20 +510,57 @@ func Create(ctx api.Context) {
 	if orderRequest.ClientOrderID == "" {
 		clientOrderID, err := uuid.NewV4()
 		if err != nil {
-			ctx.RespondError(gberrors.InternalServerError.WithError(err))
-			return
+			return nil, nil, gberrors.InternalServerError.WithError(err)
 		}
 		orderRequest.ClientOrderID = clientOrderID.String()
 	}
 
-	// need to use db.DB instead of ctx.Tx(), because it requires more than 2 tx.
-	srv := ctx.Services().Order().WithTx(db.DB())
+	return orderRequest, asset, nil
+}
 
-	order, err := srv.Create(accountID, orderRequest.ToOrder(asset))
+// Preview estimates the cost and fees of an order, after running the
+// same checks as Create, without submitting it.
+func Preview(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
 	if err != nil {
 		ctx.RespondError(err)
+		return
+	}
+
+	orderRequest := &CreateOrderRequest{}
+	if err = ctx.Read(orderRequest); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure)
+		return
+	}
+
+	if err = orderRequest.verify(); err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	asset := ctx.Services().AssetCache().Get(*orderRequest.AssetKey)
+	if asset == nil {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("could not find asset \"%s\"", *orderRequest.AssetKey)))
+		return
+	}
+
+	if !asset.Tradable || asset.Status != enum.AssetActive {
+		ctx.RespondError(
+			gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("asset %v is not tradable", asset.Symbol)))
+		return
+	}
+
+	orderRequest.AccountID = accountID.String()
+
+	// the service manages its own transaction, same as Create
+	srv := ctx.Services().Order().WithTx(db.DB())
+
+	if preview, err := srv.Preview(accountID, orderRequest.ToOrder(asset)); err != nil {
+		ctx.RespondError(err)
 	} else {
-		ctx.Respond(OrderToEntity(order, ctx.Services().AssetCache().Get(order.AssetID)))
+		ctx.Respond(preview)
 	}
 }
 
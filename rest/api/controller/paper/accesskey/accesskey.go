@@ -16,7 +16,7 @@ func List(ctx api.Context) {
 		return
 	}
 
-	svc := paper.Service().WithTx(ctx.Tx())
+	svc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	keys, err := svc.GetAccessKeys(ctx.Session().ID, paperAccountID)
 	if err != nil {
@@ -34,7 +34,7 @@ func Create(ctx api.Context) {
 		return
 	}
 
-	svc := paper.Service().WithTx(ctx.Tx())
+	svc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	accessKey, err := svc.CreateAccessKey(ctx.Session().ID, paperAccountID)
 
@@ -58,7 +58,7 @@ func Delete(ctx api.Context) {
 		return
 	}
 
-	svc := paper.Service().WithTx(ctx.Tx())
+	svc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	err = svc.DeleteAccessKey(ctx.Session().ID, paperAccountID, keyID)
 	if err != nil {
@@ -16,7 +16,7 @@ func List(ctx api.Context) {
 		return
 	}
 
-	svc := paper.Service().WithTx(ctx.Tx())
+	svc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	accounts, err := svc.List(accID)
 	if err != nil {
@@ -53,7 +53,7 @@ func Create(ctx api.Context) {
 
 	// might better to have upper limit
 
-	svc := paper.Service().WithTx(ctx.Tx())
+	svc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	account, err := svc.Create(accID, params.Cash)
 	if err != nil {
@@ -77,7 +77,7 @@ func Delete(ctx api.Context) {
 		return
 	}
 
-	svc := paper.Service().WithTx(ctx.Tx())
+	svc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	if err := svc.Delete(acctID, paperAcctID); err != nil {
 		ctx.RespondError(err)
@@ -99,7 +99,7 @@ func Get(ctx api.Context) {
 		return
 	}
 
-	svc := paper.Service().WithTx(ctx.Tx())
+	svc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	if acct, err := svc.GetByID(acctID, paperAcctID); err != nil {
 		ctx.RespondError(err)
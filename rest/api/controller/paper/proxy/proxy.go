@@ -14,7 +14,7 @@ func Proxy(ctx api.Context) {
 		return
 	}
 
-	srv := paperService.Service().WithTx(ctx.Tx())
+	srv := paperService.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 	if _, err = srv.GetByID(ctx.Session().ID, paperAccountID); err != nil {
 		ctx.RespondError(err)
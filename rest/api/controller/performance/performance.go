@@ -0,0 +1,32 @@
+package performance
+
+import (
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+)
+
+// Get returns the account's performance over the period (1M by
+// default), optionally compared against a benchmark symbol.
+func Get(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	period := ctx.URLParam("period")
+	if period == "" {
+		period = "1M"
+	}
+
+	srv := ctx.Services().Performance().WithTx(ctx.Tx())
+
+	perf, err := srv.Get(accountID, tradingdate.Current(), period, ctx.URLParam("benchmark"))
+
+	if err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(perf)
+	}
+}
@@ -25,7 +25,7 @@ func Auth(ctx api.Context) {
 
 	if acct, err := srv.VerifyKey(req.APIKeyID); err != nil {
 		if gberrors.IsNotFound(err) {
-			paperSvc := paper.Service().WithTx(ctx.Tx())
+			paperSvc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 			if resp, err := paperSvc.VerifyKeyForPolygon(req.APIKeyID); err != nil {
 				ctx.RespondError(gberrors.Unauthorized.WithError(err))
@@ -75,7 +75,7 @@ func List(ctx api.Context) {
 		}
 
 		// aggregate with papertrader
-		paperSvc := paper.Service().WithTx(ctx.Tx())
+		paperSvc := paper.Service(ctx.Services().Position()).WithTx(ctx.Tx())
 
 		paperResp, err := paperSvc.ListKeysForPolygon(req.APIKeyIDs)
 		if err != nil {
@@ -26,6 +26,36 @@ func List(ctx api.Context) {
 	}
 }
 
+// ListLots returns the open tax lots of the position in a symbol.
+func ListLots(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	assetKey := ctx.Params().Get("symbol")
+	if assetKey == "" {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("symbol is required"))
+		return
+	}
+
+	asset := ctx.Services().AssetCache().Get(assetKey)
+	if asset == nil {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("could not find asset for \"%s\"", assetKey)))
+		return
+	}
+
+	srv := ctx.Services().Position().WithTx(ctx.Tx())
+
+	if lots, err := srv.Lots(accountID, asset.IDAsUUID()); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(lots)
+	}
+}
+
 func Get(ctx api.Context) {
 	accountID, err := parameter.GetParamAccountID(ctx)
 	if err != nil {
@@ -0,0 +1,22 @@
+package snapshot
+
+import (
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+)
+
+// List returns the latest trade, quote, minute and daily bars, and
+// previous close of each of the symbols.
+func List(ctx api.Context) {
+	srv := ctx.Services().Snapshot().WithTx(ctx.Tx())
+
+	assetIDs := parameter.GetAssetIDs(ctx)
+
+	snapshots, err := srv.GetByIDs(assetIDs)
+
+	if err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(snapshots)
+	}
+}
@@ -0,0 +1,40 @@
+package trade
+
+import (
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+)
+
+// Latest returns the last trade of the asset.
+func Latest(ctx api.Context) {
+	asset, err := parameter.GetAsset(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := ctx.Services().Snapshot().WithTx(ctx.Tx())
+
+	trade, err := srv.GetLastTrade(asset.IDAsUUID())
+
+	if err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(trade)
+	}
+}
+
+// ListLatest returns the last trade of each of the symbols.
+func ListLatest(ctx api.Context) {
+	srv := ctx.Services().Snapshot().WithTx(ctx.Tx())
+
+	assetIDs := parameter.GetAssetIDs(ctx)
+
+	trades, err := srv.GetLastTrades(assetIDs)
+
+	if err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(trades)
+	}
+}
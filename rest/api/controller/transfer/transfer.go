@@ -8,6 +8,7 @@ import (
 	"github.com/alpacahq/gobroker/rest/api/controller/entities"
 	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
 	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/recurring"
 	"github.com/alpacahq/gobroker/service/transfer"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/gofrs/uuid"
@@ -113,6 +114,35 @@ func Create(ctx api.Context) {
 	ctx.Respond(xfer)
 }
 
+// CreateWire requests an outbound wire to one of the account's
+// beneficiaries. It is held for admin approval before being sent.
+func CreateWire(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	wReq := entities.WireTransferRequest{}
+	if err := ctx.Read(&wReq); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	if err := wReq.Verify(); err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := transfer.Service().WithTx(ctx.Tx())
+
+	if xfer, err := srv.CreateWire(accountID, wReq.BeneficiaryID, wReq.Amount); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(xfer)
+	}
+}
+
 // not currently working
 func Delete(ctx api.Context) {
 	accountID, err := parameter.GetParamAccountID(ctx)
@@ -135,3 +165,95 @@ func Delete(ctx api.Context) {
 		ctx.RespondWithStatus(nil, iris.StatusNoContent)
 	}
 }
+
+func ListRecurring(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := recurring.Service(transfer.Service()).WithTx(ctx.Tx())
+
+	if rts, err := srv.List(accountID); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(rts)
+	}
+}
+
+func CreateRecurring(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	rReq := recurring.RecurringTransferRequest{}
+	if err := ctx.Read(&rReq); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	srv := recurring.Service(transfer.Service()).WithTx(ctx.Tx())
+
+	if rt, err := srv.Create(accountID, rReq); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(rt)
+	}
+}
+
+func PauseRecurring(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := recurring.Service(transfer.Service()).WithTx(ctx.Tx())
+
+	if rt, err := srv.Pause(accountID, ctx.Params().Get("recurring_transfer_id"), "paused by user"); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(rt)
+	}
+}
+
+func ResumeRecurring(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	srv := recurring.Service(transfer.Service()).WithTx(ctx.Tx())
+
+	if rt, err := srv.Resume(accountID, ctx.Params().Get("recurring_transfer_id")); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(rt)
+	}
+}
+
+func DeleteRecurring(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	rtID, err := uuid.FromString(ctx.Params().Get("recurring_transfer_id"))
+	if err != nil {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("recurring_transfer_id must be a valid uuid"))
+		return
+	}
+
+	srv := recurring.Service(transfer.Service()).WithTx(ctx.Tx())
+
+	if err := srv.Cancel(accountID, rtID.String()); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.RespondWithStatus(nil, iris.StatusNoContent)
+	}
+}
@@ -0,0 +1,205 @@
+package watchlist
+
+import (
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/rest/api"
+	"github.com/alpacahq/gobroker/rest/api/controller/parameter"
+	"github.com/alpacahq/gobroker/service/watchlist"
+	"github.com/alpacahq/gobroker/stream"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/log"
+	"github.com/gofrs/uuid"
+	"github.com/kataras/iris"
+)
+
+const (
+	// EventWatchlistUpdated is sent on account_updates when a watchlist
+	// is created or changed
+	EventWatchlistUpdated = "watchlist_updated"
+	// EventWatchlistDeleted is sent on account_updates when a watchlist
+	// is deleted
+	EventWatchlistDeleted = "watchlist_deleted"
+)
+
+func List(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	if wls, err := service(ctx).List(accountID); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(wls)
+	}
+}
+
+func Get(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	if wl, err := service(ctx).GetByID(accountID, ctx.Params().Get("watchlist_id")); err != nil {
+		ctx.RespondError(err)
+	} else {
+		ctx.Respond(wl)
+	}
+}
+
+func Create(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	req := watchlist.WatchlistRequest{}
+	if err := ctx.Read(&req); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	wl, err := service(ctx).Create(accountID, req)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	respondUpdated(ctx, accountID, wl)
+}
+
+// Update renames the watchlist, and replaces its assets when symbols
+// are given.
+func Update(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	req := watchlist.WatchlistRequest{}
+	if err := ctx.Read(&req); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	wl, err := service(ctx).Update(accountID, ctx.Params().Get("watchlist_id"), req)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	respondUpdated(ctx, accountID, wl)
+}
+
+func AddAsset(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	req := struct {
+		Symbol string `json:"symbol"`
+	}{}
+	if err := ctx.Read(&req); err != nil {
+		ctx.RespondError(gberrors.RequestBodyLoadFailure.WithError(err))
+		return
+	}
+
+	if req.Symbol == "" {
+		ctx.RespondError(gberrors.InvalidRequestParam.WithMsg("symbol is required"))
+		return
+	}
+
+	wl, err := service(ctx).AddAsset(accountID, ctx.Params().Get("watchlist_id"), req.Symbol)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	respondUpdated(ctx, accountID, wl)
+}
+
+func RemoveAsset(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	wl, err := service(ctx).RemoveAsset(accountID, ctx.Params().Get("watchlist_id"), ctx.Params().Get("symbol"))
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	respondUpdated(ctx, accountID, wl)
+}
+
+func Delete(ctx api.Context) {
+	accountID, err := parameter.GetParamAccountID(ctx)
+	if err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	id := ctx.Params().Get("watchlist_id")
+
+	if err := service(ctx).Delete(accountID, id); err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	if err := ctx.Commit(); err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	publish(accountID, map[string]interface{}{
+		"event":        EventWatchlistDeleted,
+		"watchlist_id": id,
+		"timestamp":    clock.Now(),
+	})
+
+	ctx.RespondWithStatus(nil, iris.StatusNoContent)
+}
+
+func service(ctx api.Context) watchlist.WatchlistService {
+	srv := ctx.Services().Watchlist().WithTx(ctx.Tx())
+
+	if ctx.URLParam("include") == "quotes" {
+		srv = srv.WithQuotes()
+	}
+
+	return srv
+}
+
+// respondUpdated commits the change before telling the account's other
+// devices about it, so they don't read the watchlist before it's saved.
+func respondUpdated(ctx api.Context, accountID uuid.UUID, wl *watchlist.Watchlist) {
+	if err := ctx.Commit(); err != nil {
+		ctx.RespondError(err)
+		return
+	}
+
+	publish(accountID, map[string]interface{}{
+		"event":     EventWatchlistUpdated,
+		"watchlist": wl,
+		"timestamp": clock.Now(),
+	})
+
+	ctx.Respond(wl)
+}
+
+func publish(accountID uuid.UUID, update map[string]interface{}) {
+	if err := stream.Publish(stream.OutboundMessage{
+		Stream: stream.AccountUpdatesStream(accountID),
+		Data:   update,
+	}); err != nil {
+		log.Error("failed to publish watchlist update", "account", accountID, "error", err)
+	}
+}
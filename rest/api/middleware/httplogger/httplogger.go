@@ -68,6 +68,7 @@ func (h *HTTPLogger) ServeHTTP(ctx context.Context) {
 		"query":       ctx.Request().URL.RawQuery,
 		"key_id":      ctx.Request().Header.Get("APCA-API-KEY-ID"),
 		"acc_id":      ctx.Values().GetString("account_id"),
+		"request_id":  ctx.Values().GetString("request_id"),
 		"body":        string(body),
 	}
 
@@ -82,6 +83,7 @@ func (h *HTTPLogger) ServeHTTP(ctx context.Context) {
 		"ip", msg["ip"],
 		"key_id", msg["key_id"],
 		"acc_id", msg["acc_id"],
+		"request_id", msg["request_id"],
 		"body", msg["body"],
 	)
 }
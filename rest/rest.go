@@ -49,6 +49,9 @@ func run(host string, services registry.Registry) error {
 	// internal API
 	app.PartyFunc("/gobroker/api/_internal/v1", bindAPI(apis, binder.Internal))
 
+	// admin API
+	app.PartyFunc("/gobroker/api/_admin/v1", bindAPI(apis, binder.Admin))
+
 	// API for papertrader integration
 	app.PartyFunc("/gobroker/api/_papertrader/v1", bindAPI(apis, binder.PaperTrader))
 
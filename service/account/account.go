@@ -8,6 +8,7 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/audit"
 	"github.com/alpacahq/gobroker/service/op"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/alpacahq/gopaca/validate"
@@ -175,6 +176,8 @@ func (s *accountService) Patch(accountID uuid.UUID, patches map[string]interface
 		return nil, err
 	}
 
+	before := audit.Snapshot(acct)
+
 	for field, value := range patches {
 		if !acct.Modifiable(field) {
 			return nil, gberrors.InvalidRequestParam.WithMsg(fmt.Sprintf("field %v not found", field))
@@ -185,6 +188,10 @@ func (s *accountService) Patch(accountID uuid.UUID, patches map[string]interface
 		}
 	}
 
+	if err = s.audit(acct, before); err != nil {
+		return nil, err
+	}
+
 	return acct, nil
 }
 
@@ -196,15 +203,31 @@ func (s *accountService) PatchInternal(accountID uuid.UUID, patches map[string]i
 		return nil, err
 	}
 
+	before := audit.Snapshot(acct)
+
 	for field, value := range patches {
 		if err = s.tx.Model(acct).Update(field, value).Error; err != nil {
 			return nil, err
 		}
 	}
 
+	if err = s.audit(acct, before); err != nil {
+		return nil, err
+	}
+
 	return acct, nil
 }
 
+func (s *accountService) audit(acct *models.Account, before map[string]interface{}) error {
+	return audit.Record(s.tx, audit.Entry{
+		AccountID:  acct.ID,
+		Resource:   models.AuditAccount,
+		ResourceID: acct.ID,
+		Before:     before,
+		After:      acct,
+	})
+}
+
 type AccountQuery struct {
 	ApexApprovalStatus []enum.ApexApprovalStatus
 	AccountStatus      []enum.AccountStatus
@@ -214,6 +237,7 @@ type AccountQuery struct {
 	Per                int
 	AccountID          *uuid.UUID
 	ApexAccount        *string
+	Email              *string
 }
 
 type PaginationMeta struct {
@@ -249,6 +273,12 @@ func (s *accountService) List(query AccountQuery) ([]models.Account, *Pagination
 		q = q.Where("id = ?", query.AccountID.String())
 	}
 
+	if query.Email != nil {
+		q = q.Where(
+			"id IN (SELECT account_owners.account_id FROM account_owners JOIN owners ON owners.id = account_owners.owner_id WHERE owners.email ILIKE ?)",
+			"%"+*query.Email+"%")
+	}
+
 	meta := PaginationMeta{}
 
 	if err := q.Model(&models.Account{}).Count(&meta.TotalCount).Error; err != nil {
@@ -129,4 +129,17 @@ func (s *AccountTestSuite) TestAccount() {
 	require.Nil(s.T(), err)
 	require.Equal(s.T(), meta.TotalCount, int64(1))
 	require.Len(s.T(), accList, 1)
+
+	// List of accounts by email
+	email := "TEST@example"
+	accList, meta, err = srv.List(AccountQuery{Email: &email, Page: 1, Per: 20})
+	require.Nil(s.T(), err)
+	require.Equal(s.T(), meta.TotalCount, int64(1))
+	require.Len(s.T(), accList, 1)
+
+	email = "nobody@example.com"
+	accList, meta, err = srv.List(AccountQuery{Email: &email, Page: 1, Per: 20})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), meta.TotalCount, int64(0))
+	assert.Empty(s.T(), accList)
 }
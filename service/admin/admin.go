@@ -0,0 +1,456 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/audit"
+	"github.com/alpacahq/gobroker/service/op"
+	"github.com/alpacahq/gobroker/service/order"
+	"github.com/alpacahq/gobroker/service/transfer"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+type AdminService interface {
+	ListAccounts(query account.AccountQuery) ([]models.Account, *account.PaginationMeta, error)
+	GetOwnerDetails(adminID, accountID uuid.UUID, unmask bool) (*models.OwnerDetails, error)
+	SetFlags(adminID, accountID uuid.UUID, flags AccountFlags) (*models.Account, error)
+	ListNotes(accountID uuid.UUID) ([]models.AdminNote, error)
+	CreateNote(adminID, accountID uuid.UUID, body string) (*models.AdminNote, error)
+	ApproveTransfer(adminID, accountID uuid.UUID, transferID string) (*models.Transfer, error)
+	GetWireInstructions(adminID, accountID uuid.UUID, transferID string) (*WireInstructions, error)
+	GetTransferLimits(accountID uuid.UUID) (map[apex.TransferDirection]TransferLimits, error)
+	SetTransferLimits(adminID, accountID uuid.UUID, override models.TransferLimitOverride) (*models.TransferLimitOverride, error)
+	ClearTransferLimits(adminID, accountID uuid.UUID, dir apex.TransferDirection) error
+	CancelOrder(adminID, accountID, orderID uuid.UUID) error
+	ListBatchErrors(processDate string, fileCode *string) ([]models.BatchError, error)
+	ListActions(accountID uuid.UUID) ([]models.AdminAction, error)
+	WithTx(tx *gorm.DB) AdminService
+}
+
+type adminService struct {
+	AdminService
+	tx     *gorm.DB
+	orders order.OrderService
+}
+
+// Service returns the admin service. The order service is
+// used to force-cancel orders on behalf of an account.
+func Service(orders order.OrderService) AdminService {
+	return &adminService{orders: orders}
+}
+
+func (s *adminService) WithTx(tx *gorm.DB) AdminService {
+	s.tx = tx
+	return s
+}
+
+// AccountFlags are the account level blocks, and the margin status,
+// an administrator is able to toggle. Nil values are left unchanged.
+type AccountFlags struct {
+	TradingBlocked *bool `json:"trading_blocked"`
+	AccountBlocked *bool `json:"account_blocked"`
+	// WithdrawalsBlocked is set when an unauthorized return flags
+	// the account for review.
+	WithdrawalsBlocked *bool `json:"withdrawals_blocked"`
+	// DepositsBlocked can only be used to lift a deposit block
+	// early, since the block is set with an expiry by returns.
+	DepositsBlocked *bool `json:"deposits_blocked"`
+	// Margin matches the account type at the clearing broker, as good
+	// faith violations only apply to cash accounts.
+	Margin *bool `json:"margin"`
+}
+
+func (s *adminService) ListAccounts(query account.AccountQuery) ([]models.Account, *account.PaginationMeta, error) {
+	return account.Service().WithTx(s.tx).List(query)
+}
+
+// GetOwnerDetails returns the primary owner's details for the account,
+// with the PII masked unless explicitly requested. Unmasked reads are
+// recorded since they expose sensitive data.
+func (s *adminService) GetOwnerDetails(adminID, accountID uuid.UUID, unmask bool) (*models.OwnerDetails, error) {
+	acct, err := op.GetAccountByID(s.tx, accountID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := acct.PrimaryOwner()
+	if owner == nil {
+		return nil, gberrors.InternalServerError.WithError(fmt.Errorf("primary owner is not associated with %v", accountID))
+	}
+
+	if !unmask {
+		masked := owner.Details.Masked()
+		return &masked, nil
+	}
+
+	if err = s.record(adminID, &accountID, models.AdminUnmaskDetails, map[string]interface{}{
+		"owner_details_id": owner.Details.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &owner.Details, nil
+}
+
+func (s *adminService) SetFlags(adminID, accountID uuid.UUID, flags AccountFlags) (*models.Account, error) {
+	forUpdate := db.ForUpdate
+
+	acct, err := op.GetAccountByID(s.tx, accountID, &forUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	patches := map[string]interface{}{}
+
+	if flags.TradingBlocked != nil {
+		patches["trading_blocked"] = *flags.TradingBlocked
+	}
+
+	if flags.AccountBlocked != nil {
+		patches["account_blocked"] = *flags.AccountBlocked
+	}
+
+	if flags.WithdrawalsBlocked != nil {
+		patches["withdrawals_blocked"] = *flags.WithdrawalsBlocked
+	}
+
+	if flags.DepositsBlocked != nil {
+		if *flags.DepositsBlocked {
+			return nil, gberrors.InvalidRequestParam.WithMsg("deposits_blocked can only be cleared")
+		}
+		patches["deposits_blocked_until"] = nil
+	}
+
+	if flags.Margin != nil {
+		patches["margin"] = *flags.Margin
+	}
+
+	if len(patches) == 0 {
+		return nil, gberrors.InvalidRequestParam.WithMsg(
+			"trading_blocked, account_blocked, withdrawals_blocked, deposits_blocked or margin is required")
+	}
+
+	before := audit.Snapshot(acct)
+
+	if err = s.tx.Model(acct).Updates(patches).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err = audit.Record(s.tx, audit.Entry{
+		AccountID:  acct.ID,
+		Resource:   models.AuditAccount,
+		ResourceID: acct.ID,
+		Before:     before,
+		After:      acct,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = s.record(adminID, &accountID, models.AdminSetFlags, patches); err != nil {
+		return nil, err
+	}
+
+	return acct, nil
+}
+
+func (s *adminService) ListNotes(accountID uuid.UUID) ([]models.AdminNote, error) {
+	notes := []models.AdminNote{}
+
+	if err := s.tx.
+		Where("account_id = ?", accountID.String()).
+		Order("created_at DESC").
+		Find(&notes).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return notes, nil
+}
+
+func (s *adminService) CreateNote(adminID, accountID uuid.UUID, body string) (*models.AdminNote, error) {
+	if body == "" {
+		return nil, gberrors.InvalidRequestParam.WithMsg("body is required")
+	}
+
+	if _, err := op.GetAccountByID(s.tx, accountID, nil); err != nil {
+		return nil, err
+	}
+
+	note := &models.AdminNote{
+		Body:      body,
+		AccountID: accountID.String(),
+		AdminID:   adminID.String(),
+	}
+
+	if err := s.tx.Create(note).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err := s.record(adminID, &accountID, models.AdminCreateNote, map[string]interface{}{
+		"note_id": note.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+// ApproveTransfer releases a transfer held for approval to the
+// funding worker by moving it to the queued status.
+func (s *adminService) ApproveTransfer(adminID, accountID uuid.UUID, transferID string) (*models.Transfer, error) {
+	transfer := &models.Transfer{}
+
+	q := s.tx.
+		Where("id = ? AND account_id = ?", transferID, accountID.String()).
+		Set("gorm:query_option", db.ForUpdate).
+		Find(transfer)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.NotFound.WithMsg("transfer not found")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	if transfer.Status != enum.TransferApprovalPending {
+		return nil, gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("transfer is not pending approval (status: %v)", transfer.Status))
+	}
+
+	before := audit.Snapshot(transfer)
+
+	if err := s.tx.Model(transfer).Update("status", enum.TransferQueued).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err := audit.Record(s.tx, audit.Entry{
+		AccountID:  transfer.AccountID,
+		Resource:   models.AuditTransfer,
+		ResourceID: transfer.ID,
+		Before:     before,
+		After:      transfer,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.record(adminID, &accountID, models.AdminApproveTransfer, map[string]interface{}{
+		"transfer_id": transfer.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// WireInstructions are what operations needs to send an outbound
+// wire with the bank.
+type WireInstructions struct {
+	Transfer    *models.Transfer        `json:"transfer"`
+	Beneficiary *models.WireBeneficiary `json:"beneficiary"`
+	BankInfo    *models.WireBankInfo    `json:"bank_info"`
+}
+
+// GetWireInstructions returns the decrypted beneficiary bank details
+// for an outbound wire. The read is recorded since it exposes the
+// full account and routing numbers.
+func (s *adminService) GetWireInstructions(adminID, accountID uuid.UUID, transferID string) (*WireInstructions, error) {
+	transfer := &models.Transfer{}
+
+	q := s.tx.
+		Where("id = ? AND account_id = ? AND type = ?", transferID, accountID.String(), enum.Wire).
+		Preload("Beneficiary").
+		Find(transfer)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.NotFound.WithMsg("wire transfer not found")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	if transfer.Beneficiary == nil {
+		return nil, gberrors.NotFound.WithMsg("wire transfer has no beneficiary")
+	}
+
+	info, err := transfer.Beneficiary.GetBankInfo()
+	if err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err = s.record(adminID, &accountID, models.AdminViewWire, map[string]interface{}{
+		"transfer_id":    transfer.ID,
+		"beneficiary_id": transfer.Beneficiary.ID,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &WireInstructions{
+		Transfer:    transfer,
+		Beneficiary: transfer.Beneficiary,
+		BankInfo:    info,
+	}, nil
+}
+
+// TransferLimits are the limits in effect for an account, along
+// with the admin override they came from, if any.
+type TransferLimits struct {
+	Limits   transfer.Limits               `json:"limits"`
+	Override *models.TransferLimitOverride `json:"override"`
+}
+
+func (s *adminService) GetTransferLimits(accountID uuid.UUID) (map[apex.TransferDirection]TransferLimits, error) {
+	acct, err := op.GetAccountByID(s.tx, accountID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := map[apex.TransferDirection]TransferLimits{}
+
+	for _, dir := range []apex.TransferDirection{apex.Incoming, apex.Outgoing} {
+		l, override, err := transfer.LimitsFor(s.tx, acct, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		limits[dir] = TransferLimits{Limits: l, Override: override}
+	}
+
+	return limits, nil
+}
+
+// SetTransferLimits overrides the account's tiered transfer limits in
+// one direction, replacing any existing override.
+func (s *adminService) SetTransferLimits(adminID, accountID uuid.UUID, override models.TransferLimitOverride) (*models.TransferLimitOverride, error) {
+	if override.Direction != apex.Incoming && override.Direction != apex.Outgoing {
+		return nil, gberrors.InvalidRequestParam.WithMsg("invalid transfer direction")
+	}
+
+	for _, l := range []*decimal.Decimal{override.Daily, override.Rolling30Day, override.PerRelationship} {
+		if l != nil && l.LessThan(decimal.Zero) {
+			return nil, gberrors.InvalidRequestParam.WithMsg("limits must be >= 0")
+		}
+	}
+
+	if _, err := op.GetAccountByID(s.tx, accountID, nil); err != nil {
+		return nil, err
+	}
+
+	existing := &models.TransferLimitOverride{}
+
+	q := s.tx.
+		Where("account_id = ? AND direction = ?", accountID.String(), override.Direction).
+		Set("gorm:query_option", db.ForUpdate).
+		Find(existing)
+
+	if q.Error != nil && !q.RecordNotFound() {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	override.ID = existing.ID
+	override.CreatedAt = existing.CreatedAt
+	override.AccountID = accountID.String()
+	override.AdminID = adminID.String()
+
+	if err := s.tx.Save(&override).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err := s.record(adminID, &accountID, models.AdminSetLimits, override); err != nil {
+		return nil, err
+	}
+
+	return &override, nil
+}
+
+func (s *adminService) ClearTransferLimits(adminID, accountID uuid.UUID, dir apex.TransferDirection) error {
+	q := s.tx.
+		Where("account_id = ? AND direction = ?", accountID.String(), dir).
+		Delete(&models.TransferLimitOverride{})
+
+	if q.Error != nil {
+		return gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	if q.RowsAffected == 0 {
+		return gberrors.NotFound.WithMsg("transfer limit override not found")
+	}
+
+	return s.record(adminID, &accountID, models.AdminClearLimits, map[string]interface{}{
+		"direction": dir,
+	})
+}
+
+// CancelOrder submits a cancel request for the order regardless of
+// market hours.
+func (s *adminService) CancelOrder(adminID, accountID, orderID uuid.UUID) error {
+	if err := s.orders.WithTx(s.tx).Cancel(accountID, orderID); err != nil {
+		return err
+	}
+
+	return s.record(adminID, &accountID, models.AdminCancelOrder, map[string]interface{}{
+		"order_id": orderID.String(),
+	})
+}
+
+func (s *adminService) ListBatchErrors(processDate string, fileCode *string) ([]models.BatchError, error) {
+	errs := []models.BatchError{}
+
+	q := s.tx.Where("process_date = ?", processDate)
+
+	if fileCode != nil {
+		q = q.Where("file_code = ?", *fileCode)
+	}
+
+	if err := q.Order("file_code, primary_record_identifier").Find(&errs).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return errs, nil
+}
+
+func (s *adminService) ListActions(accountID uuid.UUID) ([]models.AdminAction, error) {
+	actions := []models.AdminAction{}
+
+	if err := s.tx.
+		Where("account_id = ?", accountID.String()).
+		Order("created_at DESC").
+		Find(&actions).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return actions, nil
+}
+
+func (s *adminService) record(adminID uuid.UUID, accountID *uuid.UUID, t models.AdminActionType, payload interface{}) error {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	action := &models.AdminAction{
+		AdminID: adminID.String(),
+		Type:    t,
+		Payload: buf,
+	}
+
+	if accountID != nil {
+		id := accountID.String()
+		action.AccountID = &id
+	}
+
+	if err = s.tx.Create(action).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package admin
+
+import (
+	"testing"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/utils/address"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AdminTestSuite struct {
+	dbtest.Suite
+	account *models.Account
+	adminID uuid.UUID
+}
+
+func TestAdminTestSuite(t *testing.T) {
+	suite.Run(t, new(AdminTestSuite))
+}
+
+func (s *AdminTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	apexAcct := "apca_test"
+	legalName := "First Last"
+	phone := "555-555-1234"
+	dob := "1980-01-01"
+	postal := "94105"
+	s.account = &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			models.Owner{
+				Email:   "trader@test.db",
+				Primary: true,
+				Details: models.OwnerDetails{
+					LegalName:     &legalName,
+					PhoneNumber:   &phone,
+					DateOfBirth:   &dob,
+					PostalCode:    &postal,
+					StreetAddress: address.Address{"123 Somewhere Ln"},
+				},
+			},
+		},
+	}
+	if err := db.DB().Create(s.account).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+
+	admin := &models.Administrator{
+		Email: "admin@test.db",
+		Name:  "Admin",
+	}
+	if err := db.DB().Create(admin).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+	s.adminID = admin.IDAsUUID()
+}
+
+func (s *AdminTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *AdminTestSuite) TestListAccounts() {
+	srv := adminService{tx: db.DB()}
+
+	email := "trader@"
+	accounts, meta, err := srv.ListAccounts(account.AccountQuery{Email: &email, Page: 1, Per: 10})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(1), meta.TotalCount)
+	require.Len(s.T(), accounts, 1)
+	assert.Equal(s.T(), s.account.ID, accounts[0].ID)
+}
+
+func (s *AdminTestSuite) TestOwnerDetails() {
+	srv := adminService{tx: db.DB()}
+
+	details, err := srv.GetOwnerDetails(s.adminID, s.account.IDAsUUID(), false)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "xxxxxxxx1234", *details.PhoneNumber)
+	assert.NotContains(s.T(), *details.DateOfBirth, "1980")
+	assert.Equal(s.T(), "xxxxx", *details.PostalCode)
+	assert.Equal(s.T(), "Fxxxxxxxxx", *details.LegalName)
+
+	details, err = srv.GetOwnerDetails(s.adminID, s.account.IDAsUUID(), true)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "555-555-1234", *details.PhoneNumber)
+	assert.Equal(s.T(), "First Last", *details.LegalName)
+
+	actions, err := srv.ListActions(s.account.IDAsUUID())
+	require.Nil(s.T(), err)
+	require.NotEmpty(s.T(), actions)
+	assert.Equal(s.T(), models.AdminUnmaskDetails, actions[0].Type)
+}
+
+func (s *AdminTestSuite) TestSetFlags() {
+	srv := adminService{tx: db.DB()}
+
+	_, err := srv.SetFlags(s.adminID, s.account.IDAsUUID(), AccountFlags{})
+	assert.NotNil(s.T(), err)
+
+	blocked := true
+	acct, err := srv.SetFlags(s.adminID, s.account.IDAsUUID(), AccountFlags{TradingBlocked: &blocked})
+	require.Nil(s.T(), err)
+	assert.True(s.T(), acct.TradingBlocked)
+	assert.False(s.T(), acct.AccountBlocked)
+
+	blocked = false
+	acct, err = srv.SetFlags(s.adminID, s.account.IDAsUUID(), AccountFlags{TradingBlocked: &blocked})
+	require.Nil(s.T(), err)
+	assert.False(s.T(), acct.TradingBlocked)
+
+	actions, err := srv.ListActions(s.account.IDAsUUID())
+	require.Nil(s.T(), err)
+	count := 0
+	for _, a := range actions {
+		if a.Type == models.AdminSetFlags {
+			count++
+		}
+	}
+	assert.Equal(s.T(), 2, count)
+}
+
+func (s *AdminTestSuite) TestNotes() {
+	srv := adminService{tx: db.DB()}
+
+	_, err := srv.CreateNote(s.adminID, s.account.IDAsUUID(), "")
+	assert.NotNil(s.T(), err)
+
+	note, err := srv.CreateNote(s.adminID, s.account.IDAsUUID(), "called the customer")
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), s.adminID.String(), note.AdminID)
+
+	notes, err := srv.ListNotes(s.account.IDAsUUID())
+	require.Nil(s.T(), err)
+	require.Len(s.T(), notes, 1)
+	assert.Equal(s.T(), "called the customer", notes[0].Body)
+}
+
+func (s *AdminTestSuite) TestApproveTransfer() {
+	srv := adminService{tx: db.DB()}
+
+	transfer := &models.Transfer{
+		AccountID: s.account.ID,
+		Type:      enum.ACH,
+		Status:    enum.TransferApprovalPending,
+		Direction: apex.Outgoing,
+		Amount:    decimal.NewFromFloat(500),
+	}
+	require.Nil(s.T(), db.DB().Create(transfer).Error)
+
+	t, err := srv.ApproveTransfer(s.adminID, s.account.IDAsUUID(), transfer.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.TransferQueued, t.Status)
+
+	// already approved
+	_, err = srv.ApproveTransfer(s.adminID, s.account.IDAsUUID(), transfer.ID)
+	assert.NotNil(s.T(), err)
+
+	_, err = srv.ApproveTransfer(s.adminID, s.account.IDAsUUID(), uuid.Must(uuid.NewV4()).String())
+	assert.NotNil(s.T(), err)
+}
+
+func (s *AdminTestSuite) TestBatchErrors() {
+	srv := adminService{tx: db.DB()}
+
+	require.Nil(s.T(), db.DB().Create(&models.BatchError{
+		ProcessDate:             "2019-01-02",
+		FileCode:                "EXT871",
+		PrimaryRecordIdentifier: "3AP00001",
+		Error:                   []byte(`{"error":"bad"}`),
+	}).Error)
+
+	errs, err := srv.ListBatchErrors("2019-01-02", nil)
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), errs, 1)
+
+	code := "EXT235"
+	errs, err = srv.ListBatchErrors("2019-01-02", &code)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), errs)
+}
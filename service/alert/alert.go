@@ -0,0 +1,231 @@
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/alpacahq/gobroker/external/webhook"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// MaxAlerts is the most active alerts an account can have.
+const MaxAlerts = 50
+
+type AlertService interface {
+	List(accountID uuid.UUID, active *bool) ([]models.Alert, error)
+	GetByID(accountID uuid.UUID, id string) (*models.Alert, error)
+	Create(accountID uuid.UUID, req AlertRequest) (*models.Alert, error)
+	Delete(accountID uuid.UUID, id string) error
+	Active() ([]models.Alert, error)
+	Trigger(a *models.Alert, px decimal.Decimal) (bool, error)
+	WithTx(tx *gorm.DB) AlertService
+}
+
+type alertService struct {
+	AlertService
+	tx         *gorm.DB
+	assetcache assetcache.AssetCache
+}
+
+func Service(assetcache assetcache.AssetCache) AlertService {
+	return &alertService{assetcache: assetcache}
+}
+
+func (s *alertService) WithTx(tx *gorm.DB) AlertService {
+	s.tx = tx
+	return s
+}
+
+// AlertRequest creates an alert. Price alerts take a price, and
+// pct_change alerts a signed percent from the previous close.
+type AlertRequest struct {
+	Symbol     string           `json:"symbol"`
+	Type       enum.AlertType   `json:"type"`
+	Price      *decimal.Decimal `json:"price"`
+	Percent    *decimal.Decimal `json:"percent"`
+	WebhookURL *string          `json:"webhook_url"`
+}
+
+func (r *AlertRequest) Verify() error {
+	if r.Symbol == "" {
+		return gberrors.InvalidRequestParam.WithMsg("symbol is required")
+	}
+
+	switch r.Type {
+	case enum.AlertAbove, enum.AlertBelow:
+		if r.Price == nil || !r.Price.IsPositive() {
+			return gberrors.InvalidRequestParam.WithMsg("price must be > 0")
+		}
+		if r.Percent != nil {
+			return gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("percent is not allowed for %v alerts", r.Type))
+		}
+	case enum.AlertPctChange:
+		if r.Percent == nil || r.Percent.IsZero() {
+			return gberrors.InvalidRequestParam.WithMsg("percent must not be 0")
+		}
+		if r.Price != nil {
+			return gberrors.InvalidRequestParam.WithMsg("price is not allowed for pct_change alerts")
+		}
+	default:
+		return gberrors.InvalidRequestParam.WithMsg("type must be one of above, below or pct_change")
+	}
+
+	if r.WebhookURL != nil {
+		if err := webhook.Verify(*r.WebhookURL); err != nil {
+			return gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("invalid webhook_url (%v)", err))
+		}
+	}
+
+	return nil
+}
+
+func (s *alertService) List(accountID uuid.UUID, active *bool) ([]models.Alert, error) {
+	alerts := []models.Alert{}
+
+	q := s.tx.Where("account_id = ?", accountID.String())
+
+	if active != nil {
+		q = q.Where("active = ?", *active)
+	}
+
+	if err := q.Order("created_at DESC").Find(&alerts).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return alerts, nil
+}
+
+func (s *alertService) GetByID(accountID uuid.UUID, id string) (*models.Alert, error) {
+	if _, err := uuid.FromString(id); err != nil {
+		return nil, gberrors.InvalidRequestParam.WithMsg("alert_id must be a valid uuid")
+	}
+
+	a := &models.Alert{}
+
+	q := s.tx.Where("id = ? AND account_id = ?", id, accountID.String()).Find(a)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.NotFound.WithMsg("alert not found")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	return a, nil
+}
+
+func (s *alertService) Create(accountID uuid.UUID, req AlertRequest) (*models.Alert, error) {
+	if err := req.Verify(); err != nil {
+		return nil, err
+	}
+
+	asset := s.assetcache.Get(strings.TrimSpace(req.Symbol))
+	if asset == nil {
+		return nil, gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("asset not found for %v", req.Symbol))
+	}
+
+	count := 0
+
+	if err := s.tx.Model(&models.Alert{}).
+		Where("account_id = ? AND active = ?", accountID.String(), true).
+		Count(&count).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if count >= MaxAlerts {
+		return nil, gberrors.Forbidden.WithMsg(
+			fmt.Sprintf("accounts are limited to %v active alerts", MaxAlerts))
+	}
+
+	a := &models.Alert{
+		AccountID:  accountID.String(),
+		AssetID:    asset.ID,
+		Symbol:     asset.Symbol,
+		Type:       req.Type,
+		Price:      req.Price,
+		Percent:    req.Percent,
+		WebhookURL: req.WebhookURL,
+		Active:     true,
+	}
+
+	if a.WebhookURL != nil {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, gberrors.InternalServerError.WithError(err)
+		}
+		secret := hex.EncodeToString(buf)
+		a.WebhookSecret = &secret
+	}
+
+	if err := s.tx.Create(a).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return a, nil
+}
+
+func (s *alertService) Delete(accountID uuid.UUID, id string) error {
+	a, err := s.GetByID(accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if err = s.tx.Delete(a).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return nil
+}
+
+// Active returns every alert which hasn't fired yet.
+func (s *alertService) Active() ([]models.Alert, error) {
+	alerts := []models.Alert{}
+
+	if err := s.tx.Where("active = ?", true).Find(&alerts).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return alerts, nil
+}
+
+// Trigger deactivates the alert, recording the price it fired at. It
+// returns false if the alert was deleted or already fired elsewhere, so
+// an alert is only ever delivered once.
+func (s *alertService) Trigger(a *models.Alert, px decimal.Decimal) (bool, error) {
+	now := clock.Now()
+
+	q := s.tx.Model(&models.Alert{}).
+		Where("id = ? AND active = ?", a.ID, true).
+		Updates(map[string]interface{}{
+			"active":          false,
+			"triggered_at":    now,
+			"triggered_price": px,
+		})
+
+	if q.Error != nil {
+		return false, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	if q.RowsAffected == 0 {
+		return false, nil
+	}
+
+	a.Active = false
+	a.TriggeredAt = &now
+	a.TriggeredPrice = &px
+
+	return true, nil
+}
@@ -0,0 +1,158 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AlertTestSuite struct {
+	dbtest.Suite
+	accountID uuid.UUID
+}
+
+func TestAlertTestSuite(t *testing.T) {
+	suite.Run(t, new(AlertTestSuite))
+}
+
+func (s *AlertTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	asset := &models.Asset{
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NASDAQ",
+		Symbol:   "AAPL",
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	if err := db.DB().Create(asset).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+
+	s.accountID = uuid.Must(uuid.NewV4())
+}
+
+func (s *AlertTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *AlertTestSuite) service() AlertService {
+	return Service(assetcache.GetAssetCache()).WithTx(db.DB())
+}
+
+func (s *AlertTestSuite) TestAlert() {
+	price := decimal.NewFromFloat(200)
+	hook := "https://203.0.113.10/hook"
+
+	a, err := s.service().Create(s.accountID, AlertRequest{
+		Symbol:     "AAPL",
+		Type:       enum.AlertAbove,
+		Price:      &price,
+		WebhookURL: &hook,
+	})
+	require.Nil(s.T(), err)
+	assert.True(s.T(), a.Active)
+	assert.Equal(s.T(), "AAPL", a.Symbol)
+	require.NotNil(s.T(), a.WebhookSecret)
+	assert.Len(s.T(), *a.WebhookSecret, 64)
+
+	// bad requests
+	for _, req := range []AlertRequest{
+		{Symbol: "AAPL", Type: enum.AlertBelow},
+		{Symbol: "AAPL", Type: enum.AlertPctChange, Price: &price},
+		{Symbol: "AAPL", Type: "sideways", Price: &price},
+		{Symbol: "ZZZZ", Type: enum.AlertAbove, Price: &price},
+	} {
+		_, err = s.service().Create(s.accountID, req)
+		assert.NotNil(s.T(), err)
+	}
+
+	// webhooks have to be https, and outside our network
+	for _, hook := range []string{
+		"http://203.0.113.10/hook",
+		"https://127.0.0.1/hook",
+		"https://10.0.0.1/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/hook",
+	} {
+		_, err = s.service().Create(s.accountID, AlertRequest{
+			Symbol: "AAPL", Type: enum.AlertAbove, Price: &price, WebhookURL: &hook,
+		})
+		assert.NotNil(s.T(), err, hook)
+	}
+
+	active, err := s.service().Active()
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), active, 1)
+
+	// alerts only fire once
+	fired, err := s.service().Trigger(a, decimal.NewFromFloat(201))
+	require.Nil(s.T(), err)
+	assert.True(s.T(), fired)
+	assert.False(s.T(), a.Active)
+	require.NotNil(s.T(), a.TriggeredPrice)
+
+	fired, err = s.service().Trigger(a, decimal.NewFromFloat(202))
+	require.Nil(s.T(), err)
+	assert.False(s.T(), fired)
+
+	inactive := false
+	alerts, err := s.service().List(s.accountID, &inactive)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), alerts, 1)
+	assert.True(s.T(), alerts[0].TriggeredPrice.Equal(decimal.NewFromFloat(201)))
+
+	require.Nil(s.T(), s.service().Delete(s.accountID, a.ID))
+
+	_, err = s.service().GetByID(s.accountID, a.ID)
+	assert.NotNil(s.T(), err)
+}
+
+func (s *AlertTestSuite) TestMaxAlerts() {
+	accountID := uuid.Must(uuid.NewV4())
+	price := decimal.NewFromFloat(1)
+	req := AlertRequest{Symbol: "AAPL", Type: enum.AlertBelow, Price: &price}
+
+	for i := 0; i < MaxAlerts; i++ {
+		_, err := s.service().Create(accountID, req)
+		require.Nil(s.T(), err)
+	}
+
+	_, err := s.service().Create(accountID, req)
+	assert.NotNil(s.T(), err)
+}
+
+func TestCrossed(t *testing.T) {
+	price := decimal.NewFromFloat(100)
+	down := decimal.NewFromFloat(-5)
+	up := decimal.NewFromFloat(5)
+	prevClose := decimal.NewFromFloat(100)
+
+	above := &models.Alert{Type: enum.AlertAbove, Price: &price}
+	assert.True(t, above.Crossed(decimal.NewFromFloat(100), prevClose))
+	assert.False(t, above.Crossed(decimal.NewFromFloat(99.99), prevClose))
+
+	below := &models.Alert{Type: enum.AlertBelow, Price: &price}
+	assert.True(t, below.Crossed(decimal.NewFromFloat(99.5), prevClose))
+	assert.False(t, below.Crossed(decimal.NewFromFloat(100.01), prevClose))
+
+	drop := &models.Alert{Type: enum.AlertPctChange, Percent: &down}
+	assert.True(t, drop.Crossed(decimal.NewFromFloat(95), prevClose))
+	assert.False(t, drop.Crossed(decimal.NewFromFloat(96), prevClose))
+	assert.False(t, drop.Crossed(decimal.NewFromFloat(110), prevClose))
+	// no previous close to measure from
+	assert.False(t, drop.Crossed(decimal.NewFromFloat(50), decimal.Zero))
+
+	rise := &models.Alert{Type: enum.AlertPctChange, Percent: &up}
+	assert.True(t, rise.Crossed(decimal.NewFromFloat(105.5), prevClose))
+	assert.False(t, rise.Crossed(decimal.NewFromFloat(90), prevClose))
+}
@@ -0,0 +1,306 @@
+package algo
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/bar"
+	"github.com/alpacahq/gobroker/service/order"
+	"github.com/alpacahq/gobroker/service/tradeaccount"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/env"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// MaxRejections is how many child orders in a row can be rejected before
+// the algo order is canceled, rather than sending one which won't go
+// through on every run until the window is over.
+const MaxRejections = 3
+
+type AlgoService interface {
+	Working() ([]models.Order, error)
+	Work(parent *models.Order) error
+	WithTx(tx *gorm.DB) AlgoService
+}
+
+type algoService struct {
+	AlgoService
+	tx     *gorm.DB
+	orders order.OrderService
+	bars   bar.BarService
+}
+
+func Service(orders order.OrderService, bars bar.BarService) AlgoService {
+	return &algoService{
+		orders: orders,
+		bars:   bars,
+	}
+}
+
+func (s *algoService) WithTx(tx *gorm.DB) AlgoService {
+	s.tx = tx
+	return s
+}
+
+// Working returns the algo orders which are still sending child orders.
+func (s *algoService) Working() ([]models.Order, error) {
+	orders := []models.Order{}
+
+	if err := s.tx.
+		Where("type = ? AND status = ?", enum.Algo, enum.OrderWorking).
+		Order("submitted_at, id").
+		Find(&orders).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return orders, nil
+}
+
+// Work sends the next child order for an algo order, if there's one
+// due, and expires the algo order once its window is over. The child
+// orders are limit orders at the algo order's limit price, or market
+// orders if it has none, and go through the same checks as any other.
+func (s *algoService) Work(parent *models.Order) error {
+	now := clock.Now()
+
+	acct, err := tradeaccount.Service().WithTx(s.tx).GetByApexAccount(parent.Account)
+	if err != nil {
+		return err
+	}
+
+	children := []models.Order{}
+
+	if err = s.tx.
+		Where("parent_id = ?", parent.ID).
+		Order("submitted_at, id").
+		Find(&children).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	if !now.Before(*parent.EndAt) {
+		return s.expire(acct.IDAsUUID(), parent, children)
+	}
+
+	if now.Before(*parent.StartAt) {
+		return nil
+	}
+
+	if Rejections(children) >= MaxRejections {
+		return s.orders.WithTx(s.tx).Cancel(acct.IDAsUUID(), parent.IDAsUUID())
+	}
+
+	qty, err := s.nextQty(parent, children, now)
+	if err != nil {
+		return err
+	}
+
+	if !qty.IsPositive() {
+		return nil
+	}
+
+	child := &models.Order{
+		AssetID:       parent.AssetID,
+		Qty:           qty,
+		Side:          parent.Side,
+		Type:          enum.Market,
+		TimeInForce:   enum.Day,
+		OrderCapacity: enum.Agency,
+		ParentID:      &parent.ID,
+	}
+
+	if parent.LimitPrice != nil {
+		px := *parent.LimitPrice
+		child.Type = enum.Limit
+		child.LimitPrice = &px
+	}
+
+	child.SetSymbol(parent.GetSymbol())
+
+	if _, err = s.orders.WithTx(s.tx).Create(acct.IDAsUUID(), child); err != nil && order.Rejects(err) {
+		// a child order which doesn't pass its checks is kept as
+		// rejected, so it counts toward the rejections in a row
+		now := clock.Now()
+		child.Status = enum.OrderRejected
+		child.ClientOrderType = child.Type
+		child.Account = parent.Account
+		child.SubmittedAt = now
+		child.FailedAt = &now
+
+		if cerr := s.tx.Create(child).Error; cerr != nil {
+			return gberrors.InternalServerError.WithError(cerr)
+		}
+	}
+
+	return err
+}
+
+// nextQty is the quantity the schedule has due by the end of the
+// current minute, less what's been sent already, and capped at the
+// participation limit of the volume traded since the last child order.
+func (s *algoService) nextQty(parent *models.Order, children []models.Order, now time.Time) (decimal.Decimal, error) {
+	d, err := tradingdate.New(parent.StartAt.In(calendar.NY))
+	if err != nil {
+		return decimal.Zero, gberrors.InternalServerError.WithError(err)
+	}
+
+	profile := Profile{}
+
+	if *parent.Strategy == enum.VWAP {
+		if profile, err = s.profile(parent, *d); err != nil {
+			return decimal.Zero, err
+		}
+	}
+
+	due := parent.Qty.Mul(Due(*parent.Strategy, profile, d.MarketOpen(), *parent.StartAt, *parent.EndAt, now)).Floor()
+
+	sent := Sent(children)
+	qty := decimal.Min(due, parent.Qty).Sub(sent)
+
+	if parent.MaxParticipation == nil || !qty.IsPositive() {
+		return qty, nil
+	}
+
+	since := *parent.StartAt
+	if len(children) > 0 {
+		since = children[len(children)-1].SubmittedAt
+	}
+
+	volume, err := s.volume(parent, since, now)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return decimal.Min(qty, parent.MaxParticipation.Mul(volume).Floor()), nil
+}
+
+// Sent returns the quantity of the child orders which is filled or still
+// working. What's left of canceled, expired and rejected children can be
+// sent again.
+func Sent(children []models.Order) decimal.Decimal {
+	sent := decimal.Zero
+
+	for _, c := range children {
+		switch c.Status {
+		case enum.OrderCanceled, enum.OrderExpired, enum.OrderRejected:
+			if c.FilledQty != nil {
+				sent = sent.Add(*c.FilledQty)
+			}
+		default:
+			sent = sent.Add(c.Qty)
+		}
+	}
+
+	return sent
+}
+
+// Rejections returns how many of the latest child orders were rejected
+// in a row.
+func Rejections(children []models.Order) int {
+	n := 0
+	for i := len(children) - 1; i >= 0 && children[i].Status == enum.OrderRejected; i-- {
+		n++
+	}
+	return n
+}
+
+// expire cancels the child orders still working at the end of the
+// window, and expires the algo order unless it filled.
+func (s *algoService) expire(accountID uuid.UUID, parent *models.Order, children []models.Order) error {
+	tx := s.tx.Begin()
+
+	for _, c := range children {
+		if !open(c.Status) || c.CancelRequestedAt != nil {
+			continue
+		}
+
+		if err := s.orders.WithTx(tx).Cancel(accountID, c.IDAsUUID()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	now := clock.Now()
+
+	if err := tx.Model(parent).
+		Where("status = ?", enum.OrderWorking).
+		Updates(models.Order{Status: enum.OrderExpired, ExpiredAt: &now}).Error; err != nil {
+		tx.Rollback()
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return tx.Commit().Error
+}
+
+func open(status enum.OrderStatus) bool {
+	for _, s := range enum.OrderOpen {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *algoService) volume(parent *models.Order, since, now time.Time) (decimal.Decimal, error) {
+	bars, err := s.bars.GetByID(uuid.FromStringOrNil(parent.AssetID), "1Min", &since, &now, nil)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	volume := int64(0)
+	for _, b := range bars.Bars {
+		volume += int64(b.Volume)
+	}
+
+	return decimal.New(volume, 0), nil
+}
+
+var profiles = struct {
+	sync.Mutex
+	date string
+	m    map[string]Profile
+}{m: map[string]Profile{}}
+
+// profile returns the symbol's volume profile over the ALGO_PROFILE_DAYS
+// sessions before d, which is only built once a day.
+func (s *algoService) profile(parent *models.Order, d tradingdate.TradingDate) (Profile, error) {
+	profiles.Lock()
+	defer profiles.Unlock()
+
+	if profiles.date != d.String() {
+		profiles.date = d.String()
+		profiles.m = map[string]Profile{}
+	}
+
+	if p, ok := profiles.m[parent.AssetID]; ok {
+		return p, nil
+	}
+
+	days, err := strconv.Atoi(env.GetVar("ALGO_PROFILE_DAYS"))
+	if err != nil || days <= 0 {
+		return nil, gberrors.InternalServerError.WithMsg(
+			fmt.Sprintf("invalid ALGO_PROFILE_DAYS %q", env.GetVar("ALGO_PROFILE_DAYS")))
+	}
+
+	start := d.DaysAgo(days).MarketOpen()
+	end := d.Prev().MarketClose()
+	limit := days * 24 * 60
+
+	bars, err := s.bars.GetByID(uuid.FromStringOrNil(parent.AssetID), "1Min", &start, &end, &limit)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewProfile(bars.Bars)
+	profiles.m[parent.AssetID] = p
+
+	return p, nil
+}
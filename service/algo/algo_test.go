@@ -0,0 +1,87 @@
+package algo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/bar"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProfile(t *testing.T) {
+	day := func(d, h, m int) time.Time {
+		return time.Date(2019, 2, d, h, m, 0, 0, calendar.NY)
+	}
+
+	profile := NewProfile(bar.Bars{
+		// pre-market and after-hours are left out
+		{Volume: 1000, Time: day(13, 9, 0)},
+		{Volume: 1000, Time: day(13, 16, 0)},
+		{Volume: 300, Time: day(13, 9, 30)},
+		{Volume: 100, Time: day(13, 9, 32)},
+		{Volume: 200, Time: day(14, 9, 30)},
+		// no session on the weekend
+		{Volume: 1000, Time: day(16, 9, 30)},
+	})
+
+	assert.Equal(t, Profile{500, 0, 100}, profile)
+}
+
+func TestDue(t *testing.T) {
+	open := time.Date(2019, 2, 14, 9, 30, 0, 0, calendar.NY)
+	start := open.Add(10 * time.Minute)
+	end := open.Add(20 * time.Minute)
+
+	// the current minute is due at its start
+	assert.True(t, Due(enum.TWAP, nil, open, start, end, start.Add(-time.Second)).Equal(decimal.Zero))
+	assert.True(t, Due(enum.TWAP, nil, open, start, end, start).Equal(decimal.NewFromFloat(0.1)))
+	assert.True(t, Due(enum.TWAP, nil, open, start, end, start.Add(4*time.Minute+30*time.Second)).Equal(decimal.NewFromFloat(0.5)))
+	assert.True(t, Due(enum.TWAP, nil, open, start, end, end.Add(-time.Second)).Equal(decimal.New(1, 0)))
+	assert.True(t, Due(enum.TWAP, nil, open, start, end, end).Equal(decimal.New(1, 0)))
+
+	// all of the volume in the window trades in its first two minutes
+	profile := make(Profile, 30)
+	profile[0] = 1000
+	profile[10] = 300
+	profile[11] = 100
+
+	assert.True(t, Due(enum.VWAP, profile, open, start, end, start).Equal(decimal.NewFromFloat(0.75)))
+	assert.True(t, Due(enum.VWAP, profile, open, start, end, start.Add(time.Minute)).Equal(decimal.New(1, 0)))
+
+	// no volume falls back to TWAP
+	assert.True(t, Due(enum.VWAP, Profile{}, open, start, end, start).Equal(decimal.NewFromFloat(0.1)))
+}
+
+func TestSent(t *testing.T) {
+	qty := func(q int64) decimal.Decimal {
+		return decimal.New(q, 0)
+	}
+	full := qty(10)
+	partial := qty(3)
+
+	children := []models.Order{
+		{Qty: qty(10), Status: enum.OrderFilled, FilledQty: &full},
+		{Qty: qty(10), Status: enum.OrderNew},
+		{Qty: qty(10), Status: enum.OrderCanceled, FilledQty: &partial},
+		{Qty: qty(10), Status: enum.OrderRejected},
+	}
+
+	assert.True(t, Sent(children).Equal(qty(23)))
+}
+
+func TestRejections(t *testing.T) {
+	children := []models.Order{
+		{Status: enum.OrderRejected},
+		{Status: enum.OrderFilled},
+		{Status: enum.OrderRejected},
+		{Status: enum.OrderRejected},
+	}
+
+	assert.Equal(t, 2, Rejections(children))
+	assert.Equal(t, 0, Rejections(children[:2]))
+	assert.Equal(t, 0, Rejections(nil))
+}
@@ -0,0 +1,96 @@
+package algo
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/bar"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/shopspring/decimal"
+)
+
+// Profile is the volume typically traded in each minute of the regular
+// session, indexed by minutes after the open.
+type Profile []float64
+
+// NewProfile adds up the volume of past sessions' minute bars by the
+// minute of the session they fall in. Bars outside the regular session
+// are left out.
+func NewProfile(bars bar.Bars) Profile {
+	profile := Profile{}
+
+	for _, b := range bars {
+		d, err := tradingdate.New(b.Time)
+		if err != nil {
+			continue
+		}
+
+		if b.Time.Before(d.MarketOpen()) || !b.Time.Before(d.MarketClose()) {
+			continue
+		}
+
+		m := minute(d.MarketOpen(), b.Time)
+
+		for len(profile) <= m {
+			profile = append(profile, 0)
+		}
+
+		profile[m] += float64(b.Volume)
+	}
+
+	return profile
+}
+
+// weight is how much of the window's quantity is due in minute m.
+func (p Profile) weight(strategy enum.AlgoStrategy, m int) float64 {
+	if strategy == enum.TWAP {
+		return 1
+	}
+
+	if m < len(p) {
+		return p[m]
+	}
+
+	return 0
+}
+
+// Due returns the share of an algo order's quantity which should have
+// been sent by the end of the current minute, for a window from start
+// to end in the session opening at open. VWAP windows the profile has
+// no volume for are spread evenly instead, the same as TWAP.
+func Due(strategy enum.AlgoStrategy, profile Profile, open, start, end, now time.Time) decimal.Decimal {
+	if !now.Before(end) {
+		return decimal.New(1, 0)
+	}
+
+	if now.Before(start) {
+		return decimal.Zero
+	}
+
+	first := minute(open, start)
+	last := minute(open, end.Add(-time.Nanosecond))
+	current := minute(open, now)
+
+	var due, total float64
+
+	for m := first; m <= last; m++ {
+		w := profile.weight(strategy, m)
+		total += w
+		if m <= current {
+			due += w
+		}
+	}
+
+	if total == 0 {
+		if strategy == enum.TWAP {
+			return decimal.New(1, 0)
+		}
+		return Due(enum.TWAP, profile, open, start, end, now)
+	}
+
+	return decimal.NewFromFloat(due / total)
+}
+
+func minute(open, t time.Time) int {
+	return int(t.Sub(open) / time.Minute)
+}
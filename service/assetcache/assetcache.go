@@ -40,6 +40,7 @@ type assetCache struct {
 type AssetCache interface {
 	Get(string) *models.Asset
 	GetByID(uuid.UUID) *models.Asset
+	Search(query string) []*models.Asset
 }
 
 var globalCache AssetCache
@@ -29,6 +29,7 @@ func loadAssetsMock() ([]*models.Asset, error) {
 			Class:    "us_equity",
 			Exchange: "NASDAQ",
 			Symbol:   "AAPL",
+			Name:     "Apple Inc.",
 			Status:   enum.AssetActive,
 			Tradable: true,
 		},
@@ -37,9 +38,28 @@ func loadAssetsMock() ([]*models.Asset, error) {
 			Class:    "us_equity",
 			Exchange: "NYSE",
 			Symbol:   "BAC",
+			Name:     "Bank of America Corporation",
 			Status:   enum.AssetInactive,
 			Tradable: false,
 		},
+		&models.Asset{
+			ID:       "asset-3",
+			Class:    "us_equity",
+			Exchange: "NYSE",
+			Symbol:   "AAP",
+			Name:     "Advance Auto Parts Inc",
+			Status:   enum.AssetActive,
+			Tradable: true,
+		},
+		&models.Asset{
+			ID:       "asset-4",
+			Class:    "us_equity",
+			Exchange: "NASDAQ",
+			Symbol:   "MSFT",
+			Name:     "Microsoft Corporation",
+			Status:   enum.AssetActive,
+			Tradable: true,
+		},
 	}, nil
 }
 
@@ -76,3 +96,30 @@ func (s *TestSuite) TestAssetCache() {
 	a = GetByID(id)
 	assert.Nil(s.T(), a)
 }
+
+func (s *TestSuite) TestSearch() {
+	symbols := func(assets []*models.Asset) []string {
+		syms := []string{}
+		for _, a := range assets {
+			syms = append(syms, a.Symbol)
+		}
+		return syms
+	}
+
+	// exact symbol first, then the longer prefix
+	assert.Equal(s.T(), []string{"AAP", "AAPL"}, symbols(Search("aap")))
+
+	// name words by prefix
+	assert.Equal(s.T(), []string{"MSFT"}, symbols(Search("micro")))
+	assert.Equal(s.T(), []string{"BAC"}, symbols(Search("america")))
+
+	// exact matches before typos
+	assert.Equal(s.T(), []string{"AAPL", "AAP"}, symbols(Search("AAPL")))
+
+	// a typo in the symbol or the name
+	assert.Equal(s.T(), []string{"AAPL"}, symbols(Search("APPL")))
+	assert.Equal(s.T(), []string{"MSFT"}, symbols(Search("mircosoft")))
+
+	assert.Len(s.T(), Search(""), 0)
+	assert.Len(s.T(), Search("zzzz"), 0)
+}
@@ -0,0 +1,149 @@
+package assetcache
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alpacahq/gobroker/models"
+)
+
+// match ranks how well an asset matches a search, best first.
+type match int
+
+const (
+	matchSymbol match = iota
+	matchSymbolPrefix
+	matchNamePrefix
+	matchSymbolFuzzy
+	matchNameFuzzy
+	noMatch
+)
+
+// Search returns the assets whose symbol or name match the query, best
+// matches first. Symbols and the words of names
+// match by prefix, or fuzzily with a typo, so "APPL" finds AAPL and
+// "micro" finds Microsoft.
+func (l *assetCache) Search(query string) []*models.Asset {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	if query == "" {
+		return []*models.Asset{}
+	}
+
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	type result struct {
+		asset *models.Asset
+		match match
+	}
+
+	results := []result{}
+
+	for _, asset := range l.assets {
+		if m := matchAsset(asset, query); m != noMatch {
+			results = append(results, result{asset: asset, match: m})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].match != results[j].match {
+			return results[i].match < results[j].match
+		}
+		// shorter symbols are the likelier ones for a prefix
+		if len(results[i].asset.Symbol) != len(results[j].asset.Symbol) {
+			return len(results[i].asset.Symbol) < len(results[j].asset.Symbol)
+		}
+		return results[i].asset.Symbol < results[j].asset.Symbol
+	})
+
+	assets := make([]*models.Asset, len(results))
+	for i, r := range results {
+		assets[i] = r.asset
+	}
+
+	return assets
+}
+
+func Search(query string) []*models.Asset {
+	return GetAssetCache().Search(query)
+}
+
+func matchAsset(asset *models.Asset, query string) match {
+	symbol := strings.ToUpper(asset.Symbol)
+	words := strings.Fields(strings.ToUpper(asset.Name))
+
+	switch {
+	case symbol == query:
+		return matchSymbol
+	case strings.HasPrefix(symbol, query):
+		return matchSymbolPrefix
+	}
+
+	for _, word := range words {
+		if strings.HasPrefix(word, query) {
+			return matchNamePrefix
+		}
+	}
+
+	// a single typo is only told apart from a different word once the
+	// query is a few letters long
+	if len(query) < 3 {
+		return noMatch
+	}
+
+	if distance(symbol, query) <= 1 {
+		return matchSymbolFuzzy
+	}
+
+	if len(query) < 4 {
+		return noMatch
+	}
+
+	for _, word := range words {
+		if len(word) >= len(query) && distance(word[:len(query)], query) <= 1 {
+			return matchNameFuzzy
+		}
+	}
+
+	return noMatch
+}
+
+// distance is the Damerau-Levenshtein (optimal string alignment) edit
+// distance, which counts swapped letters as one typo.
+func distance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,255 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+const actorKey = "gobroker:audit_actor"
+
+// redacted fields still show up in the diff so that it is clear
+// they were changed, but their values are never stored
+var redacted = map[string]bool{
+	"date_of_birth":        true,
+	"phone_number":         true,
+	"street_address":       true,
+	"unit":                 true,
+	"postal_code":          true,
+	"visa_expiration_date": true,
+	"masked_ssn":           true,
+	"ssn":                  true,
+	"bank_account":         true,
+	"bank_routing_number":  true,
+}
+
+// ignored fields are bookkeeping or nested associations
+// that are audited on their own
+var ignored = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"owners":     true,
+	"details":    true,
+}
+
+const redactedValue = "[REDACTED]"
+
+// Actor is who performed the change being audited
+type Actor struct {
+	ID        *uuid.UUID
+	Type      models.AuditActorType
+	RequestID string
+}
+
+// WithActor attaches the actor to the transaction so that any audit
+// records written through it are attributed correctly.
+func WithActor(tx *gorm.DB, actor Actor) *gorm.DB {
+	return tx.Set(actorKey, actor)
+}
+
+// ActorOf returns the actor attached to the transaction. Changes made
+// without an attached actor come from background processing.
+func ActorOf(tx *gorm.DB) Actor {
+	if v, ok := tx.Get(actorKey); ok {
+		if actor, ok := v.(Actor); ok {
+			return actor
+		}
+	}
+	return Actor{Type: models.ActorWorker}
+}
+
+// Entry describes a single audited change. Before is nil for
+// newly created resources.
+type Entry struct {
+	AccountID  string
+	Resource   models.AuditResource
+	ResourceID string
+	Before     interface{}
+	After      interface{}
+}
+
+// Snapshot captures the JSON representation of v at the time of
+// the call, for use as Entry.Before when v is about to be mutated
+// in place.
+func Snapshot(v interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+
+	if v == nil {
+		return m
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+
+	json.Unmarshal(buf, &m)
+
+	return m
+}
+
+// Diff returns the fields which differ between before and after,
+// with the values of PII fields redacted.
+func Diff(before, after interface{}) map[string]interface{} {
+	b := Snapshot(before)
+	a := Snapshot(after)
+
+	diff := map[string]interface{}{}
+
+	for k, av := range a {
+		if ignored[k] {
+			continue
+		}
+		bv, ok := b[k]
+		if ok && reflect.DeepEqual(av, bv) {
+			continue
+		}
+		if redacted[k] {
+			av, bv = redactedValue, redactedValue
+			if !ok {
+				bv = nil
+			}
+		}
+		diff[k] = map[string]interface{}{"before": bv, "after": av}
+	}
+
+	for k := range b {
+		if _, ok := a[k]; ok || ignored[k] {
+			continue
+		}
+		bv := b[k]
+		if redacted[k] {
+			bv = redactedValue
+		}
+		diff[k] = map[string]interface{}{"before": bv, "after": nil}
+	}
+
+	return diff
+}
+
+// Record writes an audit log for the entry using the actor attached
+// to the transaction. Nothing is written when nothing changed.
+func Record(tx *gorm.DB, entry Entry) error {
+	op := models.AuditUpdate
+	if entry.Before == nil {
+		op = models.AuditCreate
+	}
+
+	diff := Diff(entry.Before, entry.After)
+	if len(diff) == 0 {
+		return nil
+	}
+
+	buf, err := json.Marshal(diff)
+	if err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	actor := ActorOf(tx)
+
+	log := &models.AuditLog{
+		ActorType:  actor.Type,
+		Resource:   entry.Resource,
+		ResourceID: entry.ResourceID,
+		Operation:  op,
+		Diff:       buf,
+	}
+
+	if actor.ID != nil {
+		id := actor.ID.String()
+		log.ActorID = &id
+	}
+
+	if actor.RequestID != "" {
+		log.RequestID = &actor.RequestID
+	}
+
+	if entry.AccountID != "" {
+		log.AccountID = &entry.AccountID
+	}
+
+	if err = tx.Create(log).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return nil
+}
+
+type AuditService interface {
+	List(query AuditQuery) ([]models.AuditLog, error)
+	WithTx(tx *gorm.DB) AuditService
+}
+
+type auditService struct {
+	AuditService
+	tx *gorm.DB
+}
+
+func Service() AuditService {
+	return &auditService{}
+}
+
+func (s *auditService) WithTx(tx *gorm.DB) AuditService {
+	s.tx = tx
+	return s
+}
+
+type AuditQuery struct {
+	AccountID  *uuid.UUID
+	ActorID    *uuid.UUID
+	Resource   *models.AuditResource
+	ResourceID *string
+	RequestID  *string
+	After      *time.Time
+	Until      *time.Time
+	Limit      int
+}
+
+func (s *auditService) List(query AuditQuery) ([]models.AuditLog, error) {
+	logs := []models.AuditLog{}
+
+	q := s.tx
+
+	if query.AccountID != nil {
+		q = q.Where("account_id = ?", query.AccountID.String())
+	}
+
+	if query.ActorID != nil {
+		q = q.Where("actor_id = ?", query.ActorID.String())
+	}
+
+	if query.Resource != nil {
+		q = q.Where("resource = ?", *query.Resource)
+	}
+
+	if query.ResourceID != nil {
+		q = q.Where("resource_id = ?", *query.ResourceID)
+	}
+
+	if query.RequestID != nil {
+		q = q.Where("request_id = ?", *query.RequestID)
+	}
+
+	if query.After != nil {
+		q = q.Where("created_at > ?", *query.After)
+	}
+
+	if query.Until != nil {
+		q = q.Where("created_at <= ?", *query.Until)
+	}
+
+	if query.Limit > 0 {
+		q = q.Limit(query.Limit)
+	}
+
+	if err := q.Order("created_at DESC, id DESC").Find(&logs).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	dbtest.Suite
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditTestSuite))
+}
+
+func (s *AuditTestSuite) SetupSuite() {
+	s.SetupDB()
+}
+
+func (s *AuditTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *AuditTestSuite) TestDiff() {
+	phone := "555-555-1234"
+	newPhone := "555-555-9876"
+	city := "Somewhere"
+	newCity := "Nowhere"
+
+	before := models.OwnerDetails{PhoneNumber: &phone, City: &city}
+	after := models.OwnerDetails{PhoneNumber: &newPhone, City: &newCity}
+
+	diff := Diff(before, after)
+	require.Len(s.T(), diff, 2)
+
+	assert.Equal(s.T(), map[string]interface{}{"before": "Somewhere", "after": "Nowhere"}, diff["city"])
+	assert.Equal(s.T(), map[string]interface{}{"before": redactedValue, "after": redactedValue}, diff["phone_number"])
+
+	assert.Empty(s.T(), Diff(before, before))
+}
+
+func (s *AuditTestSuite) TestRecord() {
+	accountID := uuid.Must(uuid.NewV4())
+	adminID := uuid.Must(uuid.NewV4())
+
+	acct := &models.Account{ID: accountID.String(), Status: enum.Active}
+	before := Snapshot(acct)
+	acct.TradingBlocked = true
+
+	// no actor attached
+	require.Nil(s.T(), Record(db.DB(), Entry{
+		AccountID:  acct.ID,
+		Resource:   models.AuditAccount,
+		ResourceID: acct.ID,
+		Before:     before,
+		After:      acct,
+	}))
+
+	// nothing changed
+	require.Nil(s.T(), Record(db.DB(), Entry{
+		AccountID:  acct.ID,
+		Resource:   models.AuditAccount,
+		ResourceID: acct.ID,
+		Before:     Snapshot(acct),
+		After:      acct,
+	}))
+
+	tx := WithActor(db.DB(), Actor{
+		ID:        &adminID,
+		Type:      models.ActorAdmin,
+		RequestID: "req-1",
+	})
+
+	require.Nil(s.T(), Record(tx, Entry{
+		AccountID:  acct.ID,
+		Resource:   models.AuditTransfer,
+		ResourceID: "xfer",
+		After:      &models.Transfer{AccountID: acct.ID, Status: enum.TransferQueued},
+	}))
+
+	srv := auditService{tx: db.DB()}
+
+	logs, err := srv.List(AuditQuery{AccountID: &accountID})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), logs, 2)
+
+	assert.Equal(s.T(), models.AuditTransfer, logs[0].Resource)
+	assert.Equal(s.T(), models.AuditCreate, logs[0].Operation)
+	assert.Equal(s.T(), models.ActorAdmin, logs[0].ActorType)
+	assert.Equal(s.T(), adminID.String(), *logs[0].ActorID)
+	assert.Equal(s.T(), "req-1", *logs[0].RequestID)
+
+	assert.Equal(s.T(), models.AuditUpdate, logs[1].Operation)
+	assert.Equal(s.T(), models.ActorWorker, logs[1].ActorType)
+	assert.Nil(s.T(), logs[1].ActorID)
+
+	diff := map[string]map[string]interface{}{}
+	require.Nil(s.T(), json.Unmarshal(logs[1].Diff, &diff))
+	assert.Equal(s.T(), true, diff["trading_blocked"]["after"])
+
+	reqID := "req-1"
+	logs, err = srv.List(AuditQuery{RequestID: &reqID})
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), logs, 1)
+
+	// append-only
+	assert.NotNil(s.T(), db.DB().Exec("DELETE FROM audit_logs").Error)
+	assert.NotNil(s.T(), db.DB().Exec("UPDATE audit_logs SET request_id = NULL").Error)
+	logs, err = srv.List(AuditQuery{AccountID: &accountID})
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), logs, 2)
+}
@@ -0,0 +1,300 @@
+package bar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/log"
+	"github.com/shopspring/decimal"
+)
+
+// Adjustment selects the corporate actions historical bars are adjusted
+// for, so that prices before an action are comparable to the ones after.
+type Adjustment string
+
+const (
+	// AdjustmentRaw returns the bars as they traded
+	AdjustmentRaw Adjustment = "raw"
+	// AdjustmentSplit adjusts prices and volumes for splits and stock
+	// dividends
+	AdjustmentSplit Adjustment = "split"
+	// AdjustmentDividend adjusts prices for cash dividends
+	AdjustmentDividend Adjustment = "dividend"
+	// AdjustmentAll adjusts for both splits and cash dividends
+	AdjustmentAll Adjustment = "all"
+)
+
+// ParseAdjustment validates the adjustment parameter, which is raw if
+// it isn't given.
+func ParseAdjustment(s string) (Adjustment, error) {
+	switch a := Adjustment(s); a {
+	case "":
+		return AdjustmentRaw, nil
+	case AdjustmentRaw, AdjustmentSplit, AdjustmentDividend, AdjustmentAll:
+		return a, nil
+	default:
+		return "", gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("adjustment must be one of %v, %v, %v, %v",
+				AdjustmentRaw, AdjustmentSplit, AdjustmentDividend, AdjustmentAll))
+	}
+}
+
+func (a Adjustment) includes(adj adjustment) bool {
+	switch a {
+	case AdjustmentAll:
+		return true
+	case AdjustmentSplit:
+		return adj.split
+	case AdjustmentDividend:
+		return !adj.split
+	default:
+		return false
+	}
+}
+
+var splitActions = []enum.CorporateActionType{
+	enum.StockSplit,
+	enum.ReverseSplit,
+	enum.StockDividend,
+}
+
+// adjustment is a corporate action bars before it took effect are
+// adjusted for.
+type adjustment struct {
+	// from is the market open the action took effect at
+	from  time.Time
+	split bool
+	// price multiplies the prices of the bars before, and volume their
+	// volumes
+	price  float64
+	volume float64
+}
+
+// adjust applies the adjustments to the bars in place. Bars are
+// adjusted for every action which took effect after them, so they are
+// comparable to today's prices.
+func adjust(bars Bars, adjs []adjustment, a Adjustment) {
+	for _, bar := range bars {
+		price, volume := 1.0, 1.0
+
+		for _, adj := range adjs {
+			if bar.Time.Before(adj.from) && a.includes(adj) {
+				price *= adj.price
+				volume *= adj.volume
+			}
+		}
+
+		if price == 1 && volume == 1 {
+			continue
+		}
+
+		bar.Open = float32(float64(bar.Open) * price)
+		bar.High = float32(float64(bar.High) * price)
+		bar.Low = float32(float64(bar.Low) * price)
+		bar.Close = float32(float64(bar.Close) * price)
+		bar.Volume = int32(float64(bar.Volume) * volume)
+	}
+}
+
+// adjustments returns the actions in the asset which took effect after
+// since, up to today. They only change when the start of day files are
+// processed, so they are cached for the day.
+func (s *barService) adjustments(asset *models.Asset, since time.Time) ([]adjustment, error) {
+	var (
+		today = s.now().In(calendar.NY).Format("2006-01-02")
+		key   = fmt.Sprintf("%v:%v", asset.ID, since.In(calendar.NY).Format("2006-01-02"))
+	)
+
+	if adjs, ok := cache.get(today, key); ok {
+		return adjs, nil
+	}
+
+	adjs, err := s.splitAdjustments(asset, since)
+	if err != nil {
+		return nil, err
+	}
+
+	dividends, err := s.dividendAdjustments(asset, since)
+	if err != nil {
+		return nil, err
+	}
+
+	adjs = append(adjs, dividends...)
+
+	cache.set(today, key, adjs)
+
+	return adjs, nil
+}
+
+// splitAdjustments are recorded on the trading day before the split,
+// and take effect from the next open. A 2 for 1 split halves the prices
+// before it, and doubles their volumes.
+func (s *barService) splitAdjustments(asset *models.Asset, since time.Time) ([]adjustment, error) {
+	actions := []models.CorporateAction{}
+
+	if err := s.tx.
+		Where("asset_id = ? AND type IN (?)", asset.ID, splitActions).
+		Order("date ASC").
+		Find(&actions).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	adjs := []adjustment{}
+
+	for _, action := range actions {
+		if action.StockFactor == nil || action.StockFactor.LessThanOrEqual(decimal.Zero) {
+			continue
+		}
+
+		recorded, err := parseTradingDate(action.Date[:10])
+		if err != nil {
+			return nil, gberrors.InternalServerError.WithError(err)
+		}
+
+		from := recorded.Next().MarketOpen()
+		if !from.After(since) {
+			continue
+		}
+
+		factor, _ := action.StockFactor.Float64()
+
+		adjs = append(adjs, adjustment{
+			from:   from,
+			split:  true,
+			price:  1 / factor,
+			volume: factor,
+		})
+	}
+
+	return adjs, nil
+}
+
+// dividendAdjustments scale the prices before the ex-date down by the
+// dividend's share of the close before it. The dividends table holds
+// a row for each account paid, so they are the distinct ex-dates and
+// rates.
+func (s *barService) dividendAdjustments(asset *models.Asset, since time.Time) ([]adjustment, error) {
+	dividends := []struct {
+		ExchangeDate date.Date
+		DividendRate decimal.Decimal
+	}{}
+
+	if err := s.tx.
+		Model(&models.Dividend{}).
+		Select("DISTINCT exchange_date, dividend_rate").
+		Where("asset_id = ? AND exchange_date >= ?", asset.ID, since.In(calendar.NY).Format("2006-01-02")).
+		Order("exchange_date ASC").
+		Scan(&dividends).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	adjs := []adjustment{}
+
+	for _, dividend := range dividends {
+		exDate, err := tradingdate.NewFromDate(
+			dividend.ExchangeDate.Year, dividend.ExchangeDate.Month, dividend.ExchangeDate.Day)
+		if err != nil {
+			return nil, gberrors.InternalServerError.WithError(err)
+		}
+
+		from := exDate.MarketOpen()
+		if !from.After(since) {
+			continue
+		}
+
+		prevClose, err := s.closeBefore(asset.Symbol, from)
+		if err != nil {
+			return nil, err
+		}
+
+		rate, _ := dividend.DividendRate.Float64()
+
+		if prevClose <= 0 || rate >= prevClose {
+			log.Warn(
+				"skipping dividend adjustment without a usable close",
+				"symbol", asset.Symbol,
+				"ex_date", dividend.ExchangeDate,
+				"close", prevClose)
+			continue
+		}
+
+		adjs = append(adjs, adjustment{
+			from:   from,
+			price:  1 - rate/prevClose,
+			volume: 1,
+		})
+	}
+
+	return adjs, nil
+}
+
+// closeBefore returns the raw daily close before t, or zero if there
+// isn't one.
+func (s *barService) closeBefore(symbol string, t time.Time) (float64, error) {
+	var (
+		end = t.Add(-time.Second)
+		one = 1
+	)
+
+	csm, err := s.md.Bars([]string{symbol}, "1D", nil, &end, &one)
+	if err != nil {
+		return 0, gberrors.InternalServerError.WithError(err)
+	}
+
+	for _, cs := range csm {
+		if closes, ok := cs.GetByName("Close").([]float32); ok && len(closes) > 0 {
+			return float64(closes[len(closes)-1]), nil
+		}
+	}
+
+	return 0, nil
+}
+
+func parseTradingDate(d string) (*tradingdate.TradingDate, error) {
+	t, err := time.Parse("2006-01-02", d)
+	if err != nil {
+		return nil, err
+	}
+	return tradingdate.NewFromDate(t.Year(), t.Month(), t.Day())
+}
+
+// adjustmentCache holds the adjustments by asset and range start for
+// the day.
+type adjustmentCache struct {
+	sync.Mutex
+	day     string
+	entries map[string][]adjustment
+}
+
+var cache = &adjustmentCache{entries: map[string][]adjustment{}}
+
+func (c *adjustmentCache) get(day, key string) ([]adjustment, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.day != day {
+		return nil, false
+	}
+
+	adjs, ok := c.entries[key]
+	return adjs, ok
+}
+
+func (c *adjustmentCache) set(day, key string, adjs []adjustment) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.day != day {
+		c.day = day
+		c.entries = map[string][]adjustment{}
+	}
+
+	c.entries[key] = adjs
+}
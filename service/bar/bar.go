@@ -2,17 +2,15 @@ package bar
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/alpacahq/gobroker/external/mkts"
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
-	"github.com/alpacahq/marketstore/frontend"
 	"github.com/alpacahq/marketstore/utils"
 	"github.com/alpacahq/marketstore/utils/io"
 	"github.com/gofrs/uuid"
@@ -23,32 +21,24 @@ type BarService interface {
 	GetByID(assetID uuid.UUID, timeframe string, start, end *time.Time, limit *int) (*AssetBars, error)
 	GetByIDs(assetIDs []uuid.UUID, timeframe string, start, end *time.Time, limit *int) ([]*AssetBars, error)
 	WithTx(tx *gorm.DB) BarService
+	WithAdjustment(adjustment Adjustment) BarService
 }
 
 type barService struct {
 	BarService
 	tx         *gorm.DB
-	mktsdb     func(name string, args interface{}) (io.ColumnSeriesMap, error)
+	md         marketdata.MarketData
 	now        func() time.Time
 	assetcache assetcache.AssetCache
+	adjustment Adjustment
 }
 
-func Service(assetcache assetcache.AssetCache) BarService {
+func Service(assetcache assetcache.AssetCache, md marketdata.MarketData) BarService {
 	return &barService{
-		mktsdb: func(name string, args interface{}) (io.ColumnSeriesMap, error) {
-			resp, err := mkts.Client().DoRPC(name, args)
-			if err != nil {
-				return nil, err
-			}
-
-			if resp == nil {
-				return io.NewColumnSeriesMap(), nil
-			}
-
-			return *resp.(*io.ColumnSeriesMap), nil
-		},
+		md:         md,
 		now:        clock.Now,
 		assetcache: assetcache,
+		adjustment: AdjustmentRaw,
 	}
 }
 
@@ -57,6 +47,12 @@ func (s *barService) WithTx(tx *gorm.DB) BarService {
 	return s
 }
 
+// WithAdjustment sets the corporate actions the bars are adjusted for.
+func (s *barService) WithAdjustment(adjustment Adjustment) BarService {
+	s.adjustment = adjustment
+	return s
+}
+
 type AssetBars struct {
 	AssetID  uuid.UUID       `json:"asset_id"`
 	Symbol   string          `json:"symbol"`
@@ -89,11 +85,7 @@ func (s *barService) GetByID(assetID uuid.UUID, timeframe string, start, end *ti
 		return nil, gberrors.NotFound.WithMsg(fmt.Sprintf("asset not found for %v", assetID))
 	}
 
-	args := &frontend.MultiQueryRequest{
-		Requests: buildQuery([]string{asset.Symbol}, tf, start, end, limit),
-	}
-
-	csm, err := s.mktsdb("Query", args)
+	csm, err := s.query([]string{asset.Symbol}, tf, start, end, limit)
 
 	if err != nil {
 		return nil, gberrors.InternalServerError.WithError(err)
@@ -108,7 +100,13 @@ func (s *barService) GetByID(assetID uuid.UUID, timeframe string, start, end *ti
 			Bars:     Bars{},
 		}, nil
 	}
-	return s.csmToBars(csm, tf, map[string]*models.Asset{asset.Symbol: asset})[0], nil
+
+	assetBarsList, err := s.csmToBars(csm, tf, map[string]*models.Asset{asset.Symbol: asset})
+	if err != nil {
+		return nil, err
+	}
+
+	return assetBarsList[0], nil
 }
 
 const barAggThreshold = 30 * time.Second
@@ -139,51 +137,43 @@ func (s *barService) GetByIDs(assetIDs []uuid.UUID, timeframe string, start, end
 		assetMap[asset.Symbol] = asset
 	}
 
-	args := &frontend.MultiQueryRequest{
-		Requests: buildQuery(symbols, tf, start, end, limit),
-	}
-
-	csm, err := s.mktsdb("Query", args)
+	csm, err := s.query(symbols, tf, start, end, limit)
 
 	if err != nil {
 		return nil, gberrors.InternalServerError.WithError(err)
 	}
 
-	return s.csmToBars(csm, tf, assetMap), nil
+	return s.csmToBars(csm, tf, assetMap)
 }
 
-func buildQuery(symbols []string, timeframe *utils.Timeframe, start, end *time.Time, limit *int) []frontend.QueryRequest {
-	builder := mkts.NewQueryRequestBuilder(fmt.Sprintf("%v/%v/OHLCV", strings.Join(symbols, ","), timeframe))
-
-	if start != nil {
-		builder.EpochStart(start.Unix())
-	}
-
-	if end != nil {
-		builder.EpochEnd(end.Unix())
+func (s *barService) query(symbols []string, timeframe *utils.Timeframe, start, end *time.Time, limit *int) (io.ColumnSeriesMap, error) {
+	csm, err := s.md.Bars(symbols, timeframe.String, start, end, limit)
+	if err != nil {
+		return nil, err
 	}
 
-	if limit != nil {
-		builder.LimitRecordCount(*limit)
+	if csm == nil {
+		csm = io.NewColumnSeriesMap()
 	}
 
 	// if it's an aggregated timeframe, let's get the latest 1Min bar too
 	if timeframe.Duration > time.Minute {
 		oneLimit := 1
-		return append(
-			buildQuery(
-				symbols,
-				utils.TimeframeFromString("1Min"),
-				nil, end, &oneLimit,
-			),
-			builder.End(),
-		)
+
+		minCsm, err := s.md.Bars(symbols, "1Min", nil, end, &oneLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		for tbk, cs := range minCsm {
+			csm[tbk] = cs
+		}
 	}
 
-	return []frontend.QueryRequest{builder.End()}
+	return csm, nil
 }
 
-func (s *barService) csmToBars(csm io.ColumnSeriesMap, tf *utils.Timeframe, assetMap map[string]*models.Asset) []*AssetBars {
+func (s *barService) csmToBars(csm io.ColumnSeriesMap, tf *utils.Timeframe, assetMap map[string]*models.Asset) ([]*AssetBars, error) {
 	assetBarsList := []*AssetBars{}
 
 	for tbk, cs := range csm {
@@ -219,6 +209,14 @@ func (s *barService) csmToBars(csm io.ColumnSeriesMap, tf *utils.Timeframe, asse
 					Time:   t[i],
 				}
 			}
+
+			if s.adjustment != AdjustmentRaw && s.adjustment != "" && len(bars) > 0 {
+				adjs, err := s.adjustments(asset, bars[0].Time)
+				if err != nil {
+					return nil, err
+				}
+				adjust(bars, adjs, s.adjustment)
+			}
 		}
 
 		assetBars := &AssetBars{
@@ -230,7 +228,7 @@ func (s *barService) csmToBars(csm io.ColumnSeriesMap, tf *utils.Timeframe, asse
 		}
 		assetBarsList = append(assetBarsList, assetBars)
 	}
-	return assetBarsList
+	return assetBarsList, nil
 }
 
 func trimAt(csm io.ColumnSeriesMap, tbk io.TimeBucketKey, tf *utils.Timeframe, now time.Time) (index int) {
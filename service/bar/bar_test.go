@@ -8,6 +8,7 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
@@ -43,17 +44,25 @@ func (s *BarTestSuite) SetupSuite() {
 	}
 }
 
+// mockBars serves the same bars for every query.
+type mockBars struct {
+	marketdata.MarketData
+	csm io.ColumnSeriesMap
+}
+
+func (m *mockBars) Bars(symbols []string, timeframe string, start, end *time.Time, limit *int) (io.ColumnSeriesMap, error) {
+	return m.csm, nil
+}
+
 func (s *BarTestSuite) TearDownSuite() {
 	s.TeardownDB()
 }
 
 func (s *BarTestSuite) TestGetByID() {
 	srv := barService{
-		tx:  db.DB(),
-		now: func() time.Time { return clock.Now() },
-		mktsdb: func(name string, args interface{}) (io.ColumnSeriesMap, error) {
-			return nil, nil
-		},
+		tx:         db.DB(),
+		now:        func() time.Time { return clock.Now() },
+		md:         &mockBars{},
 		assetcache: assetcache.GetAssetCache(),
 	}
 
@@ -69,7 +78,7 @@ func (s *BarTestSuite) TestGetByID() {
 	assert.Nil(s.T(), assetBars)
 	assert.NotNil(s.T(), err)
 
-	srv.mktsdb = func(name string, args interface{}) (io.ColumnSeriesMap, error) {
+	srv.md = &mockBars{csm: func() io.ColumnSeriesMap {
 		m := io.NewColumnSeriesMap()
 		cs := io.NewColumnSeries()
 		cs.AddColumn("Epoch", []int64{start.Unix() + 60, start.Unix() + 120, start.Unix() + 180, start.Unix() + 240})
@@ -79,8 +88,8 @@ func (s *BarTestSuite) TestGetByID() {
 		cs.AddColumn("Close", []float32{1.0, 2.0, 3.0, 4.0})
 		cs.AddColumn("Volume", []int32{1, 2, 3, 4})
 		m.AddColumnSeries(*io.NewTimeBucketKey("AAPL/1Min/OHLCV"), cs)
-		return m, nil
-	}
+		return m
+	}()}
 
 	assetBars, err = srv.GetByID(s.asset.IDAsUUID(), "1Min", &start, &end, &limit)
 	assert.Len(s.T(), assetBars.Bars, 4)
@@ -89,11 +98,9 @@ func (s *BarTestSuite) TestGetByID() {
 
 func (s *BarTestSuite) TestGetByIDs() {
 	srv := barService{
-		tx:  db.DB(),
-		now: func() time.Time { return clock.Now() },
-		mktsdb: func(name string, args interface{}) (io.ColumnSeriesMap, error) {
-			return nil, nil
-		},
+		tx:         db.DB(),
+		now:        func() time.Time { return clock.Now() },
+		md:         &mockBars{},
 		assetcache: assetcache.GetAssetCache(),
 	}
 
@@ -110,7 +117,7 @@ func (s *BarTestSuite) TestGetByIDs() {
 	assert.Len(s.T(), bars, 0)
 	assert.Nil(s.T(), err)
 
-	srv.mktsdb = func(name string, args interface{}) (io.ColumnSeriesMap, error) {
+	srv.md = &mockBars{csm: func() io.ColumnSeriesMap {
 		m := io.NewColumnSeriesMap()
 		cs := io.NewColumnSeries()
 		cs.AddColumn("Epoch", []int64{start.Unix() + 60, start.Unix() + 120, start.Unix() + 180, start.Unix() + 240})
@@ -120,8 +127,8 @@ func (s *BarTestSuite) TestGetByIDs() {
 		cs.AddColumn("Close", []float32{1.0, 2.0, 3.0, 4.0})
 		cs.AddColumn("Volume", []int32{1, 2, 3, 4})
 		m.AddColumnSeries(*io.NewTimeBucketKey("AAPL/1Min/OHLCV"), cs)
-		return m, nil
-	}
+		return m
+	}()}
 
 	bars, err = srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()}, "1Min", &start, &end, &limit)
 	assert.NotNil(s.T(), bars)
@@ -136,18 +143,16 @@ func (s *BarTestSuite) TestAggregateTrim1DExclude() {
 	limit := 10
 
 	srv := barService{
-		tx:  db.DB(),
-		now: func() time.Time { return end.Add(14 * time.Hour) },
-		mktsdb: func(name string, args interface{}) (io.ColumnSeriesMap, error) {
-			return nil, nil
-		},
+		tx:         db.DB(),
+		now:        func() time.Time { return end.Add(14 * time.Hour) },
+		md:         &mockBars{},
 		assetcache: assetcache.GetAssetCache(),
 	}
 
 	bars, err := srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()}, "1D", &start, &end, &limit)
 	assert.Len(s.T(), bars, 0)
 	assert.Nil(s.T(), err)
-	srv.mktsdb = func(name string, args interface{}) (io.ColumnSeriesMap, error) {
+	srv.md = &mockBars{csm: func() io.ColumnSeriesMap {
 		m := io.NewColumnSeriesMap()
 		cs := io.NewColumnSeries()
 		cs.AddColumn("Epoch", []int64{
@@ -170,8 +175,8 @@ func (s *BarTestSuite) TestAggregateTrim1DExclude() {
 		minCs.AddColumn("Close", []float32{1.0})
 		minCs.AddColumn("Volume", []int32{1})
 		m.AddColumnSeries(*io.NewTimeBucketKey("AAPL/1Min/OHLCV"), cs)
-		return m, nil
-	}
+		return m
+	}()}
 
 	bars, err = srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()}, "1D", &start, &end, &limit)
 	assert.NotNil(s.T(), bars)
@@ -186,18 +191,16 @@ func (s *BarTestSuite) TestAggregateTrim1DInclude() {
 	limit := 10
 
 	srv := barService{
-		tx:  db.DB(),
-		now: func() time.Time { return end.Add(18 * time.Hour) },
-		mktsdb: func(name string, args interface{}) (io.ColumnSeriesMap, error) {
-			return nil, nil
-		},
+		tx:         db.DB(),
+		now:        func() time.Time { return end.Add(18 * time.Hour) },
+		md:         &mockBars{},
 		assetcache: assetcache.GetAssetCache(),
 	}
 
 	bars, err := srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()}, "1D", &start, &end, &limit)
 	assert.Len(s.T(), bars, 0)
 	assert.Nil(s.T(), err)
-	srv.mktsdb = func(name string, args interface{}) (io.ColumnSeriesMap, error) {
+	srv.md = &mockBars{csm: func() io.ColumnSeriesMap {
 		m := io.NewColumnSeriesMap()
 		cs := io.NewColumnSeries()
 		cs.AddColumn("Epoch", []int64{
@@ -220,8 +223,8 @@ func (s *BarTestSuite) TestAggregateTrim1DInclude() {
 		minCs.AddColumn("Close", []float32{1.0})
 		minCs.AddColumn("Volume", []int32{1})
 		m.AddColumnSeries(*io.NewTimeBucketKey("AAPL/1Min/OHLCV"), cs)
-		return m, nil
-	}
+		return m
+	}()}
 
 	bars, err = srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()}, "1D", &start, &end, &limit)
 	assert.NotNil(s.T(), bars)
@@ -236,18 +239,16 @@ func (s *BarTestSuite) TestAggregateTrim1HExclude() {
 	limit := 10
 
 	srv := barService{
-		tx:  db.DB(),
-		now: func() time.Time { return end.Add(-2 * time.Minute) },
-		mktsdb: func(name string, args interface{}) (io.ColumnSeriesMap, error) {
-			return nil, nil
-		},
+		tx:         db.DB(),
+		now:        func() time.Time { return end.Add(-2 * time.Minute) },
+		md:         &mockBars{},
 		assetcache: assetcache.GetAssetCache(),
 	}
 
 	bars, err := srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()}, "1H", &start, &end, &limit)
 	assert.Len(s.T(), bars, 0)
 	assert.Nil(s.T(), err)
-	srv.mktsdb = func(name string, args interface{}) (io.ColumnSeriesMap, error) {
+	srv.md = &mockBars{csm: func() io.ColumnSeriesMap {
 		m := io.NewColumnSeriesMap()
 		cs := io.NewColumnSeries()
 		cs.AddColumn("Epoch", []int64{
@@ -270,8 +271,8 @@ func (s *BarTestSuite) TestAggregateTrim1HExclude() {
 		minCs.AddColumn("Close", []float32{1.0})
 		minCs.AddColumn("Volume", []int32{1})
 		m.AddColumnSeries(*io.NewTimeBucketKey("AAPL/1Min/OHLCV"), cs)
-		return m, nil
-	}
+		return m
+	}()}
 
 	bars, err = srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()}, "1H", &start, &end, &limit)
 	assert.NotNil(s.T(), bars)
@@ -279,3 +280,70 @@ func (s *BarTestSuite) TestAggregateTrim1HExclude() {
 	assert.Len(s.T(), bars[0].Bars, 3)
 	assert.Nil(s.T(), err)
 }
+
+func (s *BarTestSuite) TestAdjust() {
+	exDate := time.Date(2019, 2, 8, 14, 30, 0, 0, time.UTC)
+
+	newBars := func() Bars {
+		return Bars{
+			{Open: 100, High: 110, Low: 90, Close: 100, Volume: 1000, Time: exDate.Add(-24 * time.Hour)},
+			{Open: 50, High: 55, Low: 45, Close: 50, Volume: 2000, Time: exDate},
+		}
+	}
+
+	adjs := []adjustment{
+		// 2 for 1 split
+		{from: exDate, split: true, price: 0.5, volume: 2},
+		// $1 dividend on a $50 close
+		{from: exDate, price: 0.98, volume: 1},
+	}
+
+	// raw
+	{
+		bars := newBars()
+		adjust(bars, adjs, AdjustmentRaw)
+		assert.Equal(s.T(), float32(100), bars[0].Close)
+		assert.Equal(s.T(), int32(1000), bars[0].Volume)
+	}
+
+	// split
+	{
+		bars := newBars()
+		adjust(bars, adjs, AdjustmentSplit)
+		assert.Equal(s.T(), float32(50), bars[0].Close)
+		assert.Equal(s.T(), float32(55), bars[0].High)
+		assert.Equal(s.T(), int32(2000), bars[0].Volume)
+		// bars on and after the ex-date are as they traded
+		assert.Equal(s.T(), float32(50), bars[1].Close)
+		assert.Equal(s.T(), int32(2000), bars[1].Volume)
+	}
+
+	// dividend
+	{
+		bars := newBars()
+		adjust(bars, adjs, AdjustmentDividend)
+		assert.InDelta(s.T(), 98, bars[0].Close, 1e-4)
+		assert.Equal(s.T(), int32(1000), bars[0].Volume)
+	}
+
+	// all
+	{
+		bars := newBars()
+		adjust(bars, adjs, AdjustmentAll)
+		assert.InDelta(s.T(), 49, bars[0].Close, 1e-4)
+		assert.Equal(s.T(), int32(2000), bars[0].Volume)
+	}
+}
+
+func (s *BarTestSuite) TestParseAdjustment() {
+	adjustment, err := ParseAdjustment("")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), AdjustmentRaw, adjustment)
+
+	adjustment, err = ParseAdjustment("split")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), AdjustmentSplit, adjustment)
+
+	_, err = ParseAdjustment("forward")
+	assert.NotNil(s.T(), err)
+}
@@ -0,0 +1,186 @@
+package beneficiary
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/audit"
+	"github.com/alpacahq/gobroker/service/op"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type BeneficiaryService interface {
+	GetByID(accountID uuid.UUID, beneficiaryID string) (*models.WireBeneficiary, error)
+	List(accountID uuid.UUID) ([]models.WireBeneficiary, error)
+	Create(accountID uuid.UUID, info BeneficiaryInfo) (*models.WireBeneficiary, error)
+	Cancel(accountID uuid.UUID, beneficiaryID string) error
+	WithTx(tx *gorm.DB) BeneficiaryService
+}
+
+type beneficiaryService struct {
+	BeneficiaryService
+	tx *gorm.DB
+}
+
+func Service() BeneficiaryService {
+	return &beneficiaryService{}
+}
+
+func (s *beneficiaryService) WithTx(tx *gorm.DB) BeneficiaryService {
+	s.tx = tx
+	return s
+}
+
+// BeneficiaryInfo is the wire destination supplied by the account
+// owner. Either a routing number (domestic) or a SWIFT code
+// (international) is required.
+type BeneficiaryInfo struct {
+	Nickname        *string `json:"nickname"`
+	BankName        string  `json:"bank_name"`
+	BankAddress     string  `json:"bank_address"`
+	BeneficiaryName string  `json:"beneficiary_name"`
+	AccountNumber   string  `json:"account_number"`
+	RoutingNumber   string  `json:"routing_number"`
+	SwiftCode       string  `json:"swift_code"`
+	FurtherCredit   string  `json:"further_credit"`
+}
+
+func (i *BeneficiaryInfo) Verify() error {
+	switch {
+	case i.BankName == "":
+		return gberrors.InvalidRequestParam.WithMsg("bank_name is required")
+	case i.BeneficiaryName == "":
+		return gberrors.InvalidRequestParam.WithMsg("beneficiary_name is required")
+	case i.AccountNumber == "":
+		return gberrors.InvalidRequestParam.WithMsg("account_number is required")
+	case i.RoutingNumber == "" && i.SwiftCode == "":
+		return gberrors.InvalidRequestParam.WithMsg("routing_number or swift_code is required")
+	case i.RoutingNumber != "" && len(i.RoutingNumber) != 9:
+		return gberrors.InvalidRequestParam.WithMsg("routing_number must be 9 digits")
+	case i.SwiftCode != "" && len(i.SwiftCode) != 8 && len(i.SwiftCode) != 11:
+		return gberrors.InvalidRequestParam.WithMsg("swift_code must be 8 or 11 characters")
+	}
+	return nil
+}
+
+func (s *beneficiaryService) GetByID(accountID uuid.UUID, beneficiaryID string) (*models.WireBeneficiary, error) {
+	b := &models.WireBeneficiary{}
+
+	q := s.tx.Where("id = ? AND account_id = ?", beneficiaryID, accountID.String()).Find(b)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.NotFound.WithMsg("beneficiary not found")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	return b, nil
+}
+
+func (s *beneficiaryService) List(accountID uuid.UUID) ([]models.WireBeneficiary, error) {
+	beneficiaries := []models.WireBeneficiary{}
+
+	if err := s.tx.
+		Where("account_id = ? AND status = ?", accountID.String(), enum.BeneficiaryActive).
+		Order("created_at DESC").
+		Find(&beneficiaries).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return beneficiaries, nil
+}
+
+func (s *beneficiaryService) Create(accountID uuid.UUID, info BeneficiaryInfo) (*models.WireBeneficiary, error) {
+	if err := info.Verify(); err != nil {
+		return nil, err
+	}
+
+	acct, err := op.GetAccountByID(s.tx, accountID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !acct.Fundable() {
+		return nil, gberrors.Unauthorized.WithMsg("account not authorized for wire transfers")
+	}
+
+	b := &models.WireBeneficiary{
+		AccountID: acct.ID,
+		Status:    enum.BeneficiaryActive,
+		Nickname:  info.Nickname,
+		BankName:  info.BankName,
+	}
+
+	if err = b.SetBankInfo(models.WireBankInfo{
+		BeneficiaryName: info.BeneficiaryName,
+		AccountNumber:   info.AccountNumber,
+		RoutingNumber:   info.RoutingNumber,
+		SwiftCode:       strings.ToUpper(info.SwiftCode),
+		BankAddress:     info.BankAddress,
+		FurtherCredit:   info.FurtherCredit,
+	}); err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err = s.tx.Create(b).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err = audit.Record(s.tx, audit.Entry{
+		AccountID:  b.AccountID,
+		Resource:   models.AuditWireBeneficiary,
+		ResourceID: b.ID,
+		After:      b,
+	}); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Cancel deactivates the beneficiary, and cancels any wires to it
+// which have not been sent yet.
+func (s *beneficiaryService) Cancel(accountID uuid.UUID, beneficiaryID string) error {
+	b := &models.WireBeneficiary{}
+
+	if err := s.tx.
+		Where("id = ? AND account_id = ?", beneficiaryID, accountID.String()).
+		Set("gorm:query_option", db.ForUpdate).
+		First(b).Error; err != nil {
+		return gberrors.NotFound.WithError(fmt.Errorf("beneficiary not associated with account: %v", accountID))
+	}
+
+	before := audit.Snapshot(b)
+
+	if err := s.tx.Model(b).Update("status", enum.BeneficiaryCanceled).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	if err := audit.Record(s.tx, audit.Entry{
+		AccountID:  b.AccountID,
+		Resource:   models.AuditWireBeneficiary,
+		ResourceID: b.ID,
+		Before:     before,
+		After:      b,
+	}); err != nil {
+		return err
+	}
+
+	if err := s.tx.
+		Model(&models.Transfer{}).
+		Where(
+			"beneficiary_id = ? AND status IN (?)",
+			b.ID, []enum.TransferStatus{enum.TransferApprovalPending, enum.TransferQueued}).
+		Update("status", enum.TransferCanceled).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return nil
+}
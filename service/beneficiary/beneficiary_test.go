@@ -0,0 +1,98 @@
+package beneficiary
+
+import (
+	"testing"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/env"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type BeneficiaryTestSuite struct {
+	dbtest.Suite
+	account *models.Account
+}
+
+func TestBeneficiaryTestSuite(t *testing.T) {
+	suite.Run(t, new(BeneficiaryTestSuite))
+}
+
+func (s *BeneficiaryTestSuite) SetupSuite() {
+	env.RegisterDefault("BROKER_SECRET", "fd0bxOTg7Q5qxISYKvdol0FBWnAaFgsP")
+	s.SetupDB()
+
+	apexAcct := "apca_test"
+	s.account = &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+	}
+	if err := db.DB().Create(s.account).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+}
+
+func (s *BeneficiaryTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *BeneficiaryTestSuite) TestBeneficiary() {
+	srv := beneficiaryService{tx: db.DB()}
+
+	// invalid routing number
+	_, err := srv.Create(s.account.IDAsUUID(), BeneficiaryInfo{
+		BankName:        "Test Bank",
+		BeneficiaryName: "First Last",
+		AccountNumber:   "123456789",
+		RoutingNumber:   "1234",
+	})
+	assert.NotNil(s.T(), err)
+
+	b, err := srv.Create(s.account.IDAsUUID(), BeneficiaryInfo{
+		BankName:        "Test Bank",
+		BeneficiaryName: "First Last",
+		AccountNumber:   "123456789",
+		RoutingNumber:   "021000021",
+	})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "6789", b.Mask)
+	assert.Equal(s.T(), enum.BeneficiaryActive, b.Status)
+
+	info, err := b.GetBankInfo()
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "123456789", info.AccountNumber)
+	assert.Equal(s.T(), "021000021", info.RoutingNumber)
+
+	beneficiaries, err := srv.List(s.account.IDAsUUID())
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), beneficiaries, 1)
+
+	// pending wire is canceled along with the beneficiary
+	transfer := &models.Transfer{
+		AccountID:     s.account.ID,
+		BeneficiaryID: &b.ID,
+		Type:          enum.Wire,
+		Amount:        decimal.NewFromFloat(1000),
+		Status:        enum.TransferApprovalPending,
+	}
+	require.Nil(s.T(), db.DB().Create(transfer).Error)
+
+	require.Nil(s.T(), srv.Cancel(s.account.IDAsUUID(), b.ID))
+
+	beneficiaries, err = srv.List(s.account.IDAsUUID())
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), beneficiaries)
+
+	require.Nil(s.T(), db.DB().Where("id = ?", transfer.ID).First(transfer).Error)
+	assert.Equal(s.T(), enum.TransferCanceled, transfer.Status)
+
+	// unknown beneficiary
+	assert.NotNil(s.T(), srv.Cancel(s.account.IDAsUUID(), uuid.Must(uuid.NewV4()).String()))
+}
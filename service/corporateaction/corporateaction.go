@@ -5,12 +5,16 @@ import (
 
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/service/order"
 	"github.com/alpacahq/gopaca/calendar"
+	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
 )
 
 type CorporateActionService interface {
 	List(date string) ([]models.CorporateAction, error)
+	Replacement(accountID uuid.UUID, o *models.Order) (*models.Order, *models.Asset, error)
+	ReplaceOrder(orders order.OrderService, accountID uuid.UUID, o *models.Order) (*models.Order, *models.Asset, error)
 	WithTx(tx *gorm.DB) CorporateActionService
 }
 
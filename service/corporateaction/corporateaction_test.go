@@ -9,9 +9,12 @@ import (
 	"github.com/alpacahq/gobroker/dbtest"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -52,3 +55,169 @@ func (s *CorporateActionTestSuite) TestList() {
 	assert.Nil(s.T(), err)
 	assert.Empty(s.T(), actions)
 }
+
+func (s *CorporateActionTestSuite) TestAdjustOrder() {
+	asset := &models.Asset{ID: uuid.Must(uuid.NewV4()).String(), Symbol: "NEWS"}
+	limit := decimal.NewFromFloat(100.01)
+	stop := decimal.NewFromFloat(0.9)
+	factor := decimal.New(4, 0)
+
+	order := &models.Order{
+		ID:              uuid.Must(uuid.NewV4()).String(),
+		Symbol:          "OLDS",
+		Qty:             decimal.New(15, 0),
+		Side:            enum.Sell,
+		Type:            enum.StopLimit,
+		ClientOrderType: enum.StopLimit,
+		TimeInForce:     enum.GTC,
+		LimitPrice:      &limit,
+		StopPrice:       &stop,
+	}
+
+	// 4:1 split and a symbol change
+	{
+		replacement := AdjustOrder(order, asset, []models.CorporateAction{
+			{Type: enum.StockSplit, StockFactor: &factor},
+			{Type: enum.SymbolChange},
+		})
+
+		require.NotNil(s.T(), replacement)
+		assert.Equal(s.T(), "NEWS", replacement.Symbol)
+		assert.Equal(s.T(), asset.ID, replacement.AssetID)
+		assert.True(s.T(), replacement.Qty.Equal(decimal.New(60, 0)))
+		assert.True(s.T(), replacement.LimitPrice.Equal(decimal.NewFromFloat(25)))
+		assert.True(s.T(), replacement.StopPrice.Equal(decimal.NewFromFloat(0.225)))
+		assert.Equal(s.T(), order.ID, *replacement.Replaces)
+	}
+
+	// partially filled market buys are replaced for the rest, and
+	// converted to limit orders again when created
+	{
+		filled := decimal.New(5, 0)
+		buy := *order
+		buy.Side = enum.Buy
+		buy.Type = enum.Limit
+		buy.ClientOrderType = enum.Market
+		buy.StopPrice = nil
+		buy.FilledQty = &filled
+
+		replacement := AdjustOrder(&buy, asset, []models.CorporateAction{{Type: enum.SymbolChange}})
+
+		require.NotNil(s.T(), replacement)
+		assert.Equal(s.T(), enum.Market, replacement.Type)
+		assert.Nil(s.T(), replacement.LimitPrice)
+		assert.True(s.T(), replacement.Qty.Equal(decimal.New(10, 0)))
+	}
+
+	// reverse splits cancel
+	{
+		tenth := decimal.NewFromFloat(0.1)
+		assert.Nil(s.T(), AdjustOrder(order, asset, []models.CorporateAction{
+			{Type: enum.ReverseSplit, StockFactor: &tenth},
+		}))
+	}
+
+	// so does anything without a policy
+	assert.Nil(s.T(), AdjustOrder(order, asset, []models.CorporateAction{
+		{Type: enum.SymbolChange},
+		{Type: enum.Reorg},
+	}))
+}
+
+func (s *CorporateActionTestSuite) TestReplacement() {
+	apexAcct := "apca_replace"
+	acct := &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			{
+				Email:   "replace@test.db",
+				Primary: true,
+			},
+		},
+	}
+	require.Nil(s.T(), db.DB().Create(acct).Error)
+
+	old := &models.Asset{
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NYSE",
+		Symbol:   "REPL",
+		CUSIP:    "111111111",
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	require.Nil(s.T(), db.DB().Create(old).Error)
+
+	renamed := &models.Asset{
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NYSE",
+		Symbol:   "REPN",
+		CUSIP:    "222222222",
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	require.Nil(s.T(), db.DB().Create(renamed).Error)
+
+	asof, err := date.ParseDate("2019-02-14")
+	require.Nil(s.T(), err)
+
+	factor := decimal.New(2, 0)
+	for _, action := range []models.CorporateAction{
+		{AssetID: old.IDAsUUID(), Type: enum.StockSplit, Date: asof.String(), StockFactor: &factor},
+		{AssetID: old.IDAsUUID(), Type: enum.SymbolChange, Date: asof.String(), Cusip: renamed.CUSIP},
+	} {
+		require.Nil(s.T(), db.DB().Create(&action).Error)
+	}
+
+	// the start of day process moved the lot, keeping its lot ID
+	lotID := uint(1000)
+	lot := &models.Position{
+		AccountID:          acct.ID,
+		AssetID:            renamed.IDAsUUID(),
+		Status:             models.Open,
+		Side:               models.Long,
+		Qty:                decimal.New(20, 0),
+		EntryPrice:         decimal.New(50, 0),
+		EntryTimestamp:     time.Now(),
+		OriginalPositionID: &lotID,
+	}
+	require.Nil(s.T(), db.DB().Create(lot).Error)
+
+	filled := decimal.New(4, 0)
+	order := &models.Order{
+		ID:              uuid.Must(uuid.NewV4()).String(),
+		Account:         apexAcct,
+		AssetID:         old.ID,
+		Symbol:          old.Symbol,
+		Qty:             decimal.New(10, 0),
+		FilledQty:       &filled,
+		Side:            enum.Sell,
+		Type:            enum.Market,
+		ClientOrderType: enum.Market,
+		TimeInForce:     enum.GTC,
+		LotIDs:          []int64{int64(lotID), 999999},
+	}
+
+	srv := Service().WithTx(db.DB())
+
+	// orders which weren't canceled for an action aren't replaced
+	replacement, _, err := srv.Replacement(acct.IDAsUUID(), order)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), replacement)
+
+	order.CorporateActionDate = &asof
+
+	replacement, target, err := srv.Replacement(acct.IDAsUUID(), order)
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), replacement)
+	require.NotNil(s.T(), target)
+
+	// the unfilled 6 shares, split 2:1 under the new symbol, closing
+	// the lot which is still open
+	assert.Equal(s.T(), renamed.ID, target.ID)
+	assert.Equal(s.T(), renamed.Symbol, replacement.Symbol)
+	assert.True(s.T(), replacement.Qty.Equal(decimal.New(12, 0)))
+	assert.Equal(s.T(), []int64{int64(lotID)}, []int64(replacement.LotIDs))
+	assert.Equal(s.T(), order.ID, *replacement.Replaces)
+}
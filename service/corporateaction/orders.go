@@ -0,0 +1,251 @@
+package corporateaction
+
+import (
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/order"
+	"github.com/alpacahq/gopaca/price"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// EventOrderReplaced is sent on trade_updates when an open order is
+	// cancel/replaced for a corporate action
+	EventOrderReplaced = "corporate_action_replaced"
+	// EventOrderCanceled is sent on trade_updates when an open order is
+	// canceled for a corporate action
+	EventOrderCanceled = "corporate_action_canceled"
+)
+
+// OrderPolicy is what happens to the open orders in a security before
+// the market opens on the day a corporate action takes effect.
+type OrderPolicy string
+
+const (
+	// CancelOrders cancels the open orders.
+	CancelOrders OrderPolicy = "cancel"
+	// AdjustOrders cancel/replaces the open orders with the quantity and
+	// prices adjusted for the action, under the current symbol.
+	AdjustOrders OrderPolicy = "adjust"
+)
+
+// orderPolicies follow FINRA Rule 5330, which adjusts open orders for
+// forward splits, and leaves it to the member to cancel them on reverse
+// splits.
+var orderPolicies = map[enum.CorporateActionType]OrderPolicy{
+	enum.StockSplit:   AdjustOrders,
+	enum.ReverseSplit: CancelOrders,
+	enum.SymbolChange: AdjustOrders,
+	enum.CusipChange:  AdjustOrders,
+}
+
+// Policy returns the policy for the open orders affected by an action,
+// and whether the pre-open adjustment handles the action at all.
+func Policy(t enum.CorporateActionType) (OrderPolicy, bool) {
+	policy, ok := orderPolicies[t]
+	return policy, ok
+}
+
+// AdjustOrder returns the order which replaces the remaining quantity
+// of o once the actions take effect, or nil if it should be canceled.
+// Quantities are rounded down to whole shares, and prices down to the
+// tick. The lots o names are left off, since they may not be lots of
+// the asset anymore.
+func AdjustOrder(o *models.Order, asset *models.Asset, actions []models.CorporateAction) *models.Order {
+	var (
+		qty   = o.Qty
+		limit = o.LimitPrice
+		stop  = o.StopPrice
+	)
+
+	if o.FilledQty != nil {
+		qty = qty.Sub(*o.FilledQty)
+	}
+
+	// market and stop buys are converted to limit orders when they are
+	// created, which will happen again for the replacement
+	if o.ClientOrderType == enum.Market || o.ClientOrderType == enum.Stop {
+		limit = nil
+	}
+
+	for _, action := range actions {
+		if policy, ok := orderPolicies[action.Type]; !ok || policy == CancelOrders {
+			return nil
+		}
+
+		if action.Type != enum.StockSplit {
+			continue
+		}
+
+		if action.StockFactor == nil || action.StockFactor.LessThanOrEqual(decimal.Zero) {
+			return nil
+		}
+
+		qty = qty.Mul(*action.StockFactor).Floor()
+		limit = adjustPrice(limit, *action.StockFactor)
+		stop = adjustPrice(stop, *action.StockFactor)
+	}
+
+	if qty.LessThanOrEqual(decimal.Zero) {
+		return nil
+	}
+
+	return &models.Order{
+		AssetID:     asset.ID,
+		Symbol:      asset.Symbol,
+		Qty:         qty,
+		Side:        o.Side,
+		Type:        o.ClientOrderType,
+		TimeInForce: o.TimeInForce,
+		LimitPrice:  limit,
+		StopPrice:   stop,
+		Replaces:    &o.ID,
+	}
+}
+
+func adjustPrice(px *decimal.Decimal, factor decimal.Decimal) *decimal.Decimal {
+	if px == nil {
+		return nil
+	}
+
+	adjusted, _ := price.FormatForOrder(px.Div(factor))
+
+	return &adjusted
+}
+
+// Replacement returns the order which replaces what's left of o after
+// the corporate actions it was canceled for, along with the asset it's
+// for, or nil if o is canceled outright. The lots o named carry over
+// if they're still open lots of that asset.
+func (s *corporateActionService) Replacement(accountID uuid.UUID, o *models.Order) (*models.Order, *models.Asset, error) {
+	if o.CorporateActionDate == nil {
+		return nil, nil, nil
+	}
+
+	actions := []models.CorporateAction{}
+
+	if err := s.tx.
+		Where("date = ? AND asset_id = ?", o.CorporateActionDate.String(), o.AssetID).
+		Find(&actions).Error; err != nil {
+		return nil, nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	asset := &models.Asset{}
+
+	if err := s.tx.Where("id = ?", o.AssetID).First(asset).Error; err != nil {
+		return nil, nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	target, err := s.successor(asset, actions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replacement := AdjustOrder(o, target, actions)
+	if replacement == nil {
+		return nil, nil, nil
+	}
+
+	if len(o.LotIDs) > 0 {
+		if replacement.LotIDs, err = s.openLots(accountID, target, o.LotIDs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return replacement, target, nil
+}
+
+// ReplaceOrder queues the replacement for an order canceled for
+// corporate actions, once the cancel is confirmed, so that it's for
+// what was left unfilled and the two never hold buying power at once.
+// The replacement goes through the same checks as any other order, so
+// the service needs the root DB rather than a transaction.
+func (s *corporateActionService) ReplaceOrder(
+	orders order.OrderService,
+	accountID uuid.UUID,
+	o *models.Order) (*models.Order, *models.Asset, error) {
+
+	if o.ReplacedBy != nil {
+		return nil, nil, nil
+	}
+
+	replacement, target, err := s.Replacement(accountID, o)
+	if err != nil || replacement == nil {
+		return nil, nil, err
+	}
+
+	if _, err = orders.WithTx(s.tx).Queue(accountID, replacement); err != nil {
+		return nil, nil, err
+	}
+
+	if err = s.tx.Model(o).Update("replaced_by", replacement.ID).Error; err != nil {
+		return nil, nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return replacement, target, nil
+}
+
+// successor returns the asset an order carries on under after the
+// actions. When the new security is already listed as another asset,
+// that's where the start of day process moved the lots, so the order
+// follows them.
+func (s *corporateActionService) successor(asset *models.Asset, actions []models.CorporateAction) (*models.Asset, error) {
+	for _, action := range actions {
+		if action.Type != enum.SymbolChange && action.Type != enum.CusipChange {
+			continue
+		}
+
+		if action.Cusip == "" || action.Cusip == asset.CUSIP {
+			continue
+		}
+
+		target := &models.Asset{}
+
+		q := s.tx.Where("cusip = ? AND id != ?", action.Cusip, asset.ID).First(target)
+
+		if q.RecordNotFound() {
+			continue
+		}
+
+		if q.Error != nil {
+			return nil, gberrors.InternalServerError.WithError(q.Error)
+		}
+
+		return target, nil
+	}
+
+	return asset, nil
+}
+
+// openLots returns the lot IDs which are still open lots of the asset.
+// Lots adjusted for an action keep their lot ID, so the ones that moved
+// to the asset carry over and the rest are dropped.
+func (s *corporateActionService) openLots(accountID uuid.UUID, asset *models.Asset, lotIDs []int64) ([]int64, error) {
+	positions := []models.Position{}
+
+	if err := s.tx.
+		Where("account_id = ? AND asset_id = ? AND status = ?", accountID.String(), asset.ID, models.Open).
+		Find(&positions).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	open := map[uint]bool{}
+	for _, p := range positions {
+		open[p.LotID()] = true
+	}
+
+	lots := []int64{}
+	for _, id := range lotIDs {
+		if open[uint(id)] {
+			lots = append(lots, id)
+		}
+	}
+
+	if len(lots) == 0 {
+		return nil, nil
+	}
+
+	return lots, nil
+}
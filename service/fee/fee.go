@@ -0,0 +1,203 @@
+package fee
+
+import (
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/shopspring/decimal"
+)
+
+// secRate is the SEC Section 31 fee, charged per dollar of sale
+// proceeds, from the date it takes effect.
+type secRate struct {
+	effective string
+	rate      decimal.Decimal
+}
+
+// secRates are ordered oldest first, and need a new entry each time
+// the SEC publishes a fee rate advisory.
+var secRates = []secRate{
+	{effective: "2016-10-01", rate: perMillion(21.80)},
+	{effective: "2017-10-01", rate: perMillion(23.10)},
+	{effective: "2018-02-20", rate: perMillion(13.00)},
+}
+
+// tafRate is FINRA's trading activity fee, charged per share sold
+// up to a maximum per trade, from the date it takes effect.
+type tafRate struct {
+	effective string
+	perShare  decimal.Decimal
+	max       decimal.Decimal
+}
+
+// tafRates are ordered oldest first.
+var tafRates = []tafRate{
+	{
+		effective: "2014-01-01",
+		perShare:  decimal.New(119, -6),
+		max:       decimal.New(595, -2),
+	},
+}
+
+// commission is what we charge for a trade on top of the regulatory
+// fees, which depends on the account's plan.
+type commission struct {
+	effective string
+	perTrade  decimal.Decimal
+	perShare  decimal.Decimal
+}
+
+// commissions are ordered oldest first for each plan.
+var commissions = map[enum.AccountPlan][]commission{
+	enum.RegularAccount: {
+		{effective: "2015-01-01", perTrade: decimal.Zero, perShare: decimal.Zero},
+	},
+	enum.PremiumAccount: {
+		{effective: "2015-01-01", perTrade: decimal.Zero, perShare: decimal.Zero},
+	},
+}
+
+func perMillion(rate float64) decimal.Decimal {
+	return decimal.NewFromFloat(rate).Div(decimal.New(1, 6))
+}
+
+// Fees is the breakdown of the fees charged on a trade.
+type Fees struct {
+	SEC        decimal.Decimal `json:"sec"`
+	TAF        decimal.Decimal `json:"taf"`
+	Commission decimal.Decimal `json:"commission"`
+}
+
+// Total returns the sum of all the fees.
+func (f Fees) Total() decimal.Decimal {
+	return f.SEC.Add(f.TAF).Add(f.Commission)
+}
+
+// Apply stores the fees on the execution. The SEC fee goes in the same
+// field the clearing broker reports it in and the TAF in the misc fee.
+// The commission is ours, so it has its own field.
+func (f Fees) Apply(exec *models.Execution) {
+	exec.FeeSec = &f.SEC
+	exec.FeeMisc = &f.TAF
+	exec.Commission = &f.Commission
+}
+
+// Compute returns the fees for trading qty shares at price on the
+// trade date, using the rates in effect then. Regulatory fees only
+// apply to sales, and are rounded up to the next cent.
+func Compute(
+	plan enum.AccountPlan,
+	side enum.Side,
+	qty, price decimal.Decimal,
+	tradeDate date.Date) Fees {
+
+	return compute(plan, side, qty, qty.Mul(price), tradeDate)
+}
+
+func compute(
+	plan enum.AccountPlan,
+	side enum.Side,
+	qty, notional decimal.Decimal,
+	tradeDate date.Date) Fees {
+
+	fees := zeroFees()
+
+	if side == enum.Sell {
+		if r := currentSEC(tradeDate); r != nil {
+			fees.SEC = roundUp(notional.Mul(r.rate))
+		}
+
+		if r := currentTAF(tradeDate); r != nil {
+			fees.TAF = decimal.Min(roundUp(qty.Mul(r.perShare)), r.max)
+		}
+	}
+
+	if c := currentCommission(plan, tradeDate); c != nil {
+		fees.Commission = c.perTrade.Add(qty.Mul(c.perShare)).Round(2)
+	}
+
+	return fees
+}
+
+// ForFill returns the fees for a fill, given the fills of the same
+// order earlier on the trade date. The fees are charged per trade, so
+// they are computed on the order's cumulative fill, and the fill is
+// charged the difference from what the earlier fills were. That way an
+// order filled in many parts is rounded up once, and stays within the
+// TAF maximum.
+func ForFill(plan enum.AccountPlan, exec *models.Execution, earlier []models.Execution, tradeDate date.Date) Fees {
+	qty := *exec.Qty
+	notional := exec.Qty.Mul(*exec.Price)
+	charged := zeroFees()
+
+	for _, e := range earlier {
+		qty = qty.Add(*e.Qty)
+		notional = notional.Add(e.Qty.Mul(*e.Price))
+
+		if e.FeeSec != nil {
+			charged.SEC = charged.SEC.Add(*e.FeeSec)
+		}
+		if e.FeeMisc != nil {
+			charged.TAF = charged.TAF.Add(*e.FeeMisc)
+		}
+		if e.Commission != nil {
+			charged.Commission = charged.Commission.Add(*e.Commission)
+		}
+	}
+
+	total := compute(plan, exec.Side, qty, notional, tradeDate)
+
+	return Fees{
+		SEC:        decimal.Max(total.SEC.Sub(charged.SEC), decimal.Zero),
+		TAF:        decimal.Max(total.TAF.Sub(charged.TAF), decimal.Zero),
+		Commission: decimal.Max(total.Commission.Sub(charged.Commission), decimal.Zero),
+	}
+}
+
+func zeroFees() Fees {
+	return Fees{
+		SEC:        decimal.Zero,
+		TAF:        decimal.Zero,
+		Commission: decimal.Zero,
+	}
+}
+
+func currentSEC(on date.Date) (rate *secRate) {
+	for i := range secRates {
+		if effective(secRates[i].effective, on) {
+			rate = &secRates[i]
+		}
+	}
+	return
+}
+
+func currentTAF(on date.Date) (rate *tafRate) {
+	for i := range tafRates {
+		if effective(tafRates[i].effective, on) {
+			rate = &tafRates[i]
+		}
+	}
+	return
+}
+
+func currentCommission(plan enum.AccountPlan, on date.Date) (c *commission) {
+	schedule := commissions[plan]
+	for i := range schedule {
+		if effective(schedule[i].effective, on) {
+			c = &schedule[i]
+		}
+	}
+	return
+}
+
+func effective(from string, on date.Date) bool {
+	d, err := date.ParseDate(from)
+	if err != nil {
+		return false
+	}
+	return !d.After(on.Date)
+}
+
+func roundUp(d decimal.Decimal) decimal.Decimal {
+	return d.Shift(2).Ceil().Shift(-2)
+}
@@ -0,0 +1,95 @@
+package fee
+
+import (
+	"testing"
+
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FeeTestSuite struct {
+	suite.Suite
+}
+
+func TestFeeTestSuite(t *testing.T) {
+	suite.Run(t, new(FeeTestSuite))
+}
+
+func (s *FeeTestSuite) TestCompute() {
+	d, _ := date.ParseDate("2018-06-01")
+
+	// buys are free
+	{
+		fees := Compute(enum.RegularAccount, enum.Buy, decimal.New(100, 0), decimal.New(50, 0), d)
+		assert.True(s.T(), fees.Total().Equal(decimal.Zero))
+	}
+
+	// 100 shares @ $50 = $5,000 * $13.00 / million = $0.065 -> $0.07
+	// 100 shares * $0.000119 = $0.0119 -> $0.02
+	{
+		fees := Compute(enum.RegularAccount, enum.Sell, decimal.New(100, 0), decimal.New(50, 0), d)
+		assert.Equal(s.T(), "0.07", fees.SEC.StringFixed(2))
+		assert.Equal(s.T(), "0.02", fees.TAF.StringFixed(2))
+		assert.True(s.T(), fees.Commission.Equal(decimal.Zero))
+		assert.Equal(s.T(), "0.09", fees.Total().StringFixed(2))
+	}
+
+	// TAF is capped per trade
+	{
+		fees := Compute(enum.PremiumAccount, enum.Sell, decimal.New(100000, 0), decimal.New(1, 0), d)
+		assert.Equal(s.T(), "5.95", fees.TAF.StringFixed(2))
+	}
+
+	// the rate in effect on the trade date applies
+	{
+		before, _ := date.ParseDate("2018-02-16")
+		fees := Compute(enum.RegularAccount, enum.Sell, decimal.New(100, 0), decimal.New(100, 0), before)
+		// $10,000 * $23.10 / million = $0.231 -> $0.24
+		assert.Equal(s.T(), "0.24", fees.SEC.StringFixed(2))
+	}
+
+	// no rates yet
+	{
+		early, _ := date.ParseDate("2010-01-04")
+		fees := Compute(enum.RegularAccount, enum.Sell, decimal.New(100, 0), decimal.New(100, 0), early)
+		assert.True(s.T(), fees.Total().Equal(decimal.Zero))
+	}
+}
+
+func (s *FeeTestSuite) TestForFill() {
+	d, _ := date.ParseDate("2018-06-01")
+
+	fill := func(qty, price int64) models.Execution {
+		q := decimal.New(qty, 0)
+		px := decimal.New(price, 0)
+		return models.Execution{Side: enum.Sell, Qty: &q, Price: &px}
+	}
+
+	charge := func(fills []models.Execution) Fees {
+		total := zeroFees()
+		for i := range fills {
+			fees := ForFill(enum.RegularAccount, &fills[i], fills[:i], d)
+			fees.Apply(&fills[i])
+			total.SEC = total.SEC.Add(fees.SEC)
+			total.TAF = total.TAF.Add(fees.TAF)
+		}
+		return total
+	}
+
+	// 4 fills of 25 shares @ $50 pay the same as one of 100
+	{
+		fees := charge([]models.Execution{fill(25, 50), fill(25, 50), fill(25, 50), fill(25, 50)})
+		assert.Equal(s.T(), "0.07", fees.SEC.StringFixed(2))
+		assert.Equal(s.T(), "0.02", fees.TAF.StringFixed(2))
+	}
+
+	// the TAF maximum is per trade, not per fill
+	{
+		fees := charge([]models.Execution{fill(60000, 1), fill(60000, 1)})
+		assert.Equal(s.T(), "5.95", fees.TAF.StringFixed(2))
+	}
+}
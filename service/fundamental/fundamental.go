@@ -5,6 +5,7 @@ import (
 
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/service/position"
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
 )
@@ -12,6 +13,8 @@ import (
 type FundamentalService interface {
 	GetByID(assetID uuid.UUID) (*models.Fundamental, error)
 	GetByIDs(assetIDs []uuid.UUID) ([]*models.Fundamental, error)
+	Screen(query ScreenQuery) ([]models.Fundamental, int64, error)
+	GetSectorAllocation(positions []*position.ConsolidatedPosition) ([]*SectorAllocation, error)
 	WithTx(tx *gorm.DB) FundamentalService
 }
 
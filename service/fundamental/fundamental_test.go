@@ -6,9 +6,12 @@ import (
 	"github.com/alpacahq/gobroker/dbtest"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/position"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -38,10 +41,43 @@ func (s *FundamentalTestSuite) SetupSuite() {
 		AssetID:  s.asset.ID,
 		Symbol:   s.asset.Symbol,
 		FullName: "Apple Inc.",
+		Sector:   "Technology",
+		PERatio:  15,
 	}
 	if err := db.DB().Create(s.fund).Error; err != nil {
 		assert.FailNow(s.T(), err.Error())
 	}
+
+	for _, f := range []struct {
+		symbol   string
+		sector   string
+		pe       float32
+		tradable bool
+	}{
+		{"MSFT", "Technology", 25, true},
+		{"XOM", "Energy", 10, true},
+		{"OTC", "Energy", 12, false},
+	} {
+		asset := &models.Asset{
+			Class:    enum.AssetClassUSEquity,
+			Exchange: "NYSE",
+			Symbol:   f.symbol,
+			Status:   enum.AssetActive,
+			Tradable: f.tradable,
+		}
+		if err := db.DB().Create(asset).Error; err != nil {
+			assert.FailNow(s.T(), err.Error())
+		}
+		fund := &models.Fundamental{
+			AssetID: asset.ID,
+			Symbol:  asset.Symbol,
+			Sector:  f.sector,
+			PERatio: f.pe,
+		}
+		if err := db.DB().Create(fund).Error; err != nil {
+			assert.FailNow(s.T(), err.Error())
+		}
+	}
 }
 
 func (s *FundamentalTestSuite) TearDownSuite() {
@@ -73,3 +109,69 @@ func (s *FundamentalTestSuite) TestGetByIDs() {
 	assert.Len(s.T(), f, 0)
 	assert.Nil(s.T(), err)
 }
+
+func (s *FundamentalTestSuite) TestScreen() {
+	srv := Service().WithTx(db.DB())
+
+	symbols := func(fs []models.Fundamental) []string {
+		syms := []string{}
+		for _, f := range fs {
+			syms = append(syms, f.Symbol)
+		}
+		return syms
+	}
+
+	// untradable assets are left out
+	fs, total, err := srv.Screen(ScreenQuery{Page: 1, Per: 50})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(3), total)
+	assert.Equal(s.T(), []string{"AAPL", "MSFT", "XOM"}, symbols(fs))
+
+	min, max := 12.0, 30.0
+	fs, total, err = srv.Screen(ScreenQuery{
+		Ranges:    map[string]Range{"pe_ratio": {Min: &min, Max: &max}},
+		Sectors:   []string{"Technology"},
+		OrderBy:   "pe_ratio",
+		Direction: "desc",
+		Page:      1,
+		Per:       50,
+	})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(2), total)
+	assert.Equal(s.T(), []string{"MSFT", "AAPL"}, symbols(fs))
+
+	// pages
+	fs, total, err = srv.Screen(ScreenQuery{OrderBy: "pe_ratio", Direction: "asc", Page: 2, Per: 2})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(3), total)
+	assert.Equal(s.T(), []string{"MSFT"}, symbols(fs))
+
+	_, _, err = srv.Screen(ScreenQuery{Ranges: map[string]Range{"full_name": {Min: &min}}, Page: 1, Per: 50})
+	assert.NotNil(s.T(), err)
+
+	_, _, err = srv.Screen(ScreenQuery{OrderBy: "long_description", Page: 1, Per: 50})
+	assert.NotNil(s.T(), err)
+}
+
+func (s *FundamentalTestSuite) TestGetSectorAllocation() {
+	srv := Service().WithTx(db.DB())
+
+	allocation, err := srv.GetSectorAllocation([]*position.ConsolidatedPosition{
+		{AssetID: s.asset.IDAsUUID(), Symbol: "AAPL", MarketValue: decimal.NewFromFloat(600)},
+		// shorts count towards exposure
+		{AssetID: uuid.Must(uuid.NewV4()), Symbol: "NEW", MarketValue: decimal.NewFromFloat(-400)},
+	})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), allocation, 2)
+
+	assert.Equal(s.T(), "Technology", allocation[0].Sector)
+	assert.True(s.T(), allocation[0].Weight.Equal(decimal.NewFromFloat(0.6)))
+	assert.Equal(s.T(), []string{"AAPL"}, allocation[0].Symbols)
+
+	assert.Equal(s.T(), UnknownSector, allocation[1].Sector)
+	assert.True(s.T(), allocation[1].MarketValue.Equal(decimal.NewFromFloat(400)))
+
+	allocation, err = srv.GetSectorAllocation(nil)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), allocation, 0)
+}
@@ -0,0 +1,209 @@
+package fundamental
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/position"
+	"github.com/shopspring/decimal"
+)
+
+// ScreenFields maps the numeric fundamentals which can be filtered and
+// sorted on to their columns.
+var ScreenFields = map[string]string{
+	"pe_ratio":            "fundamentals.pe_ratio",
+	"peg_ratio":           "fundamentals.peg_ratio",
+	"beta":                "fundamentals.beta",
+	"eps":                 "fundamentals.eps",
+	"market_cap":          "fundamentals.market_cap",
+	"shares_outstanding":  "fundamentals.shares_outstanding",
+	"avg_vol":             "fundamentals.avg_vol",
+	"div_rate":            "fundamentals.div_rate",
+	"roe":                 "fundamentals.roe",
+	"roa":                 "fundamentals.roa",
+	"ps":                  "fundamentals.ps",
+	"pc":                  "fundamentals.pc",
+	"gross_margin":        "fundamentals.gross_margin",
+	"fifty_two_week_high": "fundamentals.fifty_two_week_high",
+	"fifty_two_week_low":  "fundamentals.fifty_two_week_low",
+}
+
+// Range bounds a fundamental, inclusively. Either end may be open.
+type Range struct {
+	Min *float64
+	Max *float64
+}
+
+// ScreenQuery filters the fundamentals of tradable assets. Sectors and
+// industries match any of the given ones.
+type ScreenQuery struct {
+	Ranges     map[string]Range
+	Sectors    []string
+	Industries []string
+	OrderBy    string
+	Direction  string
+	Page       int
+	Per        int
+}
+
+// Screen returns a page of the fundamentals which pass the query, and
+// the total count of them.
+func (s *fundamentalService) Screen(query ScreenQuery) ([]models.Fundamental, int64, error) {
+	q := s.tx.Model(&models.Fundamental{}).
+		Joins("JOIN assets ON assets.id = fundamentals.asset_id").
+		Where("assets.tradable = ? AND assets.status = ?", true, enum.AssetActive)
+
+	// sorted for a stable query
+	fields := []string{}
+	for field := range query.Ranges {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		column, ok := ScreenFields[field]
+		if !ok {
+			return nil, 0, gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("%v is not a screenable field", field))
+		}
+
+		r := query.Ranges[field]
+
+		if r.Min != nil {
+			q = q.Where(fmt.Sprintf("%v >= ?", column), *r.Min)
+		}
+
+		if r.Max != nil {
+			q = q.Where(fmt.Sprintf("%v <= ?", column), *r.Max)
+		}
+	}
+
+	if len(query.Sectors) > 0 {
+		q = q.Where("fundamentals.sector IN (?)", query.Sectors)
+	}
+
+	if len(query.Industries) > 0 {
+		q = q.Where("fundamentals.industry_name IN (?)", query.Industries)
+	}
+
+	var total int64
+
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, gberrors.InternalServerError.WithError(err)
+	}
+
+	order := "fundamentals.symbol ASC"
+
+	if query.OrderBy != "" {
+		column, ok := ScreenFields[query.OrderBy]
+		if !ok && query.OrderBy != "symbol" {
+			return nil, 0, gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("%v is not a sortable field", query.OrderBy))
+		}
+		if !ok {
+			column = "fundamentals.symbol"
+		}
+
+		dir := "DESC"
+		if strings.ToLower(query.Direction) == "asc" {
+			dir = "ASC"
+		}
+
+		// ties are broken by symbol so pages don't overlap
+		order = fmt.Sprintf("%v %v, fundamentals.symbol ASC", column, dir)
+	}
+
+	fundamentals := []models.Fundamental{}
+
+	if err := q.
+		Select("fundamentals.*").
+		Order(order).
+		Limit(query.Per).
+		Offset((query.Page - 1) * query.Per).
+		Find(&fundamentals).Error; err != nil {
+		return nil, 0, gberrors.InternalServerError.WithError(err)
+	}
+
+	return fundamentals, total, nil
+}
+
+// UnknownSector groups positions without fundamentals.
+const UnknownSector = "Unknown"
+
+type SectorAllocation struct {
+	Sector      string          `json:"sector"`
+	MarketValue decimal.Decimal `json:"market_value"`
+	Weight      decimal.Decimal `json:"weight"`
+	Symbols     []string        `json:"symbols"`
+}
+
+// GetSectorAllocation breaks the positions down by sector, largest
+// first. Weights are of the gross market value, so short positions add
+// to their sector's exposure rather than offsetting it.
+func (s *fundamentalService) GetSectorAllocation(positions []*position.ConsolidatedPosition) ([]*SectorAllocation, error) {
+	if len(positions) == 0 {
+		return []*SectorAllocation{}, nil
+	}
+
+	fundamentals := []models.Fundamental{}
+
+	ids := make([]string, len(positions))
+	for i, p := range positions {
+		ids[i] = p.AssetID.String()
+	}
+
+	if err := s.tx.Where("asset_id IN (?)", ids).Find(&fundamentals).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	sectors := map[string]string{}
+	for _, f := range fundamentals {
+		if f.Sector != "" {
+			sectors[f.AssetID] = f.Sector
+		}
+	}
+
+	bySector := map[string]*SectorAllocation{}
+	allocations := []*SectorAllocation{}
+	gross := decimal.Zero
+
+	for _, p := range positions {
+		sector, ok := sectors[p.AssetID.String()]
+		if !ok {
+			sector = UnknownSector
+		}
+
+		a, ok := bySector[sector]
+		if !ok {
+			a = &SectorAllocation{Sector: sector, Symbols: []string{}}
+			bySector[sector] = a
+			allocations = append(allocations, a)
+		}
+
+		mv := p.MarketValue.Abs()
+
+		a.MarketValue = a.MarketValue.Add(mv)
+		a.Symbols = append(a.Symbols, p.Symbol)
+		gross = gross.Add(mv)
+	}
+
+	for _, a := range allocations {
+		if gross.IsPositive() {
+			a.Weight = a.MarketValue.Div(gross).Round(4)
+		}
+		sort.Strings(a.Symbols)
+	}
+
+	sort.SliceStable(allocations, func(i, j int) bool {
+		if !allocations[i].MarketValue.Equal(allocations[j].MarketValue) {
+			return allocations[i].MarketValue.GreaterThan(allocations[j].MarketValue)
+		}
+		return allocations[i].Sector < allocations[j].Sector
+	})
+
+	return allocations, nil
+}
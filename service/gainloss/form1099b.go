@@ -0,0 +1,70 @@
+package gainloss
+
+import (
+	"fmt"
+
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Form1099B is the realized gains for a tax year, laid out like the
+// IRS form 1099-B so it can be imported into tax software.
+type Form1099B struct {
+	TaxYear      int                    `json:"tax_year"`
+	ShortTerm    Totals                 `json:"short_term"`
+	LongTerm     Totals                 `json:"long_term"`
+	Transactions []Form1099BTransaction `json:"transactions"`
+}
+
+// Form1099BTransaction is a single sale, with the box each field
+// is reported in.
+type Form1099BTransaction struct {
+	Description            string          `json:"description"`               // 1a
+	CUSIP                  string          `json:"cusip"`                     // 1a
+	DateAcquired           date.Date       `json:"date_acquired"`             // 1b
+	DateSold               date.Date       `json:"date_sold"`                 // 1c
+	Proceeds               decimal.Decimal `json:"proceeds"`                  // 1d
+	CostBasis              decimal.Decimal `json:"cost_basis"`                // 1e
+	WashSaleLossDisallowed decimal.Decimal `json:"wash_sale_loss_disallowed"` // 1g
+	Term                   Term            `json:"term"`                      // 2
+	BasisReportedToIRS     bool            `json:"basis_reported_to_irs"`     // 12
+	GainLoss               decimal.Decimal `json:"gain_loss"`
+}
+
+// Form1099B returns the account's realized gains for the tax year.
+func (s *gainLossService) Form1099B(accountID uuid.UUID, year int) (*Form1099B, error) {
+	since, _ := date.ParseDate(fmt.Sprintf("%04d-01-01", year))
+	until, _ := date.ParseDate(fmt.Sprintf("%04d-12-31", year))
+
+	report, err := s.Report(accountID, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	form := &Form1099B{
+		TaxYear:      year,
+		ShortTerm:    report.ShortTerm,
+		LongTerm:     report.LongTerm,
+		Transactions: make([]Form1099BTransaction, len(report.Disposals)),
+	}
+
+	for i, d := range report.Disposals {
+		form.Transactions[i] = Form1099BTransaction{
+			Description:            fmt.Sprintf("%v sh. %v", d.Qty, d.Symbol),
+			CUSIP:                  d.CUSIP,
+			DateAcquired:           date.DateOf(d.AcquiredAt.In(calendar.NY)),
+			DateSold:               date.DateOf(d.SoldAt.In(calendar.NY)),
+			Proceeds:               d.Proceeds.Round(2),
+			CostBasis:              d.CostBasis.Round(2),
+			WashSaleLossDisallowed: d.WashSaleDisallowed.Round(2),
+			Term:                   d.Term,
+			// every lot was bought here, so the basis is covered
+			BasisReportedToIRS: true,
+			GainLoss:           d.GainLoss.Round(2),
+		}
+	}
+
+	return form, nil
+}
@@ -0,0 +1,427 @@
+package gainloss
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// WashSaleDays is how long before or after a sale at a loss buying
+// the same security again disallows the loss.
+const WashSaleDays = 30
+
+type Term string
+
+const (
+	ShortTerm Term = "short"
+	LongTerm  Term = "long"
+)
+
+// Disposal is the sale of all or part of a tax lot.
+type Disposal struct {
+	LotID      uint            `json:"lot_id"`
+	Symbol     string          `json:"symbol"`
+	CUSIP      string          `json:"cusip"`
+	Qty        decimal.Decimal `json:"qty"`
+	AcquiredAt time.Time       `json:"acquired_at"`
+	SoldAt     time.Time       `json:"sold_at"`
+	Proceeds   decimal.Decimal `json:"proceeds"`
+	// CostBasis includes losses disallowed by earlier wash sales
+	// which were added to the lot
+	CostBasis          decimal.Decimal `json:"cost_basis"`
+	WashSaleDisallowed decimal.Decimal `json:"wash_sale_disallowed"`
+	GainLoss           decimal.Decimal `json:"gain_loss"`
+	Term               Term            `json:"term"`
+}
+
+// BasisAdjustment is a disallowed wash sale loss added to the basis
+// of a lot which is still held.
+type BasisAdjustment struct {
+	LotID  uint            `json:"lot_id"`
+	Symbol string          `json:"symbol"`
+	Qty    decimal.Decimal `json:"qty"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// Totals sums up disposals.
+type Totals struct {
+	Proceeds           decimal.Decimal `json:"proceeds"`
+	CostBasis          decimal.Decimal `json:"cost_basis"`
+	WashSaleDisallowed decimal.Decimal `json:"wash_sale_disallowed"`
+	GainLoss           decimal.Decimal `json:"gain_loss"`
+}
+
+func (t *Totals) add(d *Disposal) {
+	t.Proceeds = t.Proceeds.Add(d.Proceeds)
+	t.CostBasis = t.CostBasis.Add(d.CostBasis)
+	t.WashSaleDisallowed = t.WashSaleDisallowed.Add(d.WashSaleDisallowed)
+	t.GainLoss = t.GainLoss.Add(d.GainLoss)
+}
+
+// Report is the realized gains and losses for the lots sold in a
+// date range.
+type Report struct {
+	Since       date.Date         `json:"since"`
+	Until       date.Date         `json:"until"`
+	ShortTerm   Totals            `json:"short_term"`
+	LongTerm    Totals            `json:"long_term"`
+	Total       Totals            `json:"total"`
+	Disposals   []Disposal        `json:"disposals"`
+	Adjustments []BasisAdjustment `json:"adjustments"`
+}
+
+type GainLossService interface {
+	Report(accountID uuid.UUID, since, until date.Date) (*Report, error)
+	Form1099B(accountID uuid.UUID, year int) (*Form1099B, error)
+	WithTx(tx *gorm.DB) GainLossService
+}
+
+type gainLossService struct {
+	GainLossService
+	tx *gorm.DB
+}
+
+func Service() GainLossService {
+	return &gainLossService{}
+}
+
+func (s *gainLossService) WithTx(tx *gorm.DB) GainLossService {
+	s.tx = tx
+	return s
+}
+
+// lot tracks the wash sale adjustments made to a tax lot.
+type lot struct {
+	assetID    uuid.UUID
+	acquiredAt time.Time
+	qty        decimal.Decimal
+	// open is true while any shares are still held, otherwise
+	// soldAt is when the last of them were sold
+	open   bool
+	soldAt *time.Time
+	// shares sold so far, going through the sales in order
+	closed decimal.Decimal
+	// shares still held which replace shares sold at a loss, in the
+	// order they are sold
+	replacements []replacement
+	symbol       string
+}
+
+// replacement is part of a lot which replaces shares sold at a loss.
+// It takes on the disallowed loss, and the holding period of the
+// shares sold.
+type replacement struct {
+	qty        decimal.Decimal
+	adjustment decimal.Decimal
+	acquiredAt time.Time
+}
+
+// Report goes through every lot the account has sold, oldest first,
+// so wash sale adjustments carry into the lots sold later, and returns
+// the disposals made between since and until (inclusive).
+func (s *gainLossService) Report(accountID uuid.UUID, since, until date.Date) (*Report, error) {
+	positions := []models.Position{}
+
+	if err := s.tx.
+		Where("account_id = ? AND status IN (?)", accountID.String(), []models.PositionStatus{models.Open, models.Closed}).
+		Preload("Asset").
+		Order("entry_timestamp, id").
+		Find(&positions).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	lots := map[uint]*lot{}
+	lotIDs := []uint{}
+	closed := []models.Position{}
+
+	for _, p := range positions {
+		l, ok := lots[p.LotID()]
+		if !ok {
+			l = &lot{
+				assetID:      p.AssetID,
+				acquiredAt:   p.EntryTimestamp,
+				qty:          decimal.Zero,
+				closed:       decimal.Zero,
+				replacements: []replacement{},
+				symbol:       p.Asset.Symbol,
+			}
+			lots[p.LotID()] = l
+			lotIDs = append(lotIDs, p.LotID())
+		}
+
+		l.qty = l.qty.Add(p.Qty)
+
+		if p.Status == models.Open {
+			l.open = true
+			continue
+		}
+
+		if l.soldAt == nil || p.ExitTimestamp.After(*l.soldAt) {
+			l.soldAt = p.ExitTimestamp
+		}
+
+		closed = append(closed, p)
+	}
+
+	sort.SliceStable(closed, func(i, j int) bool {
+		return closed[i].ExitTimestamp.Before(*closed[j].ExitTimestamp)
+	})
+
+	report := &Report{
+		Since:       since,
+		Until:       until,
+		ShortTerm:   zeroTotals(),
+		LongTerm:    zeroTotals(),
+		Total:       zeroTotals(),
+		Disposals:   []Disposal{},
+		Adjustments: []BasisAdjustment{},
+	}
+
+	for _, p := range closed {
+		l := lots[p.LotID()]
+
+		// replacement shares are disposed of separately, since their
+		// basis and holding period differ from the rest of the lot
+		for _, part := range l.take(p.Qty) {
+			d := Disposal{
+				LotID:              p.LotID(),
+				Symbol:             p.Asset.Symbol,
+				CUSIP:              p.Asset.CUSIP,
+				Qty:                part.qty,
+				AcquiredAt:         part.acquiredAt,
+				SoldAt:             *p.ExitTimestamp,
+				Proceeds:           part.qty.Mul(*p.ExitPrice),
+				CostBasis:          part.qty.Mul(p.EntryPrice).Add(part.adjustment),
+				WashSaleDisallowed: decimal.Zero,
+				Term:               term(part.acquiredAt, *p.ExitTimestamp),
+			}
+
+			d.GainLoss = d.Proceeds.Sub(d.CostBasis)
+
+			if d.GainLoss.LessThan(decimal.Zero) {
+				d.WashSaleDisallowed = washSale(lots, lotIDs, p.LotID(), l.assetID, d.AcquiredAt, d.SoldAt, d.Qty, d.GainLoss.Neg())
+				d.GainLoss = d.GainLoss.Add(d.WashSaleDisallowed)
+			}
+
+			soldOn := date.DateOf(d.SoldAt.In(calendar.NY))
+			if soldOn.Before(since.Date) || soldOn.After(until.Date) {
+				continue
+			}
+
+			switch d.Term {
+			case LongTerm:
+				report.LongTerm.add(&d)
+			default:
+				report.ShortTerm.add(&d)
+			}
+			report.Total.add(&d)
+
+			report.Disposals = append(report.Disposals, d)
+		}
+	}
+
+	for _, id := range lotIDs {
+		l := lots[id]
+		if !l.open {
+			continue
+		}
+
+		adj := BasisAdjustment{
+			LotID:  id,
+			Symbol: l.symbol,
+			Qty:    decimal.Zero,
+			Amount: decimal.Zero,
+		}
+		for _, r := range l.replacements {
+			adj.Qty = adj.Qty.Add(r.qty)
+			adj.Amount = adj.Amount.Add(r.adjustment)
+		}
+
+		if adj.Amount.GreaterThan(decimal.Zero) {
+			report.Adjustments = append(report.Adjustments, adj)
+		}
+	}
+
+	return report, nil
+}
+
+// washSale looks for lots of the same asset bought within the wash
+// sale window around a sale at a loss, and moves the loss on as many
+// shares as they replace into their basis. The replacement shares are
+// also held as of the original acquisition, so their holding period
+// includes the time the sold shares were held. It returns the
+// disallowed part of the loss.
+func washSale(
+	lots map[uint]*lot,
+	lotIDs []uint,
+	soldLotID uint,
+	assetID uuid.UUID,
+	acquiredAt, soldAt time.Time,
+	qty, loss decimal.Decimal) decimal.Decimal {
+
+	from := soldAt.AddDate(0, 0, -WashSaleDays)
+	to := soldAt.AddDate(0, 0, WashSaleDays)
+
+	disallowed := decimal.Zero
+	remaining := qty
+
+	for _, id := range lotIDs {
+		if remaining.LessThanOrEqual(decimal.Zero) {
+			break
+		}
+
+		l := lots[id]
+
+		if id == soldLotID || l.assetID != assetID {
+			continue
+		}
+
+		if l.acquiredAt.Before(from) || l.acquiredAt.After(to) {
+			continue
+		}
+
+		// the replacement has to still be held after the sale
+		if !l.open && !l.soldAt.After(soldAt) {
+			continue
+		}
+
+		// shares already sold, or already replacing others, can't
+		// replace these
+		available := l.qty.Sub(l.closed).Sub(l.replaced())
+		if available.LessThanOrEqual(decimal.Zero) {
+			continue
+		}
+
+		shares := decimal.Min(available, remaining)
+		amount := loss.Mul(shares).Div(qty).Round(2)
+
+		l.replacements = append(l.replacements, replacement{
+			qty:        shares,
+			adjustment: amount,
+			acquiredAt: l.acquiredAt.Add(-soldAt.Sub(acquiredAt)),
+		})
+
+		disallowed = disallowed.Add(amount)
+		remaining = remaining.Sub(shares)
+	}
+
+	return disallowed
+}
+
+// part is a number of shares of a lot sold together, with the same
+// basis adjustment and acquisition.
+type part struct {
+	qty        decimal.Decimal
+	adjustment decimal.Decimal
+	acquiredAt time.Time
+}
+
+// take sells qty shares of the lot, the replacement shares first, and
+// returns them split by their basis adjustment and acquisition.
+func (l *lot) take(qty decimal.Decimal) []part {
+	parts := []part{}
+	l.closed = l.closed.Add(qty)
+
+	for qty.GreaterThan(decimal.Zero) && len(l.replacements) > 0 {
+		r := &l.replacements[0]
+
+		shares := decimal.Min(qty, r.qty)
+		amount := r.adjustment.Mul(shares).Div(r.qty).Round(2)
+
+		parts = append(parts, part{qty: shares, adjustment: amount, acquiredAt: r.acquiredAt})
+
+		r.qty = r.qty.Sub(shares)
+		r.adjustment = r.adjustment.Sub(amount)
+		if r.qty.LessThanOrEqual(decimal.Zero) {
+			l.replacements = l.replacements[1:]
+		}
+
+		qty = qty.Sub(shares)
+	}
+
+	if qty.GreaterThan(decimal.Zero) {
+		parts = append(parts, part{qty: qty, adjustment: decimal.Zero, acquiredAt: l.acquiredAt})
+	}
+
+	return parts
+}
+
+// replaced is how many shares of the lot still held replace shares
+// sold at a loss.
+func (l *lot) replaced() decimal.Decimal {
+	qty := decimal.Zero
+	for _, r := range l.replacements {
+		qty = qty.Add(r.qty)
+	}
+	return qty
+}
+
+// term is long if the lot was held for more than a year.
+func term(acquiredAt, soldAt time.Time) Term {
+	if soldAt.After(acquiredAt.AddDate(1, 0, 0)) {
+		return LongTerm
+	}
+	return ShortTerm
+}
+
+func zeroTotals() Totals {
+	return Totals{
+		Proceeds:           decimal.Zero,
+		CostBasis:          decimal.Zero,
+		WashSaleDisallowed: decimal.Zero,
+		GainLoss:           decimal.Zero,
+	}
+}
+
+// WriteCSV writes the report's disposals as CSV, one row per lot sold.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{
+		"lot_id",
+		"symbol",
+		"cusip",
+		"qty",
+		"acquired_at",
+		"sold_at",
+		"proceeds",
+		"cost_basis",
+		"wash_sale_disallowed",
+		"gain_loss",
+		"term",
+	}); err != nil {
+		return err
+	}
+
+	for _, d := range r.Disposals {
+		if err := cw.Write([]string{
+			fmt.Sprintf("%v", d.LotID),
+			d.Symbol,
+			d.CUSIP,
+			d.Qty.String(),
+			date.DateOf(d.AcquiredAt.In(calendar.NY)).String(),
+			date.DateOf(d.SoldAt.In(calendar.NY)).String(),
+			d.Proceeds.StringFixed(2),
+			d.CostBasis.StringFixed(2),
+			d.WashSaleDisallowed.StringFixed(2),
+			d.GainLoss.StringFixed(2),
+			string(d.Term),
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
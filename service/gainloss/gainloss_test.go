@@ -0,0 +1,237 @@
+package gainloss
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type GainLossTestSuite struct {
+	dbtest.Suite
+	account *models.Account
+	asset   *models.Asset
+}
+
+func TestGainLossTestSuite(t *testing.T) {
+	suite.Run(t, new(GainLossTestSuite))
+}
+
+func (s *GainLossTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	apexAcct := "apca_test"
+	s.account = &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+	}
+	if err := db.DB().Create(s.account).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+
+	s.asset = &models.Asset{
+		Symbol:   "AAPL",
+		CUSIP:    "037833100",
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NASDAQ",
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	if err := db.DB().Create(s.asset).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+}
+
+func (s *GainLossTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *GainLossTestSuite) position(qty, entry float64, bought time.Time, exit *float64, sold *time.Time) *models.Position {
+	p := &models.Position{
+		AccountID:      s.account.ID,
+		AssetID:        s.asset.IDAsUUID(),
+		EntryOrderID:   uuid.Must(uuid.NewV4()).String(),
+		Side:           models.Long,
+		Status:         models.Open,
+		Qty:            decimal.NewFromFloat(qty),
+		EntryPrice:     decimal.NewFromFloat(entry),
+		EntryTimestamp: bought,
+	}
+
+	if exit != nil {
+		px := decimal.NewFromFloat(*exit)
+		p.Status = models.Closed
+		p.ExitPrice = &px
+		p.ExitTimestamp = sold
+	}
+
+	require.Nil(s.T(), db.DB().Create(p).Error)
+
+	return p
+}
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 10, 0, 0, 0, calendar.NY)
+}
+
+func (s *GainLossTestSuite) TestReport() {
+	srv := gainLossService{tx: db.DB()}
+
+	// held for more than a year, sold for a gain
+	{
+		exit := float64(150)
+		sold := day(2018, 3, 1)
+		s.position(10, 100, day(2017, 1, 3), &exit, &sold)
+	}
+
+	// sold at a $200 loss, and bought back within 30 days
+	{
+		exit := float64(80)
+		sold := day(2018, 2, 1)
+		s.position(10, 100, day(2018, 1, 2), &exit, &sold)
+	}
+
+	// the replacement, sold for $1,200 with a basis of $850 + $200
+	{
+		exit := float64(120)
+		sold := day(2018, 3, 15)
+		s.position(10, 85, day(2018, 2, 10), &exit, &sold)
+	}
+
+	since, _ := date.ParseDate("2018-01-01")
+	until, _ := date.ParseDate("2018-12-31")
+
+	report, err := srv.Report(s.account.IDAsUUID(), since, until)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), report.Disposals, 3)
+
+	// ordered by sale
+	loss := report.Disposals[0]
+	assert.Equal(s.T(), ShortTerm, loss.Term)
+	assert.Equal(s.T(), "200.00", loss.WashSaleDisallowed.StringFixed(2))
+	assert.Equal(s.T(), "0.00", loss.GainLoss.StringFixed(2))
+
+	long := report.Disposals[1]
+	assert.Equal(s.T(), LongTerm, long.Term)
+	assert.Equal(s.T(), "500.00", long.GainLoss.StringFixed(2))
+
+	// the replacement is held as of 30 days before it was bought, as
+	// long as the shares sold at a loss were held
+	replacement := report.Disposals[2]
+	assert.Equal(s.T(), "1050.00", replacement.CostBasis.StringFixed(2))
+	assert.True(s.T(), replacement.AcquiredAt.Equal(day(2018, 1, 11)))
+	assert.Equal(s.T(), "150.00", replacement.GainLoss.StringFixed(2))
+
+	assert.Equal(s.T(), "150.00", report.ShortTerm.GainLoss.StringFixed(2))
+	assert.Equal(s.T(), "500.00", report.LongTerm.GainLoss.StringFixed(2))
+	assert.Equal(s.T(), "650.00", report.Total.GainLoss.StringFixed(2))
+	assert.Empty(s.T(), report.Adjustments)
+
+	// only sales in the range are reported
+	{
+		until, _ := date.ParseDate("2018-02-28")
+		report, err := srv.Report(s.account.IDAsUUID(), since, until)
+		require.Nil(s.T(), err)
+		assert.Len(s.T(), report.Disposals, 1)
+	}
+
+	// csv has a header and a row per disposal
+	{
+		buf := &bytes.Buffer{}
+		require.Nil(s.T(), report.WriteCSV(buf))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(s.T(), lines, 4)
+	}
+
+	// 1099-B for the year
+	{
+		form, err := srv.Form1099B(s.account.IDAsUUID(), 2018)
+		require.Nil(s.T(), err)
+		assert.Equal(s.T(), 2018, form.TaxYear)
+		require.Len(s.T(), form.Transactions, 3)
+		assert.Equal(s.T(), "037833100", form.Transactions[0].CUSIP)
+		assert.True(s.T(), form.Transactions[0].BasisReportedToIRS)
+
+		form, err = srv.Form1099B(s.account.IDAsUUID(), 2017)
+		require.Nil(s.T(), err)
+		assert.Empty(s.T(), form.Transactions)
+	}
+}
+
+func (s *GainLossTestSuite) TestWashSaleClosedShares() {
+	srv := gainLossService{tx: db.DB()}
+
+	apexAcct := "apca_wash"
+	acct := &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+	}
+	require.Nil(s.T(), db.DB().Create(acct).Error)
+
+	position := func(qty float64, bought time.Time, exit *float64, sold *time.Time, lotID *uint) *models.Position {
+		p := &models.Position{
+			AccountID:          acct.ID,
+			AssetID:            s.asset.IDAsUUID(),
+			EntryOrderID:       uuid.Must(uuid.NewV4()).String(),
+			Side:               models.Long,
+			Status:             models.Open,
+			Qty:                decimal.NewFromFloat(qty),
+			EntryPrice:         decimal.NewFromFloat(100),
+			EntryTimestamp:     bought,
+			OriginalPositionID: lotID,
+		}
+		if exit != nil {
+			px := decimal.NewFromFloat(*exit)
+			p.Status = models.Closed
+			p.ExitPrice = &px
+			p.ExitTimestamp = sold
+		}
+		require.Nil(s.T(), db.DB().Create(p).Error)
+		return p
+	}
+
+	// 4 of 10 shares are still held when the other lot is sold at a loss
+	held := position(4, day(2018, 6, 1), nil, nil, nil)
+	{
+		exit := float64(100)
+		sold := day(2018, 6, 5)
+		position(6, day(2018, 6, 1), &exit, &sold, &held.ID)
+	}
+
+	// a $100 loss, of which only 4 shares' worth is disallowed
+	{
+		exit := float64(90)
+		sold := day(2018, 6, 11)
+		position(10, day(2018, 6, 4), &exit, &sold, nil)
+	}
+
+	since, _ := date.ParseDate("2018-01-01")
+	until, _ := date.ParseDate("2018-12-31")
+
+	report, err := srv.Report(acct.IDAsUUID(), since, until)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), report.Disposals, 2)
+
+	loss := report.Disposals[1]
+	assert.Equal(s.T(), "40.00", loss.WashSaleDisallowed.StringFixed(2))
+	assert.Equal(s.T(), "-60.00", loss.GainLoss.StringFixed(2))
+
+	require.Len(s.T(), report.Adjustments, 1)
+	assert.Equal(s.T(), held.ID, report.Adjustments[0].LotID)
+	assert.Equal(s.T(), "4", report.Adjustments[0].Qty.String())
+	assert.Equal(s.T(), "40.00", report.Adjustments[0].Amount.StringFixed(2))
+}
@@ -0,0 +1,303 @@
+package goodfaith
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/audit"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/service/op"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// ViolationLimit is the number of violations in the lookback
+	// period which restricts the account to settled cash
+	ViolationLimit = 3
+	// LookbackMonths is the rolling period violations are counted over
+	LookbackMonths = 12
+	// RestrictionDays is how long the account is restricted for
+	RestrictionDays = 90
+)
+
+// GoodFaithService keeps cash accounts from selling positions before
+// the funds used to buy them have settled, and tracks the violations
+// that happen anyway.
+type GoodFaithService interface {
+	FundsSettleDate(accountID uuid.UUID, tradeDate date.Date, cost decimal.Decimal) (*date.Date, error)
+	CheckSell(acct *models.TradeAccount, assetID string, qty decimal.Decimal, lotIDs []int64, tradeDate date.Date) (*date.Date, error)
+	Record(violation *models.GoodFaithViolation) error
+	Status(accountID uuid.UUID, asof date.Date) (*Status, error)
+	WithTx(tx *gorm.DB) GoodFaithService
+}
+
+type goodFaithService struct {
+	GoodFaithService
+	tx *gorm.DB
+}
+
+func Service() GoodFaithService {
+	return &goodFaithService{}
+}
+
+func (s *goodFaithService) WithTx(tx *gorm.DB) GoodFaithService {
+	s.tx = tx
+	return s
+}
+
+// FundsSettleDate returns when the proceeds used to pay for a purchase
+// settle, or nil if it is paid for with settled cash. The position
+// bought is restricted from being sold until then.
+func (s *goodFaithService) FundsSettleDate(accountID uuid.UUID, tradeDate date.Date, cost decimal.Decimal) (*date.Date, error) {
+	settles, err := ledger.Service().WithTx(s.tx).UnsettledFunding(accountID, tradeDate, cost)
+	if err != nil {
+		return nil, err
+	}
+
+	if settles == nil || !settles.After(tradeDate.Date) {
+		return nil, nil
+	}
+
+	return settles, nil
+}
+
+// CheckSell returns the date the funds used to buy the shares a sell of
+// qty would close settle, if that is after the trade date. Lots are
+// closed in the account's lot relief order, after the shares already
+// held by open sell orders.
+func (s *goodFaithService) CheckSell(
+	acct *models.TradeAccount,
+	assetID string,
+	qty decimal.Decimal,
+	lotIDs []int64,
+	tradeDate date.Date) (*date.Date, error) {
+
+	positions := []models.Position{}
+
+	if err := s.tx.Where(
+		"account_id = ? AND asset_id = ? AND side = ? AND status = ?",
+		acct.ID,
+		assetID,
+		models.Long,
+		models.Open,
+	).Order("entry_timestamp, id").Find(&positions).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	models.SortLots(positions, acct.LotMethod, lotIDs)
+
+	orders := []models.Order{}
+
+	if err := s.tx.Where(
+		"account = ? AND asset_id = ? AND side = ? AND status IN (?) AND replaced_at IS NULL",
+		*acct.ApexAccount,
+		assetID,
+		enum.Sell,
+		enum.OrderOpen,
+	).Find(&orders).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	skip := decimal.Zero
+	for _, o := range orders {
+		if o.FilledQty != nil {
+			skip = skip.Add(o.Qty.Sub(*o.FilledQty))
+		} else {
+			skip = skip.Add(o.Qty)
+		}
+	}
+
+	var settles *date.Date
+
+	remaining := qty
+
+	for i := range positions {
+		p := positions[i]
+
+		if remaining.LessThanOrEqual(decimal.Zero) {
+			break
+		}
+
+		// held for open sell orders
+		if skip.GreaterThanOrEqual(p.Qty) {
+			skip = skip.Sub(p.Qty)
+			continue
+		}
+
+		available := p.Qty.Sub(skip)
+		skip = decimal.Zero
+
+		if p.FundsSettleDate != nil && p.FundsSettleDate.After(tradeDate.Date) {
+			if settles == nil || p.FundsSettleDate.After(settles.Date) {
+				settles = p.FundsSettleDate
+			}
+		}
+
+		remaining = remaining.Sub(available)
+	}
+
+	return settles, nil
+}
+
+// Record stores a violation, and restricts the account to settled cash
+// once it reaches the limit for the lookback period. A violation which
+// was already recorded for the trade date is ignored.
+func (s *goodFaithService) Record(v *models.GoodFaithViolation) error {
+	existing := &models.GoodFaithViolation{}
+
+	q := s.tx.Where("account_id = ? AND trade_date = ?", v.AccountID, v.TradeDate).Find(existing)
+
+	switch {
+	case q.Error == nil:
+		return nil
+	case !q.RecordNotFound():
+		return gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	if err := s.tx.Create(v).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	count, err := s.count(v.AccountID, v.TradeDate)
+	if err != nil {
+		return err
+	}
+
+	log.Info(
+		"good faith violation recorded",
+		"account", v.AccountID,
+		"trade_date", v.TradeDate,
+		"source", v.Source,
+		"violations", count)
+
+	if count < ViolationLimit {
+		return nil
+	}
+
+	forUpdate := db.ForUpdate
+
+	acct, err := op.GetAccountByID(s.tx, uuid.FromStringOrNil(v.AccountID), &forUpdate)
+	if err != nil {
+		return err
+	}
+
+	until := date.Date{Date: v.TradeDate.AddDays(RestrictionDays)}
+
+	if acct.SettledCashOnlyUntil != nil && !until.After(acct.SettledCashOnlyUntil.Date) {
+		return nil
+	}
+
+	before := audit.Snapshot(acct)
+
+	if err = s.tx.Model(acct).Update("settled_cash_only_until", until).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return audit.Record(s.tx, audit.Entry{
+		AccountID:  acct.ID,
+		Resource:   models.AuditAccount,
+		ResourceID: acct.ID,
+		Before:     before,
+		After:      audit.Snapshot(acct),
+	})
+}
+
+// count returns the number of violations in the lookback period
+// ending on asof.
+func (s *goodFaithService) count(accountID string, asof date.Date) (int, error) {
+	var count int
+
+	since := date.DateOf(asof.In(time.UTC).AddDate(0, -LookbackMonths, 0))
+
+	if err := s.tx.Model(&models.GoodFaithViolation{}).
+		Where("account_id = ? AND trade_date > ? AND trade_date <= ?", accountID, since, asof).
+		Count(&count).Error; err != nil {
+		return 0, gberrors.InternalServerError.WithError(err)
+	}
+
+	return count, nil
+}
+
+// RestrictedPosition is an open position which can't be sold without
+// a violation until the funds used to buy it settle.
+type RestrictedPosition struct {
+	Symbol          string          `json:"symbol"`
+	Qty             decimal.Decimal `json:"qty"`
+	FundsSettleDate date.Date       `json:"funds_settle_date"`
+}
+
+// Status is the account's standing with respect to good faith
+// violations.
+type Status struct {
+	Protected            bool                        `json:"protected"`
+	Violations           int                         `json:"violations"`
+	ViolationLimit       int                         `json:"violation_limit"`
+	SettledCashOnly      bool                        `json:"settled_cash_only"`
+	SettledCashOnlyUntil *date.Date                  `json:"settled_cash_only_until"`
+	History              []models.GoodFaithViolation `json:"history"`
+	RestrictedPositions  []RestrictedPosition        `json:"restricted_positions"`
+}
+
+func (s *goodFaithService) Status(accountID uuid.UUID, asof date.Date) (*Status, error) {
+	acct, err := op.GetAccountByID(s.tx, accountID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.count(acct.ID, asof)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Protected:            acct.ProtectGoodFaith,
+		Violations:           count,
+		ViolationLimit:       ViolationLimit,
+		SettledCashOnly:      acct.SettledCashOnlyUntil != nil && acct.SettledCashOnlyUntil.After(asof.Date),
+		SettledCashOnlyUntil: acct.SettledCashOnlyUntil,
+		History:              []models.GoodFaithViolation{},
+		RestrictedPositions:  []RestrictedPosition{},
+	}
+
+	if err = s.tx.
+		Where("account_id = ?", acct.ID).
+		Order("trade_date desc").
+		Find(&status.History).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	positions := []models.Position{}
+
+	if err = s.tx.
+		Where("account_id = ? AND status = ? AND funds_settle_date > ?", acct.ID, models.Open, asof).
+		Preload("Asset").
+		Order("created_at").
+		Find(&positions).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	for _, p := range positions {
+		status.RestrictedPositions = append(status.RestrictedPositions, RestrictedPosition{
+			Symbol:          p.Asset.Symbol,
+			Qty:             p.Qty,
+			FundsSettleDate: *p.FundsSettleDate,
+		})
+	}
+
+	return status, nil
+}
+
+// ViolationMessage describes why a sell would be a violation.
+func ViolationMessage(settles date.Date) string {
+	return fmt.Sprintf(
+		"sell would cause a good faith violation, the funds used to buy these shares settle on %v",
+		settles)
+}
@@ -0,0 +1,182 @@
+package goodfaith
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type GoodFaithTestSuite struct {
+	dbtest.Suite
+	account *models.Account
+	asset   *models.Asset
+}
+
+func TestGoodFaithTestSuite(t *testing.T) {
+	suite.Run(t, new(GoodFaithTestSuite))
+}
+
+func (s *GoodFaithTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	apexAcct := "apca_test"
+	s.account = &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+		ProtectGoodFaith:   true,
+	}
+	if err := db.DB().Create(s.account).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+
+	s.asset = &models.Asset{
+		Symbol:   "AAPL",
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NASDAQ",
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	if err := db.DB().Create(s.asset).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+}
+
+func (s *GoodFaithTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *GoodFaithTestSuite) TestGoodFaith() {
+	srv := goodFaithService{tx: db.DB()}
+	acctID := s.account.IDAsUUID()
+
+	// thursday 2018-01-25, settles monday 2018-01-29
+	tradeDate, _ := date.ParseDate("2018-01-25")
+	settleDate, _ := date.ParseDate("2018-01-29")
+
+	// $1,000 of settled cash, and $1,000 of proceeds from a sale
+	{
+		depositDate, _ := date.ParseDate("2018-01-18")
+		require.Nil(s.T(), ledger.Service().WithTx(db.DB()).PostTransfer(&models.Transfer{
+			ID:        uuid.Must(uuid.NewV4()).String(),
+			AccountID: s.account.ID,
+			Type:      enum.ACH,
+			Direction: apex.Incoming,
+			Amount:    decimal.NewFromFloat(1000),
+		}, depositDate))
+
+		qty := decimal.NewFromFloat(10)
+		px := decimal.NewFromFloat(100)
+		require.Nil(s.T(), ledger.Service().WithTx(db.DB()).PostFill(s.account.ID, &models.Execution{
+			ID:              uuid.Must(uuid.NewV4()).String(),
+			Symbol:          "MSFT",
+			Side:            enum.Sell,
+			Qty:             &qty,
+			Price:           &px,
+			TransactionTime: time.Date(2018, 1, 25, 10, 0, 0, 0, calendar.NY),
+		}))
+	}
+
+	// paid for with settled cash
+	settles, err := srv.FundsSettleDate(acctID, tradeDate, decimal.NewFromFloat(500))
+	require.Nil(s.T(), err)
+	assert.Nil(s.T(), settles)
+
+	// paid for partly with the sale proceeds
+	settles, err = srv.FundsSettleDate(acctID, tradeDate, decimal.NewFromFloat(1500))
+	require.Nil(s.T(), err)
+	require.NotNil(s.T(), settles)
+	assert.Equal(s.T(), settleDate, *settles)
+
+	require.Nil(s.T(), db.DB().Create(&models.Position{
+		AccountID:       s.account.ID,
+		AssetID:         s.asset.IDAsUUID(),
+		EntryOrderID:    uuid.Must(uuid.NewV4()).String(),
+		Status:          models.Open,
+		Side:            models.Long,
+		Qty:             decimal.NewFromFloat(15),
+		EntryPrice:      decimal.NewFromFloat(100),
+		EntryTimestamp:  time.Date(2018, 1, 25, 11, 0, 0, 0, calendar.NY),
+		FundsSettleDate: settles,
+	}).Error)
+
+	ta, err := s.account.ToTradeAccount()
+	require.Nil(s.T(), err)
+
+	// selling before settlement is a violation
+	{
+		d, err := srv.CheckSell(ta, s.asset.ID, decimal.NewFromFloat(5), nil, tradeDate)
+		require.Nil(s.T(), err)
+		require.NotNil(s.T(), d)
+		assert.Equal(s.T(), settleDate, *d)
+	}
+
+	// selling on settlement date isn't
+	{
+		d, err := srv.CheckSell(ta, s.asset.ID, decimal.NewFromFloat(5), nil, settleDate)
+		require.Nil(s.T(), err)
+		assert.Nil(s.T(), d)
+	}
+
+	{
+		status, err := srv.Status(acctID, tradeDate)
+		require.Nil(s.T(), err)
+		assert.True(s.T(), status.Protected)
+		assert.Len(s.T(), status.RestrictedPositions, 1)
+		assert.False(s.T(), status.SettledCashOnly)
+	}
+
+	// three violations in 12 months restricts the account
+	violations := []string{"2018-01-25", "2018-02-01", "2018-02-08"}
+
+	for i, d := range violations {
+		td, _ := date.ParseDate(d)
+
+		require.Nil(s.T(), srv.Record(&models.GoodFaithViolation{
+			AccountID: s.account.ID,
+			TradeDate: td,
+			Source:    models.GoodFaithSourceFill,
+		}))
+
+		// the same day again is ignored
+		require.Nil(s.T(), srv.Record(&models.GoodFaithViolation{
+			AccountID: s.account.ID,
+			TradeDate: td,
+			Source:    models.GoodFaithSourceApex,
+		}))
+
+		acct := &models.Account{}
+		require.Nil(s.T(), db.DB().Where("id = ?", s.account.ID).Find(acct).Error)
+
+		if i < len(violations)-1 {
+			assert.Nil(s.T(), acct.SettledCashOnlyUntil)
+		} else {
+			require.NotNil(s.T(), acct.SettledCashOnlyUntil)
+			assert.Equal(s.T(), "2018-05-09", acct.SettledCashOnlyUntil.String())
+		}
+	}
+
+	{
+		asof, _ := date.ParseDate("2018-02-08")
+
+		status, err := srv.Status(acctID, asof)
+		require.Nil(s.T(), err)
+		assert.Equal(s.T(), 3, status.Violations)
+		assert.Len(s.T(), status.History, 3)
+		assert.True(s.T(), status.SettledCashOnly)
+		assert.Empty(s.T(), status.RestrictedPositions)
+	}
+}
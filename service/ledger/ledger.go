@@ -0,0 +1,424 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// LedgerService records an account's cash movements as balanced
+// journals, so that settled and unsettled cash can be known at
+// any point in the day rather than only after the start of day
+// files are synced.
+type LedgerService interface {
+	Post(journal *models.Journal) (*models.Journal, error)
+	PostFill(accountID string, exec *models.Execution) error
+	PostFee(accountID string, exec *models.Execution, fee decimal.Decimal) error
+	PostFeeAdjustment(accountID string, exec *models.Execution, diff decimal.Decimal) error
+	PostDividend(div *models.Dividend, amount decimal.Decimal, asof date.Date) error
+	PostTransfer(xfer *models.Transfer, asof date.Date) error
+	PostTransferReturn(xfer *models.Transfer, asof date.Date) error
+	PostCorporateAction(accountID, referenceID, description string, amount decimal.Decimal, asof date.Date) error
+	Balances(accountID uuid.UUID, asof date.Date) (*models.CashBalances, error)
+	UnsettledFunding(accountID uuid.UUID, asof date.Date, cost decimal.Decimal) (*date.Date, error)
+	Journals(accountID uuid.UUID, since, until date.Date) ([]models.Journal, error)
+	Reconcile(accountID uuid.UUID, asof date.Date, cash decimal.Decimal) (*Reconciliation, error)
+	WithTx(tx *gorm.DB) LedgerService
+}
+
+type ledgerService struct {
+	LedgerService
+	tx *gorm.DB
+}
+
+func Service() LedgerService {
+	return &ledgerService{}
+}
+
+func (s *ledgerService) WithTx(tx *gorm.DB) LedgerService {
+	s.tx = tx
+	return s
+}
+
+// Post stores the journal and its entries. A journal that was already
+// posted for the same type and reference is returned as is, so callers
+// can safely post activity again when files are re-processed.
+func (s *ledgerService) Post(journal *models.Journal) (*models.Journal, error) {
+	if len(journal.Entries) < 2 {
+		return nil, gberrors.InternalServerError.WithMsg("journal requires at least two entries")
+	}
+
+	if !journal.Balanced() {
+		return nil, gberrors.InternalServerError.WithMsg(
+			fmt.Sprintf("journal %v %v is not balanced", journal.Type, journal.ReferenceID))
+	}
+
+	existing := &models.Journal{}
+
+	q := s.tx.
+		Where("type = ? AND reference_id = ?", journal.Type, journal.ReferenceID).
+		Preload("Entries").
+		Find(existing)
+
+	if q.Error == nil {
+		return existing, nil
+	}
+
+	if !q.RecordNotFound() {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	for i := range journal.Entries {
+		journal.Entries[i].AccountID = journal.AccountID
+		journal.Entries[i].TradeDate = journal.TradeDate
+		journal.Entries[i].SettleDate = journal.SettleDate
+	}
+
+	if err := s.tx.Create(journal).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return journal, nil
+}
+
+// post is a shortcut for the common two sided journal, where the
+// customer's cash moves against one other ledger account.
+func (s *ledgerService) post(
+	accountID string,
+	typ enum.JournalType,
+	referenceID, description string,
+	contra enum.LedgerAccount,
+	amount decimal.Decimal,
+	tradeDate, settleDate date.Date) error {
+
+	_, err := s.Post(&models.Journal{
+		AccountID:   accountID,
+		Type:        typ,
+		ReferenceID: referenceID,
+		Description: description,
+		TradeDate:   tradeDate,
+		SettleDate:  settleDate,
+		Entries: []models.JournalEntry{
+			{Ledger: enum.LedgerCash, Amount: amount},
+			{Ledger: contra, Amount: amount.Neg()},
+		},
+	})
+
+	return err
+}
+
+// PostFill books the cash side of an execution, settling T+2 from
+// the trading date it was executed on.
+func (s *ledgerService) PostFill(accountID string, exec *models.Execution) error {
+	if exec.Qty == nil || exec.Price == nil {
+		return gberrors.InternalServerError.WithMsg(
+			fmt.Sprintf("execution %v is missing qty or price", exec.ID))
+	}
+
+	amount := exec.Qty.Mul(*exec.Price)
+	if exec.Side == enum.Buy {
+		amount = amount.Neg()
+	}
+
+	td := tradingdate.TradeDate(exec.TransactionTime)
+
+	return s.post(
+		accountID,
+		enum.JournalFill,
+		exec.ID,
+		fmt.Sprintf("%v %v %v @ %v", exec.Side, exec.Qty, exec.Symbol, exec.Price),
+		enum.LedgerSecurities,
+		amount,
+		td.Date(),
+		td.Settlement().Date())
+}
+
+// PostFee books the regulatory and clearing fees for an execution,
+// which settle with the trade they were charged on.
+func (s *ledgerService) PostFee(accountID string, exec *models.Execution, fee decimal.Decimal) error {
+	if fee.Equal(decimal.Zero) {
+		return nil
+	}
+
+	td := tradingdate.TradeDate(exec.TransactionTime)
+
+	return s.post(
+		accountID,
+		enum.JournalFee,
+		exec.ID,
+		fmt.Sprintf("fees for %v %v", exec.Side, exec.Symbol),
+		enum.LedgerFees,
+		fee.Neg(),
+		td.Date(),
+		td.Settlement().Date())
+}
+
+// PostFeeAdjustment books the difference between the fees charged
+// for an execution at fill time, and what the clearing broker charged.
+func (s *ledgerService) PostFeeAdjustment(accountID string, exec *models.Execution, diff decimal.Decimal) error {
+	if diff.Equal(decimal.Zero) {
+		return nil
+	}
+
+	td := tradingdate.TradeDate(exec.TransactionTime)
+
+	return s.post(
+		accountID,
+		enum.JournalFee,
+		fmt.Sprintf("adjustment:%v", exec.ID),
+		fmt.Sprintf("fee adjustment for %v %v", exec.Side, exec.Symbol),
+		enum.LedgerFees,
+		diff.Neg(),
+		td.Date(),
+		td.Settlement().Date())
+}
+
+// PostDividend books a dividend payment, which is settled as of the
+// day it was paid.
+func (s *ledgerService) PostDividend(div *models.Dividend, amount decimal.Decimal, asof date.Date) error {
+	return s.post(
+		div.AccountID,
+		enum.JournalDividend,
+		fmt.Sprintf("%v:%v:%v", div.AccountID, div.AssetID, div.ExchangeDate),
+		fmt.Sprintf("dividend %v", div.Symbol),
+		enum.LedgerIncome,
+		amount,
+		asof,
+		asof)
+}
+
+// PostTransfer books a deposit or withdrawal once the clearing
+// broker has processed it.
+func (s *ledgerService) PostTransfer(xfer *models.Transfer, asof date.Date) error {
+	amount := xfer.Amount
+	if xfer.Direction == apex.Outgoing {
+		amount = amount.Neg()
+	}
+
+	return s.post(
+		xfer.AccountID,
+		enum.JournalTransfer,
+		xfer.ID,
+		fmt.Sprintf("%v %v transfer", xfer.Direction, xfer.Type),
+		enum.LedgerBank,
+		amount,
+		asof,
+		asof)
+}
+
+// PostTransferReturn reverses a transfer which was booked, and then
+// returned by the bank.
+func (s *ledgerService) PostTransferReturn(xfer *models.Transfer, asof date.Date) error {
+	amount := xfer.Amount.Neg()
+	if xfer.Direction == apex.Outgoing {
+		amount = xfer.Amount
+	}
+
+	return s.post(
+		xfer.AccountID,
+		enum.JournalTransferReturn,
+		xfer.ID,
+		fmt.Sprintf("%v %v transfer returned (%v)", xfer.Direction, xfer.Type, xfer.ReasonCode),
+		enum.LedgerBank,
+		amount,
+		asof,
+		asof)
+}
+
+// PostCorporateAction books cash paid (or charged) by a corporate
+// action, such as cash in lieu of fractional shares.
+func (s *ledgerService) PostCorporateAction(
+	accountID, referenceID, description string,
+	amount decimal.Decimal,
+	asof date.Date) error {
+
+	return s.post(
+		accountID,
+		enum.JournalCorporateAction,
+		referenceID,
+		description,
+		enum.LedgerCorporateActions,
+		amount,
+		asof,
+		asof)
+}
+
+// Balances returns the account's cash as of the end of the given
+// date. Cash from trades which have not settled yet can be traded
+// with, but not withdrawn, while unsettled purchases (and pending
+// withdrawals) count against what can be withdrawn right away.
+func (s *ledgerService) Balances(accountID uuid.UUID, asof date.Date) (*models.CashBalances, error) {
+	sums := struct {
+		Cash            decimal.Decimal
+		Settled         decimal.Decimal
+		UnsettledDebits decimal.Decimal
+	}{}
+
+	if err := s.tx.Raw(`
+		SELECT
+			COALESCE(SUM(amount), 0) AS cash,
+			COALESCE(SUM(CASE WHEN settle_date <= ? THEN amount ELSE 0 END), 0) AS settled,
+			COALESCE(SUM(CASE WHEN settle_date > ? AND amount < 0 THEN amount ELSE 0 END), 0) AS unsettled_debits
+		FROM journal_entries
+		WHERE account_id = ? AND ledger = ? AND trade_date <= ?`,
+		asof, asof, accountID.String(), enum.LedgerCash, asof).
+		Scan(&sums).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	pending := []models.Transfer{}
+
+	if err := s.tx.Where(
+		`account_id = ? AND
+		direction = ? AND
+		status NOT IN (?)
+		AND batch_processed_at IS NULL`,
+		accountID.String(), apex.Outgoing, []enum.TransferStatus{
+			enum.TransferRejected,
+			enum.TransferCanceled,
+			enum.TransferReturned,
+			enum.TransferVoid,
+			enum.TransferStopPayment,
+		}).Find(&pending).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	withdrawable := sums.Settled.Add(sums.UnsettledDebits)
+	for _, xfer := range pending {
+		withdrawable = withdrawable.Sub(xfer.Amount)
+	}
+
+	return &models.CashBalances{
+		Cash:         sums.Cash,
+		Settled:      sums.Settled,
+		Unsettled:    sums.Cash.Sub(sums.Settled),
+		Withdrawable: decimal.Max(withdrawable, decimal.Zero),
+	}, nil
+}
+
+// UnsettledFunding returns the date the proceeds needed to pay for a
+// purchase of cost on asof settle, or nil if settled cash covers it.
+// Proceeds are used in the order they settle. If they still don't
+// cover the purchase the rest is on margin, and the last date is
+// returned.
+func (s *ledgerService) UnsettledFunding(accountID uuid.UUID, asof date.Date, cost decimal.Decimal) (*date.Date, error) {
+	balances, err := s.Balances(accountID, asof)
+	if err != nil {
+		return nil, err
+	}
+
+	shortfall := cost.Sub(balances.Withdrawable)
+	if shortfall.LessThanOrEqual(decimal.Zero) {
+		return nil, nil
+	}
+
+	credits := []models.JournalEntry{}
+
+	if err = s.tx.
+		Where(
+			"account_id = ? AND ledger = ? AND amount > 0 AND trade_date <= ? AND settle_date > ?",
+			accountID.String(), enum.LedgerCash, asof, asof).
+		Order("settle_date").
+		Find(&credits).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	var settles *date.Date
+
+	for i := range credits {
+		settles = &credits[i].SettleDate
+		shortfall = shortfall.Sub(credits[i].Amount)
+
+		if shortfall.LessThanOrEqual(decimal.Zero) {
+			break
+		}
+	}
+
+	return settles, nil
+}
+
+// Journals returns the journals booked for the account with a trade
+// date in the given range (inclusive).
+func (s *ledgerService) Journals(accountID uuid.UUID, since, until date.Date) ([]models.Journal, error) {
+	journals := []models.Journal{}
+
+	if err := s.tx.
+		Where("account_id = ? AND trade_date BETWEEN ? AND ?", accountID.String(), since, until).
+		Preload("Entries").
+		Order("trade_date, created_at").
+		Find(&journals).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return journals, nil
+}
+
+// Reconciliation is the result of comparing the ledger's cash to the
+// clearing broker's for a day.
+type Reconciliation struct {
+	Expected   decimal.Decimal `json:"expected"`
+	Ledger     decimal.Decimal `json:"ledger"`
+	Difference decimal.Decimal `json:"difference"`
+	// Opening is true when the account had nothing in the ledger,
+	// and the adjustment is simply its opening balance.
+	Opening    bool            `json:"opening"`
+	Adjustment *models.Journal `json:"adjustment"`
+}
+
+// Reconcile compares the ledger's cash as of the end of the day to the
+// balance reported by the clearing broker. Any difference is booked to
+// the suspense account so the ledger tracks the books of record, and is
+// returned so it can be investigated.
+func (s *ledgerService) Reconcile(accountID uuid.UUID, asof date.Date, cash decimal.Decimal) (*Reconciliation, error) {
+	balances, err := s.Balances(accountID, asof)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reconciliation{
+		Expected:   cash,
+		Ledger:     balances.Cash,
+		Difference: cash.Sub(balances.Cash),
+	}
+
+	if r.Difference.Equal(decimal.Zero) {
+		return r, nil
+	}
+
+	var count int
+	if err = s.tx.Model(&models.Journal{}).
+		Where("account_id = ? AND trade_date <= ?", accountID.String(), asof).
+		Count(&count).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	r.Opening = count == 0
+
+	description := "reconciliation with clearing balance"
+	if r.Opening {
+		description = "opening balance"
+	}
+
+	r.Adjustment, err = s.Post(&models.Journal{
+		AccountID:   accountID.String(),
+		Type:        enum.JournalAdjustment,
+		ReferenceID: fmt.Sprintf("%v:%v", accountID, asof),
+		Description: description,
+		TradeDate:   asof,
+		SettleDate:  asof,
+		Entries: []models.JournalEntry{
+			{Ledger: enum.LedgerCash, Amount: r.Difference},
+			{Ledger: enum.LedgerSuspense, Amount: r.Difference.Neg()},
+		},
+	})
+
+	return r, err
+}
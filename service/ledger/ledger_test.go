@@ -0,0 +1,162 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LedgerTestSuite struct {
+	dbtest.Suite
+	account *models.Account
+}
+
+func TestLedgerTestSuite(t *testing.T) {
+	suite.Run(t, new(LedgerTestSuite))
+}
+
+func (s *LedgerTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	apexAcct := "apca_test"
+	s.account = &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+	}
+	if err := db.DB().Create(s.account).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+}
+
+func (s *LedgerTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *LedgerTestSuite) TestLedger() {
+	srv := ledgerService{tx: db.DB()}
+	acctID := s.account.IDAsUUID()
+
+	// thursday 2018-01-25, settles monday 2018-01-29
+	tradeDate, _ := date.ParseDate("2018-01-25")
+	settleDate, _ := date.ParseDate("2018-01-29")
+
+	// unbalanced journals are rejected
+	_, err := srv.Post(&models.Journal{
+		AccountID:   s.account.ID,
+		Type:        enum.JournalAdjustment,
+		ReferenceID: "unbalanced",
+		TradeDate:   tradeDate,
+		SettleDate:  tradeDate,
+		Entries: []models.JournalEntry{
+			{Ledger: enum.LedgerCash, Amount: decimal.NewFromFloat(1)},
+			{Ledger: enum.LedgerSuspense, Amount: decimal.NewFromFloat(2)},
+		},
+	})
+	assert.NotNil(s.T(), err)
+
+	// deposit $1,000
+	deposit := &models.Transfer{
+		ID:        uuid.Must(uuid.NewV4()).String(),
+		AccountID: s.account.ID,
+		Type:      enum.ACH,
+		Direction: apex.Incoming,
+		Amount:    decimal.NewFromFloat(1000),
+	}
+	require.Nil(s.T(), srv.PostTransfer(deposit, tradeDate))
+
+	// posting again is a no-op
+	require.Nil(s.T(), srv.PostTransfer(deposit, tradeDate))
+
+	// buy 10 @ $50
+	qty := decimal.NewFromFloat(10)
+	px := decimal.NewFromFloat(50)
+	buy := &models.Execution{
+		ID:              uuid.Must(uuid.NewV4()).String(),
+		Symbol:          "AAPL",
+		Side:            enum.Buy,
+		Qty:             &qty,
+		Price:           &px,
+		TransactionTime: time.Date(2018, 1, 25, 10, 0, 0, 0, calendar.NY),
+	}
+	require.Nil(s.T(), srv.PostFill(s.account.ID, buy))
+	require.Nil(s.T(), srv.PostFee(s.account.ID, buy, decimal.NewFromFloat(0.01)))
+
+	// sell 4 @ $60
+	sellQty := decimal.NewFromFloat(4)
+	sellPx := decimal.NewFromFloat(60)
+	sell := &models.Execution{
+		ID:              uuid.Must(uuid.NewV4()).String(),
+		Symbol:          "AAPL",
+		Side:            enum.Sell,
+		Qty:             &sellQty,
+		Price:           &sellPx,
+		TransactionTime: time.Date(2018, 1, 25, 14, 0, 0, 0, calendar.NY),
+	}
+	require.Nil(s.T(), srv.PostFill(s.account.ID, sell))
+
+	// trade date - the purchase holds back cash, the sale proceeds
+	// are not withdrawable yet
+	{
+		balances, err := srv.Balances(acctID, tradeDate)
+		require.Nil(s.T(), err)
+		assert.True(s.T(), balances.Cash.Equal(decimal.NewFromFloat(739.99)))
+		assert.True(s.T(), balances.Settled.Equal(decimal.NewFromFloat(1000)))
+		assert.True(s.T(), balances.Unsettled.Equal(decimal.NewFromFloat(-260.01)))
+		assert.True(s.T(), balances.Withdrawable.Equal(decimal.NewFromFloat(499.99)))
+	}
+
+	// settlement date - everything is settled
+	{
+		balances, err := srv.Balances(acctID, settleDate)
+		require.Nil(s.T(), err)
+		assert.True(s.T(), balances.Cash.Equal(decimal.NewFromFloat(739.99)))
+		assert.True(s.T(), balances.Settled.Equal(decimal.NewFromFloat(739.99)))
+		assert.True(s.T(), balances.Unsettled.Equal(decimal.Zero))
+		assert.True(s.T(), balances.Withdrawable.Equal(decimal.NewFromFloat(739.99)))
+	}
+
+	journals, err := srv.Journals(acctID, tradeDate, settleDate)
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), journals, 4)
+	for _, j := range journals {
+		assert.True(s.T(), j.Balanced())
+	}
+
+	// reconcile with the clearing balance, which is off by a cent
+	{
+		r, err := srv.Reconcile(acctID, tradeDate, decimal.NewFromFloat(740))
+		require.Nil(s.T(), err)
+		assert.False(s.T(), r.Opening)
+		assert.True(s.T(), r.Difference.Equal(decimal.NewFromFloat(0.01)))
+		require.NotNil(s.T(), r.Adjustment)
+
+		// and now it matches
+		r, err = srv.Reconcile(acctID, tradeDate, decimal.NewFromFloat(740))
+		require.Nil(s.T(), err)
+		assert.True(s.T(), r.Difference.Equal(decimal.Zero))
+		assert.Nil(s.T(), r.Adjustment)
+	}
+
+	// the deposit is returned
+	{
+		require.Nil(s.T(), srv.PostTransferReturn(deposit, settleDate))
+
+		balances, err := srv.Balances(acctID, settleDate)
+		require.Nil(s.T(), err)
+		assert.True(s.T(), balances.Cash.Equal(decimal.NewFromFloat(-260)))
+		assert.True(s.T(), balances.Withdrawable.Equal(decimal.Zero))
+	}
+}
@@ -0,0 +1,120 @@
+package marketdata
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/gobroker/external/mkts"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/marketstore/frontend"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/alpacahq/polycache/rest/client"
+	"github.com/alpacahq/polycache/structures"
+	"github.com/pkg/errors"
+)
+
+type live struct{}
+
+// Live returns the market data served by polycache for the latest
+// trades and quotes, and by marketstore for bars.
+func Live() MarketData {
+	return &live{}
+}
+
+func (l *live) LastTrades(symbols []string) (map[string]structures.Trade, error) {
+	return client.GetTrades(symbols)
+}
+
+func (l *live) Quotes(symbols []string) (map[string]structures.Quote, error) {
+	return client.GetQuotes(symbols)
+}
+
+func (l *live) Bars(symbols []string, timeframe string, start, end *time.Time, limit *int) (io.ColumnSeriesMap, error) {
+	if len(symbols) == 0 {
+		return io.NewColumnSeriesMap(), nil
+	}
+
+	builder := mkts.NewQueryRequestBuilder(fmt.Sprintf("%v/%v/OHLCV", strings.Join(symbols, ","), timeframe))
+
+	if start != nil {
+		builder.EpochStart(start.Unix())
+	}
+
+	if end != nil {
+		builder.EpochEnd(end.Unix())
+	}
+
+	if limit != nil {
+		builder.LimitRecordCount(*limit)
+	}
+
+	return query(&frontend.MultiQueryRequest{
+		Requests: []frontend.QueryRequest{builder.End()},
+	})
+}
+
+// LastClose is the last minute bar's close before the market closed,
+// as the daily bars are only written after the close.
+func (l *live) LastClose(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+
+	args := &frontend.MultiQueryRequest{}
+	prev := lastCloseDate(on)
+
+	for _, symbol := range symbols {
+		args.Requests = append(args.Requests,
+			mkts.NewQueryRequestBuilder(fmt.Sprintf("%v/1Min/OHLCV", symbol)).
+				EpochEnd(prev.MarketClose().Unix()-1).
+				LimitRecordCount(1).
+				End())
+	}
+
+	csm, err := query(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query mktsdb")
+	}
+
+	return lastTrades(csm), nil
+}
+
+func query(args *frontend.MultiQueryRequest) (io.ColumnSeriesMap, error) {
+	resp, err := mkts.Client().DoRPC("Query", args)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp == nil {
+		return io.NewColumnSeriesMap(), nil
+	}
+
+	return *resp.(*io.ColumnSeriesMap), nil
+}
+
+// lastTrades returns the close of the last bar of each symbol as a
+// trade.
+func lastTrades(csm io.ColumnSeriesMap) map[string]structures.Trade {
+	trades := make(map[string]structures.Trade, len(csm))
+
+	for tbk, cs := range csm {
+		if cs == nil {
+			continue
+		}
+
+		closes, _ := cs.GetByName("Close").([]float32)
+		epochs, _ := cs.GetByName("Epoch").([]int64)
+
+		if len(closes) == 0 || len(epochs) == 0 {
+			continue
+		}
+
+		trades[tbk.GetItems()[0]] = structures.Trade{
+			Timestamp: time.Unix(epochs[len(epochs)-1], 0),
+			Price:     float64(closes[len(closes)-1]),
+		}
+	}
+
+	return trades
+}
@@ -0,0 +1,45 @@
+package marketdata
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/env"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/alpacahq/polycache/structures"
+)
+
+// MarketData is where the broker gets its prices from. Services take
+// it through the registry rather than calling marketstore or polycache
+// themselves, so they can run against a local store.
+type MarketData interface {
+	// LastTrades returns the latest trade of each symbol. Symbols without
+	// a trade are left out.
+	LastTrades(symbols []string) (map[string]structures.Trade, error)
+	// Quotes returns the latest quote of each symbol. Symbols without a
+	// quote are left out.
+	Quotes(symbols []string) (map[string]structures.Quote, error)
+	// Bars returns the OHLCV bars of each symbol in the timeframe, keyed
+	// by {symbol}/{timeframe}/OHLCV. Bars are limited to the ones between
+	// start and end when given, and to the last limit of them.
+	Bars(symbols []string, timeframe string, start, end *time.Time, limit *int) (io.ColumnSeriesMap, error)
+	// LastClose returns the closing price of each symbol on the trading
+	// day before on, or before the current trading day if on is nil.
+	LastClose(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error)
+}
+
+// FromEnv returns the local store in MARKET_DATA_DIR if it is set, and
+// the live feeds otherwise.
+func FromEnv() (MarketData, error) {
+	if dir := env.GetVar("MARKET_DATA_DIR"); dir != "" {
+		return Load(dir)
+	}
+	return Live(), nil
+}
+
+func lastCloseDate(on *tradingdate.TradingDate) tradingdate.TradingDate {
+	if on == nil {
+		return tradingdate.Current().Prev()
+	}
+	return on.Prev()
+}
@@ -0,0 +1,305 @@
+package marketdata
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/marketstore/utils/io"
+	"github.com/alpacahq/polycache/structures"
+	"github.com/gocarina/gocsv"
+	"github.com/pkg/errors"
+)
+
+// Bar is a row of a bars file.
+type Bar struct {
+	Time   time.Time `csv:"time"`
+	Open   float32   `csv:"open"`
+	High   float32   `csv:"high"`
+	Low    float32   `csv:"low"`
+	Close  float32   `csv:"close"`
+	Volume int32     `csv:"volume"`
+}
+
+// Trade is a row of the trades file.
+type Trade struct {
+	Symbol string    `csv:"symbol"`
+	Time   time.Time `csv:"time"`
+	Price  float64   `csv:"price"`
+	Size   int64     `csv:"size"`
+}
+
+// Quote is a row of the quotes file.
+type Quote struct {
+	Symbol   string    `csv:"symbol"`
+	Time     time.Time `csv:"time"`
+	BidPrice float64   `csv:"bid_price"`
+	BidSize  int64     `csv:"bid_size"`
+	AskPrice float64   `csv:"ask_price"`
+	AskSize  int64     `csv:"ask_size"`
+}
+
+// Store is market data held in memory, for running the broker offline
+// and deterministically. The same store always answers the same way,
+// whatever the time is.
+type Store struct {
+	sync.RWMutex
+	trades map[string]structures.Trade
+	quotes map[string]structures.Quote
+	// bars by {symbol}/{timeframe}, in time order
+	bars map[string][]Bar
+}
+
+// NewStore returns an empty store.
+func NewStore() *Store {
+	return &Store{
+		trades: map[string]structures.Trade{},
+		quotes: map[string]structures.Quote{},
+		bars:   map[string][]Bar{},
+	}
+}
+
+// Load reads a store from the CSV files in dir, laid out as
+//
+//	trades.csv                      symbol,time,price,size
+//	quotes.csv                      symbol,time,bid_price,bid_size,ask_price,ask_size
+//	bars/{symbol}/{timeframe}.csv   time,open,high,low,close,volume
+//
+// with times in RFC 3339. Any of them may be missing.
+func Load(dir string) (*Store, error) {
+	s := NewStore()
+
+	trades := []Trade{}
+	if err := readCSV(filepath.Join(dir, "trades.csv"), &trades); err != nil {
+		return nil, err
+	}
+	for _, t := range trades {
+		s.SetTrade(t.Symbol, structures.Trade{Timestamp: t.Time, Price: t.Price, Size: t.Size})
+	}
+
+	quotes := []Quote{}
+	if err := readCSV(filepath.Join(dir, "quotes.csv"), &quotes); err != nil {
+		return nil, err
+	}
+	for _, q := range quotes {
+		s.SetQuote(q.Symbol, structures.Quote{
+			Timestamp: q.Time,
+			BidPrice:  q.BidPrice,
+			BidSize:   q.BidSize,
+			AskPrice:  q.AskPrice,
+			AskSize:   q.AskSize,
+		})
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "bars", "*", "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		var (
+			symbol    = filepath.Base(filepath.Dir(file))
+			timeframe = strings.TrimSuffix(filepath.Base(file), ".csv")
+			bars      = []Bar{}
+		)
+
+		if err := readCSV(file, &bars); err != nil {
+			return nil, err
+		}
+
+		s.SetBars(symbol, timeframe, bars)
+	}
+
+	return s, nil
+}
+
+func readCSV(path string, out interface{}) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = gocsv.UnmarshalFile(f, out); err != nil {
+		return errors.Wrapf(err, "failed to read %v", path)
+	}
+
+	return nil
+}
+
+// SetTrade sets the latest trade of the symbol.
+func (s *Store) SetTrade(symbol string, trade structures.Trade) {
+	s.Lock()
+	defer s.Unlock()
+	s.trades[symbol] = trade
+}
+
+// SetQuote sets the latest quote of the symbol.
+func (s *Store) SetQuote(symbol string, quote structures.Quote) {
+	s.Lock()
+	defer s.Unlock()
+	s.quotes[symbol] = quote
+}
+
+// SetBars replaces the symbol's bars in the timeframe.
+func (s *Store) SetBars(symbol, timeframe string, bars []Bar) {
+	sorted := make([]Bar, len(bars))
+	copy(sorted, bars)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	s.Lock()
+	defer s.Unlock()
+	s.bars[barsKey(symbol, timeframe)] = sorted
+}
+
+func (s *Store) LastTrades(symbols []string) (map[string]structures.Trade, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	trades := make(map[string]structures.Trade, len(symbols))
+	for _, symbol := range symbols {
+		if trade, ok := s.trades[symbol]; ok {
+			trades[symbol] = trade
+		}
+	}
+	return trades, nil
+}
+
+func (s *Store) Quotes(symbols []string) (map[string]structures.Quote, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	quotes := make(map[string]structures.Quote, len(symbols))
+	for _, symbol := range symbols {
+		if quote, ok := s.quotes[symbol]; ok {
+			quotes[symbol] = quote
+		}
+	}
+	return quotes, nil
+}
+
+func (s *Store) Bars(symbols []string, timeframe string, start, end *time.Time, limit *int) (io.ColumnSeriesMap, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	csm := io.NewColumnSeriesMap()
+
+	for _, symbol := range symbols {
+		bars := s.slice(symbol, timeframe, start, end, limit)
+		if len(bars) == 0 {
+			continue
+		}
+
+		tbk := io.NewTimeBucketKey(fmt.Sprintf("%v/%v/OHLCV", symbol, timeframe))
+		csm.AddColumnSeries(*tbk, toColumnSeries(bars))
+	}
+
+	return csm, nil
+}
+
+// LastClose is the close of the last minute bar before the market
+// closed, or of the daily bar if the store only has those.
+func (s *Store) LastClose(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var (
+		prev   = lastCloseDate(on)
+		end    = prev.MarketClose().Add(-time.Second)
+		one    = 1
+		trades = make(map[string]structures.Trade, len(symbols))
+	)
+
+	for _, symbol := range symbols {
+		bars := s.slice(symbol, "1Min", nil, &end, &one)
+		if len(bars) == 0 {
+			bars = s.slice(symbol, "1D", nil, &end, &one)
+		}
+
+		if len(bars) == 0 {
+			continue
+		}
+
+		trades[symbol] = structures.Trade{
+			Timestamp: bars[0].Time,
+			Price:     float64(bars[0].Close),
+		}
+	}
+
+	return trades, nil
+}
+
+// slice returns the bars between start and end, both inclusive, and
+// the last limit of them, the same as marketstore.
+func (s *Store) slice(symbol, timeframe string, start, end *time.Time, limit *int) []Bar {
+	bars := s.bars[barsKey(symbol, timeframe)]
+
+	from := 0
+	if start != nil {
+		from = sort.Search(len(bars), func(i int) bool {
+			return !bars[i].Time.Before(*start)
+		})
+	}
+
+	to := len(bars)
+	if end != nil {
+		to = sort.Search(len(bars), func(i int) bool {
+			return bars[i].Time.After(*end)
+		})
+	}
+
+	if from >= to {
+		return nil
+	}
+
+	if limit != nil && to-from > *limit {
+		from = to - *limit
+	}
+
+	return bars[from:to]
+}
+
+func barsKey(symbol, timeframe string) string {
+	return fmt.Sprintf("%v/%v", symbol, timeframe)
+}
+
+func toColumnSeries(bars []Bar) *io.ColumnSeries {
+	var (
+		epochs  = make([]int64, len(bars))
+		opens   = make([]float32, len(bars))
+		highs   = make([]float32, len(bars))
+		lows    = make([]float32, len(bars))
+		closes  = make([]float32, len(bars))
+		volumes = make([]int32, len(bars))
+	)
+
+	for i, bar := range bars {
+		epochs[i] = bar.Time.Unix()
+		opens[i] = bar.Open
+		highs[i] = bar.High
+		lows[i] = bar.Low
+		closes[i] = bar.Close
+		volumes[i] = bar.Volume
+	}
+
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epochs)
+	cs.AddColumn("Open", opens)
+	cs.AddColumn("High", highs)
+	cs.AddColumn("Low", lows)
+	cs.AddColumn("Close", closes)
+	cs.AddColumn("Volume", volumes)
+
+	return cs
+}
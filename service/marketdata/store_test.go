@@ -0,0 +1,102 @@
+package marketdata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type StoreTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
+
+func (s *StoreTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "marketdata")
+	require.Nil(s.T(), err)
+	s.dir = dir
+
+	s.write("trades.csv", "symbol,time,price,size\n"+
+		"AAPL,2018-06-01T15:59:59-04:00,190.24,100\n")
+
+	s.write("quotes.csv", "symbol,time,bid_price,bid_size,ask_price,ask_size\n"+
+		"AAPL,2018-06-01T15:59:59-04:00,190.2,3,190.3,5\n")
+
+	s.write(filepath.Join("bars", "AAPL", "1Min.csv"), "time,open,high,low,close,volume\n"+
+		"2018-05-31T15:58:00-04:00,186.8,186.9,186.7,186.85,1000\n"+
+		"2018-05-31T15:59:00-04:00,186.85,187,186.8,186.87,2000\n"+
+		"2018-06-01T09:30:00-04:00,187.99,188.2,187.9,188.1,3000\n")
+}
+
+func (s *StoreTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+}
+
+func (s *StoreTestSuite) write(name, content string) {
+	path := filepath.Join(s.dir, name)
+	require.Nil(s.T(), os.MkdirAll(filepath.Dir(path), 0755))
+	require.Nil(s.T(), ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func (s *StoreTestSuite) TestLoad() {
+	store, err := Load(s.dir)
+	require.Nil(s.T(), err)
+
+	trades, err := store.LastTrades([]string{"AAPL", "MSFT"})
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), trades, 1)
+	assert.Equal(s.T(), 190.24, trades["AAPL"].Price)
+
+	quotes, err := store.Quotes([]string{"AAPL"})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 190.3, quotes["AAPL"].AskPrice)
+}
+
+func (s *StoreTestSuite) TestBars() {
+	store, err := Load(s.dir)
+	require.Nil(s.T(), err)
+
+	// end is inclusive, and the limit keeps the latest bars
+	end := time.Date(2018, 5, 31, 15, 59, 0, 0, calendar.NY)
+	one := 1
+
+	csm, err := store.Bars([]string{"AAPL", "MSFT"}, "1Min", nil, &end, &one)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), csm, 1)
+
+	for _, cs := range csm {
+		assert.Equal(s.T(), []float32{186.87}, cs.GetByName("Close"))
+		assert.Equal(s.T(), []int64{end.Unix()}, cs.GetByName("Epoch"))
+	}
+
+	// nothing in range
+	start := end.Add(time.Hour)
+	csm, err = store.Bars([]string{"AAPL"}, "1Min", &start, &start, nil)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), csm, 0)
+}
+
+func (s *StoreTestSuite) TestLastClose() {
+	store, err := Load(s.dir)
+	require.Nil(s.T(), err)
+
+	on, err := tradingdate.NewFromDate(2018, time.June, 1)
+	require.Nil(s.T(), err)
+
+	closes, err := store.LastClose([]string{"AAPL", "MSFT"}, on)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), closes, 1)
+	assert.InDelta(s.T(), 186.87, closes["AAPL"].Price, 1e-4)
+}
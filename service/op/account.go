@@ -203,7 +203,7 @@ func PatternDayTrades(tx *gorm.DB, a *models.TradeAccount, order *models.Order,
 	}
 
 	if err := tx.
-		Where("account = ? AND status IN (?)", a.ApexAccount, enum.OrderOpen).
+		Where("account = ? AND status IN (?) AND replaced_at IS NULL", a.ApexAccount, enum.OrderOpen).
 		Order("symbol").
 		Find(&orders).Error; err != nil {
 		return 0, err
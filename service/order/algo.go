@@ -0,0 +1,173 @@
+package order
+
+import (
+	"strings"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// createAlgo stores an algo order for the algo worker to send child
+// orders for. The whole quantity is checked up front, the same as a
+// market order, or a limit order if it has a limit price, and each
+// child order is checked again as it's sent.
+func (s *orderService) createAlgo(accountID uuid.UUID, o *models.Order) (*models.Order, error) {
+	if err := verifyAlgo(o, clock.Now()); err != nil {
+		return nil, err
+	}
+
+	tx := s.tx.Begin()
+
+	acct, err := s.accService.WithTx(tx).ForUpdate().GetByID(accountID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// the first child order goes out at the start of the window
+	check := *o
+	check.Type = enum.Market
+	check.ReleaseAt = o.StartAt
+	if o.LimitPrice != nil {
+		check.Type = enum.Limit
+	}
+
+	if err = s.verifyOrder(tx, acct, &check); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	o.SetInitials(acct.LegalName)
+
+	o.ClientOrderType = enum.Algo
+	o.Warnings = check.Warnings
+	o.Status = enum.OrderWorking
+	o.SubmittedAt = clock.Now()
+	o.Account = *acct.ApexAccount
+
+	if err = tx.Create(o).Error; err != nil {
+		tx.Rollback()
+		if strings.Contains(err.Error(), "idx_client_order_id_account") {
+			return nil, gberrors.InvalidRequestParam.WithMsg("client_order_id must be unique")
+		}
+		return nil, gberrors.InternalServerError.WithMsg("failed to create order").WithError(err)
+	}
+
+	if err = s.checkPatternDayTrades(tx, acct, o); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return o, nil
+}
+
+// verifyAlgo checks an algo order's parameters and fills in its window,
+// which runs from now until the close by default, and has to fall
+// within a single regular session.
+func verifyAlgo(o *models.Order, now time.Time) error {
+	if o.Strategy == nil || !enum.ValidAlgoStrategy(*o.Strategy) {
+		return gberrors.InvalidRequestParam.WithMsg("algo orders require a twap or vwap strategy")
+	}
+
+	if o.StopPrice != nil {
+		return gberrors.InvalidRequestParam.WithMsg("algo orders take no stop price")
+	}
+
+	if o.TimeInForce != enum.Day || o.ExtendedHours {
+		return gberrors.InvalidRequestParam.WithMsg("algo orders must be DAY orders in the regular session")
+	}
+
+	if o.MaxParticipation != nil &&
+		(!o.MaxParticipation.IsPositive() || o.MaxParticipation.GreaterThan(decimal.New(1, 0))) {
+		return gberrors.InvalidRequestParam.WithMsg("max_participation must be > 0 and <= 1")
+	}
+
+	start := now
+	if o.StartAt != nil && o.StartAt.After(now) {
+		start = *o.StartAt
+	}
+
+	d, err := tradingdate.New(start.In(calendar.NY))
+	if err != nil {
+		return gberrors.InvalidRequestParam.WithMsg("algo orders must start on a trading day")
+	}
+
+	if start.Before(d.MarketOpen()) {
+		start = d.MarketOpen()
+	}
+
+	end := d.MarketClose()
+	if o.EndAt != nil {
+		end = *o.EndAt
+	}
+
+	if !end.After(start) || end.After(d.MarketClose()) {
+		return gberrors.InvalidRequestParam.WithMsg("end_at must be after start_at, and no later than the close")
+	}
+
+	o.StartAt = &start
+	o.EndAt = &end
+
+	return nil
+}
+
+// cancelAlgo cancels the open child orders of an algo order, and the
+// algo order itself, which never went to the order queue.
+func (s *orderService) cancelAlgo(tx *gorm.DB, accountID uuid.UUID, parent *models.Order, now time.Time) error {
+	children := []models.Order{}
+
+	if err := tx.
+		Where("parent_id = ? AND status IN (?) AND cancel_requested_at IS NULL", parent.ID, enum.OrderOpen).
+		Find(&children).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	for _, child := range children {
+		if err := s.Cancel(accountID, child.IDAsUUID()); err != nil {
+			return err
+		}
+	}
+
+	if parent.Status != enum.OrderWorking {
+		return nil
+	}
+
+	parent.Status = enum.OrderCanceled
+	parent.CanceledAt = &now
+
+	return tx.Save(parent).Error
+}
+
+// RollUp updates an algo order's filled quantity and average price from
+// its child orders.
+func (s *orderService) RollUp(parentID string) error {
+	parent := &models.Order{}
+
+	if err := s.tx.
+		Set("gorm:query_option", "FOR UPDATE").
+		Where("id = ?", parentID).
+		First(parent).Error; err != nil {
+		return err
+	}
+
+	// read after the lock, so fills of the other children are seen
+	children := []models.Order{}
+
+	if err := s.tx.Where("parent_id = ?", parentID).Find(&children).Error; err != nil {
+		return err
+	}
+
+	return s.tx.Save(parent.RollUp(children)).Error
+}
@@ -8,6 +8,10 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/fee"
+	"github.com/alpacahq/gobroker/service/goodfaith"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/service/op"
 	"github.com/alpacahq/gobroker/service/position"
 	"github.com/alpacahq/gobroker/service/tradeaccount"
@@ -30,7 +34,13 @@ type OrderService interface {
 		after *time.Time,
 		isAscending bool) ([]models.Order, error)
 	Create(accountID uuid.UUID, order *models.Order) (*models.Order, error)
+	Preview(accountID uuid.UUID, order *models.Order) (*Preview, error)
 	Cancel(accountID uuid.UUID, orderID uuid.UUID) error
+	Queue(accountID uuid.UUID, order *models.Order) (*models.Order, error)
+	Replace(accountID uuid.UUID, orderID uuid.UUID, order *models.Order) (*models.Order, error)
+	Releasable(limit int) ([]models.Order, error)
+	Release(order *models.Order) error
+	RollUp(parentID string) error
 	GetByID(accountID uuid.UUID, orderID uuid.UUID) (*models.Order, error)
 	GetByClientOrderID(accountID uuid.UUID, clientOrderID string) (*models.Order, error)
 	WithTx(tx *gorm.DB) OrderService
@@ -41,15 +51,17 @@ type orderService struct {
 	submit     OrderRequester
 	posService position.PositionService
 	accService tradeaccount.TradeAccountService
+	md         marketdata.MarketData
 }
 
 type OrderRequester func(accountID uuid.UUID, msg interface{}) error
 
-func Service(submit OrderRequester, posService position.PositionService, accService tradeaccount.TradeAccountService) OrderService {
+func Service(submit OrderRequester, posService position.PositionService, accService tradeaccount.TradeAccountService, md marketdata.MarketData) OrderService {
 	return &orderService{
 		submit:     submit,
 		posService: posService,
 		accService: accService,
+		md:         md,
 	}
 }
 
@@ -132,7 +144,9 @@ func (s *orderService) List(
 	}
 
 	if statuses != nil {
-		q = q.Where("status IN (?)", statuses)
+		// queued orders which were replaced are done with, even though
+		// replaced is an open status for the ones which went out
+		q = q.Where("status IN (?) AND replaced_at IS NULL", statuses)
 	}
 
 	if limit != nil && *limit > 0 {
@@ -167,6 +181,23 @@ func (s *orderService) Cancel(accountID uuid.UUID, orderID uuid.UUID) error {
 		return err
 	}
 
+	if order.Type == enum.Algo {
+		return s.cancelAlgo(tx, accountID, order, now)
+	}
+
+	// queued orders which were replaced never went out either
+	if order.ReplacedAt != nil {
+		return gberrors.Forbidden.WithMsg("order has been replaced")
+	}
+
+	// queued orders haven't been sent yet, so there's nothing to ask
+	// the order queue for
+	if order.Status == enum.OrderQueued {
+		order.Status = enum.OrderCanceled
+		order.CanceledAt = &now
+		return tx.Save(order).Error
+	}
+
 	req := OrderRequest{
 		RequestType: REQ_CANCEL,
 		Order:       order,
@@ -203,6 +234,10 @@ func liquidationOnly(acct *models.TradeAccount) bool {
 }
 
 func (s *orderService) Create(accountID uuid.UUID, o *models.Order) (*models.Order, error) {
+	if o.Type == enum.Algo {
+		return s.createAlgo(accountID, o)
+	}
+
 	// tx.TX need to be db.DB, just transaction because need to handle 2 tx here.
 	// so for here, we need to handle rollback in a right way. Be careful.
 	tx := s.tx.Begin()
@@ -241,16 +276,66 @@ func (s *orderService) Create(accountID uuid.UUID, o *models.Order) (*models.Ord
 		return nil, gberrors.InternalServerError.WithMsg("failed to create order").WithError(err)
 	}
 
+	if err := s.checkPatternDayTrades(tx, acct, o); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// We'll commit order first in postgres to record we've received order
+	// and receive asyncrous update from gotrader.
+	// There is also potential for sending order failure to gotrader when rmq is down etc.
+	// For that case, ideally we need to have another process which monitor orders
+	// status = accepted and took so long. ANd need to ask the order status to fix gateway, but
+	// we dicided for now we don't do that because it is pretty edge case.
+	if err := tx.Commit().Error; err != nil {
+		return nil, errors.Wrap(err, "failed to commit order")
+	}
+
+	// released not to call rollback when panic
+	tx = nil
+
+	if err := s.send(acct.IDAsUUID(), o); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// send submits an accepted order to the order queue, and moves it to
+// new once it's out.
+func (s *orderService) send(accountID uuid.UUID, o *models.Order) error {
 	req := OrderRequest{
 		RequestType: REQ_NEW,
 		Order:       o,
 	}
 
+	if err := s.submit(accountID, req); err != nil {
+		// In this case, order might be sent or not. We'll implement garbage collector in different
+		// proccess later, but leave it as it for now.
+		return fmt.Errorf("failed to submit order (%v)", err)
+	}
+
+	tx := s.tx.Begin()
+
+	// Optimistic update to update only accepted state order. Theoretically, this operation
+	// has potential to run after update from gotrader.
+	if err := tx.Model(o).Where("status = ?", enum.OrderAccepted).Update("status", enum.OrderNew).Error; err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to update order status")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return errors.Wrap(err, "failed to commit order status update")
+	}
+
+	return nil
+}
+
+func (s *orderService) checkPatternDayTrades(tx *gorm.DB, acct *models.TradeAccount, o *models.Order) error {
 	// calculate total equity for pattern day trader marking
 	equity, err := s.totalEquity(tx, acct)
 	if err != nil {
-		tx.Rollback()
-		return nil, err
+		return err
 	}
 
 	// pattern day trader rule
@@ -259,20 +344,17 @@ func (s *orderService) Create(accountID uuid.UUID, o *models.Order) (*models.Ord
 
 		pdts, err := op.PatternDayTrades(tx, acct, o, now)
 		if err != nil {
-			tx.Rollback()
-			return nil, gberrors.InternalServerError.WithError(fmt.Errorf("failed to calculate pattern day trades"))
+			return gberrors.InternalServerError.WithError(fmt.Errorf("failed to calculate pattern day trades"))
 		}
 
 		// if the user is already marked, make sure they don't get another
 		if acct.PatternDayTrader {
 			prevPdts, err := op.PatternDayTrades(tx, acct, nil, now)
 			if err != nil {
-				tx.Rollback()
-				return nil, gberrors.InternalServerError.WithError(fmt.Errorf("failed to calculate pattern day trades"))
+				return gberrors.InternalServerError.WithError(fmt.Errorf("failed to calculate pattern day trades"))
 			}
 			if prevPdts < pdts {
-				tx.Rollback()
-				return nil, gberrors.Forbidden.WithMsg("account is flagged as a pattern day trader - day trades are restricted")
+				return gberrors.Forbidden.WithMsg("account is flagged as a pattern day trader - day trades are restricted")
 			}
 		}
 
@@ -281,51 +363,26 @@ func (s *orderService) Create(accountID uuid.UUID, o *models.Order) (*models.Ord
 		// that could occur due to this new order, hence the check for 4 instead of 3
 		if pdts == 4 {
 			// protect
-			tx.Rollback()
-			return nil, gberrors.Forbidden.WithMsg("trade denied due to pattern day trading protection")
+			return gberrors.Forbidden.WithMsg("trade denied due to pattern day trading protection")
 		} else if pdts > 4 {
 			// mark
 			if err = s.accService.WithTx(tx).MarkPatternDayTrader(acct); err != nil {
-				tx.Rollback()
-				return nil, gberrors.InternalServerError.WithError(err)
+				return gberrors.InternalServerError.WithError(err)
 			}
 		}
-
 	}
 
-	// We'll commit order first in postgres to record we've received order
-	// and receive asyncrous update from gotrader.
-	// There is also potential for sending order failure to gotrader when rmq is down etc.
-	// For that case, ideally we need to have another process which monitor orders
-	// status = accepted and took so long. ANd need to ask the order status to fix gateway, but
-	// we dicided for now we don't do that because it is pretty edge case.
-	if err := tx.Commit().Error; err != nil {
-		return nil, errors.Wrap(err, "failed to commit order")
-	}
-
-	// released not to call rollback when panic
-	tx = nil
-
-	if err := s.submit(acct.IDAsUUID(), req); err != nil {
-		// In this case, order might be sent or not. We'll implement garbage collector in different
-		// proccess later, but leave it as it for now.
-		return nil, fmt.Errorf("failed to submit order (%v)", err)
-	}
-
-	tx = s.tx.Begin()
-
-	// Optimistic update to update only accepted state order. Theoretically, this operation
-	// has potential to run after update from gotrader.
-	if err := tx.Model(&o).Where("status = ?", enum.OrderAccepted).Update("status", enum.OrderNew).Error; err != nil {
-		tx.Rollback()
-		return nil, errors.Wrap(err, "failed to update order status")
-	}
+	return nil
+}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, errors.Wrap(err, "failed to commit order status update")
+// SessionOpen returns true in the regular session, and in the pre-market
+// and after-hours sessions for extended hours orders.
+func SessionOpen(extendedHours bool, now time.Time) bool {
+	if calendar.IsMarketOpen(now) {
+		return true
 	}
 
-	return o, nil
+	return extendedHours && tradingdate.SessionAt(now).Extended()
 }
 
 func (s *orderService) verifyOrder(tx *gorm.DB, acct *models.TradeAccount, o *models.Order) error {
@@ -342,8 +399,8 @@ func (s *orderService) verifyOrder(tx *gorm.DB, acct *models.TradeAccount, o *mo
 			return gberrors.InvalidRequestParam.WithMsg("market orders require no stop or limit price")
 		}
 		if o.Side == enum.Buy {
-			if err := models.ToLimit(o, balances.BuyingPower); err != nil {
-				return gberrors.Forbidden.WithMsg(err.Error())
+			if err := s.toLimit(o, balances.BuyingPower); err != nil {
+				return err
 			}
 		}
 	case enum.Limit:
@@ -355,8 +412,8 @@ func (s *orderService) verifyOrder(tx *gorm.DB, acct *models.TradeAccount, o *mo
 			return gberrors.InvalidRequestParam.WithMsg("stop orders require only stop price")
 		}
 		if o.Side == enum.Buy {
-			if err := models.ToLimit(o, balances.BuyingPower); err != nil {
-				return gberrors.Forbidden.WithMsg(err.Error())
+			if err := s.toLimit(o, balances.BuyingPower); err != nil {
+				return err
 			}
 		}
 	case enum.StopLimit:
@@ -366,6 +423,14 @@ func (s *orderService) verifyOrder(tx *gorm.DB, acct *models.TradeAccount, o *mo
 	default:
 		return gberrors.InvalidRequestParam.WithMsg("invalid order type")
 	}
+	if o.ExtendedHours && (o.Type != enum.Limit || o.TimeInForce != enum.Day) {
+		return gberrors.InvalidRequestParam.WithMsg("extended hours orders must be DAY limit orders")
+	}
+	// orders go out right away, unless they're queued to be released
+	// ahead of a session they can trade in
+	if o.ReleaseAt == nil && !SessionOpen(o.ExtendedHours, clock.Now()) {
+		return gberrors.Forbidden.WithMsg("market is closed")
+	}
 	if o.Side == enum.Buy {
 		if models.CostBasis(o, false).GreaterThan(balances.BuyingPower) {
 			return gberrors.Forbidden.WithMsg("insufficient buying power")
@@ -375,11 +440,179 @@ func (s *orderService) verifyOrder(tx *gorm.DB, acct *models.TradeAccount, o *mo
 		return gberrors.Forbidden.WithMsg("account is not authorized to trade")
 	}
 	if o.Side == enum.Buy && liquidationOnly(acct) {
-		tx.Rollback()
 		return gberrors.Forbidden.WithMsg("account is restricted to liquidation only")
 	}
 
-	return checkAvailableQty(tx, o, acct)
+	if err := checkAvailableQty(tx, o, acct); err != nil {
+		return err
+	}
+
+	return checkGoodFaith(tx, o, acct)
+}
+
+// Preview is what an order is expected to cost, or bring in for a
+// sell, including fees.
+type Preview struct {
+	Price    decimal.Decimal `json:"estimated_price"`
+	Notional decimal.Decimal `json:"estimated_notional"`
+	Fees     fee.Fees        `json:"estimated_fees"`
+	Total    decimal.Decimal `json:"estimated_total"`
+	Warnings []string        `json:"warnings"`
+}
+
+// Preview runs the same checks as Create and estimates the order's
+// cost, without submitting it.
+func (s *orderService) Preview(accountID uuid.UUID, o *models.Order) (*Preview, error) {
+	// nothing is stored for a preview
+	tx := s.tx.Begin()
+	defer tx.Rollback()
+
+	acct, err := s.accService.WithTx(tx).GetByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// orders placed while the market is closed are previewed as queued
+	if now := clock.Now(); o.ReleaseAt == nil && !SessionOpen(o.ExtendedHours, now) {
+		release := releaseTime(o, now)
+		o.ReleaseAt = &release
+	}
+
+	if err = s.verifyOrder(tx, acct, o); err != nil {
+		return nil, err
+	}
+
+	px, err := s.estimatePrice(o)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &Preview{
+		Price:    px,
+		Notional: o.Qty.Mul(px),
+		Fees:     fee.Compute(acct.Plan, o.Side, o.Qty, px, tradingdate.TradeDate(clock.Now()).Date()),
+		Warnings: o.Warnings,
+	}
+
+	if o.Side == enum.Buy {
+		preview.Total = preview.Notional.Add(preview.Fees.Total())
+	} else {
+		preview.Total = preview.Notional.Sub(preview.Fees.Total())
+	}
+
+	if preview.Warnings == nil {
+		preview.Warnings = []string{}
+	}
+
+	return preview, nil
+}
+
+// estimatePrice returns the price an order is expected to fill at,
+// which is the last trade for market orders.
+func (s *orderService) estimatePrice(o *models.Order) (decimal.Decimal, error) {
+	switch o.ClientOrderType {
+	case enum.Limit, enum.StopLimit:
+		return *o.LimitPrice, nil
+	case enum.Stop:
+		return *o.StopPrice, nil
+	default:
+		px, err := s.lastPrice(o.GetSymbol())
+		if err != nil {
+			return decimal.Zero, gberrors.InternalServerError.WithMsg(err.Error())
+		}
+		return px, nil
+	}
+}
+
+// toLimit converts market and stop buys to limits off the last trade.
+func (s *orderService) toLimit(o *models.Order, buyingPower decimal.Decimal) error {
+	px, err := s.lastPrice(o.GetSymbol())
+	if err != nil {
+		return gberrors.Forbidden.WithMsg(err.Error())
+	}
+
+	if err = models.ToLimit(o, buyingPower, px); err != nil {
+		return gberrors.Forbidden.WithMsg(err.Error())
+	}
+
+	return nil
+}
+
+func (s *orderService) lastPrice(symbol string) (decimal.Decimal, error) {
+	trades, err := s.md.LastTrades([]string{symbol})
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("%v price not found", symbol)
+	}
+
+	trade, ok := trades[symbol]
+	if !ok {
+		return decimal.Zero, fmt.Errorf("%v price not found", symbol)
+	}
+
+	return decimal.NewFromFloat(trade.Price), nil
+}
+
+// checkGoodFaith keeps the account from buying with unsettled funds while
+// it is restricted to settled cash, and from selling shares before the
+// funds used to buy them settle. Accounts which have turned off good faith
+// protection are only warned about the sell.
+func checkGoodFaith(tx *gorm.DB, o *models.Order, acct *models.TradeAccount) error {
+	if !acct.CashAccount() {
+		return nil
+	}
+
+	tradeDate := tradingdate.TradeDate(clock.Now()).Date()
+
+	switch o.Side {
+	case enum.Buy:
+		if !acct.SettledCashOnly(tradeDate) {
+			return nil
+		}
+
+		balances, err := ledger.Service().WithTx(tx).Balances(acct.IDAsUUID(), tradeDate)
+		if err != nil {
+			return err
+		}
+
+		available := balances.Withdrawable
+
+		orders := []models.Order{}
+		if err = tx.Where(
+			"account = ? AND status IN (?) AND side = ? AND replaced_at IS NULL",
+			*acct.ApexAccount,
+			enum.OrderOpen,
+			enum.Buy).Find(&orders).Error; err != nil {
+			return gberrors.InternalServerError.WithError(err)
+		}
+
+		for i := range orders {
+			available = available.Sub(models.CostBasis(&orders[i], true))
+		}
+
+		if models.CostBasis(o, false).GreaterThan(available) {
+			return gberrors.Forbidden.WithMsg(
+				fmt.Sprintf(
+					"account is restricted to trading with settled cash until %v due to good faith violations",
+					acct.SettledCashOnlyUntil))
+		}
+	case enum.Sell:
+		settles, err := goodfaith.Service().WithTx(tx).CheckSell(acct, o.AssetID, o.Qty, o.LotIDs, tradeDate)
+		if err != nil {
+			return err
+		}
+
+		if settles == nil {
+			return nil
+		}
+
+		if acct.ProtectGoodFaith {
+			return gberrors.Forbidden.WithMsg(goodfaith.ViolationMessage(*settles))
+		}
+
+		o.Warnings = append(o.Warnings, goodfaith.ViolationMessage(*settles))
+	}
+
+	return nil
 }
 
 func checkAvailableQty(tx *gorm.DB, o *models.Order, acct *models.TradeAccount) error {
@@ -404,11 +637,21 @@ func checkAvailableQty(tx *gorm.DB, o *models.Order, acct *models.TradeAccount)
 			return gberrors.InternalServerError.WithError(q.Error)
 		}
 
+		lots := map[uint]bool{}
 		for _, p := range positions {
 			qty = qty.Add(p.Qty)
+			lots[p.LotID()] = true
 		}
+
+		for _, id := range o.LotIDs {
+			if !lots[uint(id)] {
+				return gberrors.InvalidRequestParam.WithMsg(
+					fmt.Sprintf("lot %v is not an open lot of %v", id, o.GetSymbol()))
+			}
+		}
+
 		orders := []models.Order{}
-		q = tx.Where("account = ? and status IN (?)",
+		q = tx.Where("account = ? and status IN (?) AND replaced_at IS NULL",
 			*acct.ApexAccount, enum.OrderOpen).Find(&orders)
 
 		if q.Error != nil && !gorm.IsRecordNotFoundError(q.Error) {
@@ -416,6 +659,10 @@ func checkAvailableQty(tx *gorm.DB, o *models.Order, acct *models.TradeAccount)
 		}
 
 		for _, order := range orders {
+			// the order being replaced is canceled along with it
+			if o.Replaces != nil && order.ID == *o.Replaces {
+				continue
+			}
 			if order.GetSymbol() == o.GetSymbol() && order.Side == o.Side {
 				if order.FilledQty != nil {
 					qty = qty.Sub(order.Qty.Sub(*order.FilledQty))
@@ -1,18 +1,25 @@
 package order
 
 import (
+	"errors"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/service/position"
 	"github.com/alpacahq/gobroker/service/tradeaccount"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/alpacahq/gopaca/env"
+	"github.com/alpacahq/polycache/structures"
 	"github.com/gofrs/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -27,17 +34,26 @@ type OrderTestSuite struct {
 	oldOrder       *models.Order
 	account        *models.Account
 	orderRequester func(accountID uuid.UUID, msg interface{}) error
+	md             *marketdata.Store
 }
 
 func TestOrderTestSuite(t *testing.T) {
 	suite.Run(t, new(OrderTestSuite))
 }
 
+// a Wednesday morning in the regular session, so that orders go out
+var regularSession = time.Date(2019, 2, 13, 10, 0, 0, 0, calendar.NY)
+
 func (s *OrderTestSuite) SetupSuite() {
+	clock.Set(regularSession)
+
 	s.orderRequester = func(accountID uuid.UUID, msg interface{}) error {
 		return nil
 	}
 
+	s.md = marketdata.NewStore()
+	s.md.SetTrade("AAPL", structures.Trade{Price: 100, Size: 100, Timestamp: clock.Now()})
+
 	s.SetupDB()
 	amt, _ := decimal.NewFromString("1000000")
 	apexAcct := "apca_test"
@@ -109,10 +125,11 @@ func (s *OrderTestSuite) SetupSuite() {
 
 func (s *OrderTestSuite) TearDownSuite() {
 	s.TeardownDB()
+	clock.Set(time.Now())
 }
 
 func (s *OrderTestSuite) TestList() {
-	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache()), tradeaccount.Service()).WithTx(db.DB())
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
 
 	status := enum.OrderClosed
 	orders, err := srv.List(s.account.IDAsUUID(), status, nil, nil, nil, true)
@@ -160,7 +177,7 @@ func (s *OrderTestSuite) TestList() {
 }
 
 func (s *OrderTestSuite) TestCreate() {
-	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache()), tradeaccount.Service()).WithTx(db.DB())
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
 
 	o := &models.Order{
 		Account:     *s.account.ApexAccount,
@@ -203,6 +220,113 @@ func (s *OrderTestSuite) TestCreate() {
 	order, err = srv.Create(uuid.Must(uuid.NewV4()), o)
 	assert.NotNil(s.T(), err)
 	assert.Nil(s.T(), order)
+
+	// extended hours are only for DAY limit orders
+	o = &models.Order{
+		Account:       *s.account.ApexAccount,
+		Qty:           decimal.NewFromFloat(float64(1)),
+		AssetID:       s.asset.ID,
+		Symbol:        s.asset.Symbol,
+		Type:          enum.Limit,
+		LimitPrice:    s.order.LimitPrice,
+		Side:          enum.Buy,
+		TimeInForce:   enum.GTC,
+		ExtendedHours: true,
+	}
+
+	order, err = srv.Create(s.account.IDAsUUID(), o)
+	assert.NotNil(s.T(), err)
+	assert.Nil(s.T(), order)
+
+	o.TimeInForce = enum.Day
+	order, err = srv.Create(s.account.IDAsUUID(), o)
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), order)
+	assert.True(s.T(), order.ExtendedHours)
+}
+
+func (s *OrderTestSuite) TestSession() {
+	defer clock.Set(regularSession)
+
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
+
+	newOrder := func(extendedHours bool) *models.Order {
+		return &models.Order{
+			Account:       *s.account.ApexAccount,
+			Qty:           decimal.NewFromFloat(float64(1)),
+			AssetID:       s.asset.ID,
+			Symbol:        s.asset.Symbol,
+			Type:          enum.Limit,
+			LimitPrice:    s.order.LimitPrice,
+			Side:          enum.Buy,
+			TimeInForce:   enum.Day,
+			ExtendedHours: extendedHours,
+		}
+	}
+
+	// pre-market, only extended hours orders go out
+	clock.Set(time.Date(2019, 2, 13, 8, 0, 0, 0, calendar.NY))
+
+	order, err := srv.Create(s.account.IDAsUUID(), newOrder(false))
+	require.NotNil(s.T(), err)
+	assert.Equal(s.T(), gberrors.Forbidden.Code, err.(*gberrors.Error).Code)
+	assert.Nil(s.T(), order)
+
+	order, err = srv.Create(s.account.IDAsUUID(), newOrder(true))
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), order)
+	assert.Equal(s.T(), enum.OrderAccepted, order.Status)
+
+	// but they can still be queued for the open
+	order, err = srv.Queue(s.account.IDAsUUID(), newOrder(false))
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), order)
+	assert.Equal(s.T(), enum.OrderQueued, order.Status)
+
+	// overnight, nothing goes out
+	clock.Set(time.Date(2019, 2, 13, 21, 0, 0, 0, calendar.NY))
+
+	order, err = srv.Create(s.account.IDAsUUID(), newOrder(true))
+	assert.NotNil(s.T(), err)
+	assert.Nil(s.T(), order)
+}
+
+func (s *OrderTestSuite) TestGoodFaithMargin() {
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
+
+	until := date.DateOf(clock.Now().AddDate(0, 0, 90))
+	require.Nil(s.T(), db.DB().Model(s.account).Update("settled_cash_only_until", until).Error)
+	defer db.DB().Model(s.account).Updates(map[string]interface{}{
+		"settled_cash_only_until": nil,
+		"margin":                  false,
+	})
+
+	newOrder := func() *models.Order {
+		return &models.Order{
+			Account:     *s.account.ApexAccount,
+			Qty:         decimal.NewFromFloat(float64(1)),
+			AssetID:     s.asset.ID,
+			Symbol:      s.asset.Symbol,
+			Type:        enum.Limit,
+			LimitPrice:  s.order.LimitPrice,
+			Side:        enum.Buy,
+			TimeInForce: enum.Day,
+		}
+	}
+
+	// a restricted cash account has no settled cash to buy with
+	order, err := srv.Create(s.account.IDAsUUID(), newOrder())
+	require.NotNil(s.T(), err)
+	assert.Equal(s.T(), gberrors.Forbidden.Code, err.(*gberrors.Error).Code)
+	assert.Nil(s.T(), order)
+
+	// margin accounts aren't subject to good faith violations
+	require.Nil(s.T(), db.DB().Model(s.account).Update("margin", true).Error)
+
+	order, err = srv.Create(s.account.IDAsUUID(), newOrder())
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), order)
+	assert.Equal(s.T(), enum.OrderAccepted, order.Status)
 }
 
 func (s *OrderTestSuite) TestLiquidationOnly() {
@@ -223,7 +347,7 @@ func (s *OrderTestSuite) TestLiquidationOnly() {
 }
 
 func (s *OrderTestSuite) TestCancel() {
-	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache()), tradeaccount.Service()).WithTx(db.DB())
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
 
 	o := &models.Order{
 		Account:     *s.account.ApexAccount,
@@ -251,7 +375,7 @@ func (s *OrderTestSuite) TestCancel() {
 }
 
 func (s *OrderTestSuite) TestGetByID() {
-	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache()), tradeaccount.Service()).WithTx(db.DB())
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
 
 	order, err := srv.GetByID(s.account.IDAsUUID(), s.order.IDAsUUID())
 	assert.Nil(s.T(), err)
@@ -268,7 +392,7 @@ func (s *OrderTestSuite) TestGetByID() {
 }
 
 func (s *OrderTestSuite) TestGetByClientOrderID() {
-	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache()), tradeaccount.Service()).WithTx(db.DB())
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
 
 	order, err := srv.GetByClientOrderID(s.account.IDAsUUID(), s.order.ClientOrderID)
 	assert.Nil(s.T(), err)
@@ -383,3 +507,259 @@ func (s *OrderTestSuite) TestAvailableQty() {
 		assert.NotNil(s.T(), checkAvailableQty(db.DB(), o, ta))
 	}
 }
+
+func (s *OrderTestSuite) TestQueue() {
+	srv := Service(s.orderRequester, position.Service(assetcache.GetAssetCache(), s.md), tradeaccount.Service(), s.md).WithTx(db.DB())
+
+	o := &models.Order{
+		Qty:         decimal.NewFromFloat(float64(10)),
+		AssetID:     s.asset.ID,
+		Symbol:      s.asset.Symbol,
+		Type:        enum.Market,
+		Side:        enum.Buy,
+		TimeInForce: enum.Day,
+	}
+
+	order, err := srv.Queue(s.account.IDAsUUID(), o)
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), order)
+	assert.Equal(s.T(), enum.OrderQueued, order.Status)
+	require.NotNil(s.T(), order.ReleaseAt)
+	assert.True(s.T(), order.ReleaseAt.After(clock.Now()))
+
+	// not due yet
+	orders, err := srv.Releasable(10)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), orders, 0)
+
+	// only queued orders can be replaced, and only with the same symbol and side
+	limitPx := decimal.NewFromFloat(float64(101))
+	replacement := &models.Order{
+		Qty:         decimal.NewFromFloat(float64(20)),
+		AssetID:     s.asset.ID,
+		Symbol:      s.asset.Symbol,
+		Type:        enum.Limit,
+		LimitPrice:  &limitPx,
+		Side:        enum.Sell,
+		TimeInForce: enum.Day,
+	}
+
+	_, err = srv.Replace(s.account.IDAsUUID(), order.IDAsUUID(), replacement)
+	assert.NotNil(s.T(), err)
+
+	_, err = srv.Replace(s.account.IDAsUUID(), s.order.IDAsUUID(), replacement)
+	assert.NotNil(s.T(), err)
+
+	replacement.Side = enum.Buy
+	replaced, err := srv.Replace(s.account.IDAsUUID(), order.IDAsUUID(), replacement)
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), replaced)
+	assert.Equal(s.T(), enum.OrderQueued, replaced.Status)
+	require.NotNil(s.T(), replaced.Replaces)
+	assert.Equal(s.T(), order.ID, *replaced.Replaces)
+
+	old, err := srv.GetByID(s.account.IDAsUUID(), order.IDAsUUID())
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.OrderReplaced, old.Status)
+	require.NotNil(s.T(), old.ReplacedBy)
+	assert.Equal(s.T(), replaced.ID, *old.ReplacedBy)
+
+	// released once it's due
+	assert.Nil(s.T(), db.DB().Model(replaced).Update("release_at", clock.Now().Add(-time.Minute)).Error)
+
+	orders, err = srv.Releasable(10)
+	assert.Nil(s.T(), err)
+	require.Len(s.T(), orders, 1)
+
+	assert.Nil(s.T(), srv.Release(&orders[0]))
+	assert.Equal(s.T(), enum.OrderNew, orders[0].Status)
+
+	// and not twice
+	assert.NotNil(s.T(), srv.Release(&orders[0]))
+
+	// rejected if it no longer passes
+	o = &models.Order{
+		Qty:         decimal.NewFromFloat(float64(10)),
+		AssetID:     s.asset.ID,
+		Symbol:      s.asset.Symbol,
+		Type:        enum.Limit,
+		LimitPrice:  &limitPx,
+		Side:        enum.Buy,
+		TimeInForce: enum.Day,
+	}
+
+	order, err = srv.Queue(s.account.IDAsUUID(), o)
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), order)
+
+	hugeQty := decimal.NewFromFloat(float64(1000000))
+	assert.Nil(s.T(), db.DB().Model(order).Update("qty", hugeQty).Error)
+
+	assert.NotNil(s.T(), srv.Release(order))
+	assert.Equal(s.T(), enum.OrderRejected, order.Status)
+	assert.NotNil(s.T(), order.FailedAt)
+
+	// canceled without going to the order queue
+	o = &models.Order{
+		Qty:         decimal.NewFromFloat(float64(10)),
+		AssetID:     s.asset.ID,
+		Symbol:      s.asset.Symbol,
+		Type:        enum.Limit,
+		LimitPrice:  &limitPx,
+		Side:        enum.Buy,
+		TimeInForce: enum.Day,
+	}
+
+	order, err = srv.Queue(s.account.IDAsUUID(), o)
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), order)
+
+	canceled := Service(
+		func(accountID uuid.UUID, msg interface{}) error {
+			assert.FailNow(s.T(), "queued order cancel was submitted")
+			return nil
+		},
+		position.Service(assetcache.GetAssetCache(), s.md),
+		tradeaccount.Service(),
+		s.md).WithTx(db.DB())
+
+	assert.Nil(s.T(), canceled.Cancel(s.account.IDAsUUID(), order.IDAsUUID()))
+
+	order, err = srv.GetByID(s.account.IDAsUUID(), order.IDAsUUID())
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.OrderCanceled, order.Status)
+	assert.NotNil(s.T(), order.CanceledAt)
+}
+
+func TestReleaseTime(t *testing.T) {
+	env.RegisterDefault("ORDER_RELEASE_LEAD", "1m")
+
+	// friday after the close, with monday a holiday
+	friday := time.Date(2019, 2, 15, 17, 0, 0, 0, calendar.NY)
+	tuesday := time.Date(2019, 2, 19, 9, 29, 0, 0, calendar.NY)
+
+	o := &models.Order{}
+	assert.True(t, releaseTime(o, friday).Equal(tuesday))
+
+	o.ExtendedHours = true
+	assert.True(t, releaseTime(o, friday).Equal(time.Date(2019, 2, 19, 3, 59, 0, 0, calendar.NY)))
+
+	// before the open is released the same day
+	early := time.Date(2019, 2, 19, 3, 0, 0, 0, calendar.NY)
+	assert.True(t, releaseTime(o, early).Equal(time.Date(2019, 2, 19, 3, 59, 0, 0, calendar.NY)))
+
+	o.ExtendedHours = false
+	assert.True(t, releaseTime(o, early).Equal(tuesday))
+}
+
+func (s *OrderTestSuite) TestAlgo() {
+	clock.Set(time.Date(2019, 2, 14, 10, 0, 0, 0, calendar.NY))
+	defer clock.Set(regularSession)
+
+	submitted := []OrderRequest{}
+	srv := Service(
+		func(accountID uuid.UUID, msg interface{}) error {
+			submitted = append(submitted, msg.(OrderRequest))
+			return nil
+		},
+		position.Service(assetcache.GetAssetCache(), s.md),
+		tradeaccount.Service(),
+		s.md).WithTx(db.DB())
+
+	twap := enum.TWAP
+	parent, err := srv.Create(s.account.IDAsUUID(), &models.Order{
+		Qty:         decimal.NewFromFloat(float64(100)),
+		AssetID:     s.asset.ID,
+		Symbol:      s.asset.Symbol,
+		Type:        enum.Algo,
+		Side:        enum.Buy,
+		TimeInForce: enum.Day,
+		Strategy:    &twap,
+	})
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), parent)
+	assert.Equal(s.T(), enum.OrderWorking, parent.Status)
+	assert.Equal(s.T(), enum.Algo, parent.ClientOrderType)
+	assert.True(s.T(), parent.EndAt.Equal(time.Date(2019, 2, 14, 16, 0, 0, 0, calendar.NY)))
+
+	// the algo order itself never goes to the order queue
+	assert.Len(s.T(), submitted, 0)
+
+	child, err := srv.Create(s.account.IDAsUUID(), &models.Order{
+		Qty:         decimal.NewFromFloat(float64(40)),
+		AssetID:     s.asset.ID,
+		Symbol:      s.asset.Symbol,
+		Type:        enum.Market,
+		Side:        enum.Buy,
+		TimeInForce: enum.Day,
+		ParentID:    &parent.ID,
+	})
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), child)
+	assert.Len(s.T(), submitted, 1)
+
+	filledQty := decimal.NewFromFloat(float64(20))
+	filledPx := decimal.NewFromFloat(float64(100.5))
+	assert.Nil(s.T(), db.DB().Model(child).Updates(models.Order{
+		Status:         enum.OrderPartiallyFilled,
+		FilledQty:      &filledQty,
+		FilledAvgPrice: &filledPx,
+	}).Error)
+
+	assert.Nil(s.T(), srv.RollUp(parent.ID))
+
+	parent, err = srv.GetByID(s.account.IDAsUUID(), parent.IDAsUUID())
+	assert.Nil(s.T(), err)
+	require.NotNil(s.T(), parent.FilledQty)
+	assert.True(s.T(), parent.FilledQty.Equal(filledQty))
+	assert.True(s.T(), parent.FilledAvgPrice.Equal(filledPx))
+	assert.Equal(s.T(), enum.OrderWorking, parent.Status)
+
+	// canceling the algo order cancels its working children
+	assert.Nil(s.T(), srv.Cancel(s.account.IDAsUUID(), parent.IDAsUUID()))
+	require.Len(s.T(), submitted, 2)
+	assert.Equal(s.T(), REQ_CANCEL, submitted[1].RequestType)
+	assert.Equal(s.T(), child.ID, submitted[1].Order.ID)
+
+	parent, err = srv.GetByID(s.account.IDAsUUID(), parent.IDAsUUID())
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.OrderCanceled, parent.Status)
+}
+
+func TestRejects(t *testing.T) {
+	assert.True(t, Rejects(gberrors.InvalidRequestParam.WithMsg("qty must be > 0")))
+	assert.True(t, Rejects(gberrors.Forbidden.WithMsg("insufficient buying power")))
+	assert.False(t, Rejects(gberrors.InternalServerError.WithMsg("AAPL price not found")))
+	assert.False(t, Rejects(errors.New("failed to get balances")))
+}
+
+func TestVerifyAlgo(t *testing.T) {
+	now := time.Date(2019, 2, 14, 8, 0, 0, 0, calendar.NY)
+	twap := enum.TWAP
+
+	o := &models.Order{TimeInForce: enum.Day}
+	assert.NotNil(t, verifyAlgo(o, now))
+
+	// defaults to the whole session
+	o.Strategy = &twap
+	assert.Nil(t, verifyAlgo(o, now))
+	assert.True(t, o.StartAt.Equal(time.Date(2019, 2, 14, 9, 30, 0, 0, calendar.NY)))
+	assert.True(t, o.EndAt.Equal(time.Date(2019, 2, 14, 16, 0, 0, 0, calendar.NY)))
+
+	// past the close
+	end := time.Date(2019, 2, 14, 16, 30, 0, 0, calendar.NY)
+	o = &models.Order{TimeInForce: enum.Day, Strategy: &twap, EndAt: &end}
+	assert.NotNil(t, verifyAlgo(o, now))
+
+	// not a trading day
+	start := time.Date(2019, 2, 16, 10, 0, 0, 0, calendar.NY)
+	o = &models.Order{TimeInForce: enum.Day, Strategy: &twap, StartAt: &start}
+	assert.NotNil(t, verifyAlgo(o, now))
+
+	over := decimal.NewFromFloat(1.5)
+	o = &models.Order{TimeInForce: enum.Day, Strategy: &twap, MaxParticipation: &over}
+	assert.NotNil(t, verifyAlgo(o, now))
+
+	o = &models.Order{TimeInForce: enum.GTC, Strategy: &twap}
+	assert.NotNil(t, verifyAlgo(o, now))
+}
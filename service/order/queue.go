@@ -0,0 +1,283 @@
+package order
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/op"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/env"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// Queue takes an order while the market is closed. It runs the same
+// checks as Create, but rather than going to the order queue right
+// away, the order is held as queued until shortly before the next
+// session it can trade in opens.
+func (s *orderService) Queue(accountID uuid.UUID, o *models.Order) (*models.Order, error) {
+	tx := s.tx.Begin()
+
+	acct, err := s.accService.WithTx(tx).ForUpdate().GetByID(accountID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = s.queue(tx, acct, o, releaseTime(o, clock.Now())); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return o, nil
+}
+
+// Replace cancels a queued order and queues the new one in its place.
+// Orders which have already been released can't be replaced.
+func (s *orderService) Replace(accountID uuid.UUID, orderID uuid.UUID, o *models.Order) (*models.Order, error) {
+	tx := s.tx.Begin()
+
+	acct, err := s.accService.WithTx(tx).ForUpdate().GetByID(accountID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if acct.ApexAccount == nil {
+		tx.Rollback()
+		return nil, gberrors.NotFound.WithMsg(fmt.Sprintf("order not found for %v", orderID))
+	}
+
+	old, err := op.GetOrderByID(tx, *acct.ApexAccount, orderID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if old.Status != enum.OrderQueued {
+		tx.Rollback()
+		return nil, gberrors.Forbidden.WithMsg("only queued orders can be replaced")
+	}
+
+	if o.AssetID != old.AssetID || o.Side != old.Side {
+		tx.Rollback()
+		return nil, gberrors.InvalidRequestParam.WithMsg("replacement must be for the same symbol and side")
+	}
+
+	// the old order is replaced first, so that it doesn't count
+	// against the replacement when it's verified
+	now := clock.Now()
+	old.Status = enum.OrderReplaced
+	old.ReplacedAt = &now
+
+	if err = tx.Save(old).Error; err != nil {
+		tx.Rollback()
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	o.Replaces = &old.ID
+
+	// a replacement made just ahead of the open shouldn't wait a day
+	release := releaseTime(o, now)
+	if old.ReleaseAt != nil && old.ReleaseAt.Before(release) {
+		release = *old.ReleaseAt
+	}
+
+	if err = s.queue(tx, acct, o, release); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = tx.Model(old).Update("replaced_by", o.ID).Error; err != nil {
+		tx.Rollback()
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return o, nil
+}
+
+func (s *orderService) queue(tx *gorm.DB, acct *models.TradeAccount, o *models.Order, release time.Time) error {
+	o.ReleaseAt = &release
+
+	if err := s.verifyOrder(tx, acct, o); err != nil {
+		return err
+	}
+
+	o.SetInitials(acct.LegalName)
+
+	o.Status = enum.OrderQueued
+	o.SubmittedAt = clock.Now()
+	o.Account = *acct.ApexAccount
+
+	if err := tx.Create(o).Error; err != nil {
+		if strings.Contains(err.Error(), "idx_client_order_id_account") {
+			return gberrors.InvalidRequestParam.WithMsg("client_order_id must be unique")
+		}
+		return gberrors.InternalServerError.WithMsg("failed to create order").WithError(err)
+	}
+
+	return s.checkPatternDayTrades(tx, acct, o)
+}
+
+// Releasable returns up to limit queued orders which are due to be
+// released, the longest waiting first.
+func (s *orderService) Releasable(limit int) ([]models.Order, error) {
+	orders := []models.Order{}
+
+	if err := s.tx.
+		Where("status = ? AND release_at <= ?", enum.OrderQueued, clock.Now()).
+		Order("release_at, submitted_at, id").
+		Limit(limit).
+		Find(&orders).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return orders, nil
+}
+
+// Release verifies a queued order again, against the account's current
+// buying power and the latest quotes, and sends it to the order queue.
+// An order which no longer passes is rejected, and the reason returned
+// along with the order's status set to rejected. Other errors leave it
+// queued.
+func (s *orderService) Release(o *models.Order) error {
+	tx := s.tx.Begin()
+
+	acct, err := s.accService.WithTx(tx).ForUpdate().GetByApexAccount(o.Account)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// it may have been canceled or replaced since it was listed
+	q := tx.Where("id = ? AND status = ?", o.ID, enum.OrderQueued).Find(o)
+	if q.RecordNotFound() {
+		tx.Rollback()
+		return gberrors.Conflict.WithMsg(fmt.Sprintf("order %v is no longer queued", o.ID))
+	}
+	if q.Error != nil {
+		tx.Rollback()
+		return gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	// the order doesn't hold any shares or buying power while it's
+	// verified, same as when it was new
+	if err = tx.Model(&models.Order{}).Where("id = ?", o.ID).Update("status", enum.OrderRejected).Error; err != nil {
+		tx.Rollback()
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	// undo the conversion to a limit, so it's priced off the latest trade
+	o.Type = o.ClientOrderType
+	if o.Type == enum.Market || o.Type == enum.Stop {
+		o.LimitPrice = nil
+	}
+	o.Warnings = nil
+
+	if verr := s.verifyRelease(tx, acct, o); verr != nil {
+		// an order which couldn't be checked, say for a lack of
+		// market data, stays queued for the next run
+		if !Rejects(verr) {
+			tx.Rollback()
+			o.Status = enum.OrderQueued
+			return verr
+		}
+
+		now := clock.Now()
+		o.Status = enum.OrderRejected
+		o.FailedAt = &now
+
+		if err = tx.Save(o).Error; err != nil {
+			tx.Rollback()
+			o.Status = enum.OrderQueued
+			return gberrors.InternalServerError.WithError(err)
+		}
+
+		if err = tx.Commit().Error; err != nil {
+			o.Status = enum.OrderQueued
+			return gberrors.InternalServerError.WithError(err)
+		}
+
+		return verr
+	}
+
+	o.Status = enum.OrderAccepted
+
+	if err = tx.Save(o).Error; err != nil {
+		tx.Rollback()
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return s.send(acct.IDAsUUID(), o)
+}
+
+// Rejects returns true if a verification error means the order doesn't
+// pass its checks, rather than that it couldn't be checked.
+func Rejects(err error) bool {
+	gberr, ok := err.(*gberrors.Error)
+	if !ok {
+		return false
+	}
+
+	switch gberr.Code {
+	case gberrors.InvalidRequestParam.Code, gberrors.Forbidden.Code:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *orderService) verifyRelease(tx *gorm.DB, acct *models.TradeAccount, o *models.Order) error {
+	if acct.TradeSuspendedByUser {
+		return gberrors.Forbidden.WithMsg("new orders are rejected by user request")
+	}
+
+	asset := &models.Asset{}
+	if err := tx.Where("id = ?", o.AssetID).First(asset).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	if !asset.Tradable || asset.Status != enum.AssetActive {
+		return gberrors.Forbidden.WithMsg(fmt.Sprintf("asset %v is not tradable", asset.Symbol))
+	}
+
+	return s.verifyOrder(tx, acct, o)
+}
+
+// releaseTime is when an order queued at now goes out, which is
+// ORDER_RELEASE_LEAD ahead of the open of the next session the order
+// can trade in.
+func releaseTime(o *models.Order, now time.Time) time.Time {
+	lead, _ := time.ParseDuration(env.GetVar("ORDER_RELEASE_LEAD"))
+
+	open := func(d tradingdate.TradingDate) time.Time {
+		if o.ExtendedHours {
+			return d.PreMarketOpen()
+		}
+		return d.MarketOpen()
+	}
+
+	d := tradingdate.TradeDate(now)
+	if !now.Before(open(d)) {
+		d = d.Next()
+	}
+
+	return open(d).Add(-lead)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/audit"
 	"github.com/alpacahq/gobroker/service/op"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/alpacahq/gobroker/utils/address"
@@ -81,6 +82,8 @@ func (s *ownerDetailsService) Patch(accountID uuid.UUID, patches map[string]inte
 		return nil, gberrors.Forbidden.WithMsg("too soon for account update")
 	}
 
+	before := audit.Snapshot(acct.PrimaryOwner().Details)
+
 	// if this is a new account, onboarding for the first time,
 	// just update the existing owner details until it is submitted.
 	// if the account is being updated post-approval, then we need
@@ -99,6 +102,16 @@ func (s *ownerDetailsService) Patch(accountID uuid.UUID, patches map[string]inte
 		acct.PrimaryOwner().Details = *details
 	}
 
+	if err = audit.Record(s.tx, audit.Entry{
+		AccountID:  acct.ID,
+		Resource:   models.AuditOwnerDetails,
+		ResourceID: acct.PrimaryOwner().ID,
+		Before:     before,
+		After:      acct.PrimaryOwner().Details,
+	}); err != nil {
+		return nil, err
+	}
+
 	return &acct.PrimaryOwner().Details, nil
 }
 
@@ -8,7 +8,6 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/rest/api/controller/entities"
-	"github.com/alpacahq/gobroker/service/assetcache"
 	"github.com/alpacahq/gobroker/service/position"
 	"github.com/alpacahq/gobroker/service/tradeaccount"
 	"github.com/alpacahq/gomarkets/sources"
@@ -48,11 +47,13 @@ type paperService struct {
 	papercli    ppcli
 	cacheVerify func(string, string) (*models.AccessKey, bool, error)
 	cacheStore  func(*auth.AuthInfo) error
+	posService  position.PositionService
 }
 
-func Service() PaperService {
+func Service(posService position.PositionService) PaperService {
 	return &paperService{
-		papercli: paper.NewClient(),
+		papercli:   paper.NewClient(),
+		posService: posService,
 		cacheVerify: func(id, secret string) (*models.AccessKey, bool, error) {
 			pl, err := auth.Get(id)
 			if err != nil {
@@ -98,7 +99,7 @@ func (s *paperService) Create(accountID uuid.UUID, cash decimal.Decimal) (*model
 
 		cash = balances.Cash
 
-		pSrv := position.Service(assetcache.GetAssetCache()).WithTx(s.tx)
+		pSrv := s.posService.WithTx(s.tx)
 
 		positions, err := pSrv.List(accountID)
 		if err != nil {
@@ -0,0 +1,412 @@
+package performance
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/bar"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// TradingDaysPerYear annualizes daily volatility and Sharpe ratios
+	TradingDaysPerYear = 252
+	// PeriodAll covers the whole history of the account
+	PeriodAll = "all"
+)
+
+// RiskFreeRate is the annual rate the Sharpe ratio measures excess
+// returns against (3 month treasury bill).
+var RiskFreeRate = 0.024
+
+// periods are the number of trading days each period looks back,
+// the same as the portfolio history.
+var periods = map[string]int{
+	"1M": 20,
+	"3M": 60,
+	"6M": 20 * 6,
+	"1A": 252,
+}
+
+// Metrics are the risk and return statistics of a daily return series.
+type Metrics struct {
+	// Return is the time-weighted return over the period
+	Return      decimal.Decimal  `json:"return"`
+	MaxDrawdown decimal.Decimal  `json:"max_drawdown"`
+	Volatility  *decimal.Decimal `json:"volatility"`
+	SharpeRatio *decimal.Decimal `json:"sharpe_ratio"`
+}
+
+// Benchmark is the performance of holding a symbol over the period.
+type Benchmark struct {
+	Symbol string `json:"symbol"`
+	Metrics
+}
+
+// Performance is the account's performance over the period. Returns
+// are fractions for the period, while volatility and the Sharpe ratio
+// are annualized.
+type Performance struct {
+	Period string `json:"period"`
+	Since  string `json:"since"`
+	Until  string `json:"until"`
+	Metrics
+	MoneyWeightedReturn *decimal.Decimal `json:"money_weighted_return"`
+	NetDeposits         decimal.Decimal  `json:"net_deposits"`
+	Benchmark           *Benchmark       `json:"benchmark,omitempty"`
+	// ExcessReturn is the return over the benchmark's
+	ExcessReturn *decimal.Decimal `json:"excess_return,omitempty"`
+}
+
+type PerformanceService interface {
+	Get(accountID uuid.UUID, on tradingdate.TradingDate, period, benchmark string) (*Performance, error)
+	WithTx(tx *gorm.DB) PerformanceService
+}
+
+type performanceService struct {
+	PerformanceService
+	tx         *gorm.DB
+	assetcache assetcache.AssetCache
+	barService bar.BarService
+}
+
+func Service(assetcache assetcache.AssetCache, barService bar.BarService) PerformanceService {
+	return &performanceService{
+		assetcache: assetcache,
+		barService: barService,
+	}
+}
+
+func (s *performanceService) WithTx(tx *gorm.DB) PerformanceService {
+	s.tx = tx
+	return s
+}
+
+// Get computes the account's performance from the daily P/L snapshots
+// up to the trading day before on, the last one that is settled. The
+// snapshots measure market P/L on the start of day value, so transfers
+// don't count towards the time-weighted return, while the money-weighted
+// return accounts for them as cash flows.
+func (s *performanceService) Get(accountID uuid.UUID, on tradingdate.TradingDate, period, benchmark string) (*Performance, error) {
+	q := s.tx.
+		Where("account_id = ?", accountID.String()).
+		Where("date <= ?", on.Prev().String())
+
+	if period != PeriodAll {
+		days, ok := periods[period]
+		if !ok {
+			return nil, gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("period must be one of %v, %v", strings.Join(periodNames(), ", "), PeriodAll))
+		}
+		q = q.Where("date >= ?", on.DaysAgo(days).String())
+	}
+
+	snaps := []models.DayPLSnapshot{}
+
+	if err := q.Order("date ASC").Find(&snaps).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	perf := &Performance{Period: period}
+
+	if len(snaps) == 0 {
+		return perf, nil
+	}
+
+	perf.Since = snaps[0].DateString()
+	perf.Until = snaps[len(snaps)-1].DateString()
+
+	returns := make([]float64, len(snaps))
+	for i, snap := range snaps {
+		if snap.Basis.GreaterThan(decimal.Zero) {
+			returns[i], _ = snap.ProfitLoss.Div(snap.Basis).Float64()
+		}
+	}
+
+	perf.Metrics = metrics(returns)
+
+	flows, net, err := s.cashFlows(accountID, snaps)
+	if err != nil {
+		return nil, err
+	}
+
+	perf.NetDeposits = net
+
+	if mwr, ok := moneyWeightedReturn(flows); ok {
+		perf.MoneyWeightedReturn = toDecimal(mwr)
+	}
+
+	if benchmark != "" {
+		if perf.Benchmark, err = s.benchmark(benchmark, perf.Since, perf.Until); err != nil {
+			return nil, err
+		}
+
+		excess := perf.Return.Sub(perf.Benchmark.Return)
+		perf.ExcessReturn = &excess
+	}
+
+	return perf, nil
+}
+
+// cashFlows returns the flows to solve the money-weighted return for,
+// from the investor's side: the starting value is paid in, deposits
+// are paid in, and withdrawals and the ending value are paid out.
+func (s *performanceService) cashFlows(
+	accountID uuid.UUID,
+	snaps []models.DayPLSnapshot) ([]cashFlow, decimal.Decimal, error) {
+
+	var (
+		first = snaps[0]
+		last  = snaps[len(snaps)-1]
+		net   = decimal.Zero
+	)
+
+	start, _ := time.Parse("2006-01-02", first.DateString())
+	end, _ := time.Parse("2006-01-02", last.DateString())
+
+	transfers := []models.Transfer{}
+
+	if err := s.tx.
+		Where("account_id = ? AND status = ?", accountID.String(), enum.TransferComplete).
+		Where("batch_processed_at >= ? AND batch_processed_at < ?",
+			first.DateString(), end.AddDate(0, 0, 1).Format("2006-01-02")).
+		Find(&transfers).Error; err != nil {
+		return nil, net, gberrors.InternalServerError.WithError(err)
+	}
+
+	startValue, _ := first.Basis.Float64()
+
+	flows := []cashFlow{{Days: 0, Amount: -startValue}}
+
+	for _, xfer := range transfers {
+		processed, _ := time.Parse("2006-01-02", (*xfer.BatchProcessedAt)[:10])
+		amount, _ := xfer.Amount.Float64()
+
+		if xfer.Direction == apex.Incoming {
+			net = net.Add(xfer.Amount)
+			amount = -amount
+		} else {
+			net = net.Sub(xfer.Amount)
+		}
+
+		flows = append(flows, cashFlow{Days: processed.Sub(start).Hours() / 24, Amount: amount})
+	}
+
+	endValue, _ := last.Basis.Add(last.ProfitLoss).Float64()
+
+	flows = append(flows, cashFlow{Days: end.Sub(start).Hours()/24 + 1, Amount: endValue})
+
+	return flows, net, nil
+}
+
+// benchmark returns the performance of holding the symbol over the
+// same trading days, from its daily closes adjusted for splits and
+// dividends.
+func (s *performanceService) benchmark(symbol, since, until string) (*Benchmark, error) {
+	asset := s.assetcache.Get(symbol)
+	if asset == nil {
+		return nil, gberrors.NotFound.WithMsg(fmt.Sprintf("benchmark symbol %v not found", symbol))
+	}
+
+	first, err := parseTradingDate(since)
+	if err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	last, err := parseTradingDate(until)
+	if err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	// the close before the first day is where its return starts from
+	start := first.Prev().MarketOpen()
+	end := last.MarketClose()
+
+	bars, err := s.barService.WithTx(s.tx).WithAdjustment(bar.AdjustmentAll).GetByID(asset.IDAsUUID(), "1D", &start, &end, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	returns := []float64{}
+	for i := 1; i < len(bars.Bars); i++ {
+		if prev := bars.Bars[i-1].Close; prev > 0 {
+			returns = append(returns, float64(bars.Bars[i].Close/prev)-1)
+		}
+	}
+
+	return &Benchmark{
+		Symbol:  asset.Symbol,
+		Metrics: metrics(returns),
+	}, nil
+}
+
+func parseTradingDate(d string) (*tradingdate.TradingDate, error) {
+	t, err := time.Parse("2006-01-02", d)
+	if err != nil {
+		return nil, err
+	}
+	return tradingdate.NewFromDate(t.Year(), t.Month(), t.Day())
+}
+
+func periodNames() []string {
+	return []string{"1M", "3M", "6M", "1A"}
+}
+
+func metrics(returns []float64) Metrics {
+	m := Metrics{
+		Return:      *toDecimal(timeWeightedReturn(returns)),
+		MaxDrawdown: *toDecimal(maxDrawdown(returns)),
+	}
+
+	if vol, ok := volatility(returns); ok {
+		m.Volatility = toDecimal(vol)
+	}
+
+	if sharpe, ok := sharpeRatio(returns); ok {
+		m.SharpeRatio = toDecimal(sharpe)
+	}
+
+	return m
+}
+
+func toDecimal(f float64) *decimal.Decimal {
+	d := decimal.NewFromFloat(f).Round(6)
+	return &d
+}
+
+// timeWeightedReturn chains the daily returns.
+func timeWeightedReturn(returns []float64) float64 {
+	growth := 1.0
+	for _, r := range returns {
+		growth *= 1 + r
+	}
+	return growth - 1
+}
+
+// maxDrawdown is the largest decline from a peak of the chained
+// returns, as a positive fraction of the peak.
+func maxDrawdown(returns []float64) float64 {
+	var (
+		growth = 1.0
+		peak   = 1.0
+		max    = 0.0
+	)
+
+	for _, r := range returns {
+		growth *= 1 + r
+		if growth > peak {
+			peak = growth
+		}
+		if dd := 1 - growth/peak; dd > max {
+			max = dd
+		}
+	}
+
+	return max
+}
+
+func meanStdDev(returns []float64) (mean, stddev float64) {
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	for _, r := range returns {
+		stddev += (r - mean) * (r - mean)
+	}
+	stddev = math.Sqrt(stddev / float64(len(returns)-1))
+
+	return
+}
+
+// volatility is the annualized sample standard deviation of the daily
+// returns.
+func volatility(returns []float64) (float64, bool) {
+	if len(returns) < 2 {
+		return 0, false
+	}
+
+	_, stddev := meanStdDev(returns)
+
+	return stddev * math.Sqrt(TradingDaysPerYear), true
+}
+
+// sharpeRatio is the annualized mean daily return over the risk free
+// rate, per unit of volatility.
+func sharpeRatio(returns []float64) (float64, bool) {
+	if len(returns) < 2 {
+		return 0, false
+	}
+
+	mean, stddev := meanStdDev(returns)
+	if stddev == 0 {
+		return 0, false
+	}
+
+	excess := mean - RiskFreeRate/TradingDaysPerYear
+
+	return excess / stddev * math.Sqrt(TradingDaysPerYear), true
+}
+
+// cashFlow is an amount paid out to (positive) or in by (negative) the
+// investor, the number of days after the start of the period.
+type cashFlow struct {
+	Days   float64
+	Amount float64
+}
+
+// npv is the value of the flows at the start of the period, discounted
+// at the daily rate.
+func npv(flows []cashFlow, rate float64) float64 {
+	v := 0.0
+	for _, f := range flows {
+		v += f.Amount / math.Pow(1+rate, f.Days)
+	}
+	return v
+}
+
+// moneyWeightedReturn solves the internal rate of return of the flows
+// by bisection, and compounds it over the period. There is no return
+// to solve for if nothing was invested.
+func moneyWeightedReturn(flows []cashFlow) (float64, bool) {
+	var in, out bool
+	for _, f := range flows {
+		in = in || f.Amount < 0
+		out = out || f.Amount > 0
+	}
+
+	if !in || !out {
+		return 0, false
+	}
+
+	// daily rates between -99% and +100%
+	lo, hi := -0.99, 1.0
+
+	if npv(flows, lo)*npv(flows, hi) > 0 {
+		return 0, false
+	}
+
+	for i := 0; i < 200 && hi-lo > 1e-12; i++ {
+		mid := (lo + hi) / 2
+		if npv(flows, lo)*npv(flows, mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	rate := (lo + hi) / 2
+	days := flows[len(flows)-1].Days
+
+	return math.Pow(1+rate, days) - 1, true
+}
@@ -0,0 +1,83 @@
+package performance
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PerformanceTestSuite struct {
+	suite.Suite
+}
+
+func TestPerformanceTestSuite(t *testing.T) {
+	suite.Run(t, new(PerformanceTestSuite))
+}
+
+func (s *PerformanceTestSuite) TestTimeWeightedReturn() {
+	// +10%, -10% = -1%
+	assert.InDelta(s.T(), -0.01, timeWeightedReturn([]float64{0.1, -0.1}), 1e-9)
+	assert.Equal(s.T(), 0.0, timeWeightedReturn(nil))
+}
+
+func (s *PerformanceTestSuite) TestMaxDrawdown() {
+	// 1 -> 1.1 -> 0.88 -> 0.968 -> 1.2584, the drop from 1.1 to 0.88
+	// is the largest
+	assert.InDelta(s.T(), 0.2, maxDrawdown([]float64{0.1, -0.2, 0.1, 0.3}), 1e-9)
+
+	// only gains
+	assert.Equal(s.T(), 0.0, maxDrawdown([]float64{0.1, 0.1}))
+}
+
+func (s *PerformanceTestSuite) TestVolatilityAndSharpe() {
+	_, ok := volatility([]float64{0.01})
+	assert.False(s.T(), ok)
+
+	// a flat return has no volatility to measure the Sharpe ratio by
+	_, ok = sharpeRatio([]float64{0.01, 0.01})
+	assert.False(s.T(), ok)
+
+	returns := []float64{0.01, -0.01, 0.02, 0}
+
+	vol, ok := volatility(returns)
+	require.True(s.T(), ok)
+	// sample stddev of the returns is 0.012910
+	assert.InDelta(s.T(), 0.012910*math.Sqrt(252), vol, 1e-5)
+
+	sharpe, ok := sharpeRatio(returns)
+	require.True(s.T(), ok)
+	assert.InDelta(s.T(), (0.005-RiskFreeRate/252)/0.012910*math.Sqrt(252), sharpe, 1e-3)
+}
+
+func (s *PerformanceTestSuite) TestMoneyWeightedReturn() {
+	// $100 in, $110 out a day later
+	{
+		mwr, ok := moneyWeightedReturn([]cashFlow{
+			{Days: 0, Amount: -100},
+			{Days: 1, Amount: 110},
+		})
+		require.True(s.T(), ok)
+		assert.InDelta(s.T(), 0.1, mwr, 1e-6)
+	}
+
+	// the same gain on more money weighs more: $100 gains 10% on the
+	// first day, then $1,000 is deposited and loses 1% on the second,
+	// which has a positive time-weighted return but loses money
+	{
+		mwr, ok := moneyWeightedReturn([]cashFlow{
+			{Days: 0, Amount: -100},
+			{Days: 1, Amount: -1000},
+			{Days: 2, Amount: 1098.9},
+		})
+		require.True(s.T(), ok)
+		assert.True(s.T(), mwr < 0)
+		assert.True(s.T(), timeWeightedReturn([]float64{0.1, -0.01}) > 0)
+	}
+
+	// nothing invested
+	_, ok := moneyWeightedReturn([]cashFlow{{Days: 0, Amount: 0}, {Days: 1, Amount: 0}})
+	assert.False(s.T(), ok)
+}
@@ -10,12 +10,11 @@ import (
 
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
-	"github.com/alpacahq/gobroker/price"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/service/pfhistory"
 	"github.com/alpacahq/gobroker/utils/tradingdate"
 	"github.com/alpacahq/gopaca/calendar"
-	"github.com/alpacahq/polycache/rest/client"
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
 	"github.com/shopspring/decimal"
@@ -194,13 +193,15 @@ type portfolioService struct {
 	assetcache assetcache.AssetCache
 	accService tradeaccount.TradeAccountService
 	posService position.PositionService
+	md         marketdata.MarketData
 }
 
-func Service(assetcache assetcache.AssetCache, accService tradeaccount.TradeAccountService, posService position.PositionService) PortfolioService {
+func Service(assetcache assetcache.AssetCache, accService tradeaccount.TradeAccountService, posService position.PositionService, md marketdata.MarketData) PortfolioService {
 	return &portfolioService{
 		assetcache: assetcache,
 		accService: accService,
 		posService: posService,
+		md:         md,
 	}
 }
 
@@ -216,7 +217,19 @@ func (s *portfolioService) GetHistory(
 	period string, now *time.Time) (*ChartData, error) {
 
 	if timeframe == calendar.Min5 {
-		return s.getIntradayPortfolioHistories(id, on, now)
+		from := on
+		if period == "1W" {
+			from = on.DaysAgo(4)
+		}
+
+		chartdata, err := s.getEquityHistories(id, from, on, now)
+		if err != nil || chartdata != nil {
+			return chartdata, err
+		}
+
+		// replay the executions if there are no snapshots to serve
+		// the period from at all
+		return s.getIntradayPortfolioHistories(id, from, on, now)
 	}
 
 	return s.getDailyPortfolioHistories(id, on, period, now)
@@ -379,19 +392,133 @@ func (s *portfolioService) GetTotalEquity(accID uuid.UUID, date tradingdate.Trad
 	return totalEquity, nil
 }
 
+// getEquityHistories returns the intraday histories from the equity
+// snapshots taken since the open of from, or nil if there are none.
+// Days the snapshots don't cover, such as those before the account's
+// first snapshot, are replayed from the executions instead. Changes
+// are measured against the account's value at the close before, or
+// its replayed value if that isn't known.
+func (s *portfolioService) getEquityHistories(
+	accountID uuid.UUID,
+	from, on tradingdate.TradingDate,
+	now *time.Time) (*ChartData, error) {
+
+	since := from.MarketOpen()
+	until := on.MarketClose()
+
+	snaps := []models.EquitySnapshot{}
+
+	if err := s.tx.
+		Where("account_id = ? AND time >= ? AND time < ?", accountID, since, until).
+		Order("time ASC").
+		Find(&snaps).Error; err != nil {
+		return nil, err
+	}
+
+	if len(snaps) == 0 {
+		return nil, nil
+	}
+
+	equity := make(map[int64]decimal.Decimal, len(snaps))
+	covered := map[string]bool{}
+
+	for _, snap := range snaps {
+		equity[snap.Time.Unix()] = snap.Equity
+		covered[dayOf(snap.Time)] = true
+	}
+
+	uncovered := map[string]bool{}
+	for d := from; !d.MarketOpen().After(on.MarketOpen()); d = d.Next() {
+		if now != nil && d.MarketOpen().After(*now) {
+			break
+		}
+		if day := dayOf(d.MarketOpen()); !covered[day] {
+			uncovered[day] = true
+		}
+	}
+
+	baseValue := snaps[0].Equity
+
+	if len(uncovered) > 0 {
+		hist, value, err := s.replayIntraday(accountID, from, on, now)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, t := range hist.Time {
+			if hist.Close[i] != nil && uncovered[dayOf(t)] {
+				equity[t.Unix()] = value.Add(*hist.Close[i])
+			}
+		}
+
+		baseValue = value
+	}
+
+	var last models.DayPLSnapshot
+
+	q := s.tx.Where("account_id = ? AND date = ?", accountID, from.Prev().String()).Find(&last)
+
+	switch {
+	case q.RecordNotFound():
+	case q.Error != nil:
+		return nil, q.Error
+	default:
+		baseValue = last.Basis.Add(last.ProfitLoss)
+	}
+
+	timestamps, err := calendar.NewRange(since, until, calendar.Min5)
+	if err != nil {
+		return nil, err
+	}
+
+	pls := make([]*decimal.Decimal, len(timestamps))
+	for i, t := range timestamps {
+		if now != nil && t.After(*now) {
+			break
+		}
+		if value, ok := equity[t.Unix()]; ok {
+			pl := value.Sub(baseValue)
+			pls[i] = &pl
+		}
+	}
+
+	return NewChartData(timestamps, pls, baseValue, calendar.Min5), nil
+}
+
+// dayOf returns the New York date of t.
+func dayOf(t time.Time) string {
+	return t.In(calendar.NY).Format("2006-01-02")
+}
+
 func (s *portfolioService) getIntradayPortfolioHistories(
 	accountID uuid.UUID,
-	on tradingdate.TradingDate,
+	from, on tradingdate.TradingDate,
 	now *time.Time) (*ChartData, error) {
 
-	portfolioValue, err := s.GetTotalEquity(accountID, on)
+	hist, portfolioValue, err := s.replayIntraday(accountID, from, on, now)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewChartData(hist.Time, hist.Close, portfolioValue, calendar.Min5), nil
+}
+
+// replayIntraday replays the executions since the open of from over
+// the 5 minute bars. It returns the profit/loss at each step, and the
+// account's value before the period.
+func (s *portfolioService) replayIntraday(
+	accountID uuid.UUID,
+	from, on tradingdate.TradingDate,
+	now *time.Time) (*pfhistory.PFHistoryResponse, decimal.Decimal, error) {
+
+	portfolioValue, err := s.GetTotalEquity(accountID, on)
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
 	tx := s.tx
 
-	since := on.MarketOpen()
+	since := from.MarketOpen()
 	until := on.MarketClose()
 
 	sodPositions := []SoDPosition{}
@@ -414,7 +541,7 @@ FROM S s
 GROUP BY s.side, s.asset_id
   `, accountID, since, since).Scan(&sodPositions).Error
 	if err != nil {
-		return nil, err
+		return nil, decimal.Zero, err
 	}
 
 	tfpositions := make([]pfhistory.Position, len(sodPositions))
@@ -423,7 +550,7 @@ GROUP BY s.side, s.asset_id
 			p.Symbol = asset.Symbol
 			tfpositions[i] = p
 		} else {
-			return nil, fmt.Errorf("failed to load asset %v", p.AssetID)
+			return nil, decimal.Zero, fmt.Errorf("failed to load asset %v", p.AssetID)
 		}
 	}
 
@@ -453,7 +580,7 @@ FROM
 ORDER BY filled_at asc
   `, accountID, since, until, accountID, since, until).Scan(&orders).Error
 	if err != nil {
-		return nil, err
+		return nil, decimal.Zero, err
 	}
 
 	tforders := make([]pfhistory.Order, len(orders))
@@ -462,7 +589,7 @@ ORDER BY filled_at asc
 			v.Symbol = asset.Symbol
 			tforders[i] = v
 		} else {
-			return nil, fmt.Errorf("failed to load asset %v", v.AssetID)
+			return nil, decimal.Zero, fmt.Errorf("failed to load asset %v", v.AssetID)
 		}
 	}
 
@@ -479,21 +606,21 @@ ORDER BY filled_at asc
 		symbols = append(symbols, k)
 	}
 
-	// Use prev trading day's market close price as beginning price.
-	beginningPrices, err := price.LastDayClosing(symbols, &on)
+	// Use the market close price before the period as beginning price.
+	beginningPrices, err := s.md.LastClose(symbols, &from)
 	if err != nil {
-		return nil, err
+		return nil, decimal.Zero, err
 	}
 
-	csm, err := price.Get(symbols, "5Min", since, until)
+	csm, err := s.md.Bars(symbols, "5Min", &since, &until, nil)
 	if err != nil {
-		return nil, err
+		return nil, decimal.Zero, err
 	}
 
 	// Last Price on the ComputePL with be overritten by live quotes.
-	livePrices, err := client.GetTrades(symbols)
+	livePrices, err := s.md.LastTrades(symbols)
 	if err != nil {
-		return nil, err
+		return nil, decimal.Zero, err
 	}
 
 	hist := pfhistory.ComputePLWithQuoteOverride(
@@ -514,5 +641,5 @@ ORDER BY filled_at asc
 
 	portfolioValue = portfolioValue.Sub(dayChange)
 
-	return NewChartData(hist.Time, hist.Close, portfolioValue, calendar.Min5), nil
+	return &hist, portfolioValue, nil
 }
@@ -0,0 +1,179 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
+	"github.com/alpacahq/gobroker/service/position"
+	"github.com/alpacahq/gobroker/service/tradeaccount"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PortfolioTestSuite struct {
+	dbtest.Suite
+	srv PortfolioService
+	// Friday at noon, the last day of the week charted
+	now time.Time
+	on  tradingdate.TradingDate
+}
+
+func TestPortfolioTestSuite(t *testing.T) {
+	suite.Run(t, new(PortfolioTestSuite))
+}
+
+func (s *PortfolioTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	s.now = time.Date(2019, 2, 15, 12, 0, 0, 0, calendar.NY)
+
+	on, err := tradingdate.New(s.now)
+	require.Nil(s.T(), err)
+	s.on = *on
+
+	md := marketdata.NewStore()
+
+	s.srv = Service(
+		assetcache.GetAssetCache(),
+		tradeaccount.Service(),
+		position.Service(assetcache.GetAssetCache(), md),
+		md).WithTx(db.DB())
+}
+
+func (s *PortfolioTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+// genAccount creates an account holding only cash, so replaying its
+// executions has it worth the cash the whole time.
+func (s *PortfolioTestSuite) genAccount(apexAcct string) uuid.UUID {
+	cash := decimal.New(10000, 0)
+	acct := &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		Cash:               cash,
+		CashWithdrawable:   cash,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			{
+				Email:   apexAcct + "@test.db",
+				Primary: true,
+			},
+		},
+	}
+	require.Nil(s.T(), db.DB().Create(acct).Error)
+	return acct.IDAsUUID()
+}
+
+func (s *PortfolioTestSuite) genSnapshot(accountID uuid.UUID, at time.Time, equity int64) {
+	require.Nil(s.T(), db.DB().Create(&models.EquitySnapshot{
+		AccountID: accountID.String(),
+		Time:      at,
+		Equity:    decimal.New(equity, 0),
+	}).Error)
+}
+
+// plAt returns the charted profit/loss at t, or nil if there's a gap.
+func (s *PortfolioTestSuite) plAt(chart *ChartData, t time.Time) *float64 {
+	timestamps := chart.Arrays[0].([]int64)
+	pls := chart.Arrays[1].([]*float64)
+
+	for i := range timestamps {
+		if timestamps[i] == t.Unix()*1000 {
+			return pls[i]
+		}
+	}
+
+	require.FailNow(s.T(), "timestamp not charted", t)
+	return nil
+}
+
+func (s *PortfolioTestSuite) TestEquityHistories() {
+	var (
+		monday = time.Date(2019, 2, 11, 10, 0, 0, 0, calendar.NY)
+		open   = time.Date(2019, 2, 15, 9, 30, 0, 0, calendar.NY)
+		gap    = open.Add(5 * time.Minute)
+		later  = open.Add(30 * time.Minute)
+	)
+
+	// no snapshots, so the whole period is replayed
+	{
+		accountID := s.genAccount("apca_empty")
+
+		chart, err := s.srv.GetHistory(accountID, s.on, calendar.Min5, "1W", &s.now)
+		require.Nil(s.T(), err)
+		require.NotNil(s.T(), chart)
+
+		assert.Equal(s.T(), float64(10000), chart.BaseValue)
+
+		for _, t := range []time.Time{monday, open, gap} {
+			pl := s.plAt(chart, t)
+			require.NotNil(s.T(), pl)
+			assert.Equal(s.T(), float64(0), *pl)
+		}
+	}
+
+	accountID := s.genAccount("apca_snaps")
+	s.genSnapshot(accountID, open, 10100)
+	s.genSnapshot(accountID, later, 10200)
+
+	// the day is fully covered, so it's served from the snapshots
+	// alone, measured against the close before
+	{
+		require.Nil(s.T(), db.DB().Create(&models.DayPLSnapshot{
+			AccountID:  accountID.String(),
+			Date:       s.on.Prev().String(),
+			Basis:      decimal.New(9900, 0),
+			ProfitLoss: decimal.New(100, 0),
+		}).Error)
+
+		chart, err := s.srv.GetHistory(accountID, s.on, calendar.Min5, "1D", &s.now)
+		require.Nil(s.T(), err)
+		require.NotNil(s.T(), chart)
+
+		assert.Equal(s.T(), float64(10000), chart.BaseValue)
+
+		pl := s.plAt(chart, open)
+		require.NotNil(s.T(), pl)
+		assert.Equal(s.T(), float64(100), *pl)
+
+		pl = s.plAt(chart, later)
+		require.NotNil(s.T(), pl)
+		assert.Equal(s.T(), float64(200), *pl)
+
+		// missed snapshots are gaps, not replayed
+		assert.Nil(s.T(), s.plAt(chart, gap))
+	}
+
+	// the snapshots only start on Friday, so the days before are
+	// replayed, and measured against the replayed value before the
+	// week
+	{
+		chart, err := s.srv.GetHistory(accountID, s.on, calendar.Min5, "1W", &s.now)
+		require.Nil(s.T(), err)
+		require.NotNil(s.T(), chart)
+
+		assert.Equal(s.T(), float64(10000), chart.BaseValue)
+
+		pl := s.plAt(chart, monday)
+		require.NotNil(s.T(), pl)
+		assert.Equal(s.T(), float64(0), *pl)
+
+		pl = s.plAt(chart, open)
+		require.NotNil(s.T(), pl)
+		assert.Equal(s.T(), float64(100), *pl)
+
+		assert.Nil(s.T(), s.plAt(chart, gap))
+	}
+}
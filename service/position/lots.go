@@ -0,0 +1,69 @@
+package position
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Lot is an open tax lot of a position, which a sell order can
+// close specifically by its ID.
+type Lot struct {
+	LotID      uint            `json:"lot_id"`
+	Symbol     string          `json:"symbol"`
+	Qty        decimal.Decimal `json:"qty"`
+	EntryPrice decimal.Decimal `json:"entry_price"`
+	CostBasis  decimal.Decimal `json:"cost_basis"`
+	AcquiredAt time.Time       `json:"acquired_at"`
+	// LongTerm is true once the lot has been held for more than a year
+	LongTerm bool `json:"long_term"`
+}
+
+// Lots returns the open lots of the account's position in an asset,
+// oldest first. Positions split by partial sells are merged back
+// into the lot they came from.
+func (s *positionService) Lots(accountID uuid.UUID, assetID uuid.UUID) ([]Lot, error) {
+	positions := []models.Position{}
+
+	if err := s.tx.Where(
+		"account_id = ? AND asset_id = ? AND status = ?",
+		accountID, assetID, models.Open).
+		Order("entry_timestamp, id").
+		Find(&positions).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	symbol := ""
+	if asset := s.assetcache.GetByID(assetID); asset != nil {
+		symbol = asset.Symbol
+	}
+
+	now := clock.Now()
+	lots := []Lot{}
+	index := map[uint]int{}
+
+	for _, p := range positions {
+		if i, ok := index[p.LotID()]; ok {
+			lots[i].Qty = lots[i].Qty.Add(p.Qty)
+			lots[i].CostBasis = lots[i].CostBasis.Add(p.Qty.Mul(p.EntryPrice))
+			continue
+		}
+
+		index[p.LotID()] = len(lots)
+		lots = append(lots, Lot{
+			LotID:      p.LotID(),
+			Symbol:     symbol,
+			Qty:        p.Qty,
+			EntryPrice: p.EntryPrice,
+			CostBasis:  p.Qty.Mul(p.EntryPrice),
+			AcquiredAt: p.EntryTimestamp,
+			LongTerm:   now.After(p.EntryTimestamp.AddDate(1, 0, 0)),
+		})
+	}
+
+	return lots, nil
+}
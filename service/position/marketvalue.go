@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/utils/tradingdate"
 	"github.com/alpacahq/gopaca/price"
 	"github.com/alpacahq/polycache/structures"
@@ -47,7 +48,7 @@ func (s *positionService) MarketValueAt(accountID uuid.UUID, at tradingdate.Trad
 		}
 	}
 
-	cache, err := newClosePriceCache(symbols, at)
+	cache, err := newClosePriceCache(s.md, symbols, at)
 	if err != nil {
 		return value, err
 	}
@@ -73,10 +74,10 @@ type closePriceCache struct {
 	prices  map[string]structures.Trade
 }
 
-func newClosePriceCache(symbols []string, at tradingdate.TradingDate) (*closePriceCache, error) {
+func newClosePriceCache(md marketdata.MarketData, symbols []string, at tradingdate.TradingDate) (*closePriceCache, error) {
 	// I wanna closing price on that tradingdate.
 	nextDay := at.Next()
-	lastDayPrices, err := priceProxy.LastDayClosing(symbols, &nextDay)
+	lastDayPrices, err := md.LastClose(symbols, &nextDay)
 	if err != nil {
 		return nil, err
 	}
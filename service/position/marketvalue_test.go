@@ -10,7 +10,7 @@ import (
 )
 
 func (s *PositionTestSuite) TestMarketValueAt() {
-	srv := Service(assetcache.GetAssetCache()).WithTx(db.DB())
+	srv := Service(assetcache.GetAssetCache(), s.md).WithTx(db.DB())
 
 	{
 		value, err := srv.MarketValueAt(s.accountID, s.date)
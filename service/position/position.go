@@ -9,14 +9,13 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
-	"github.com/alpacahq/gobroker/price"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/utils/tradingdate"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/log"
 	pricefmt "github.com/alpacahq/gopaca/price"
-	"github.com/alpacahq/polycache/rest/client"
 	"github.com/alpacahq/polycache/structures"
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
@@ -24,18 +23,10 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-// for test mock
-var priceProxy = struct {
-	LivePrices     func(symbols []string) (map[string]structures.Trade, error)
-	LastDayClosing func(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error)
-}{
-	LivePrices:     client.GetTrades,
-	LastDayClosing: price.LastDayClosing,
-}
-
 type PositionService interface {
 	GetByAssetID(accountID uuid.UUID, assetID uuid.UUID) (*ConsolidatedPosition, error)
 	List(accountID uuid.UUID) ([]*ConsolidatedPosition, error)
+	Lots(accountID uuid.UUID, assetID uuid.UUID) ([]Lot, error)
 	MarketValueAt(accountID uuid.UUID, at tradingdate.TradingDate) (decimal.Decimal, error)
 	RawPositionsActAt(accountID uuid.UUID, at tradingdate.TradingDate) ([]*models.Position, error)
 	WithTx(tx *gorm.DB) PositionService
@@ -45,10 +36,11 @@ type positionService struct {
 	PositionService
 	tx         *gorm.DB
 	assetcache assetcache.AssetCache
+	md         marketdata.MarketData
 }
 
-func Service(assetcache assetcache.AssetCache) PositionService {
-	return &positionService{assetcache: assetcache}
+func Service(assetcache assetcache.AssetCache, md marketdata.MarketData) PositionService {
+	return &positionService{assetcache: assetcache, md: md}
 }
 
 func (s *positionService) WithTx(tx *gorm.DB) PositionService {
@@ -89,7 +81,7 @@ func (s *positionService) List(accountID uuid.UUID) ([]*ConsolidatedPosition, er
 		}
 	}
 
-	pcache, err := newPriceCache(symbols)
+	pcache, err := newPriceCache(s.md, symbols)
 	if err != nil {
 		return nil, gberrors.InternalServerError.WithError(errors.Wrap(err, "failed to build price cache"))
 	}
@@ -134,7 +126,7 @@ func (s *positionService) GetByAssetID(accountID uuid.UUID, assetID uuid.UUID) (
 		// but not have it on asset cache.
 		return nil, gberrors.InternalServerError.WithError(fmt.Errorf("asset \"%v\" not found", assetID))
 	}
-	pcache, err := newPriceCache([]string{asset.Symbol})
+	pcache, err := newPriceCache(s.md, []string{asset.Symbol})
 	if err != nil {
 		return nil, gberrors.InternalServerError.WithError(errors.Wrap(err, "failed to build price cache"))
 	}
@@ -300,7 +292,7 @@ type priceCache struct {
 }
 
 // query the quotes and bars in parallel
-func newPriceCache(symbols []string) (*priceCache, error) {
+func newPriceCache(md marketdata.MarketData, symbols []string) (*priceCache, error) {
 	var (
 		latestErr     error
 		lastDayErr    error
@@ -312,12 +304,12 @@ func newPriceCache(symbols []string) (*priceCache, error) {
 	pc.Add(2)
 
 	go func() {
-		latestPrices, latestErr = priceProxy.LivePrices(symbols)
+		latestPrices, latestErr = md.LastTrades(symbols)
 		pc.Done()
 	}()
 
 	go func() {
-		lastDayPrices, lastDayErr = priceProxy.LastDayClosing(symbols, nil)
+		lastDayPrices, lastDayErr = md.LastClose(symbols, nil)
 		pc.Done()
 	}()
 
@@ -331,12 +323,55 @@ func newPriceCache(symbols []string) (*priceCache, error) {
 		return nil, lastDayErr
 	}
 
+	if err := markToClose(md, latestPrices); err != nil {
+		return nil, err
+	}
+
 	pc.lastDayPrices = lastDayPrices
 	pc.latestPrices = latestPrices
 
 	return pc, nil
 }
 
+// markToClose replaces after-hours trades with the regular close once
+// the after-hours session is over. Extended hours prices are only used
+// while their session is active, and the regular session always marks
+// to the latest trade.
+func markToClose(md marketdata.MarketData, latestPrices map[string]structures.Trade) error {
+	now := clock.Now()
+
+	if tradingdate.SessionAt(now) != tradingdate.Closed {
+		return nil
+	}
+
+	last := tradingdate.Last(now)
+	stale := []string{}
+
+	for symbol, trade := range latestPrices {
+		if !trade.Timestamp.Before(last.MarketClose()) {
+			stale = append(stale, symbol)
+		}
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	// the close of the last trading date is the one before the next
+	next := last.Next()
+
+	closes, err := md.LastClose(stale, &next)
+	if err != nil {
+		return err
+	}
+
+	for symbol, trade := range closes {
+		latestPrices[symbol] = trade
+	}
+
+	return nil
+}
+
 func (c *priceCache) get(symbol string) (latestPrice, lastDayPrice decimal.Decimal, err error) {
 	lastDayOffer, haveLastDay := c.lastDayPrices[symbol]
 	lastTrade, haveLatest := c.latestPrices[symbol]
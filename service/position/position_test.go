@@ -3,18 +3,22 @@ package position
 import (
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/alpacahq/gobroker/dbtest"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/alpacahq/polycache/structures"
 	"github.com/gofrs/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -24,6 +28,7 @@ type PositionTestSuite struct {
 	asset     *models.Asset
 	position  *models.Position
 	accountID uuid.UUID
+	md        *marketdata.Store
 }
 
 func TestPositionTestSuite(t *testing.T) {
@@ -58,33 +63,15 @@ func (s *PositionTestSuite) SetupSuite() {
 		assert.FailNow(s.T(), err.Error())
 	}
 
-	priceProxy.LivePrices = func(symbols []string) (map[string]structures.Trade, error) {
-		ret := map[string]structures.Trade{}
-		for _, symbol := range symbols {
-			if symbol == "AAPL" {
-				ret[symbol] = structures.Trade{
-					Price:     110.00,
-					Size:      10,
-					Timestamp: clock.Now(),
-				}
-			}
-		}
-		return ret, nil
-	}
-
-	priceProxy.LastDayClosing = func(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error) {
-		ret := map[string]structures.Trade{}
-		for _, symbol := range symbols {
-			if symbol == "AAPL" {
-				ret[symbol] = structures.Trade{
-					Price:     109.00,
-					Size:      10,
-					Timestamp: clock.Now(),
-				}
-			}
-		}
-		return ret, nil
-	}
+	s.md = marketdata.NewStore()
+	s.md.SetTrade("AAPL", structures.Trade{
+		Price:     110.00,
+		Size:      10,
+		Timestamp: s.date.MarketOpen().Add(time.Minute),
+	})
+	s.md.SetBars("AAPL", "1Min", []marketdata.Bar{
+		{Time: s.date.Prev().MarketOpen(), Open: 109, High: 109, Low: 109, Close: 109, Volume: 10},
+	})
 }
 
 func (s *PositionTestSuite) TearDownSuite() {
@@ -92,7 +79,7 @@ func (s *PositionTestSuite) TearDownSuite() {
 }
 
 func (s *PositionTestSuite) TestGetByAssetID() {
-	srv := Service(assetcache.GetAssetCache()).WithTx(db.DB())
+	srv := Service(assetcache.GetAssetCache(), s.md).WithTx(db.DB())
 
 	pos, err := srv.GetByAssetID(s.accountID, s.asset.IDAsUUID())
 	assert.Nil(s.T(), err)
@@ -105,7 +92,7 @@ func (s *PositionTestSuite) TestGetByAssetID() {
 }
 
 func (s *PositionTestSuite) TestList() {
-	srv := Service(assetcache.GetAssetCache()).WithTx(db.DB())
+	srv := Service(assetcache.GetAssetCache(), s.md).WithTx(db.DB())
 
 	pos, err := srv.List(s.accountID)
 	assert.NotNil(s.T(), pos)
@@ -122,3 +109,32 @@ func (s *PositionTestSuite) TestList() {
 	assert.NotNil(s.T(), pos)
 	assert.Len(s.T(), pos, 0)
 }
+
+func TestMarkToClose(t *testing.T) {
+	defer clock.Set(time.Now())
+
+	md := marketdata.NewStore()
+	md.SetBars("AAPL", "1Min", []marketdata.Bar{
+		{Time: time.Date(2018, 1, 25, 15, 59, 0, 0, calendar.NY), Close: 100},
+	})
+
+	trades := func() map[string]structures.Trade {
+		return map[string]structures.Trade{
+			"AAPL": {Price: 105, Timestamp: time.Date(2018, 1, 25, 17, 0, 0, 0, calendar.NY)},
+			"MSFT": {Price: 90, Timestamp: time.Date(2018, 1, 25, 15, 0, 0, 0, calendar.NY)},
+		}
+	}
+
+	// after hours prices while the session is active
+	clock.Set(time.Date(2018, 1, 25, 18, 0, 0, 0, calendar.NY))
+	latest := trades()
+	require.Nil(t, markToClose(md, latest))
+	assert.Equal(t, 105.0, latest["AAPL"].Price)
+
+	// and the regular close once it's over
+	clock.Set(time.Date(2018, 1, 25, 22, 0, 0, 0, calendar.NY))
+	latest = trades()
+	require.Nil(t, markToClose(md, latest))
+	assert.Equal(t, 100.0, latest["AAPL"].Price)
+	assert.Equal(t, 90.0, latest["MSFT"].Price)
+}
@@ -4,33 +4,18 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/alpacahq/polycache/rest/client"
-	"github.com/alpacahq/polycache/structures"
-
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
-	"github.com/alpacahq/gobroker/price"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/service/pfhistory"
 	"github.com/alpacahq/gobroker/utils/tradingdate"
-	"github.com/alpacahq/marketstore/utils/io"
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	"github.com/shopspring/decimal"
 )
 
-// for test mock
-var priceProxy = struct {
-	Get            func(symbols []string, timeframe string, since, until time.Time) (io.ColumnSeriesMap, error)
-	LastDayClosing func(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error)
-	LivePrices     func(symbols []string) (map[string]structures.Trade, error)
-}{
-	Get:            price.Get,
-	LastDayClosing: price.LastDayClosing,
-	LivePrices:     client.GetTrades,
-}
-
 type ProfitLoss struct {
 	TotalPLPC decimal.Decimal `json:"total_plpc"`
 	DayPLPC   decimal.Decimal `json:"day_plpc"`
@@ -44,10 +29,11 @@ type ProfitLossService interface {
 type profitLossService struct {
 	tx         *gorm.DB
 	assetcache assetcache.AssetCache
+	md         marketdata.MarketData
 }
 
-func Service(assetcache assetcache.AssetCache) ProfitLossService {
-	return &profitLossService{assetcache: assetcache}
+func Service(assetcache assetcache.AssetCache, md marketdata.MarketData) ProfitLossService {
+	return &profitLossService{assetcache: assetcache, md: md}
 }
 
 var one = decimal.New(1, 0)
@@ -256,18 +242,18 @@ ORDER BY filled_at asc
 	}
 
 	// Use prev trading day's market close price as beginning price.
-	beginningPrices, err := priceProxy.LastDayClosing(symbols, &on)
+	beginningPrices, err := s.md.LastClose(symbols, &on)
 	if err != nil {
 		return nil, err
 	}
 
-	csm, err := priceProxy.Get(symbols, "5Min", since, until)
+	csm, err := s.md.Bars(symbols, "5Min", &since, &until, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	// Last Price on the ComputePL with be overritten by live quotes.
-	livePrices, err := priceProxy.LivePrices(symbols)
+	livePrices, err := s.md.LastTrades(symbols)
 	if err != nil {
 		return nil, err
 	}
@@ -9,6 +9,7 @@ import (
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/account"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/utils/tradingdate"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
@@ -25,6 +26,27 @@ type ProfitlossTestSuite struct {
 	dbtest.Suite
 	asset *models.Asset
 	acct  *models.Account
+	md    *mockMarketData
+}
+
+// mockMarketData has the previous close of AAPL, the bars set by the
+// test, and no live trades.
+type mockMarketData struct {
+	marketdata.MarketData
+	closes map[string]structures.Trade
+	csm    io.ColumnSeriesMap
+}
+
+func (m *mockMarketData) LastClose(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error) {
+	return m.closes, nil
+}
+
+func (m *mockMarketData) Bars(symbols []string, timeframe string, start, end *time.Time, limit *int) (io.ColumnSeriesMap, error) {
+	return m.csm, nil
+}
+
+func (m *mockMarketData) LastTrades(symbols []string) (map[string]structures.Trade, error) {
+	return map[string]structures.Trade{}, nil
 }
 
 func TestProfitlossTestSuite(t *testing.T) {
@@ -56,22 +78,14 @@ func (s *ProfitlossTestSuite) SetupSuite() {
 
 	tx.Commit()
 
-	priceProxy.LastDayClosing = func(symbols []string, on *tradingdate.TradingDate) (map[string]structures.Trade, error) {
-		ret := map[string]structures.Trade{}
-		for _, symbol := range symbols {
-			if symbol == "AAPL" {
-				ret[symbol] = structures.Trade{
-					Price:     109.00,
-					Size:      10,
-					Timestamp: clock.Now(),
-				}
-			}
-		}
-		return ret, nil
-	}
-
-	priceProxy.LivePrices = func(symbols []string) (map[string]structures.Trade, error) {
-		return map[string]structures.Trade{}, nil
+	s.md = &mockMarketData{
+		closes: map[string]structures.Trade{
+			"AAPL": {
+				Price:     109.00,
+				Size:      10,
+				Timestamp: clock.Now(),
+			},
+		},
 	}
 
 }
@@ -123,11 +137,9 @@ func (s *ProfitlossTestSuite) TestGet() {
 	aaplTbk := io.NewTimeBucketKey("AAPL/5Min/OHLCV", io.DefaultTimeBucketSchema)
 	csm.AddColumnSeries(*aaplTbk, cs1)
 
-	priceProxy.Get = func(symbols []string, timeframe string, since, until time.Time) (io.ColumnSeriesMap, error) {
-		return csm, nil
-	}
+	s.md.csm = csm
 
-	svc := Service(assetcache.GetAssetCache()).WithTx(db.DB())
+	svc := Service(assetcache.GetAssetCache(), s.md).WithTx(db.DB())
 
 	pl, err := svc.Get(s.acct.IDAsUUID(), tradingdate.Last(sod), sod.Add(time.Minute*15))
 	if err != nil {
@@ -1,11 +1,15 @@
 package quote
 
 import (
+	"fmt"
+
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/price"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
+	"github.com/alpacahq/gopaca/calendar"
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
 )
@@ -19,12 +23,12 @@ type QuoteService interface {
 type quoteService struct {
 	QuoteService
 	tx         *gorm.DB
-	getQuotes  func(symbols []string) ([]price.Quote, error)
+	md         marketdata.MarketData
 	assetcache assetcache.AssetCache
 }
 
-func Service(assetcache assetcache.AssetCache) QuoteService {
-	return &quoteService{getQuotes: price.Quotes, assetcache: assetcache}
+func Service(assetcache assetcache.AssetCache, md marketdata.MarketData) QuoteService {
+	return &quoteService{md: md, assetcache: assetcache}
 }
 
 type QuoteAsAsset struct {
@@ -39,6 +43,45 @@ func (s *quoteService) WithTx(tx *gorm.DB) QuoteService {
 	return s
 }
 
+// getQuotes returns the quotes of the symbols, in the same order, with
+// the last trade of each.
+func (s *quoteService) getQuotes(symbols []string) ([]price.Quote, error) {
+	trades, err := s.md.LastTrades(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	nbbos, err := s.md.Quotes(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := make([]price.Quote, len(symbols))
+
+	for i, symbol := range symbols {
+		trade, ok := trades[symbol]
+		if !ok {
+			return nil, fmt.Errorf("quotes unavailable for this set of symbols")
+		}
+
+		nbbo, ok := nbbos[symbol]
+		if !ok {
+			return nil, fmt.Errorf("quotes unavailable for this set of symbols")
+		}
+
+		quotes[i] = price.Quote{
+			BidTimestamp:  nbbo.Timestamp.In(calendar.NY),
+			Bid:           float32(nbbo.BidPrice),
+			AskTimestamp:  nbbo.Timestamp.In(calendar.NY),
+			Ask:           float32(nbbo.AskPrice),
+			LastTimestamp: trade.Timestamp.In(calendar.NY),
+			Last:          float32(trade.Price),
+		}
+	}
+
+	return quotes, nil
+}
+
 func (s *quoteService) GetByIDs(assetIDs []uuid.UUID) ([]*QuoteAsAsset, error) {
 	if len(assetIDs) == 0 {
 		return []*QuoteAsAsset{}, nil
@@ -1,16 +1,16 @@
 package quote
 
 import (
-	"fmt"
 	"testing"
 
 	"github.com/alpacahq/gobroker/dbtest"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
-	"github.com/alpacahq/gobroker/price"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/polycache/structures"
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -19,6 +19,7 @@ import (
 type QuoteTestSuite struct {
 	dbtest.Suite
 	asset *models.Asset
+	md    *marketdata.Store
 }
 
 func TestQuoteTestSuite(t *testing.T) {
@@ -37,6 +38,10 @@ func (s *QuoteTestSuite) SetupSuite() {
 	if err := db.DB().Create(s.asset).Error; err != nil {
 		assert.FailNow(s.T(), err.Error())
 	}
+
+	s.md = marketdata.NewStore()
+	s.md.SetTrade("AAPL", structures.Trade{Price: 100.32, Timestamp: clock.Now()})
+	s.md.SetQuote("AAPL", structures.Quote{BidPrice: 100.32, AskPrice: 100.32, Timestamp: clock.Now()})
 }
 
 func (s *QuoteTestSuite) TearDownSuite() {
@@ -45,21 +50,8 @@ func (s *QuoteTestSuite) TearDownSuite() {
 
 func (s *QuoteTestSuite) TestGetByID() {
 	srv := quoteService{
-		tx: db.DB(),
-		getQuotes: func(symbols []string) ([]price.Quote, error) {
-			quotes := make([]price.Quote, len(symbols))
-			for i := range symbols {
-				quotes[i] = price.Quote{
-					BidTimestamp:  clock.Now(),
-					Bid:           100.32,
-					AskTimestamp:  clock.Now(),
-					Ask:           100.32,
-					LastTimestamp: clock.Now(),
-					Last:          100.32,
-				}
-			}
-			return quotes, nil
-		},
+		tx:         db.DB(),
+		md:         s.md,
 		assetcache: assetcache.GetAssetCache(),
 	}
 
@@ -71,9 +63,8 @@ func (s *QuoteTestSuite) TestGetByID() {
 	assert.Nil(s.T(), quote)
 	assert.NotNil(s.T(), err)
 
-	srv.getQuotes = func(symbols []string) ([]price.Quote, error) {
-		return nil, fmt.Errorf("no quote!")
-	}
+	// nothing in the market data for the symbol
+	srv.md = marketdata.NewStore()
 	quote, err = srv.GetByID(s.asset.IDAsUUID())
 	assert.Nil(s.T(), quote)
 	assert.NotNil(s.T(), err)
@@ -81,21 +72,8 @@ func (s *QuoteTestSuite) TestGetByID() {
 
 func (s *QuoteTestSuite) TestGetByIDs() {
 	srv := quoteService{
-		tx: db.DB(),
-		getQuotes: func(symbols []string) ([]price.Quote, error) {
-			quotes := make([]price.Quote, len(symbols))
-			for i := range symbols {
-				quotes[i] = price.Quote{
-					BidTimestamp:  clock.Now(),
-					Bid:           100.32,
-					AskTimestamp:  clock.Now(),
-					Ask:           100.32,
-					LastTimestamp: clock.Now(),
-					Last:          100.32,
-				}
-			}
-			return quotes, nil
-		},
+		tx:         db.DB(),
+		md:         s.md,
 		assetcache: assetcache.GetAssetCache(),
 	}
 
@@ -108,9 +86,8 @@ func (s *QuoteTestSuite) TestGetByIDs() {
 	assert.Nil(s.T(), quotes)
 	assert.NotNil(s.T(), err)
 
-	srv.getQuotes = func(symbols []string) ([]price.Quote, error) {
-		return nil, fmt.Errorf("no quote!")
-	}
+	// nothing in the market data for the symbol
+	srv.md = marketdata.NewStore()
 	quotes, err = srv.GetByIDs([]uuid.UUID{s.asset.IDAsUUID()})
 	assert.Nil(s.T(), quotes)
 	assert.Len(s.T(), quotes, 0)
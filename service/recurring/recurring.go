@@ -0,0 +1,287 @@
+package recurring
+
+import (
+	"fmt"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/audit"
+	"github.com/alpacahq/gobroker/service/transfer"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+type RecurringTransferService interface {
+	List(accountID uuid.UUID) ([]models.RecurringTransfer, error)
+	GetByID(accountID uuid.UUID, id string) (*models.RecurringTransfer, error)
+	Create(accountID uuid.UUID, req RecurringTransferRequest) (*models.RecurringTransfer, error)
+	Pause(accountID uuid.UUID, id, reason string) (*models.RecurringTransfer, error)
+	Resume(accountID uuid.UUID, id string) (*models.RecurringTransfer, error)
+	Cancel(accountID uuid.UUID, id string) error
+	Due(asof date.Date) ([]models.RecurringTransfer, error)
+	Execute(rt *models.RecurringTransfer, asof date.Date) (*models.Transfer, error)
+	WithTx(tx *gorm.DB) RecurringTransferService
+}
+
+type recurringService struct {
+	RecurringTransferService
+	tx        *gorm.DB
+	transfers transfer.TransferService
+}
+
+// Service returns the recurring transfer service. The transfer
+// service is used to create the transfer for each run.
+func Service(transfers transfer.TransferService) RecurringTransferService {
+	return &recurringService{transfers: transfers}
+}
+
+func (s *recurringService) WithTx(tx *gorm.DB) RecurringTransferService {
+	s.tx = tx
+	return s
+}
+
+// RecurringTransferRequest schedules a deposit. The start date
+// defaults to today when omitted.
+type RecurringTransferRequest struct {
+	RelationshipID string                  `json:"relationship_id"`
+	Amount         decimal.Decimal         `json:"amount"`
+	Frequency      enum.RecurringFrequency `json:"frequency"`
+	StartDate      *date.Date              `json:"start_date"`
+}
+
+func (r *RecurringTransferRequest) Verify() error {
+	if r.RelationshipID == "" {
+		return gberrors.InvalidRequestParam.WithMsg("relationship_id is required")
+	}
+
+	if r.Amount.LessThanOrEqual(decimal.Zero) {
+		return gberrors.InvalidRequestParam.WithMsg("amount must be > 0")
+	}
+
+	switch r.Frequency {
+	case enum.Weekly, enum.Biweekly, enum.Monthly:
+	default:
+		return gberrors.InvalidRequestParam.WithMsg("frequency must be one of weekly, biweekly or monthly")
+	}
+
+	if r.StartDate != nil && r.StartDate.Before(today().Date) {
+		return gberrors.InvalidRequestParam.WithMsg("start_date must not be in the past")
+	}
+
+	return nil
+}
+
+func (s *recurringService) List(accountID uuid.UUID) ([]models.RecurringTransfer, error) {
+	rts := []models.RecurringTransfer{}
+
+	if err := s.tx.
+		Where("account_id = ? AND status != ?", accountID.String(), enum.RecurringCanceled).
+		Order("created_at DESC").
+		Find(&rts).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return rts, nil
+}
+
+func (s *recurringService) GetByID(accountID uuid.UUID, id string) (*models.RecurringTransfer, error) {
+	rt := &models.RecurringTransfer{}
+
+	q := s.tx.
+		Where("id = ? AND account_id = ?", id, accountID.String()).
+		Set("gorm:query_option", db.ForUpdate).
+		Find(rt)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.NotFound.WithMsg("recurring transfer not found")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	return rt, nil
+}
+
+func (s *recurringService) Create(accountID uuid.UUID, req RecurringTransferRequest) (*models.RecurringTransfer, error) {
+	if err := req.Verify(); err != nil {
+		return nil, err
+	}
+
+	rel := &models.ACHRelationship{}
+
+	q := s.tx.Where(
+		"id = ? AND account_id = ? AND status = ?",
+		req.RelationshipID, accountID.String(), enum.RelationshipApproved).Find(rel)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.InvalidRequestParam.WithMsg("relationship not found or not approved")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	start := today()
+	if req.StartDate != nil {
+		start = *req.StartDate
+	}
+
+	rt := &models.RecurringTransfer{
+		AccountID:      accountID.String(),
+		RelationshipID: rel.ID,
+		Amount:         req.Amount,
+		Frequency:      req.Frequency,
+		Status:         enum.RecurringActive,
+		StartDate:      start,
+	}
+
+	rt.NextRunDate = rt.RunDate(0)
+
+	if err := s.tx.Create(rt).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err := s.audit(rt, nil); err != nil {
+		return nil, err
+	}
+
+	return rt, nil
+}
+
+// Pause stops the schedule from running until it is resumed. The
+// reason is shown to the account owner.
+func (s *recurringService) Pause(accountID uuid.UUID, id, reason string) (*models.RecurringTransfer, error) {
+	rt, err := s.GetByID(accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if rt.Status != enum.RecurringActive {
+		return nil, gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("recurring transfer is not active (status: %v)", rt.Status))
+	}
+
+	return rt, s.update(rt, map[string]interface{}{
+		"status": enum.RecurringPaused,
+		"reason": reason,
+	})
+}
+
+// Resume reactivates a paused schedule. Runs missed while it was
+// paused are skipped.
+func (s *recurringService) Resume(accountID uuid.UUID, id string) (*models.RecurringTransfer, error) {
+	rt, err := s.GetByID(accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if rt.Status != enum.RecurringPaused {
+		return nil, gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("recurring transfer is not paused (status: %v)", rt.Status))
+	}
+
+	// move to the first run on or after today
+	next := *rt
+	next.Advance(date.Date{Date: today().AddDays(-1)})
+
+	return rt, s.update(rt, map[string]interface{}{
+		"status":        enum.RecurringActive,
+		"reason":        "",
+		"runs":          next.Runs,
+		"next_run_date": next.NextRunDate,
+	})
+}
+
+func (s *recurringService) Cancel(accountID uuid.UUID, id string) error {
+	rt, err := s.GetByID(accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if rt.Status == enum.RecurringCanceled {
+		return nil
+	}
+
+	return s.update(rt, map[string]interface{}{
+		"status": enum.RecurringCanceled,
+	})
+}
+
+// Due returns the active schedules with a run on or before asof.
+func (s *recurringService) Due(asof date.Date) ([]models.RecurringTransfer, error) {
+	rts := []models.RecurringTransfer{}
+
+	if err := s.tx.
+		Where("status = ? AND next_run_date <= ?", enum.RecurringActive, asof).
+		Order("next_run_date ASC").
+		Find(&rts).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return rts, nil
+}
+
+// Execute creates the transfer for the schedule's next run, and
+// moves the schedule on to the following run after asof.
+func (s *recurringService) Execute(rt *models.RecurringTransfer, asof date.Date) (*models.Transfer, error) {
+	xfer, err := s.transfers.WithTx(s.tx).Create(
+		rt.AccountIDAsUUID(),
+		rt.RelationshipID,
+		apex.Incoming,
+		rt.Amount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.tx.Model(xfer).Update("recurring_transfer_id", rt.ID).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	now := clock.Now()
+
+	next := *rt
+	next.Advance(asof)
+
+	if err = s.tx.Model(rt).Updates(map[string]interface{}{
+		"runs":          next.Runs,
+		"next_run_date": next.NextRunDate,
+		"last_run_at":   now,
+	}).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return xfer, nil
+}
+
+func (s *recurringService) update(rt *models.RecurringTransfer, updates map[string]interface{}) error {
+	before := audit.Snapshot(rt)
+
+	if err := s.tx.Model(rt).Updates(updates).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return s.audit(rt, before)
+}
+
+func (s *recurringService) audit(rt *models.RecurringTransfer, before interface{}) error {
+	return audit.Record(s.tx, audit.Entry{
+		AccountID:  rt.AccountID,
+		Resource:   models.AuditRecurringTransfer,
+		ResourceID: rt.ID,
+		Before:     before,
+		After:      rt,
+	})
+}
+
+func today() date.Date {
+	return date.DateOf(clock.Now().In(calendar.NY))
+}
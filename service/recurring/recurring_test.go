@@ -0,0 +1,158 @@
+package recurring
+
+import (
+	"testing"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/transfer"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecurringTestSuite struct {
+	dbtest.Suite
+	account      *models.Account
+	relationship *models.ACHRelationship
+}
+
+func TestRecurringTestSuite(t *testing.T) {
+	suite.Run(t, new(RecurringTestSuite))
+}
+
+func (s *RecurringTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	apexAcct := "apca_test"
+	legalName := "First Last"
+	s.account = &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			models.Owner{
+				Email:   "trader@test.db",
+				Primary: true,
+				Details: models.OwnerDetails{
+					LegalName: &legalName,
+				},
+			},
+		},
+	}
+	if err := db.DB().Create(s.account).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+	plaidUUID := uuid.Must(uuid.NewV4()).String()
+	s.relationship = &models.ACHRelationship{
+		ID:               uuid.Must(uuid.NewV4()).String(),
+		Status:           enum.RelationshipApproved,
+		AccountID:        s.account.ID,
+		ApprovalMethod:   apex.Plaid,
+		PlaidAccount:     &plaidUUID,
+		PlaidToken:       &plaidUUID,
+		PlaidItem:        &plaidUUID,
+		PlaidInstitution: &plaidUUID,
+	}
+	if err := db.DB().Create(s.relationship).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+}
+
+func (s *RecurringTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *RecurringTestSuite) TestSchedule() {
+	d := func(v string) date.Date {
+		dt, err := date.ParseDate(v)
+		require.Nil(s.T(), err)
+		return dt
+	}
+
+	// friday, every other week
+	rt := models.RecurringTransfer{Frequency: enum.Biweekly, StartDate: d("2019-01-18")}
+	assert.Equal(s.T(), d("2019-01-18"), rt.RunDate(0))
+	assert.Equal(s.T(), d("2019-02-01"), rt.RunDate(1))
+
+	// MLK day falls on the second run
+	rt = models.RecurringTransfer{Frequency: enum.Weekly, StartDate: d("2019-01-14")}
+	assert.Equal(s.T(), d("2019-01-21"), rt.ScheduledDate(1))
+	assert.Equal(s.T(), d("2019-01-22"), rt.RunDate(1))
+
+	// end of month is clamped, and weekends roll forward
+	rt = models.RecurringTransfer{Frequency: enum.Monthly, StartDate: d("2019-01-31")}
+	assert.Equal(s.T(), d("2019-02-28"), rt.RunDate(1))
+	assert.Equal(s.T(), d("2019-03-31"), rt.ScheduledDate(2))
+	assert.Equal(s.T(), d("2019-04-01"), rt.RunDate(2))
+
+	// missed runs are skipped
+	rt.NextRunDate = rt.RunDate(0)
+	rt.Advance(d("2019-03-15"))
+	assert.Equal(s.T(), 2, rt.Runs)
+	assert.Equal(s.T(), d("2019-04-01"), rt.NextRunDate)
+}
+
+func (s *RecurringTestSuite) TestRecurring() {
+	srv := recurringService{tx: db.DB(), transfers: transfer.Service()}
+
+	// bad frequency
+	_, err := srv.Create(s.account.IDAsUUID(), RecurringTransferRequest{
+		RelationshipID: s.relationship.ID,
+		Amount:         decimal.NewFromFloat(500),
+		Frequency:      "daily",
+	})
+	assert.NotNil(s.T(), err)
+
+	rt, err := srv.Create(s.account.IDAsUUID(), RecurringTransferRequest{
+		RelationshipID: s.relationship.ID,
+		Amount:         decimal.NewFromFloat(500),
+		Frequency:      enum.Biweekly,
+	})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.RecurringActive, rt.Status)
+	assert.False(s.T(), rt.NextRunDate.Before(today().Date))
+
+	asof := rt.NextRunDate
+
+	due, err := srv.Due(asof)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), due, 1)
+
+	xfer, err := srv.Execute(&due[0], asof)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), apex.Incoming, xfer.Direction)
+	assert.True(s.T(), xfer.Amount.Equal(rt.Amount))
+	assert.Equal(s.T(), rt.ID, *xfer.RecurringTransferID)
+
+	rt, err = srv.GetByID(s.account.IDAsUUID(), rt.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, rt.Runs)
+	assert.True(s.T(), rt.NextRunDate.After(asof.Date))
+
+	due, err = srv.Due(asof)
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), due)
+
+	rt, err = srv.Pause(s.account.IDAsUUID(), rt.ID, "transfer returned")
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.RecurringPaused, rt.Status)
+	assert.Equal(s.T(), "transfer returned", rt.Reason)
+
+	rt, err = srv.Resume(s.account.IDAsUUID(), rt.ID)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.RecurringActive, rt.Status)
+	assert.Empty(s.T(), rt.Reason)
+
+	require.Nil(s.T(), srv.Cancel(s.account.IDAsUUID(), rt.ID))
+
+	rts, err := srv.List(s.account.IDAsUUID())
+	require.Nil(s.T(), err)
+	assert.Empty(s.T(), rts)
+}
@@ -2,16 +2,22 @@ package registry
 
 import (
 	"github.com/alpacahq/gobroker/service/accesskey"
+	"github.com/alpacahq/gobroker/service/alert"
+	"github.com/alpacahq/gobroker/service/algo"
 	"github.com/alpacahq/gobroker/service/asset"
 	"github.com/alpacahq/gobroker/service/assetcache"
 	"github.com/alpacahq/gobroker/service/bar"
 	"github.com/alpacahq/gobroker/service/fundamental"
+	"github.com/alpacahq/gobroker/service/marketdata"
 	"github.com/alpacahq/gobroker/service/order"
+	"github.com/alpacahq/gobroker/service/performance"
 	"github.com/alpacahq/gobroker/service/portfolio"
 	"github.com/alpacahq/gobroker/service/position"
 	"github.com/alpacahq/gobroker/service/profitloss"
 	"github.com/alpacahq/gobroker/service/quote"
+	"github.com/alpacahq/gobroker/service/snapshot"
 	"github.com/alpacahq/gobroker/service/tradeaccount"
+	"github.com/alpacahq/gobroker/service/watchlist"
 )
 
 type Registry interface {
@@ -20,10 +26,16 @@ type Registry interface {
 	Asset() asset.AssetService
 	AssetCache() assetcache.AssetCache
 	Fundamental() fundamental.FundamentalService
+	MarketData() marketdata.MarketData
 	Quote() quote.QuoteService
 	Bar() bar.BarService
+	Snapshot() snapshot.SnapshotService
 	Position() position.PositionService
 	Order() order.OrderService
 	Portfolio() portfolio.PortfolioService
 	ProfitLoss() profitloss.ProfitLossService
+	Performance() performance.PerformanceService
+	Watchlist() watchlist.WatchlistService
+	Alert() alert.AlertService
+	Algo() algo.AlgoService
 }
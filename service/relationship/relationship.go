@@ -9,8 +9,8 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/audit"
 	"github.com/alpacahq/gobroker/utils"
-	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
@@ -165,6 +165,15 @@ func (s *relationshipService) Create(accountID uuid.UUID, bInfo BankAcctInfo) (*
 		return nil, gberrors.InternalServerError.WithError(err)
 	}
 
+	if err = audit.Record(s.tx, audit.Entry{
+		AccountID:  rel.AccountID,
+		Resource:   models.AuditACHRelationship,
+		ResourceID: rel.ID,
+		After:      rel,
+	}); err != nil {
+		return nil, err
+	}
+
 	// notify via slack
 	{
 		msg := slack.NewFundingActivity()
@@ -238,22 +247,10 @@ func (s *relationshipService) Cancel(accountID uuid.UUID, relID string) error {
 		}
 	}
 
-	// Check created_at. If it's less than 90 days from creation, update the Account to be flagged.
-	// .Hours() gives hours since the 2 dates, so divide that by 24 to get days
-	if (clock.Now().Sub(rel.CreatedAt).Hours() / 24) < 90 {
-		d := date.DateOf(clock.Now().In(calendar.NY))
-		acct.RiskyTransfers = true
-		acct.MarkedRiskyTransfersAt = &d
-
-		updates := map[string]interface{}{
-			"risky_transfers":           true,
-			"marked_risky_transfers_at": &d,
-		}
-		// Update the account to flag the account with risky_transfers == true
-		if err = s.tx.Model(acct).Updates(updates).Error; err != nil {
-			return gberrors.InternalServerError.WithError(err)
-		}
-	}
+	// unlinking a bank soon after linking it holds withdrawals for a
+	// while, see the transfer velocity checks
+	now := clock.Now()
+	rel.UnlinkedAt = &now
 
 	// notify via slack
 	if err = s.tx.Save(&rel).Error; err == nil {
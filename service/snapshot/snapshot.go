@@ -0,0 +1,248 @@
+package snapshot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/bar"
+	"github.com/alpacahq/gobroker/service/marketdata"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type SnapshotService interface {
+	GetLastTrade(assetID uuid.UUID) (*TradeAsAsset, error)
+	GetLastTrades(assetIDs []uuid.UUID) ([]*TradeAsAsset, error)
+	GetByIDs(assetIDs []uuid.UUID) ([]*Snapshot, error)
+	WithTx(tx *gorm.DB) SnapshotService
+}
+
+type snapshotService struct {
+	SnapshotService
+	tx         *gorm.DB
+	md         marketdata.MarketData
+	assetcache assetcache.AssetCache
+	barService bar.BarService
+}
+
+func Service(assetcache assetcache.AssetCache, md marketdata.MarketData, barService bar.BarService) SnapshotService {
+	return &snapshotService{
+		md:         md,
+		assetcache: assetcache,
+		barService: barService,
+	}
+}
+
+func (s *snapshotService) WithTx(tx *gorm.DB) SnapshotService {
+	s.tx = tx
+	return s
+}
+
+type Trade struct {
+	Price     float64   `json:"price"`
+	Size      int64     `json:"size"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type Quote struct {
+	BidPrice  float64   `json:"bid_price"`
+	BidSize   int64     `json:"bid_size"`
+	AskPrice  float64   `json:"ask_price"`
+	AskSize   int64     `json:"ask_size"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type TradeAsAsset struct {
+	Trade
+	AssetID uuid.UUID       `json:"asset_id"`
+	Symbol  string          `json:"symbol"`
+	Class   enum.AssetClass `json:"asset_class"`
+}
+
+// Snapshot is the current market data of an asset in one response.
+// Any of it may be missing, such as the quote of a halted symbol, or
+// everything but the previous close before the open.
+type Snapshot struct {
+	AssetID     uuid.UUID       `json:"asset_id"`
+	Symbol      string          `json:"symbol"`
+	Class       enum.AssetClass `json:"asset_class"`
+	LatestTrade *Trade          `json:"latest_trade"`
+	LatestQuote *Quote          `json:"latest_quote"`
+	MinuteBar   *bar.Bar        `json:"minute_bar"`
+	DailyBar    *bar.Bar        `json:"daily_bar"`
+	PrevClose   *float64        `json:"prev_close"`
+}
+
+func (s *snapshotService) GetLastTrade(assetID uuid.UUID) (*TradeAsAsset, error) {
+	asset := s.assetcache.GetByID(assetID)
+	if asset == nil {
+		return nil, gberrors.NotFound
+	}
+
+	trades, err := s.lastTrades([]*models.Asset{asset})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(trades) == 0 {
+		return nil, gberrors.NotFound.WithMsg(fmt.Sprintf("no trade found for %v", asset.Symbol))
+	}
+
+	return trades[0], nil
+}
+
+// GetLastTrades returns the latest trade of each asset, leaving out the
+// ones which haven't traded.
+func (s *snapshotService) GetLastTrades(assetIDs []uuid.UUID) ([]*TradeAsAsset, error) {
+	return s.lastTrades(s.assets(assetIDs))
+}
+
+func (s *snapshotService) lastTrades(assets []*models.Asset) ([]*TradeAsAsset, error) {
+	trades, err := s.md.LastTrades(symbols(assets))
+	if err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	ta := []*TradeAsAsset{}
+
+	for _, asset := range assets {
+		trade, ok := trades[asset.Symbol]
+		if !ok {
+			continue
+		}
+
+		ta = append(ta, &TradeAsAsset{
+			Trade: Trade{
+				Price:     trade.Price,
+				Size:      trade.Size,
+				Timestamp: trade.Timestamp.In(calendar.NY),
+			},
+			AssetID: asset.IDAsUUID(),
+			Symbol:  asset.Symbol,
+			Class:   asset.Class,
+		})
+	}
+
+	return ta, nil
+}
+
+// GetByIDs returns the snapshots of the assets, in the order asked for.
+func (s *snapshotService) GetByIDs(assetIDs []uuid.UUID) ([]*Snapshot, error) {
+	assets := s.assets(assetIDs)
+
+	if len(assets) == 0 {
+		return []*Snapshot{}, nil
+	}
+
+	syms := symbols(assets)
+
+	trades, err := s.md.LastTrades(syms)
+	if err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	quotes, err := s.md.Quotes(syms)
+	if err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	closes, err := s.md.LastClose(syms, nil)
+	if err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	minuteBars, err := s.lastBars(assetIDs, "1Min", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// the latest daily bar, which is today's so far while the market
+	// is open
+	dailyBars, err := s.lastBars(assetIDs, "1D", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, len(assets))
+
+	for i, asset := range assets {
+		snap := &Snapshot{
+			AssetID:   asset.IDAsUUID(),
+			Symbol:    asset.Symbol,
+			Class:     asset.Class,
+			MinuteBar: minuteBars[asset.Symbol],
+			DailyBar:  dailyBars[asset.Symbol],
+		}
+
+		if trade, ok := trades[asset.Symbol]; ok {
+			snap.LatestTrade = &Trade{
+				Price:     trade.Price,
+				Size:      trade.Size,
+				Timestamp: trade.Timestamp.In(calendar.NY),
+			}
+		}
+
+		if quote, ok := quotes[asset.Symbol]; ok {
+			snap.LatestQuote = &Quote{
+				BidPrice:  quote.BidPrice,
+				BidSize:   quote.BidSize,
+				AskPrice:  quote.AskPrice,
+				AskSize:   quote.AskSize,
+				Timestamp: quote.Timestamp.In(calendar.NY),
+			}
+		}
+
+		if prevClose, ok := closes[asset.Symbol]; ok {
+			px := prevClose.Price
+			snap.PrevClose = &px
+		}
+
+		snapshots[i] = snap
+	}
+
+	return snapshots, nil
+}
+
+// lastBars returns the latest bar of each symbol in the timeframe.
+func (s *snapshotService) lastBars(assetIDs []uuid.UUID, timeframe string, limit int) (map[string]*bar.Bar, error) {
+	list, err := s.barService.WithTx(s.tx).GetByIDs(assetIDs, timeframe, nil, nil, &limit)
+	if err != nil {
+		return nil, err
+	}
+
+	bars := map[string]*bar.Bar{}
+
+	for _, assetBars := range list {
+		if len(assetBars.Bars) > 0 {
+			bars[assetBars.Symbol] = assetBars.Bars[len(assetBars.Bars)-1]
+		}
+	}
+
+	return bars, nil
+}
+
+// assets looks up the assets, skipping the ones which don't exist.
+func (s *snapshotService) assets(assetIDs []uuid.UUID) []*models.Asset {
+	assets := []*models.Asset{}
+
+	for _, id := range assetIDs {
+		if asset := s.assetcache.GetByID(id); asset != nil {
+			assets = append(assets, asset)
+		}
+	}
+
+	return assets
+}
+
+func symbols(assets []*models.Asset) []string {
+	symbols := make([]string, len(assets))
+	for i, asset := range assets {
+		symbols[i] = asset.Symbol
+	}
+	return symbols
+}
@@ -0,0 +1,141 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/bar"
+	"github.com/alpacahq/gobroker/service/marketdata"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/polycache/structures"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnapshotTestSuite struct {
+	dbtest.Suite
+	asset *models.Asset
+	other *models.Asset
+	md    *marketdata.Store
+}
+
+func TestSnapshotTestSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotTestSuite))
+}
+
+func (s *SnapshotTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	s.asset = &models.Asset{
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NASDAQ",
+		Symbol:   "AAPL",
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	if err := db.DB().Create(s.asset).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+
+	s.other = &models.Asset{
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NASDAQ",
+		Symbol:   "MSFT",
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	if err := db.DB().Create(s.other).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+
+	prev := tradingdate.Current().Prev()
+
+	// only AAPL has market data
+	s.md = marketdata.NewStore()
+	s.md.SetTrade("AAPL", structures.Trade{Price: 100.32, Size: 50, Timestamp: clock.Now()})
+	s.md.SetQuote("AAPL", structures.Quote{BidPrice: 100.3, AskPrice: 100.34, Timestamp: clock.Now()})
+	s.md.SetBars("AAPL", "1Min", []marketdata.Bar{
+		{Time: prev.MarketClose().Add(-time.Minute), Open: 99, High: 99.5, Low: 98.5, Close: 99.1, Volume: 1000},
+	})
+	s.md.SetBars("AAPL", "1D", []marketdata.Bar{
+		{Time: prev.MarketOpen(), Open: 98, High: 100, Low: 97, Close: 99.1, Volume: 100000},
+	})
+}
+
+func (s *SnapshotTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *SnapshotTestSuite) service() *snapshotService {
+	return &snapshotService{
+		tx:         db.DB(),
+		md:         s.md,
+		assetcache: assetcache.GetAssetCache(),
+		barService: bar.Service(assetcache.GetAssetCache(), s.md),
+	}
+}
+
+func (s *SnapshotTestSuite) TestGetLastTrade() {
+	srv := s.service()
+
+	trade, err := srv.GetLastTrade(s.asset.IDAsUUID())
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "AAPL", trade.Symbol)
+	assert.Equal(s.T(), 100.32, trade.Price)
+	assert.Equal(s.T(), int64(50), trade.Size)
+
+	// no trade for the symbol
+	trade, err = srv.GetLastTrade(s.other.IDAsUUID())
+	assert.Nil(s.T(), trade)
+	assert.NotNil(s.T(), err)
+
+	trade, err = srv.GetLastTrade(uuid.Must(uuid.NewV4()))
+	assert.Nil(s.T(), trade)
+	assert.NotNil(s.T(), err)
+}
+
+func (s *SnapshotTestSuite) TestGetLastTrades() {
+	trades, err := s.service().GetLastTrades([]uuid.UUID{s.asset.IDAsUUID(), s.other.IDAsUUID()})
+	assert.Nil(s.T(), err)
+	require.Len(s.T(), trades, 1)
+	assert.Equal(s.T(), "AAPL", trades[0].Symbol)
+}
+
+func (s *SnapshotTestSuite) TestGetByIDs() {
+	snapshots, err := s.service().GetByIDs([]uuid.UUID{s.other.IDAsUUID(), s.asset.IDAsUUID()})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), snapshots, 2)
+
+	// in the order asked for, with nothing for MSFT
+	assert.Equal(s.T(), "MSFT", snapshots[0].Symbol)
+	assert.Nil(s.T(), snapshots[0].LatestTrade)
+	assert.Nil(s.T(), snapshots[0].LatestQuote)
+	assert.Nil(s.T(), snapshots[0].MinuteBar)
+	assert.Nil(s.T(), snapshots[0].DailyBar)
+	assert.Nil(s.T(), snapshots[0].PrevClose)
+
+	snap := snapshots[1]
+	assert.Equal(s.T(), "AAPL", snap.Symbol)
+	require.NotNil(s.T(), snap.LatestTrade)
+	assert.Equal(s.T(), 100.32, snap.LatestTrade.Price)
+	require.NotNil(s.T(), snap.LatestQuote)
+	assert.Equal(s.T(), 100.34, snap.LatestQuote.AskPrice)
+	require.NotNil(s.T(), snap.MinuteBar)
+	assert.Equal(s.T(), float32(99.1), snap.MinuteBar.Close)
+	require.NotNil(s.T(), snap.DailyBar)
+	assert.Equal(s.T(), float32(98), snap.DailyBar.Open)
+	require.NotNil(s.T(), snap.PrevClose)
+	assert.InDelta(s.T(), 99.1, *snap.PrevClose, 1e-4)
+
+	snapshots, err = s.service().GetByIDs([]uuid.UUID{})
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), snapshots, 0)
+}
@@ -3,7 +3,9 @@ package tradeaccount
 import (
 	"time"
 
+	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/op"
 	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/calendar"
@@ -105,7 +107,9 @@ func (s *tradeAccountService) ForUpdate() TradeAccountService {
 }
 
 type ConfigureRequest struct {
-	SuspendTrade *bool `json:"suspend_trade"`
+	SuspendTrade     *bool           `json:"suspend_trade"`
+	ProtectGoodFaith *bool           `json:"protect_good_faith"`
+	LotMethod        *enum.LotMethod `json:"lot_method"`
 }
 
 // Configure sets user-configure values to the account. Returns true if
@@ -123,6 +127,19 @@ func (s *tradeAccountService) Configure(accountID uuid.UUID, req *ConfigureReque
 		toUpdate = true
 	}
 
+	if req.ProtectGoodFaith != nil {
+		acct.ProtectGoodFaith = *req.ProtectGoodFaith
+		toUpdate = true
+	}
+
+	if req.LotMethod != nil {
+		if !enum.ValidLotMethod(*req.LotMethod) {
+			return false, gberrors.InvalidRequestParam.WithMsg("lot_method must be one of fifo, lifo or hifo")
+		}
+		acct.LotMethod = *req.LotMethod
+		toUpdate = true
+	}
+
 	if toUpdate {
 		if err := s.tx.Save(acct).Error; err != nil {
 			return false, err
@@ -0,0 +1,323 @@
+package transfer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alpacahq/apex"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// Limits are the maximum totals allowed to be transferred in a
+// single direction.
+type Limits struct {
+	Daily           decimal.Decimal `json:"daily"`
+	Rolling30Day    decimal.Decimal `json:"rolling_30_day"`
+	PerRelationship decimal.Decimal `json:"per_relationship"`
+}
+
+type limitTier struct {
+	// minimum account age for the tier to apply
+	age        time.Duration
+	plan       enum.AccountPlan
+	deposit    Limits
+	withdrawal Limits
+}
+
+// tiers are ordered from the most to the least established, the
+// first matching one applies.
+var tiers = []limitTier{
+	{
+		age:        90 * calendar.Day,
+		plan:       enum.PremiumAccount,
+		deposit:    limits(250000, 1000000, 1000000),
+		withdrawal: limits(250000, 1000000, 1000000),
+	},
+	{
+		age:        0,
+		plan:       enum.PremiumAccount,
+		deposit:    limits(100000, 250000, 250000),
+		withdrawal: limits(100000, 250000, 250000),
+	},
+	{
+		age:        90 * calendar.Day,
+		plan:       enum.RegularAccount,
+		deposit:    limits(50000, 250000, 250000),
+		withdrawal: limits(50000, 250000, 250000),
+	},
+	{
+		age:        0,
+		plan:       enum.RegularAccount,
+		deposit:    limits(50000, 100000, 100000),
+		withdrawal: limits(50000, 100000, 100000),
+	},
+}
+
+func limits(daily, rolling, perRelationship int64) Limits {
+	return Limits{
+		Daily:           decimal.New(daily, 0),
+		Rolling30Day:    decimal.New(rolling, 0),
+		PerRelationship: decimal.New(perRelationship, 0),
+	}
+}
+
+var (
+	// withdrawals to a bank linked more recently than this are held
+	newRelationshipWindow = 7 * calendar.Day
+	// withdrawals this soon after a deposit are held
+	depositWithdrawalWindow = 5 * calendar.Day
+	// withdrawals are held for this long after a bank is unlinked,
+	// if it was linked for less than this
+	unlinkedRelationshipWindow = 90 * calendar.Day
+)
+
+// LimitReason identifies why a transfer was rejected or held.
+type LimitReason string
+
+const (
+	// limits, which reject the transfer
+	DailyLimit        LimitReason = "daily_limit"
+	RollingLimit      LimitReason = "rolling_30_day_limit"
+	RelationshipLimit LimitReason = "relationship_limit"
+	// velocity checks, which hold the transfer for admin approval
+	NewRelationship        LimitReason = "new_relationship"
+	WithdrawalAfterDeposit LimitReason = "withdrawal_after_deposit"
+	UnlinkedRelationship   LimitReason = "unlinked_relationship"
+)
+
+type LimitCheck struct {
+	Reason  LimitReason      `json:"reason"`
+	Message string           `json:"message"`
+	Limit   *decimal.Decimal `json:"limit,omitempty"`
+}
+
+// LimitError is returned when a transfer exceeds the account's
+// limits. The reasons are included in the response body.
+type LimitError struct {
+	err     *gberrors.Error
+	Reasons []LimitCheck
+}
+
+func (e *LimitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *LimitError) ExceptionBody() map[string]interface{} {
+	body := e.err.ExceptionBody()
+	body["reasons"] = e.Reasons
+	return body
+}
+
+func (e *LimitError) ExceptionStatusCode() int {
+	return e.err.ExceptionStatusCode()
+}
+
+func (e *LimitError) RawException() error {
+	return e.err.RawException()
+}
+
+// LimitsFor returns the effective limits for the account in the
+// given direction, including any admin override.
+func LimitsFor(tx *gorm.DB, acct *models.Account, dir apex.TransferDirection) (Limits, *models.TransferLimitOverride, error) {
+	age := clock.Now().Sub(acct.CreatedAt)
+
+	plan := acct.Plan
+	if plan != enum.PremiumAccount {
+		plan = enum.RegularAccount
+	}
+
+	var l Limits
+
+	for _, tier := range tiers {
+		if tier.plan == plan && age >= tier.age {
+			if dir == apex.Incoming {
+				l = tier.deposit
+			} else {
+				l = tier.withdrawal
+			}
+			break
+		}
+	}
+
+	override := &models.TransferLimitOverride{}
+
+	q := tx.Where("account_id = ? AND direction = ?", acct.ID, dir).Find(override)
+
+	if q.RecordNotFound() {
+		return l, nil, nil
+	}
+
+	if q.Error != nil {
+		return l, nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	if override.Expired() {
+		return l, nil, nil
+	}
+
+	if override.Daily != nil {
+		l.Daily = *override.Daily
+	}
+
+	if override.Rolling30Day != nil {
+		l.Rolling30Day = *override.Rolling30Day
+	}
+
+	if override.PerRelationship != nil {
+		l.PerRelationship = *override.PerRelationship
+	}
+
+	return l, override, nil
+}
+
+// checkLimits verifies the transfer fits within the account's limits,
+// and runs the velocity checks. Exceeding a limit returns a LimitError,
+// while failed velocity checks are returned so that the transfer can be
+// held for review.
+func (s *transferService) checkLimits(
+	acct *models.Account,
+	rel *models.ACHRelationship,
+	amt decimal.Decimal,
+	dir apex.TransferDirection) ([]LimitCheck, error) {
+
+	l, override, err := LimitsFor(s.tx, acct, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := clock.Now().In(calendar.NY)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, calendar.NY)
+	monthAgo := now.Add(-30 * calendar.Day)
+
+	sum := func(q *gorm.DB) (decimal.Decimal, error) {
+		transfers := []models.Transfer{}
+
+		if err := q.
+			Where("account_id = ? AND direction = ? AND status NOT IN (?)",
+				acct.ID, dir, []enum.TransferStatus{enum.TransferCanceled, enum.TransferRejected}).
+			Find(&transfers).Error; err != nil {
+			return decimal.Zero, gberrors.InternalServerError.WithError(err)
+		}
+
+		total := amt
+		for _, transfer := range transfers {
+			total = total.Add(transfer.Amount)
+		}
+
+		return total, nil
+	}
+
+	exceeded := []LimitCheck{}
+
+	check := func(reason LimitReason, msg string, limit decimal.Decimal, q *gorm.DB) error {
+		total, err := sum(q)
+		if err != nil {
+			return err
+		}
+
+		if total.GreaterThan(limit) {
+			f, _ := limit.Float64()
+			exceeded = append(exceeded, LimitCheck{
+				Reason:  reason,
+				Message: fmt.Sprintf("%v $%v", msg, humanize.Commaf(f)),
+				Limit:   &limit,
+			})
+		}
+
+		return nil
+	}
+
+	if err = check(
+		DailyLimit,
+		"maximum total daily transfer allowed is",
+		l.Daily,
+		s.tx.Where("created_at >= ?", today)); err != nil {
+		return nil, err
+	}
+
+	if err = check(
+		RollingLimit,
+		"maximum total transfer allowed over 30 days is",
+		l.Rolling30Day,
+		s.tx.Where("created_at >= ?", monthAgo)); err != nil {
+		return nil, err
+	}
+
+	if rel != nil {
+		if err = check(
+			RelationshipLimit,
+			"maximum total transfer allowed with this bank over 30 days is",
+			l.PerRelationship,
+			s.tx.Where("relationship_id = ? AND created_at >= ?", rel.ID, monthAgo)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return nil, &LimitError{
+			err:     gberrors.InvalidRequestParam.WithMsg(exceeded[0].Message),
+			Reasons: exceeded,
+		}
+	}
+
+	if dir != apex.Outgoing || (override != nil && override.SkipVelocity) {
+		return nil, nil
+	}
+
+	holds := []LimitCheck{}
+
+	unlinked := []models.ACHRelationship{}
+
+	if err = s.tx.
+		Unscoped().
+		Where("account_id = ? AND unlinked_at >= ?", acct.ID, now.Add(-unlinkedRelationshipWindow)).
+		Find(&unlinked).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	for _, r := range unlinked {
+		if r.UnlinkedAt.Sub(r.CreatedAt) < unlinkedRelationshipWindow {
+			holds = append(holds, LimitCheck{
+				Reason: UnlinkedRelationship,
+				Message: fmt.Sprintf(
+					"a bank linked for less than %v days was unlinked within the last %v days",
+					int(unlinkedRelationshipWindow/calendar.Day), int(unlinkedRelationshipWindow/calendar.Day)),
+			})
+			break
+		}
+	}
+
+	if rel != nil && now.Sub(rel.CreatedAt) < newRelationshipWindow {
+		holds = append(holds, LimitCheck{
+			Reason:  NewRelationship,
+			Message: fmt.Sprintf("bank was linked within the last %v days", int(newRelationshipWindow/calendar.Day)),
+		})
+	}
+
+	var deposits int
+
+	if err = s.tx.
+		Model(&models.Transfer{}).
+		Where("account_id = ? AND direction = ? AND created_at >= ? AND status NOT IN (?)",
+			acct.ID, apex.Incoming, now.Add(-depositWithdrawalWindow),
+			[]enum.TransferStatus{enum.TransferCanceled, enum.TransferRejected}).
+		Count(&deposits).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if deposits > 0 {
+		holds = append(holds, LimitCheck{
+			Reason:  WithdrawalAfterDeposit,
+			Message: fmt.Sprintf("deposit was made within the last %v days", int(depositWithdrawalWindow/calendar.Day)),
+		})
+	}
+
+	return holds, nil
+}
@@ -1,6 +1,7 @@
 package transfer
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/alpacahq/apex"
@@ -8,6 +9,7 @@ import (
 	"github.com/alpacahq/gobroker/gberrors"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/audit"
 	"github.com/alpacahq/gobroker/service/op"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
@@ -20,6 +22,7 @@ import (
 type TransferService interface {
 	GetByID(accountID uuid.UUID, transferID string) (*models.Transfer, error)
 	Create(accountID uuid.UUID, relID string, dir apex.TransferDirection, amt decimal.Decimal) (*models.Transfer, error)
+	CreateWire(accountID uuid.UUID, beneficiaryID string, amt decimal.Decimal) (*models.Transfer, error)
 	Cancel(accountID uuid.UUID, transferID string) error
 	List(accountID uuid.UUID, dir *apex.TransferDirection, limit, offset *int) ([]models.Transfer, error)
 	Update(transfer *models.Transfer) (*models.Transfer, error)
@@ -67,10 +70,6 @@ func (s *transferService) Create(accountID uuid.UUID, relID string, dir apex.Tra
 		return nil, err
 	}
 
-	if err = s.verifyTransfer(acct, amt, dir); err != nil {
-		return nil, err
-	}
-
 	rel := &models.ACHRelationship{}
 
 	q := s.tx.Where("id = ?", relID).First(rel)
@@ -84,17 +83,26 @@ func (s *transferService) Create(accountID uuid.UUID, relID string, dir apex.Tra
 		return nil, gberrors.InternalServerError.WithError(q.Error)
 	}
 
+	holds, err := s.verifyTransfer(acct, rel, amt, dir)
+	if err != nil {
+		return nil, err
+	}
+
 	expiresAt := clock.Now().Add(7 * calendar.Day)
 
-	// Check if the account is flagged. If it is, update Status to be APPROVAL_PENDING
+	// hold the transfer for approval if any velocity checks failed
 	var (
-		status    enum.TransferStatus
-		slackType string
+		status      enum.TransferStatus
+		slackType   string
+		holdReasons json.RawMessage
 	)
 
-	if acct.RiskyTransfers && dir == apex.Outgoing {
+	if len(holds) > 0 {
 		status = enum.TransferApprovalPending
 		slackType = "transfer_approval_pending"
+		if holdReasons, err = json.Marshal(holds); err != nil {
+			return nil, gberrors.InternalServerError.WithError(err)
+		}
 	} else {
 		status = enum.TransferQueued
 		slackType = "transfer_queued"
@@ -108,12 +116,108 @@ func (s *transferService) Create(accountID uuid.UUID, relID string, dir apex.Tra
 		Direction:      dir,
 		Status:         status,
 		ExpiresAt:      &expiresAt,
+		HoldReasons:    holdReasons,
+	}
+
+	if err = s.tx.Create(transfer).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	if err = audit.Record(s.tx, audit.Entry{
+		AccountID:  transfer.AccountID,
+		Resource:   models.AuditTransfer,
+		ResourceID: transfer.ID,
+		After:      transfer,
+	}); err != nil {
+		return nil, err
+	}
+
+	// notify via slack
+	{
+		msg := slack.NewFundingActivity()
+
+		msg.SetBody(struct {
+			Type        string       `json:"type"`
+			ApexAccount string       `json:"apex_account"`
+			Name        string       `json:"name"`
+			Email       string       `json:"email"`
+			Direction   string       `json:"direction"`
+			Amount      string       `json:"amount"`
+			Reasons     []LimitCheck `json:"reasons,omitempty"`
+		}{
+			slackType,
+			*acct.ApexAccount,
+			*acct.PrimaryOwner().Details.LegalName,
+			acct.PrimaryOwner().Email,
+			string(dir),
+			amt.String(),
+			holds,
+		})
+
+		slack.Notify(msg)
+	}
+
+	return transfer, err
+}
+
+// CreateWire queues an outbound wire to one of the account's
+// beneficiaries. Wires are always held for admin approval before
+// they are released to be sent.
+func (s *transferService) CreateWire(accountID uuid.UUID, beneficiaryID string, amt decimal.Decimal) (*models.Transfer, error) {
+	opt := db.ForUpdate
+
+	acct, err := op.GetAccountByID(s.tx, accountID, &opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if !acct.Fundable() {
+		return nil, gberrors.Forbidden.WithMsg("account is not fundable")
+	}
+
+	if _, err = s.verifyTransfer(acct, nil, amt, apex.Outgoing); err != nil {
+		return nil, err
+	}
+
+	b := &models.WireBeneficiary{}
+
+	q := s.tx.Where(
+		"id = ? AND account_id = ? AND status = ?",
+		beneficiaryID, acct.ID, enum.BeneficiaryActive).First(b)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.InvalidRequestParam.WithMsg("beneficiary not found")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	expiresAt := clock.Now().Add(7 * calendar.Day)
+
+	transfer := &models.Transfer{
+		AccountID:     acct.ID,
+		BeneficiaryID: &b.ID,
+		Type:          enum.Wire,
+		Amount:        amt,
+		Direction:     apex.Outgoing,
+		Status:        enum.TransferApprovalPending,
+		ExpiresAt:     &expiresAt,
 	}
 
 	if err = s.tx.Create(transfer).Error; err != nil {
 		return nil, gberrors.InternalServerError.WithError(err)
 	}
 
+	if err = audit.Record(s.tx, audit.Entry{
+		AccountID:  transfer.AccountID,
+		Resource:   models.AuditTransfer,
+		ResourceID: transfer.ID,
+		After:      transfer,
+	}); err != nil {
+		return nil, err
+	}
+
 	// notify via slack
 	{
 		msg := slack.NewFundingActivity()
@@ -125,19 +229,21 @@ func (s *transferService) Create(accountID uuid.UUID, relID string, dir apex.Tra
 			Email       string `json:"email"`
 			Direction   string `json:"direction"`
 			Amount      string `json:"amount"`
+			Bank        string `json:"bank"`
 		}{
-			slackType,
+			"wire_approval_pending",
 			*acct.ApexAccount,
 			*acct.PrimaryOwner().Details.LegalName,
 			acct.PrimaryOwner().Email,
-			string(dir),
+			string(apex.Outgoing),
 			amt.String(),
+			b.BankName,
 		})
 
 		slack.Notify(msg)
 	}
 
-	return transfer, err
+	return transfer, nil
 }
 
 func (s *transferService) Cancel(accountID uuid.UUID, transferID string) error {
@@ -168,6 +274,10 @@ func (s *transferService) Cancel(accountID uuid.UUID, transferID string) error {
 
 	if transfer.Status == enum.TransferQueued || transfer.Status == enum.TransferApprovalPending {
 		status = enum.TransferCanceled
+	} else if transfer.Type == enum.Wire {
+		// wires are sent manually once released, so there
+		// is nothing to cancel with the clearing broker
+		return gberrors.InvalidRequestParam.WithMsg("wire has already been released")
 	} else {
 		if transfer.ApexID == nil {
 			return gberrors.InvalidRequestParam.WithMsg("too soon to cancel transfer")
@@ -218,47 +328,50 @@ func (s *transferService) Update(transfer *models.Transfer) (*models.Transfer, e
 	return transfer, err
 }
 
-var transferLimit = decimal.New(50000, 0)
+// verifyTransfer checks the transfer is allowed, returning the
+// velocity checks which require it to be held for approval.
+func (s *transferService) verifyTransfer(
+	acct *models.Account,
+	rel *models.ACHRelationship,
+	amt decimal.Decimal,
+	dir apex.TransferDirection) ([]LimitCheck, error) {
 
-func (s *transferService) verifyTransfer(acct *models.Account, amt decimal.Decimal, dir apex.TransferDirection) error {
 	if amt.LessThanOrEqual(decimal.Zero) {
-		return gberrors.InvalidRequestParam.WithMsg("transfer amount must be greater than $0")
+		return nil, gberrors.InvalidRequestParam.WithMsg("transfer amount must be greater than $0")
 	}
 
-	// check transfer limit
-	{
-		todaysTransfers := []models.Transfer{}
-		q := s.tx.
-			Where("account_id = ? AND direction = ? AND created_at >= ?",
-				acct.ID, dir, clock.Now().In(calendar.NY).Format("2006-01-02")).
-			Find(&todaysTransfers)
-
-		if q.Error != nil {
-			return q.Error
+	// restrictions placed by a returned transfer
+	switch dir {
+	case apex.Incoming:
+		if acct.DepositsBlockedUntil != nil && acct.DepositsBlockedUntil.After(clock.Now()) {
+			return nil, gberrors.Forbidden.WithMsg(
+				fmt.Sprintf(
+					"deposits are blocked until %v (a previous deposit was returned)",
+					acct.DepositsBlockedUntil.Format("2006-01-02")))
 		}
-
-		totalAmt := amt
-		for _, transfer := range todaysTransfers {
-			totalAmt = totalAmt.Add(transfer.Amount)
+	case apex.Outgoing:
+		if acct.WithdrawalsBlocked {
+			return nil, gberrors.Forbidden.WithMsg("withdrawals are blocked pending review")
 		}
+	}
 
-		if totalAmt.GreaterThan(transferLimit) {
-			return gberrors.InvalidRequestParam.WithMsg("maximum total daily transfer allowed is $50,000")
-		}
+	holds, err := s.checkLimits(acct, rel, amt, dir)
+	if err != nil {
+		return nil, err
 	}
 
 	if dir == apex.Outgoing {
 		tradeAccount, err := acct.ToTradeAccount()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if balances, err := op.GetAccountBalances(s.tx, tradeAccount); err != nil {
-			return err
+			return nil, err
 		} else if amt.GreaterThan(balances.CashWithdrawable) {
-			return gberrors.Forbidden.WithMsg("transfer amount must be less than withdrawable cash")
+			return nil, gberrors.Forbidden.WithMsg("transfer amount must be less than withdrawable cash")
 		}
 	}
 
-	return nil
+	return holds, nil
 }
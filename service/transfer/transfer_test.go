@@ -1,12 +1,14 @@
 package transfer
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/alpacahq/apex"
 	"github.com/alpacahq/gobroker/dbtest"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/gofrs/uuid"
 	"github.com/shopspring/decimal"
@@ -159,3 +161,156 @@ func (s *TransferTestSuite) TestTransfer() {
 	assert.Contains(s.T(), err.Error(), "transfer amount must be greater than $0")
 	assert.Nil(s.T(), transfer)
 }
+
+func (s *TransferTestSuite) TestWire() {
+	service := transferService{tx: db.DB()}
+
+	b := &models.WireBeneficiary{
+		AccountID: s.account.ID,
+		Status:    enum.BeneficiaryActive,
+		BankName:  "Test Bank",
+		Mask:      "6789",
+	}
+	require.Nil(s.T(), db.DB().Create(b).Error)
+
+	// unknown beneficiary
+	transfer, err := service.CreateWire(
+		s.account.IDAsUUID(),
+		uuid.Must(uuid.NewV4()).String(),
+		decimal.NewFromFloat(1000),
+	)
+	assert.NotNil(s.T(), err)
+	assert.Nil(s.T(), transfer)
+
+	transfer, err = service.CreateWire(
+		s.account.IDAsUUID(),
+		b.ID,
+		decimal.NewFromFloat(1000),
+	)
+	require.Nil(s.T(), err)
+	require.NotNil(s.T(), transfer)
+	assert.Equal(s.T(), enum.Wire, transfer.Type)
+	assert.Equal(s.T(), apex.Outgoing, transfer.Direction)
+	assert.Equal(s.T(), enum.TransferApprovalPending, transfer.Status)
+	assert.Equal(s.T(), b.ID, *transfer.BeneficiaryID)
+
+	// released wires can't be canceled
+	transfer.Status = enum.TransferPending
+	require.Nil(s.T(), db.DB().Save(transfer).Error)
+	assert.NotNil(s.T(), service.Cancel(s.account.IDAsUUID(), transfer.ID))
+}
+
+func (s *TransferTestSuite) TestLimits() {
+	service := transferService{tx: db.DB()}
+
+	amt := decimal.New(10000, 0)
+	apexAcct := "apca_limits"
+	legalName := "First Last"
+	acct := &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		Cash:               amt,
+		CashWithdrawable:   amt,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			models.Owner{
+				Email:   "limits@test.db",
+				Primary: true,
+				Details: models.OwnerDetails{
+					LegalName: &legalName,
+				},
+			},
+		},
+	}
+	require.Nil(s.T(), db.DB().Create(acct).Error)
+
+	plaidUUID := uuid.Must(uuid.NewV4()).String()
+	rel := &models.ACHRelationship{
+		ID:               uuid.Must(uuid.NewV4()).String(),
+		Status:           enum.RelationshipApproved,
+		AccountID:        acct.ID,
+		ApprovalMethod:   apex.Plaid,
+		PlaidAccount:     &plaidUUID,
+		PlaidToken:       &plaidUUID,
+		PlaidItem:        &plaidUUID,
+		PlaidInstitution: &plaidUUID,
+	}
+	require.Nil(s.T(), db.DB().Create(rel).Error)
+
+	// new regular account tier
+	limits, override, err := LimitsFor(db.DB(), acct, apex.Incoming)
+	require.Nil(s.T(), err)
+	assert.Nil(s.T(), override)
+	assert.True(s.T(), limits.Daily.Equal(decimal.New(50000, 0)))
+	assert.True(s.T(), limits.Rolling30Day.Equal(decimal.New(100000, 0)))
+
+	// admin lowered the daily deposit limit
+	daily := decimal.New(1000, 0)
+	require.Nil(s.T(), db.DB().Create(&models.TransferLimitOverride{
+		AccountID: acct.ID,
+		Direction: apex.Incoming,
+		AdminID:   uuid.Must(uuid.NewV4()).String(),
+		Daily:     &daily,
+	}).Error)
+
+	transfer, err := service.Create(acct.IDAsUUID(), rel.ID, apex.Incoming, decimal.New(1500, 0))
+	require.NotNil(s.T(), err)
+	assert.Nil(s.T(), transfer)
+
+	limitErr, ok := err.(*LimitError)
+	require.True(s.T(), ok)
+	require.Len(s.T(), limitErr.Reasons, 1)
+	assert.Equal(s.T(), DailyLimit, limitErr.Reasons[0].Reason)
+
+	transfer, err = service.Create(acct.IDAsUUID(), rel.ID, apex.Incoming, decimal.New(500, 0))
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.TransferQueued, transfer.Status)
+
+	// withdrawal right after a deposit, to a new bank
+	transfer, err = service.Create(acct.IDAsUUID(), rel.ID, apex.Outgoing, decimal.New(100, 0))
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.TransferApprovalPending, transfer.Status)
+
+	holds := []LimitCheck{}
+	require.Nil(s.T(), json.Unmarshal(transfer.HoldReasons, &holds))
+	require.Len(s.T(), holds, 2)
+	assert.Equal(s.T(), NewRelationship, holds[0].Reason)
+	assert.Equal(s.T(), WithdrawalAfterDeposit, holds[1].Reason)
+
+	// another bank was unlinked right after it was linked
+	unlinkedAt := clock.Now()
+	unlinkedUUID := uuid.Must(uuid.NewV4()).String()
+	require.Nil(s.T(), db.DB().Create(&models.ACHRelationship{
+		ID:               uuid.Must(uuid.NewV4()).String(),
+		Status:           enum.RelationshipCanceled,
+		AccountID:        acct.ID,
+		ApprovalMethod:   apex.Plaid,
+		PlaidAccount:     &unlinkedUUID,
+		PlaidToken:       &unlinkedUUID,
+		PlaidItem:        &unlinkedUUID,
+		PlaidInstitution: &unlinkedUUID,
+		UnlinkedAt:       &unlinkedAt,
+	}).Error)
+
+	transfer, err = service.Create(acct.IDAsUUID(), rel.ID, apex.Outgoing, decimal.New(100, 0))
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.TransferApprovalPending, transfer.Status)
+
+	holds = []LimitCheck{}
+	require.Nil(s.T(), json.Unmarshal(transfer.HoldReasons, &holds))
+	require.Len(s.T(), holds, 3)
+	assert.Equal(s.T(), UnlinkedRelationship, holds[0].Reason)
+
+	// admin waived the velocity checks
+	require.Nil(s.T(), db.DB().Create(&models.TransferLimitOverride{
+		AccountID:    acct.ID,
+		Direction:    apex.Outgoing,
+		AdminID:      uuid.Must(uuid.NewV4()).String(),
+		SkipVelocity: true,
+	}).Error)
+
+	transfer, err = service.Create(acct.IDAsUUID(), rel.ID, apex.Outgoing, decimal.New(100, 0))
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), enum.TransferQueued, transfer.Status)
+	assert.Empty(s.T(), transfer.HoldReasons)
+}
@@ -0,0 +1,401 @@
+package watchlist
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/price"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/quote"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// MaxAssets is the most assets a watchlist can hold.
+const MaxAssets = 200
+
+type WatchlistService interface {
+	List(accountID uuid.UUID) ([]*Watchlist, error)
+	GetByID(accountID uuid.UUID, id string) (*Watchlist, error)
+	Create(accountID uuid.UUID, req WatchlistRequest) (*Watchlist, error)
+	Update(accountID uuid.UUID, id string, req WatchlistRequest) (*Watchlist, error)
+	AddAsset(accountID uuid.UUID, id, symbol string) (*Watchlist, error)
+	RemoveAsset(accountID uuid.UUID, id, symbol string) (*Watchlist, error)
+	Delete(accountID uuid.UUID, id string) error
+	WithTx(tx *gorm.DB) WatchlistService
+	WithQuotes() WatchlistService
+}
+
+type watchlistService struct {
+	WatchlistService
+	tx         *gorm.DB
+	assetcache assetcache.AssetCache
+	quotes     quote.QuoteService
+	withQuotes bool
+}
+
+func Service(assetcache assetcache.AssetCache, quotes quote.QuoteService) WatchlistService {
+	return &watchlistService{
+		assetcache: assetcache,
+		quotes:     quotes,
+	}
+}
+
+func (s *watchlistService) WithTx(tx *gorm.DB) WatchlistService {
+	s.tx = tx
+	return s
+}
+
+// WithQuotes embeds the latest quote of each asset in the watchlists.
+func (s *watchlistService) WithQuotes() WatchlistService {
+	s.withQuotes = true
+	return s
+}
+
+// Watchlist is a watchlist with its assets resolved.
+type Watchlist struct {
+	models.Watchlist
+	Assets []*Asset `json:"assets"`
+}
+
+type Asset struct {
+	*models.Asset
+	Quote *price.Quote `json:"quote,omitempty"`
+}
+
+// WatchlistRequest creates or updates a watchlist. Symbols replace the
+// assets of the watchlist on update when given.
+type WatchlistRequest struct {
+	Name    *string  `json:"name"`
+	Symbols []string `json:"symbols"`
+}
+
+func (s *watchlistService) List(accountID uuid.UUID) ([]*Watchlist, error) {
+	wls := []models.Watchlist{}
+
+	if err := s.tx.
+		Where("account_id = ?", accountID.String()).
+		Order("created_at ASC").
+		Find(&wls).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	resolved := make([]*Watchlist, len(wls))
+
+	for i := range wls {
+		resolved[i] = s.resolve(&wls[i])
+	}
+
+	s.attachQuotes(resolved...)
+
+	return resolved, nil
+}
+
+func (s *watchlistService) GetByID(accountID uuid.UUID, id string) (*Watchlist, error) {
+	wl, err := s.get(accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.respond(wl), nil
+}
+
+func (s *watchlistService) Create(accountID uuid.UUID, req WatchlistRequest) (*Watchlist, error) {
+	if req.Name == nil {
+		return nil, gberrors.InvalidRequestParam.WithMsg("name is required")
+	}
+
+	wl := &models.Watchlist{
+		AccountID: accountID.String(),
+		AssetIDs:  pq.StringArray{},
+	}
+
+	if err := s.setName(wl, *req.Name); err != nil {
+		return nil, err
+	}
+
+	if err := s.setAssets(wl, req.Symbols); err != nil {
+		return nil, err
+	}
+
+	if err := s.tx.Create(wl).Error; err != nil {
+		return nil, gberrors.InternalServerError.WithError(err)
+	}
+
+	return s.respond(wl), nil
+}
+
+func (s *watchlistService) Update(accountID uuid.UUID, id string, req WatchlistRequest) (*Watchlist, error) {
+	wl, err := s.get(accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		if err = s.setName(wl, *req.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Symbols != nil {
+		if err = s.setAssets(wl, req.Symbols); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = s.save(wl); err != nil {
+		return nil, err
+	}
+
+	return s.respond(wl), nil
+}
+
+// AddAsset appends the asset to the watchlist, if it isn't already on
+// it.
+func (s *watchlistService) AddAsset(accountID uuid.UUID, id, symbol string) (*Watchlist, error) {
+	wl, err := s.get(accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := s.asset(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if !wl.Has(asset.ID) {
+		if len(wl.AssetIDs) >= MaxAssets {
+			return nil, gberrors.InvalidRequestParam.WithMsg(
+				fmt.Sprintf("watchlists are limited to %v assets", MaxAssets))
+		}
+
+		wl.AssetIDs = append(wl.AssetIDs, asset.ID)
+
+		if err = s.save(wl); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.respond(wl), nil
+}
+
+func (s *watchlistService) RemoveAsset(accountID uuid.UUID, id, symbol string) (*Watchlist, error) {
+	wl, err := s.get(accountID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	asset, err := s.asset(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	if !wl.Has(asset.ID) {
+		return nil, gberrors.NotFound.WithMsg(
+			fmt.Sprintf("%v is not on the watchlist", asset.Symbol))
+	}
+
+	ids := pq.StringArray{}
+	for _, assetID := range wl.AssetIDs {
+		if assetID != asset.ID {
+			ids = append(ids, assetID)
+		}
+	}
+
+	wl.AssetIDs = ids
+
+	if err = s.save(wl); err != nil {
+		return nil, err
+	}
+
+	return s.respond(wl), nil
+}
+
+func (s *watchlistService) Delete(accountID uuid.UUID, id string) error {
+	wl, err := s.get(accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if err = s.tx.Delete(wl).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	return nil
+}
+
+func (s *watchlistService) get(accountID uuid.UUID, id string) (*models.Watchlist, error) {
+	if _, err := uuid.FromString(id); err != nil {
+		return nil, gberrors.InvalidRequestParam.WithMsg("watchlist_id must be a valid uuid")
+	}
+
+	wl := &models.Watchlist{}
+
+	q := s.tx.
+		Where("id = ? AND account_id = ?", id, accountID.String()).
+		Set("gorm:query_option", db.ForUpdate).
+		Find(wl)
+
+	if q.RecordNotFound() {
+		return nil, gberrors.NotFound.WithMsg("watchlist not found")
+	}
+
+	if q.Error != nil {
+		return nil, gberrors.InternalServerError.WithError(q.Error)
+	}
+
+	return wl, nil
+}
+
+func (s *watchlistService) save(wl *models.Watchlist) error {
+	if err := s.tx.Model(wl).Updates(map[string]interface{}{
+		"name":      wl.Name,
+		"asset_ids": wl.AssetIDs,
+	}).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+	return nil
+}
+
+// setName checks the name is given, and not taken by another of the
+// account's watchlists.
+func (s *watchlistService) setName(wl *models.Watchlist, name string) error {
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		return gberrors.InvalidRequestParam.WithMsg("name is required")
+	}
+
+	q := s.tx.Model(&models.Watchlist{}).Where("account_id = ? AND name = ?", wl.AccountID, name)
+	if wl.ID != "" {
+		q = q.Where("id != ?", wl.ID)
+	}
+
+	count := 0
+	if err := q.Count(&count).Error; err != nil {
+		return gberrors.InternalServerError.WithError(err)
+	}
+
+	if count > 0 {
+		return gberrors.Conflict.WithMsg(fmt.Sprintf("watchlist %v already exists", name))
+	}
+
+	wl.Name = name
+
+	return nil
+}
+
+// setAssets replaces the watchlist's assets with the symbols, in order,
+// without duplicates.
+func (s *watchlistService) setAssets(wl *models.Watchlist, symbols []string) error {
+	ids := pq.StringArray{}
+	seen := map[string]bool{}
+
+	for _, symbol := range symbols {
+		asset, err := s.asset(symbol)
+		if err != nil {
+			return err
+		}
+
+		if !seen[asset.ID] {
+			seen[asset.ID] = true
+			ids = append(ids, asset.ID)
+		}
+	}
+
+	if len(ids) > MaxAssets {
+		return gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("watchlists are limited to %v assets", MaxAssets))
+	}
+
+	wl.AssetIDs = ids
+
+	return nil
+}
+
+func (s *watchlistService) asset(symbol string) (*models.Asset, error) {
+	asset := s.assetcache.Get(strings.TrimSpace(symbol))
+	if asset == nil {
+		return nil, gberrors.InvalidRequestParam.WithMsg(
+			fmt.Sprintf("asset not found for %v", symbol))
+	}
+	return asset, nil
+}
+
+func (s *watchlistService) respond(wl *models.Watchlist) *Watchlist {
+	resolved := s.resolve(wl)
+	s.attachQuotes(resolved)
+	return resolved
+}
+
+// resolve looks up the watchlist's assets, leaving out any which are
+// no longer in the asset cache.
+func (s *watchlistService) resolve(wl *models.Watchlist) *Watchlist {
+	resolved := &Watchlist{
+		Watchlist: *wl,
+		Assets:    []*Asset{},
+	}
+
+	for _, id := range wl.AssetIDs {
+		if asset := s.assetcache.Get(id); asset != nil {
+			resolved.Assets = append(resolved.Assets, &Asset{Asset: asset})
+		}
+	}
+
+	return resolved
+}
+
+// attachQuotes quotes the assets of the watchlists in one request.
+// Assets without a quote, such as delisted ones, are left without.
+func (s *watchlistService) attachQuotes(wls ...*Watchlist) {
+	if !s.withQuotes {
+		return
+	}
+
+	ids := []uuid.UUID{}
+	seen := map[string]bool{}
+
+	for _, wl := range wls {
+		for _, asset := range wl.Assets {
+			if !seen[asset.ID] {
+				seen[asset.ID] = true
+				ids = append(ids, asset.IDAsUUID())
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	quotes := map[string]*price.Quote{}
+
+	qas, err := s.quotes.WithTx(s.tx).GetByIDs(ids)
+	if err == nil {
+		for _, qa := range qas {
+			q := qa.Quote
+			quotes[qa.AssetID.String()] = &q
+		}
+	} else {
+		// quotes fail as a set, so quote the assets one by one
+		log.Warn("failed to quote watchlist assets", "error", err)
+
+		for _, id := range ids {
+			if qa, err := s.quotes.WithTx(s.tx).GetByID(id); err == nil {
+				q := qa.Quote
+				quotes[id.String()] = &q
+			}
+		}
+	}
+
+	for _, wl := range wls {
+		for _, asset := range wl.Assets {
+			asset.Quote = quotes[asset.ID]
+		}
+	}
+}
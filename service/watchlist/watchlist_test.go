@@ -0,0 +1,128 @@
+package watchlist
+
+import (
+	"testing"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/marketdata"
+	"github.com/alpacahq/gobroker/service/quote"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/polycache/structures"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WatchlistTestSuite struct {
+	dbtest.Suite
+	accountID uuid.UUID
+	md        *marketdata.Store
+}
+
+func TestWatchlistTestSuite(t *testing.T) {
+	suite.Run(t, new(WatchlistTestSuite))
+}
+
+func (s *WatchlistTestSuite) SetupSuite() {
+	s.SetupDB()
+
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		asset := &models.Asset{
+			Class:    enum.AssetClassUSEquity,
+			Exchange: "NASDAQ",
+			Symbol:   symbol,
+			Status:   enum.AssetActive,
+			Tradable: true,
+		}
+		if err := db.DB().Create(asset).Error; err != nil {
+			assert.FailNow(s.T(), err.Error())
+		}
+	}
+
+	s.accountID = uuid.Must(uuid.NewV4())
+
+	// only AAPL is quoted
+	s.md = marketdata.NewStore()
+	s.md.SetTrade("AAPL", structures.Trade{Price: 100.32, Timestamp: clock.Now()})
+	s.md.SetQuote("AAPL", structures.Quote{BidPrice: 100.3, AskPrice: 100.34, Timestamp: clock.Now()})
+}
+
+func (s *WatchlistTestSuite) TearDownSuite() {
+	s.TeardownDB()
+}
+
+func (s *WatchlistTestSuite) service() WatchlistService {
+	return Service(
+		assetcache.GetAssetCache(),
+		quote.Service(assetcache.GetAssetCache(), s.md),
+	).WithTx(db.DB())
+}
+
+func (s *WatchlistTestSuite) TestWatchlist() {
+	name := "tech"
+
+	wl, err := s.service().Create(s.accountID, WatchlistRequest{
+		Name:    &name,
+		Symbols: []string{"AAPL", "AAPL", "MSFT"},
+	})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), wl.Assets, 2)
+	assert.Equal(s.T(), "AAPL", wl.Assets[0].Symbol)
+	assert.Nil(s.T(), wl.Assets[0].Quote)
+
+	// names are unique per account
+	_, err = s.service().Create(s.accountID, WatchlistRequest{Name: &name})
+	assert.Equal(s.T(), gberrors.Conflict.Code, err.(*gberrors.Error).Code)
+
+	// unknown symbols are rejected
+	other := "other"
+	_, err = s.service().Create(s.accountID, WatchlistRequest{Name: &other, Symbols: []string{"ZZZZ"}})
+	assert.NotNil(s.T(), err)
+
+	wl, err = s.service().RemoveAsset(s.accountID, wl.ID, "AAPL")
+	require.Nil(s.T(), err)
+	require.Len(s.T(), wl.Assets, 1)
+	assert.Equal(s.T(), "MSFT", wl.Assets[0].Symbol)
+
+	_, err = s.service().RemoveAsset(s.accountID, wl.ID, "AAPL")
+	assert.NotNil(s.T(), err)
+
+	// adding twice keeps one
+	for i := 0; i < 2; i++ {
+		wl, err = s.service().AddAsset(s.accountID, wl.ID, "AAPL")
+		require.Nil(s.T(), err)
+	}
+	assert.Len(s.T(), wl.Assets, 2)
+
+	wl, err = s.service().WithQuotes().GetByID(s.accountID, wl.ID)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), wl.Assets, 2)
+	assert.Nil(s.T(), wl.Assets[0].Quote)
+	require.NotNil(s.T(), wl.Assets[1].Quote)
+	assert.Equal(s.T(), "AAPL", wl.Assets[1].Symbol)
+
+	renamed := "faang"
+	wl, err = s.service().Update(s.accountID, wl.ID, WatchlistRequest{Name: &renamed})
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "faang", wl.Name)
+	assert.Len(s.T(), wl.Assets, 2)
+
+	// other accounts can't see it
+	_, err = s.service().GetByID(uuid.Must(uuid.NewV4()), wl.ID)
+	assert.NotNil(s.T(), err)
+
+	wls, err := s.service().List(s.accountID)
+	require.Nil(s.T(), err)
+	assert.Len(s.T(), wls, 1)
+
+	require.Nil(s.T(), s.service().Delete(s.accountID, wl.ID))
+
+	_, err = s.service().GetByID(s.accountID, wl.ID)
+	assert.NotNil(s.T(), err)
+}
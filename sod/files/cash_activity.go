@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/jinzhu/gorm"
@@ -13,6 +14,8 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/stream"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/clock"
@@ -137,6 +140,7 @@ type CashActivityReportProcessor struct {
 	asof                          date.Date
 	transfers                     map[string][]SoDCashActivity
 	dividends                     map[string][]SoDCashActivity
+	cashInLieu                    map[string][]SoDCashActivity
 	accounts                      []string
 	errors                        []models.BatchError
 	iterIndex                     int
@@ -147,11 +151,13 @@ type CashActivityReportProcessor struct {
 		direction apex.TransferDirection,
 		transferAmount decimal.Decimal,
 		deliverAt *time.Time) error
+	publish func(msg stream.OutboundMessage) error
 }
 
 func NewCashActivityReportProcessor(asof date.Date, activities []SoDCashActivity) *CashActivityReportProcessor {
 	transfers := make(map[string][]SoDCashActivity)
 	dividends := make(map[string][]SoDCashActivity)
+	cashInLieu := make(map[string][]SoDCashActivity)
 
 	accset := set.New()
 
@@ -184,6 +190,9 @@ func NewCashActivityReportProcessor(asof date.Date, activities []SoDCashActivity
 			case "CIL":
 				// Payments made to investors who received fractional shares
 				// as a consequence of stock splits etc.
+				cashInLieu[activity.AccountNumber] = append(cashInLieu[activity.AccountNumber], activities[i])
+				accset.Add(activity.AccountNumber)
+				continue
 			case "DIV":
 				// dividents
 				if items, ok := dividends[activity.AccountNumber]; ok {
@@ -209,12 +218,14 @@ func NewCashActivityReportProcessor(asof date.Date, activities []SoDCashActivity
 	return &CashActivityReportProcessor{
 		asof:                          asof,
 		dividends:                     dividends,
+		cashInLieu:                    cashInLieu,
 		transfers:                     transfers,
 		accounts:                      accset.List(),
 		errors:                        []models.BatchError{},
 		iterIndex:                     -1,
 		ExtCode:                       "EXT869",
 		sendMoneyTransferNotification: mailer.SendMoneyTransferNotification,
+		publish:                       stream.Publish,
 	}
 }
 
@@ -235,6 +246,14 @@ func (p *CashActivityReportProcessor) NoEmail() *CashActivityReportProcessor {
 	return p
 }
 
+func (p *CashActivityReportProcessor) NoPublish() *CashActivityReportProcessor {
+	p.publish = func(msg stream.OutboundMessage) error {
+		log.Debug("publish stream message", "stream", msg.Stream)
+		return nil
+	}
+	return p
+}
+
 func (p *CashActivityReportProcessor) Next() bool {
 	nextIdx := p.iterIndex + 1
 	if nextIdx < len(p.accounts) {
@@ -244,7 +263,7 @@ func (p *CashActivityReportProcessor) Next() bool {
 	return false
 }
 
-func (p *CashActivityReportProcessor) Values() (apexAccount string, transfers []SoDCashActivity, dividends []SoDCashActivity) {
+func (p *CashActivityReportProcessor) Values() (apexAccount string, transfers, dividends, cashInLieu []SoDCashActivity) {
 	apexAccount = p.accounts[p.iterIndex]
 
 	if items, ok := p.transfers[apexAccount]; ok {
@@ -259,7 +278,13 @@ func (p *CashActivityReportProcessor) Values() (apexAccount string, transfers []
 		dividends = []SoDCashActivity{}
 	}
 
-	return apexAccount, transfers, dividends
+	if items, ok := p.cashInLieu[apexAccount]; ok {
+		cashInLieu = items
+	} else {
+		cashInLieu = []SoDCashActivity{}
+	}
+
+	return apexAccount, transfers, dividends, cashInLieu
 }
 
 func (p *CashActivityReportProcessor) AddError(apexAccount string, keysAndValues ...interface{}) {
@@ -295,89 +320,45 @@ func (p *CashActivityReportProcessor) AddError(apexAccount string, keysAndValues
 
 func (p *CashActivityReportProcessor) Run() {
 	for p.Next() {
-		apexAcct, transfers, dividends := p.Values()
+		apexAcct, transfers, dividends, cashInLieu := p.Values()
 
 		log.Debug("try cash activity process",
 			"acct", apexAcct,
 			"transfers", len(transfers),
-			"dividends", len(dividends))
+			"dividends", len(dividends),
+			"cash_in_lieu", len(cashInLieu))
 
 		tx := db.Begin()
+		ledgerSrv := ledger.Service().WithTx(tx)
 
 		// wires
+		wires := []SoDCashActivity{}
 		{
-			// aggregate wires for idempotency
-			wires := []models.Transfer{}
-
-			q := tx.
-				Where("type = ? AND created_at > ?",
-					enum.Wire, p.asof.String()).
-				Find(&wires)
-
-			if q.Error != nil {
-				log.Panic("start of day database error", "file", p.ExtCode, "error", q.Error)
-			}
+			// split the wires out so they are not handled
+			// in the following ACH logic
+			achs := []SoDCashActivity{}
 
-			if len(wires) > 0 {
-				// we have wires that have already been processed
-				// so let's find the ones that we have already
-				// handled and omit them from this op
-				for i, transfer := range transfers {
-					if transfer.IsWire() {
-						for _, wire := range wires {
-							if wire.Amount.Equal(transfer.Amount.Abs()) && ((transfer.Amount.Sign() == 0 && wire.Direction == apex.Outgoing) ||
-								(transfer.Amount.Sign() > 0 && wire.Direction == apex.Incoming)) {
-								// found it, remove it
-								transfers = append(transfers[:i], transfers[i+1:]...)
-								break
-							}
-						}
-					}
+			for _, t := range transfers {
+				if t.IsWire() {
+					wires = append(wires, t)
+				} else {
+					achs = append(achs, t)
 				}
 			}
 
-			// create the remaining wires that aren't accounted for
-			// and remove them from the list as well so they are not
-			// handled in the following ACH logic
-			srv := account.Service().WithTx(tx)
-
-			for i, t := range transfers {
-				if t.IsWire() {
-					acct, err := srv.GetByApexAccount(t.AccountNumber)
-					if err != nil {
-						if utils.Prod() {
-							tx.Rollback()
-							log.Panic("account not found", "account", t.AccountNumber)
-						}
-						continue
-					}
-
-					b := true
-					date := p.asof.String()
-
-					transfer := &models.Transfer{
-						Type:             enum.Wire,
-						Status:           enum.TransferComplete,
-						BalanceValidated: &b,
-						BatchProcessedAt: &date,
-						AccountID:        acct.ID,
-					}
-
-					if t.Amount.Sign() > 0 {
-						transfer.Direction = apex.Incoming
-						transfer.Amount = *t.Amount
-					} else {
-						transfer.Direction = apex.Outgoing
-						transfer.Amount = t.Amount.Mul(decimal.New(-1, 0))
-					}
+			transfers = achs
+		}
 
-					if err := tx.Save(transfer).Error; err != nil {
-						log.Panic("start of day database error", "file", p.ExtCode, "error", err)
-					}
+		completedWires, ok := p.processWires(tx, apexAcct, wires)
+		if !ok {
+			tx.Rollback()
+			continue
+		}
 
-					// created it, remove it from the list
-					transfers = append(transfers[:i], transfers[i+1:]...)
-				}
+		for i := range completedWires {
+			if err := ledgerSrv.PostTransfer(&completedWires[i], p.asof); err != nil {
+				tx.Rollback()
+				log.Panic("start of day database error", "file", p.ExtCode, "error", err)
 			}
 		}
 
@@ -392,8 +373,8 @@ func (p *CashActivityReportProcessor) Run() {
 				Preload("Transfers", func(db *gorm.DB) *gorm.DB {
 					// get not batch processed one or processed one on that as of date to be idempotent.
 					return db.
-						Where("created_at < ? AND status = ?",
-							p.asof.AddDays(1).String(), enum.TransferComplete).
+						Where("created_at < ? AND status = ? AND type = ?",
+							p.asof.AddDays(1).String(), enum.TransferComplete, enum.ACH).
 						Where("batch_processed_at IS NULL OR batch_processed_at = ?", p.asof.String()).
 						Order("created_at asc")
 				}).Find(&acct).Error; err != nil {
@@ -458,6 +439,11 @@ func (p *CashActivityReportProcessor) Run() {
 					log.Panic("start of day database error", "file", p.ExtCode, "error", err)
 				}
 
+				if err := ledgerSrv.PostTransfer(&transfer, p.asof); err != nil {
+					tx.Rollback()
+					log.Panic("start of day database error", "file", p.ExtCode, "error", err)
+				}
+
 				oktransfers = append(oktransfers, acct.Transfers[ti])
 				log.Debug("done transfer",
 					"acct", apexAcct,
@@ -516,6 +502,44 @@ func (p *CashActivityReportProcessor) Run() {
 					tx.Rollback()
 					log.Panic("start of day database error", "file", p.ExtCode, "error", err)
 				}
+
+				if err := ledgerSrv.PostDividend(&div, activityAmount, p.asof); err != nil {
+					tx.Rollback()
+					log.Panic("start of day database error", "file", p.ExtCode, "error", err)
+				}
+			}
+
+			for _, activity := range cashInLieu {
+				// mergers with a cash in lieu rate are booked when the
+				// lots are exchanged
+				booked := 0
+				if err := tx.Model(&models.Journal{}).
+					Where("account_id = ? AND type = ? AND reference_id LIKE ? AND reference_id LIKE ?",
+						acct.ID,
+						enum.JournalCorporateAction,
+						fmt.Sprintf("cil:%v:%%", acct.ID),
+						fmt.Sprintf("%%:%v:%%", strings.ToUpper(activity.Cusip))).
+					Count(&booked).Error; err != nil {
+					tx.Rollback()
+					log.Panic("start of day database error", "file", p.ExtCode, "error", err)
+				}
+
+				if booked > 0 {
+					continue
+				}
+
+				// minus = equity, plus = dead
+				amt := activity.Amount.Neg()
+
+				if err := ledgerSrv.PostCorporateAction(
+					acct.ID,
+					fmt.Sprintf("%v:%v:%v:%v", apexAcct, activity.Cusip, p.asof, activity.SequenceNumber),
+					fmt.Sprintf("cash in lieu %v", activity.Cusip),
+					amt,
+					p.asof); err != nil {
+					tx.Rollback()
+					log.Panic("start of day database error", "file", p.ExtCode, "error", err)
+				}
 			}
 
 			// Everything looks good, then send email to user. there might issue sending email multiple times
@@ -535,12 +559,138 @@ func (p *CashActivityReportProcessor) Run() {
 			if err := tx.Commit().Error; err != nil {
 				log.Panic("start of day database error", "file", p.ExtCode, "error", err)
 			}
+
+			for i := range completedWires {
+				if err := p.publish(stream.OutboundMessage{
+					Stream: stream.AccountUpdatesStream(acct.IDAsUUID()),
+					Data:   completedWires[i],
+				}); err != nil {
+					log.Error(
+						"failed to publish wire transfer",
+						"file", p.ExtCode,
+						"transfer", completedWires[i].ID,
+						"error", err)
+				}
+			}
 		}
 	}
 
 	StoreErrors(p.errors)
 }
 
+// processWires matches the wires in the SoD file against the ones
+// in the database. Outgoing wires that were released to be sent are
+// marked complete, and incoming wires are recorded as new transfers.
+// Wires already processed for the as of date are skipped, so it is
+// safe to run more than once. It returns the transfers which were
+// completed, and false if the account could not be found.
+func (p *CashActivityReportProcessor) processWires(
+	tx *gorm.DB,
+	apexAcct string,
+	wires []SoDCashActivity) ([]models.Transfer, bool) {
+
+	completed := []models.Transfer{}
+
+	if len(wires) == 0 {
+		return completed, true
+	}
+
+	acct, err := account.Service().WithTx(tx).GetByApexAccount(apexAcct)
+	if err != nil {
+		if utils.Prod() {
+			tx.Rollback()
+			log.Panic("account not found", "account", apexAcct)
+		}
+		return nil, false
+	}
+
+	existing := []models.Transfer{}
+
+	if err = tx.
+		Where("account_id = ? AND type = ? AND status IN (?)",
+			acct.ID, enum.Wire,
+			[]enum.TransferStatus{enum.TransferPending, enum.TransferComplete}).
+		Where("batch_processed_at IS NULL OR batch_processed_at = ?", p.asof.String()).
+		Order("created_at asc").
+		Find(&existing).Error; err != nil {
+
+		tx.Rollback()
+		log.Panic("start of day database error", "file", p.ExtCode, "error", err)
+	}
+
+	date := p.asof.String()
+
+	for _, wire := range wires {
+		// minus = equity, plus = debit
+		amt := wire.Amount.Neg()
+
+		dir := apex.Incoming
+		if amt.Sign() < 0 {
+			dir = apex.Outgoing
+			amt = amt.Abs()
+		}
+
+		var matched *models.Transfer
+
+		for i := range existing {
+			if existing[i].Direction == dir && existing[i].Amount.Equal(amt) {
+				matched = &existing[i]
+				existing = append(existing[:i], existing[i+1:]...)
+				break
+			}
+		}
+
+		switch {
+		case matched != nil && matched.BatchProcessedAt != nil:
+			// already processed
+			continue
+		case matched != nil:
+			updates := map[string]interface{}{
+				"status":             enum.TransferComplete,
+				"batch_processed_at": date,
+			}
+
+			if err = tx.Model(matched).Updates(updates).Error; err != nil {
+				tx.Rollback()
+				log.Panic("start of day database error", "file", p.ExtCode, "error", err)
+			}
+
+			completed = append(completed, *matched)
+		default:
+			if dir == apex.Outgoing {
+				// every outgoing wire should have been requested
+				// and approved through us, so flag it for review
+				p.AddError(
+					apexAcct,
+					"error", fmt.Errorf("outgoing wire not found in db"),
+					"sod_amount", amt,
+				)
+			}
+
+			validated := true
+
+			transfer := models.Transfer{
+				Type:             enum.Wire,
+				Status:           enum.TransferComplete,
+				BalanceValidated: &validated,
+				BatchProcessedAt: &date,
+				AccountID:        acct.ID,
+				Direction:        dir,
+				Amount:           amt,
+			}
+
+			if err = tx.Create(&transfer).Error; err != nil {
+				tx.Rollback()
+				log.Panic("start of day database error", "file", p.ExtCode, "error", err)
+			}
+
+			completed = append(completed, transfer)
+		}
+	}
+
+	return completed, true
+}
+
 // Sync goes through the cash activities for the day, aggregates
 // them on an account basis, and compares the net change to the
 // aggregated transfers stored in the database. Any inconsistencies
@@ -557,11 +707,11 @@ func (car *CashActivityReport) Sync(asOf time.Time) (uint, uint) {
 	return uint(len(car.activities) - nerrors), uint(nerrors)
 }
 
-// SyncForBackfill do Sync with out sending out emails.
+// SyncForBackfill do Sync with out sending out emails or stream messages.
 func (car *CashActivityReport) SyncForBackfill(asOf time.Time) (uint, uint) {
 	asOfDate := date.DateOf(asOf)
 
-	props := NewCashActivityReportProcessor(asOfDate, car.activities).NoEmail()
+	props := NewCashActivityReportProcessor(asOfDate, car.activities).NoEmail().NoPublish()
 	props.Run()
 
 	nerrors := len(props.Errors())
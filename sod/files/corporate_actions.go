@@ -0,0 +1,331 @@
+package files
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+)
+
+// lotProcessor adjusts one account's open lots in an asset for a
+// corporate action. Lots which are replaced are marked split, and their
+// replacements keep the lot lineage through OriginalPositionID. The
+// target is the asset the new security is listed as, if it is listed
+// separately from the asset the lots are held in.
+type lotProcessor func(
+	tx *gorm.DB,
+	asOf time.Time,
+	action *SoDMandatoryAction,
+	target *models.Asset,
+	lots []*models.Position) error
+
+var lotProcessors = map[enum.SoDCorporateAction]lotProcessor{
+	enum.SoDSymbolChange: moveLots,
+	enum.SoDCusipChange:  moveLots,
+	enum.SoDStockMerger:  mergeLots,
+	enum.SoDSpinoff:      spinoffLots,
+	enum.SoDCashMerger:   cashMergeLots,
+}
+
+// moveLots moves the lots as they are to the new security. When the
+// asset itself was renamed there is nothing to move.
+func moveLots(
+	tx *gorm.DB,
+	asOf time.Time,
+	action *SoDMandatoryAction,
+	target *models.Asset,
+	lots []*models.Position) error {
+
+	if target == nil {
+		return nil
+	}
+
+	for _, lot := range lots {
+		if err := replaceLot(tx, lot, successor(lot, target.IDAsUUID(), lot.Qty, lot.Qty.Mul(lot.EntryPrice))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeLots exchanges the lots for shares of the acquiring security at
+// the stock factor, carrying the cost basis over. When the acquirer is
+// not listed separately, the asset was renamed and the quantities are
+// reconciled as a split against the position report instead. The
+// account's fractional share is paid as cash in lieu at the cash factor,
+// the rate per new share, which disposes of the lots too small to
+// receive a whole share.
+func mergeLots(
+	tx *gorm.DB,
+	asOf time.Time,
+	action *SoDMandatoryAction,
+	target *models.Asset,
+	lots []*models.Position) error {
+
+	if target == nil {
+		return nil
+	}
+
+	if action.StockFactor == nil {
+		return fmt.Errorf("stock merger without stock factor")
+	}
+
+	shares := allocateShares(lots, *action.StockFactor)
+
+	var (
+		fraction  = totalQty(lots).Mul(*action.StockFactor).Sub(sumShares(shares))
+		remainder = decimal.Zero
+	)
+
+	for i, lot := range lots {
+		if shares[i].IsZero() {
+			remainder = remainder.Add(lot.Qty.Mul(*action.StockFactor))
+		}
+	}
+
+	// without a rate the cash in lieu is booked when it's paid, but the
+	// lots it disposes of can't be closed
+	if remainder.GreaterThan(decimal.Zero) && action.CashFactor == nil {
+		return fmt.Errorf("stock merger without cash in lieu rate, lots held for manual adjustment")
+	}
+
+	cash := decimal.Zero
+	if fraction.GreaterThan(decimal.Zero) && action.CashFactor != nil {
+		cash = fraction.Mul(*action.CashFactor).Round(2)
+	}
+
+	for i, lot := range lots {
+		if shares[i].GreaterThan(decimal.Zero) {
+			if err := replaceLot(tx, lot, successor(lot, target.IDAsUUID(), shares[i], lot.Qty.Mul(lot.EntryPrice))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// the lot's share of the cash in lieu is what it's sold for
+		proceeds := cash.Mul(lot.Qty.Mul(*action.StockFactor)).Div(remainder)
+
+		if err := closeLot(tx, asOf, lot, proceeds.Div(lot.Qty)); err != nil {
+			return err
+		}
+	}
+
+	if cash.IsZero() {
+		return nil
+	}
+
+	asOfDate := date.DateOf(asOf)
+
+	// the payment can show up in the cash activity the same day, in
+	// which case it's already booked
+	paid := 0
+	if err := tx.Model(&models.Journal{}).
+		Where("account_id = ? AND type = ? AND trade_date = ? AND description IN (?)",
+			lots[0].AccountID,
+			enum.JournalCorporateAction,
+			asOfDate,
+			[]string{
+				fmt.Sprintf("cash in lieu %v", action.Cusip),
+				fmt.Sprintf("cash in lieu %v", action.CusipOld),
+			}).
+		Count(&paid).Error; err != nil {
+		return err
+	}
+
+	if paid > 0 {
+		return nil
+	}
+
+	return ledger.Service().WithTx(tx).PostCorporateAction(
+		lots[0].AccountID,
+		cashInLieuReference(lots[0].AccountID, action, asOfDate),
+		fmt.Sprintf("cash in lieu %v", action.Cusip),
+		cash,
+		asOfDate)
+}
+
+// spinoffLots creates lots in the spun off security at the stock factor.
+// The basis of each parent lot is split between the two by the fair
+// market value allocation in the action's notice, and both keep the
+// parent's lineage and holding period. Without an allocation the lots
+// are left as they are, to be allocated by hand.
+func spinoffLots(
+	tx *gorm.DB,
+	asOf time.Time,
+	action *SoDMandatoryAction,
+	target *models.Asset,
+	lots []*models.Position) error {
+
+	if target == nil {
+		return fmt.Errorf("spinoff security %v not found", action.Cusip)
+	}
+
+	if action.StockFactor == nil {
+		return fmt.Errorf("spinoff without stock factor")
+	}
+
+	allocation := action.BasisAllocation()
+	if allocation == nil {
+		return fmt.Errorf("spinoff %v has no cost basis allocation, lots held for manual allocation", action.Cusip)
+	}
+
+	shares := allocateShares(lots, *action.StockFactor)
+
+	for i, lot := range lots {
+		if shares[i].LessThanOrEqual(decimal.Zero) {
+			continue
+		}
+
+		var (
+			basis     = lot.Qty.Mul(lot.EntryPrice)
+			spunBasis = basis.Mul(*allocation)
+		)
+
+		if err := replaceLot(tx, lot, successor(lot, lot.AssetID, lot.Qty, basis.Sub(spunBasis))); err != nil {
+			return err
+		}
+
+		if err := tx.Create(successor(lot, target.IDAsUUID(), shares[i], spunBasis)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cashMergeLots closes the lots at the cash factor, and books the cash
+// paid for them.
+func cashMergeLots(
+	tx *gorm.DB,
+	asOf time.Time,
+	action *SoDMandatoryAction,
+	target *models.Asset,
+	lots []*models.Position) error {
+
+	if action.CashFactor == nil {
+		return fmt.Errorf("cash merger without cash factor")
+	}
+
+	if len(lots) == 0 {
+		return nil
+	}
+
+	amount := decimal.Zero
+
+	for _, lot := range lots {
+		if err := closeLot(tx, asOf, lot, *action.CashFactor); err != nil {
+			return err
+		}
+
+		amount = amount.Add(lot.Qty.Mul(*action.CashFactor))
+	}
+
+	asOfDate := date.DateOf(asOf)
+
+	return ledger.Service().WithTx(tx).PostCorporateAction(
+		lots[0].AccountID,
+		fmt.Sprintf("%v:%v:%v", lots[0].AccountID, action.CusipOld, asOfDate),
+		fmt.Sprintf("cash merger %v @ %v", action.CusipOld, action.CashFactor),
+		amount.Round(2),
+		asOfDate)
+}
+
+// successor returns the lot which replaces lot in the asset, holding
+// qty shares at the given cost basis.
+func successor(lot *models.Position, assetID uuid.UUID, qty, basis decimal.Decimal) *models.Position {
+	lotID := lot.LotID()
+
+	return &models.Position{
+		Status:             models.Open,
+		AccountID:          lot.AccountID,
+		EntryOrderID:       lot.EntryOrderID,
+		OriginalPositionID: &lotID,
+		AssetID:            assetID,
+		Side:               lot.Side,
+		Qty:                qty,
+		EntryPrice:         basis.Div(qty),
+		EntryTimestamp:     lot.EntryTimestamp,
+		FundsSettleDate:    lot.FundsSettleDate,
+	}
+}
+
+// closeLot disposes of the lot at the price, as of the action.
+func closeLot(tx *gorm.DB, asOf time.Time, lot *models.Position, price decimal.Decimal) error {
+	return tx.Model(lot).Updates(map[string]interface{}{
+		"status":            models.Closed,
+		"exit_price":        price,
+		"exit_timestamp":    asOf,
+		"gross_profit_loss": price.Sub(lot.EntryPrice).Mul(lot.Qty),
+	}).Error
+}
+
+// cashInLieuReference identifies the cash in lieu booked for an account
+// by an action, under both securities, so that the payment reported in
+// the cash activity isn't booked again.
+func cashInLieuReference(accountID string, action *SoDMandatoryAction, asOf date.Date) string {
+	return fmt.Sprintf("cil:%v:%v:%v:%v", accountID, action.CusipOld, action.Cusip, asOf)
+}
+
+// replaceLot marks the lot split, and stores its replacement, if any.
+func replaceLot(tx *gorm.DB, lot, next *models.Position) error {
+	if err := tx.Model(lot).Update("status", models.Split).Error; err != nil {
+		return err
+	}
+
+	if next == nil {
+		return nil
+	}
+
+	return tx.Create(next).Error
+}
+
+// allocateShares returns the whole number of new shares each lot
+// receives at the stock factor. The account's total is rounded down,
+// as the fraction is paid as cash in lieu, and the shares left over
+// after rounding each lot down go to the lots with the largest
+// remainders.
+func allocateShares(lots []*models.Position, factor decimal.Decimal) []decimal.Decimal {
+	var (
+		shares    = make([]decimal.Decimal, len(lots))
+		remainder = make([]decimal.Decimal, len(lots))
+		order     = make([]int, len(lots))
+		left      = totalQty(lots).Mul(factor).Floor()
+	)
+
+	for i, lot := range lots {
+		exact := lot.Qty.Mul(factor)
+		shares[i] = exact.Floor()
+		remainder[i] = exact.Sub(shares[i])
+		left = left.Sub(shares[i])
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainder[order[i]].GreaterThan(remainder[order[j]])
+	})
+
+	for _, i := range order {
+		if left.LessThanOrEqual(decimal.Zero) {
+			break
+		}
+		shares[i] = shares[i].Add(decimal.New(1, 0))
+		left = left.Sub(decimal.New(1, 0))
+	}
+
+	return shares
+}
+
+func sumShares(shares []decimal.Decimal) (total decimal.Decimal) {
+	for _, qty := range shares {
+		total = total.Add(qty)
+	}
+	return
+}
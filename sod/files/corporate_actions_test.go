@@ -0,0 +1,240 @@
+package files
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *FileTestSuite) TestAllocateShares() {
+	// Positions: (10, 10, 5)
+	// Factor: 0.42
+	// Exact: (4.2, 4.2, 2.1) = 10.5
+	// Expected: (4, 4, 2) = 10
+	{
+		shares := allocateShares(genPositions([]decimal.Decimal{
+			decimal.New(10, 0),
+			decimal.New(10, 0),
+			decimal.New(5, 0),
+		}), decimal.NewFromFloat(0.42))
+
+		require.Len(s.T(), shares, 3)
+		assert.True(s.T(), shares[0].Equal(decimal.New(4, 0)))
+		assert.True(s.T(), shares[1].Equal(decimal.New(4, 0)))
+		assert.True(s.T(), shares[2].Equal(decimal.New(2, 0)))
+	}
+
+	// Positions: (3, 3, 3)
+	// Factor: 0.5
+	// Exact: (1.5, 1.5, 1.5) = 4.5
+	// Expected: (2, 1, 1) = 4
+	{
+		shares := allocateShares(genPositions([]decimal.Decimal{
+			decimal.New(3, 0),
+			decimal.New(3, 0),
+			decimal.New(3, 0),
+		}), decimal.NewFromFloat(0.5))
+
+		require.Len(s.T(), shares, 3)
+		assert.True(s.T(), shares[0].Equal(decimal.New(2, 0)))
+		assert.True(s.T(), shares[1].Equal(decimal.New(1, 0)))
+		assert.True(s.T(), shares[2].Equal(decimal.New(1, 0)))
+	}
+}
+
+func (s *FileTestSuite) TestCorporateActionLots() {
+	newAsset := func(symbol, cusip string) *models.Asset {
+		asset := &models.Asset{
+			Symbol:   symbol,
+			Class:    enum.AssetClassUSEquity,
+			Exchange: "NYSE",
+			CUSIP:    cusip,
+			Status:   enum.AssetActive,
+			Tradable: true,
+		}
+		require.Nil(s.T(), db.DB().Create(asset).Error)
+		return asset
+	}
+
+	apexAcct := "apca_lots"
+	acct := &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			{
+				Email:   "lots@test.db",
+				Primary: true,
+			},
+		},
+	}
+	require.Nil(s.T(), db.DB().Create(acct).Error)
+
+	accountID := acct.ID
+	entry := time.Date(2018, 1, 10, 10, 0, 0, 0, time.UTC)
+
+	newLot := func(asset *models.Asset, qty, price int64) *models.Position {
+		lot := &models.Position{
+			AssetID:        asset.IDAsUUID(),
+			AccountID:      accountID,
+			Status:         models.Open,
+			EntryOrderID:   uuid.Must(uuid.NewV4()).String(),
+			Side:           models.Long,
+			Qty:            decimal.New(qty, 0),
+			EntryPrice:     decimal.New(price, 0),
+			EntryTimestamp: entry,
+		}
+		require.Nil(s.T(), db.DB().Create(lot).Error)
+		return lot
+	}
+
+	openLots := func(asset *models.Asset) []models.Position {
+		lots := []models.Position{}
+		require.Nil(s.T(), db.DB().
+			Where("asset_id = ? AND status = ?", asset.ID, models.Open).
+			Order("id").
+			Find(&lots).Error)
+		return lots
+	}
+
+	// spinoff: 1 new share for every 2 held, with 25% of the basis
+	// allocated to the spun off shares, 100 @ $30 -> 100 parent shares
+	// at $2,250 and 50 spun off shares at $750
+	{
+		parent := newAsset("PRNT", "111111111")
+		spun := newAsset("SPUN", "222222222")
+		lot := newLot(parent, 100, 30)
+		factor := decimal.NewFromFloat(0.5)
+
+		action := &SoDMandatoryAction{
+			Action:      enum.SoDSpinoff,
+			CusipOld:    parent.CUSIP,
+			Cusip:       spun.CUSIP,
+			StockFactor: &factor,
+		}
+
+		// without the allocation the lots are held
+		tx := db.DB().Begin()
+		assert.NotNil(s.T(), spinoffLots(tx, s.asOf, action, spun, []*models.Position{lot}))
+		tx.Rollback()
+
+		assert.Len(s.T(), openLots(spun), 0)
+		require.Len(s.T(), openLots(parent), 1)
+		assert.True(s.T(), openLots(parent)[0].EntryPrice.Equal(decimal.New(30, 0)))
+
+		action.ActionMessage = "SPINOFF 1 SPUN FOR 2 PRNT; COST BASIS ALLOCATION: 25% TO SPUN, 75% TO PRNT"
+
+		tx = db.DB().Begin()
+		require.Nil(s.T(), spinoffLots(tx, s.asOf, action, spun, []*models.Position{lot}))
+		require.Nil(s.T(), tx.Commit().Error)
+
+		parents := openLots(parent)
+		require.Len(s.T(), parents, 1)
+		assert.True(s.T(), parents[0].Qty.Equal(decimal.New(100, 0)))
+		assert.True(s.T(), parents[0].EntryPrice.Equal(decimal.NewFromFloat(22.5)))
+		assert.Equal(s.T(), lot.ID, *parents[0].OriginalPositionID)
+
+		spuns := openLots(spun)
+		require.Len(s.T(), spuns, 1)
+		assert.True(s.T(), spuns[0].Qty.Equal(decimal.New(50, 0)))
+		assert.True(s.T(), spuns[0].EntryPrice.Equal(decimal.New(15, 0)))
+		assert.Equal(s.T(), lot.ID, *spuns[0].OriginalPositionID)
+		assert.True(s.T(), spuns[0].EntryTimestamp.Equal(entry))
+	}
+
+	// stock merger at 0.42 with $50 cash in lieu per new share,
+	// 10 @ $21 -> 4 shares at $52.50, and 1 @ $20 is too small for a
+	// share so it's sold for the $31 paid for the 0.62 left over
+	{
+		target := newAsset("TRGT", "333333333")
+		acquirer := newAsset("ACQR", "444444444")
+		lot := newLot(target, 10, 21)
+		odd := newLot(target, 1, 20)
+		factor := decimal.NewFromFloat(0.42)
+
+		action := &SoDMandatoryAction{
+			Action:      enum.SoDStockMerger,
+			CusipOld:    target.CUSIP,
+			Cusip:       acquirer.CUSIP,
+			StockFactor: &factor,
+		}
+
+		// without a rate the lots are held
+		tx := db.DB().Begin()
+		assert.NotNil(s.T(), mergeLots(tx, s.asOf, action, acquirer, []*models.Position{lot, odd}))
+		tx.Rollback()
+
+		assert.Len(s.T(), openLots(target), 2)
+		assert.Len(s.T(), openLots(acquirer), 0)
+
+		rate := decimal.New(50, 0)
+		action.CashFactor = &rate
+
+		tx = db.DB().Begin()
+		require.Nil(s.T(), mergeLots(tx, s.asOf, action, acquirer, []*models.Position{lot, odd}))
+		require.Nil(s.T(), tx.Commit().Error)
+
+		assert.Len(s.T(), openLots(target), 0)
+
+		lots := openLots(acquirer)
+		require.Len(s.T(), lots, 1)
+		assert.True(s.T(), lots[0].Qty.Equal(decimal.New(4, 0)))
+		assert.True(s.T(), lots[0].EntryPrice.Equal(decimal.NewFromFloat(52.5)))
+		assert.Equal(s.T(), lot.ID, *lots[0].OriginalPositionID)
+
+		closed := &models.Position{}
+		require.Nil(s.T(), db.DB().Where("id = ?", odd.ID).First(closed).Error)
+		assert.Equal(s.T(), models.Closed, closed.Status)
+		require.NotNil(s.T(), closed.GrossProfitLoss)
+		assert.True(s.T(), closed.GrossProfitLoss.Equal(decimal.New(11, 0)))
+
+		journal := &models.Journal{}
+		require.Nil(s.T(), db.DB().
+			Where("account_id = ? AND reference_id = ?",
+				accountID, cashInLieuReference(accountID, action, date.DateOf(s.asOf))).
+			Preload("Entries").
+			First(journal).Error)
+
+		cash := decimal.Zero
+		for _, e := range journal.Entries {
+			if e.Ledger == enum.LedgerCash {
+				cash = cash.Add(e.Amount)
+			}
+		}
+		assert.True(s.T(), cash.Equal(decimal.New(31, 0)))
+	}
+
+	// cash merger at $5.05, 10 @ $4 closes for a $10.50 gain
+	{
+		asset := newAsset("CASH", "555555555")
+		lot := newLot(asset, 10, 4)
+		factor := decimal.NewFromFloat(5.05)
+
+		tx := db.DB().Begin()
+		require.Nil(s.T(), cashMergeLots(tx, s.asOf, &SoDMandatoryAction{
+			Action:     enum.SoDCashMerger,
+			CusipOld:   asset.CUSIP,
+			CashFactor: &factor,
+		}, nil, []*models.Position{lot}))
+		require.Nil(s.T(), tx.Commit().Error)
+
+		closed := &models.Position{}
+		require.Nil(s.T(), db.DB().Where("id = ?", lot.ID).First(closed).Error)
+		assert.Equal(s.T(), models.Closed, closed.Status)
+		require.NotNil(s.T(), closed.GrossProfitLoss)
+		assert.True(s.T(), closed.GrossProfitLoss.Equal(decimal.NewFromFloat(10.5)))
+
+		journal := &models.Journal{}
+		require.Nil(s.T(), db.DB().
+			Where("account_id = ? AND type = ? AND description LIKE ?",
+				accountID, enum.JournalCorporateAction, "cash merger%").
+			First(journal).Error)
+	}
+}
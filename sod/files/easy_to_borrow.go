@@ -1,6 +1,8 @@
 package files
 
 import (
+	"encoding/json"
+	"fmt"
 	"reflect"
 	"strings"
 	"time"
@@ -12,6 +14,11 @@ import (
 	"github.com/lib/pq"
 )
 
+// minEasyToBorrowRatio is how small the list can be relative to the
+// assets currently shortable before it's taken for a truncated or failed
+// download, rather than a change in what can be borrowed.
+const minEasyToBorrowRatio = 0.5
+
 type SoDEasyToBorrow struct {
 	Symbols []string `json:"symbols" gorm:"type:varchar(10)[]"`
 }
@@ -45,27 +52,66 @@ func (e2b *EasyToBorrowReport) Append(v interface{}) {
 	e2b.list.Symbols = pq.StringArray(syms)
 }
 
+// Sync marks the assets on the list easy to borrow, and the rest hard
+// to borrow. Only easy to borrow securities can be sold short, so the
+// list also decides which are shortable. A list which is empty, or far
+// shorter than the shortable set, is reported and left unapplied.
 func (e2b *EasyToBorrowReport) Sync(asOf time.Time) (uint, uint) {
 	assets := e2b.gatherAssets()
 
-	tx := db.Begin()
+	listed := map[string]bool{}
 
 	for _, symbol := range e2b.list.Symbols {
-		if asset, ok := assets[strings.TrimSpace(symbol)]; ok {
-			asset.Shortable = true
-			if err := tx.Save(&asset).Error; err != nil {
-				tx.Rollback()
-				log.Panic(
-					"start of day database error",
-					"file", e2b.ExtCode(),
-					"error", err)
-			}
+		symbol = strings.TrimSpace(symbol)
+		if _, ok := assets[symbol]; ok {
+			listed[symbol] = true
 		} else {
 			// we don't know about this symbol, let's warn
 			log.Warn("unknown symbol on the easy to borrow list", "symbol", symbol)
 		}
 	}
 
+	shortable := 0
+	for _, asset := range assets {
+		if asset.Shortable {
+			shortable++
+		}
+	}
+
+	// every asset missing from the list becomes hard to borrow, so a
+	// bad list would take shorting away from the whole universe
+	if len(listed) == 0 || float64(len(listed)) < minEasyToBorrowRatio*float64(shortable) {
+		StoreErrors([]models.BatchError{
+			e2b.genError(asOf, fmt.Errorf(
+				"easy to borrow list has %v known symbols, while %v assets are shortable",
+				len(listed), shortable)),
+		})
+		return 0, 1
+	}
+
+	tx := db.Begin()
+
+	for symbol, asset := range assets {
+		etb := listed[symbol]
+
+		if asset.Shortable == etb && asset.EasyToBorrow == etb {
+			continue
+		}
+
+		updates := map[string]interface{}{
+			"shortable":      etb,
+			"easy_to_borrow": etb,
+		}
+
+		if err := tx.Model(&asset).Update(updates).Error; err != nil {
+			tx.Rollback()
+			log.Panic(
+				"start of day database error",
+				"file", e2b.ExtCode(),
+				"error", err)
+		}
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		log.Panic(
 			"start of day database error",
@@ -76,6 +122,20 @@ func (e2b *EasyToBorrowReport) Sync(asOf time.Time) (uint, uint) {
 	return uint(len(e2b.list.Symbols)), 0
 }
 
+func (e2b *EasyToBorrowReport) genError(asOf time.Time, err error) models.BatchError {
+	log.Error("start of day error", "file", e2b.ExtCode(), "error", err)
+	buf, _ := json.Marshal(map[string]interface{}{
+		"error":   err.Error(),
+		"symbols": len(e2b.list.Symbols),
+	})
+	return models.BatchError{
+		ProcessDate:             asOf.Format("2006-01-02"),
+		FileCode:                e2b.ExtCode(),
+		PrimaryRecordIdentifier: e2b.ExtCode(),
+		Error:                   buf,
+	}
+}
+
 func (e2b *EasyToBorrowReport) gatherAssets() map[string]models.Asset {
 	assets := []models.Asset{}
 	m := map[string]models.Asset{}
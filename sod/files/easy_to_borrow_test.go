@@ -0,0 +1,55 @@
+package files
+
+import (
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *FileTestSuite) TestEasyToBorrow() {
+	symbols := []string{"ETBA", "ETBB", "ETBC", "ETBD"}
+	for _, symbol := range symbols {
+		require.Nil(s.T(), db.DB().Create(&models.Asset{
+			Symbol:       symbol,
+			Class:        enum.AssetClassUSEquity,
+			Exchange:     "NYSE",
+			Status:       enum.AssetActive,
+			Tradable:     true,
+			Shortable:    true,
+			EasyToBorrow: true,
+		}).Error)
+	}
+
+	shortable := func(symbol string) bool {
+		asset := &models.Asset{}
+		require.Nil(s.T(), db.DB().Where("symbol = ?", symbol).First(asset).Error)
+		return asset.Shortable && asset.EasyToBorrow
+	}
+
+	sync := func(list ...string) (uint, uint) {
+		e2b := &EasyToBorrowReport{}
+		for _, symbol := range list {
+			e2b.Append(symbol)
+		}
+		return e2b.Sync(s.asOf)
+	}
+
+	// an empty list is left unapplied
+	_, errors := sync()
+	assert.Equal(s.T(), uint(1), errors)
+	for _, symbol := range symbols {
+		assert.True(s.T(), shortable(symbol))
+	}
+
+	// and so is one far shorter than the shortable set
+	_, errors = sync("ETBA")
+	assert.Equal(s.T(), uint(1), errors)
+	assert.True(s.T(), shortable("ETBD"))
+
+	_, errors = sync("ETBA", "ETBB", "ETBC")
+	assert.Equal(s.T(), uint(0), errors)
+	assert.True(s.T(), shortable("ETBA"))
+	assert.False(s.T(), shortable("ETBD"))
+}
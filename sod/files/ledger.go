@@ -0,0 +1,83 @@
+package files
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ReconcileLedger compares each account's cash ledger against the net
+// balance synced from the buying power summary, once the cash and
+// trade activity for the day have been booked. Differences are booked
+// to the ledger so that it follows the books of record, and stored as
+// errors to be investigated unless it is the account's opening balance.
+func ReconcileLedger(asOf time.Time) (uint, uint) {
+	errors := []models.BatchError{}
+	asof := date.DateOf(asOf)
+
+	balances := []struct {
+		AccountID   string
+		ApexAccount string
+		Value       decimal.Decimal
+	}{}
+
+	if err := db.DB().
+		Table("cashes").
+		Select("cashes.account_id, accounts.apex_account, cashes.value").
+		Joins("JOIN accounts ON accounts.id = cashes.account_id").
+		Where("cashes.date = ?", asof).
+		Scan(&balances).Error; err != nil {
+		log.Panic("ledger reconciliation database error", "error", err)
+	}
+
+	for _, balance := range balances {
+		tx := db.Begin()
+
+		r, err := ledger.Service().WithTx(tx).Reconcile(
+			uuid.FromStringOrNil(balance.AccountID),
+			asof,
+			balance.Value)
+		if err != nil {
+			tx.Rollback()
+			log.Panic("ledger reconciliation database error", "account", balance.AccountID, "error", err)
+		}
+
+		if err = tx.Commit().Error; err != nil {
+			log.Panic("ledger reconciliation database error", "account", balance.AccountID, "error", err)
+		}
+
+		if r.Difference.Equal(decimal.Zero) || r.Opening {
+			continue
+		}
+
+		log.Error(
+			"ledger reconciliation mismatch",
+			"account", balance.AccountID,
+			"expected", r.Expected,
+			"ledger", r.Ledger)
+
+		buf, _ := json.Marshal(map[string]interface{}{
+			"error":          "ledger cash does not match net balance",
+			"account_id":     balance.AccountID,
+			"reconciliation": r,
+		})
+
+		errors = append(errors, models.BatchError{
+			ProcessDate:             asof.String(),
+			FileCode:                (&BuyingPowerSummaryReport{}).ExtCode(),
+			PrimaryRecordIdentifier: balance.ApexAccount,
+			Error:                   buf,
+		})
+	}
+
+	StoreErrors(errors)
+
+	return uint(len(balances) - len(errors)), uint(len(errors))
+}
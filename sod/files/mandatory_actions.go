@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/corporateaction"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/alpacahq/gopaca/log"
 	"github.com/shopspring/decimal"
@@ -41,6 +43,29 @@ type SoDMandatoryAction struct {
 	RecordDate          *string                 `sql:"type:date"`
 }
 
+// basisAllocationRe finds the percentage of the parent's cost basis
+// allocated to the spun off security by fair market value in a spinoff
+// notice, e.g. "COST BASIS ALLOCATION: 12.5% TO ...".
+var basisAllocationRe = regexp.MustCompile(`(?i)(?:cost\s+basis|fmv)[^%|]*?(\d+(?:\.\d+)?)\s*%`)
+
+// BasisAllocation returns the fraction of the parent's cost basis the
+// spun off security is allocated, if the action's notice gives one.
+func (a *SoDMandatoryAction) BasisAllocation() *decimal.Decimal {
+	m := basisAllocationRe.FindStringSubmatch(a.ActionMessage)
+	if m == nil {
+		return nil
+	}
+
+	pct, err := decimal.NewFromString(m[1])
+	if err != nil || pct.LessThanOrEqual(decimal.Zero) || pct.GreaterThanOrEqual(decimal.New(100, 0)) {
+		return nil
+	}
+
+	allocation := pct.Div(decimal.New(100, 0))
+
+	return &allocation
+}
+
 type MandatoryActionReport struct {
 	actions []SoDMandatoryAction
 }
@@ -121,9 +146,10 @@ func (ma *MandatoryActionReport) Sync(asOf time.Time) (uint, uint) {
 		}
 
 		// find and cancel open orders
-		if err := ma.handleOrders(asOf, agged); err != nil {
-			errors = append(errors, *err)
-		}
+		errors = append(errors, ma.handleOrders(asOf, agged)...)
+
+		// move the lots to the new security, and adjust their basis
+		errors = append(errors, ma.handleLots(asOf, agged)...)
 
 		// find and mark positions with splits
 		if err := ma.handlePositions(asOf, agged); err != nil {
@@ -139,7 +165,13 @@ func (ma *MandatoryActionReport) Sync(asOf time.Time) (uint, uint) {
 
 	StoreErrors(errors)
 
-	return uint(len(ma.actions) - len(errors)), uint(len(errors))
+	// an action can fail for more than one order or account
+	failed := len(errors)
+	if failed > len(ma.actions) {
+		failed = len(ma.actions)
+	}
+
+	return uint(len(ma.actions) - failed), uint(failed)
 }
 
 func (ma *MandatoryActionReport) genError(asOf time.Time, action SoDMandatoryAction, err error) *models.BatchError {
@@ -174,6 +206,7 @@ func (ma *MandatoryActionReport) storeAction(asOf time.Time, action SoDMandatory
 		Date:        asOf.Format("2006-01-02"),
 		StockFactor: action.StockFactor,
 		CashFactor:  action.CashFactor,
+		Cusip:       action.Cusip,
 	}
 
 	tx := db.RepeatableRead()
@@ -191,12 +224,41 @@ func (ma *MandatoryActionReport) handleAsset(
 	agged *AggedAction) *models.BatchError {
 
 	if agged.needUpdateAsset() {
+		// the new security may already be listed on its own, in which
+		// case the lots are moved to it rather than renaming the asset
+		if target := ma.findTarget(agged.Cusip, &agged.asset); target != nil {
+			agged.target = target
+			return nil
+		}
+
 		ma.updateAsset(&agged.asset, agged.SoDMandatoryAction)
 	}
 
 	return nil
 }
 
+// findTarget finds the asset the new security of an action is listed
+// as, other than the asset the action applies to.
+func (ma *MandatoryActionReport) findTarget(cusip string, asset *models.Asset) *models.Asset {
+	if cusip == "" {
+		return nil
+	}
+
+	target := &models.Asset{}
+
+	q := db.DB().Where("cusip = ? AND id != ?", cusip, asset.ID).First(target)
+
+	if q.RecordNotFound() {
+		return nil
+	}
+
+	if q.Error != nil {
+		log.Panic("start of day database error", "file", ma.ExtCode(), "error", q.Error)
+	}
+
+	return target
+}
+
 func (ma *MandatoryActionReport) updateAsset(asset *models.Asset, action SoDMandatoryAction) {
 	// update the cusip to be latest
 	if action.Cusip != "" {
@@ -242,7 +304,12 @@ func (ma *MandatoryActionReport) updateAsset(asset *models.Asset, action SoDMand
 
 func (ma *MandatoryActionReport) handleOrders(
 	asOf time.Time,
-	agged *AggedAction) (bErr *models.BatchError) {
+	agged *AggedAction) (errors []models.BatchError) {
+
+	// left for the pre-open job to cancel or adjust
+	if agged.adjustOrdersPreOpen() {
+		return nil
+	}
 
 	orders := []models.Order{}
 
@@ -272,8 +339,10 @@ func (ma *MandatoryActionReport) handleOrders(
 		q := db.DB().Where("apex_account = ?", order.Account).Find(acct)
 
 		if q.RecordNotFound() {
-			bErr = ma.genError(asOf, agged.SoDMandatoryAction, fmt.Errorf("account not found"))
-			return
+			errors = append(errors, *ma.genError(
+				asOf, agged.SoDMandatoryAction,
+				fmt.Errorf("account %v not found for order %v", order.Account, order.ID)))
+			continue
 		}
 
 		if q.Error != nil {
@@ -284,13 +353,88 @@ func (ma *MandatoryActionReport) handleOrders(
 
 		if err := srv.WithTx(tx).Cancel(acct.IDAsUUID(), order.IDAsUUID()); err != nil {
 			tx.Rollback()
-			bErr = ma.genError(asOf, agged.SoDMandatoryAction, err)
-			return
+			errors = append(errors, *ma.genError(
+				asOf, agged.SoDMandatoryAction,
+				fmt.Errorf("failed to cancel order %v (%v)", order.ID, err)))
+			continue
 		}
 
 		tx.Commit()
 	}
-	return nil
+	return
+}
+
+// handleLots runs the lot processor for each action, one account at
+// a time, so one account's failure leaves the others adjusted.
+func (ma *MandatoryActionReport) handleLots(
+	asOf time.Time,
+	agged *AggedAction) (errors []models.BatchError) {
+
+	for _, action := range agged.actions {
+		process, ok := lotProcessors[action.Action]
+		if !ok {
+			continue
+		}
+
+		positions := []*models.Position{}
+
+		q := db.DB().
+			Where("asset_id = ? AND status = ?", agged.asset.ID, models.Open).
+			Order("entry_timestamp, id").
+			Find(&positions)
+
+		if q.Error != nil {
+			log.Panic("start of day database error", "file", ma.ExtCode(), "error", q.Error)
+		}
+
+		if len(positions) == 0 {
+			continue
+		}
+
+		target := ma.findTarget(action.Cusip, &agged.asset)
+
+		accounts := []string{}
+		lots := map[string][]*models.Position{}
+
+		for _, p := range positions {
+			if _, ok := lots[p.AccountID]; !ok {
+				accounts = append(accounts, p.AccountID)
+			}
+			lots[p.AccountID] = append(lots[p.AccountID], p)
+		}
+
+		for _, accountID := range accounts {
+			tx := db.RepeatableRead()
+
+			if err := process(tx, asOf, action, target, lots[accountID]); err != nil {
+				tx.Rollback()
+				errors = append(errors, *ma.genError(
+					asOf, *action,
+					fmt.Errorf("account %v: %v", accountID, err)))
+				continue
+			}
+
+			if err := tx.Commit().Error; err != nil {
+				log.Panic("start of day database error", "file", ma.ExtCode(), "error", err)
+			}
+		}
+
+		switch action.Action {
+		case enum.SoDCashMerger:
+			agged.adjusted = true
+		case enum.SoDStockMerger:
+			if target != nil {
+				agged.adjusted = true
+				agged.target = target
+			}
+		case enum.SoDSymbolChange, enum.SoDCusipChange:
+			if target != nil {
+				agged.target = target
+			}
+		}
+	}
+
+	return
 }
 
 // mark positions for split
@@ -298,12 +442,16 @@ func (ma *MandatoryActionReport) handlePositions(
 	asOf time.Time,
 	agged *AggedAction) (bErr *models.BatchError) {
 
-	if !agged.needMarkPosition() {
+	// lots already exchanged at the stock factor need no reconciling
+	if agged.adjusted || !agged.needMarkPosition() {
 		return
 	}
 
 	positions := []models.Position{}
 	asset := agged.asset
+	if agged.target != nil {
+		asset = *agged.target
+	}
 	// find open positions for the asset
 	q := db.DB().Where("asset_id = ? AND status = ?",
 		asset.ID, models.Open).Find(&positions)
@@ -391,6 +539,11 @@ type AggedAction struct {
 
 	actions []*SoDMandatoryAction
 	asset   models.Asset
+
+	// target is the separately listed asset the lots were moved to
+	target *models.Asset
+	// adjusted is set once the lots were exchanged or closed
+	adjusted bool
 }
 
 func (agg *AggedAction) needUpdateAsset() bool {
@@ -405,11 +558,20 @@ func (agg *AggedAction) needUpdateAsset() bool {
 	return false
 }
 
+// adjustOrdersPreOpen returns true if the pre-open job has a policy for
+// the open orders on every action.
+func (agg *AggedAction) adjustOrdersPreOpen() bool {
+	for _, action := range agg.actions {
+		if _, ok := corporateaction.Policy(enum.CorporateActionTypeFromSoD(action.Action)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func (agg *AggedAction) needMarkPosition() bool {
 	for _, action := range agg.actions {
 		switch action.Action {
-		case enum.SoDCashMerger:
-			fallthrough
 		case enum.SoDStockMerger:
 			fallthrough
 		case enum.SoDStockSplit:
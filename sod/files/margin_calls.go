@@ -11,7 +11,9 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/goodfaith"
 	"github.com/alpacahq/gobroker/utils"
+	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
@@ -148,6 +150,26 @@ func (mcr *MarginCallReport) Sync(asOf time.Time) (uint, uint) {
 			log.Panic("start of day database error", "file", mcr.ExtCode(), "error", err)
 		}
 
+		// apex caught a good faith violation we didn't, so count it
+		// against the account as well
+		if call.CallType == GoodFaithViolations {
+			tradeDate, err := time.Parse("01/02/2006", call.TradeDate)
+			if err != nil {
+				tx.Rollback()
+				errors = append(errors, mcr.genError(asOf, call, fmt.Errorf("failed to parse trade_date (%v)", err)))
+				continue
+			}
+
+			if err = goodfaith.Service().WithTx(tx).Record(&models.GoodFaithViolation{
+				AccountID: acct.ID,
+				TradeDate: date.DateOf(tradeDate),
+				Source:    models.GoodFaithSourceApex,
+			}); err != nil {
+				tx.Rollback()
+				log.Panic("start of day database error", "file", mcr.ExtCode(), "error", err)
+			}
+		}
+
 		if mc.ShouldNotify(nil) {
 			now := clock.Now()
 
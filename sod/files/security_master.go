@@ -112,6 +112,11 @@ func (s *SoDSecurityMaster) isValid() bool {
 		strings.EqualFold(s.ForeignCountry, "US")
 }
 
+// marginable returns true if the security can be bought on margin.
+func (s *SoDSecurityMaster) marginable() bool {
+	return strings.EqualFold(s.MarginEligibleCode, "Y")
+}
+
 type SecurityMaster struct {
 	securities []SoDSecurityMaster
 }
@@ -175,10 +180,26 @@ func (sm *SecurityMaster) Sync(asof time.Time) (uint, uint) {
 	// that must be added to the DB, and will be handled after
 	// the removals.
 	for _, asset := range assets {
-		_, ok := secs[asset.CUSIP]
+		sec, ok := secs[asset.CUSIP]
 		polySec, valid := poly.SymbolValid(asset.Symbol)
 
 		if ok && valid {
+			if marginable := sec.marginable(); asset.Marginable != marginable {
+				log.Info(
+					"updating asset marginability",
+					"symbol", asset.Symbol,
+					"marginable", marginable)
+
+				updates := map[string]interface{}{"marginable": marginable}
+
+				if err := updateAsset(&asset, updates); err != nil {
+					log.Panic(
+						"start of day database error",
+						"file", sm.ExtCode(),
+						"error", err)
+				}
+			}
+
 			// make sure the exchange is up-to-date
 			if !strings.EqualFold(asset.Exchange, *polySec.exchange) {
 				log.Info(
@@ -241,12 +262,13 @@ func (sm *SecurityMaster) Sync(asof time.Time) (uint, uint) {
 			log.Debug("adding security", "symbol", polySec.symbol)
 
 			asset := &models.Asset{
-				Class:    enum.AssetClassUSEquity,
-				Exchange: *polySec.exchange,
-				Symbol:   polySec.symbol,
-				CUSIP:    sec.CUSIP,
-				Status:   enum.AssetActive,
-				Tradable: true,
+				Class:      enum.AssetClassUSEquity,
+				Exchange:   *polySec.exchange,
+				Symbol:     polySec.symbol,
+				CUSIP:      sec.CUSIP,
+				Status:     enum.AssetActive,
+				Tradable:   true,
+				Marginable: sec.marginable(),
 			}
 
 			tx := db.Begin()
@@ -255,10 +277,11 @@ func (sm *SecurityMaster) Sync(asof time.Time) (uint, uint) {
 				Class:    asset.Class,
 				Exchange: asset.Exchange,
 				Symbol:   polySec.symbol,
-			}).Assign(&models.Asset{
-				CUSIP:    sec.CUSIP,
-				Status:   enum.AssetActive,
-				Tradable: true}).FirstOrCreate(asset).Error; err != nil {
+			}).Assign(map[string]interface{}{
+				"cusip":      sec.CUSIP,
+				"status":     enum.AssetActive,
+				"tradable":   true,
+				"marginable": sec.marginable()}).FirstOrCreate(asset).Error; err != nil {
 
 				tx.Rollback()
 
@@ -282,6 +305,13 @@ func (sm *SecurityMaster) Sync(asof time.Time) (uint, uint) {
 		}
 	}
 
+	if err := syncNames(); err != nil {
+		log.Panic(
+			"start of day database error",
+			"file", sm.ExtCode(),
+			"error", err)
+	}
+
 	count := 0
 
 	db.DB().Model(&models.Asset{}).Count(&count)
@@ -397,6 +427,18 @@ func (sm *SecurityMaster) gatherApexSecurities() map[string]*SoDSecurityMaster {
 	return m
 }
 
+// syncNames copies the company names from the fundamentals to the
+// assets, so the asset cache can search them.
+func syncNames() error {
+	return db.DB().Exec(`
+		UPDATE assets SET name = fundamentals.full_name
+		FROM fundamentals
+		WHERE fundamentals.asset_id = assets.id
+		AND fundamentals.deleted_at IS NULL
+		AND fundamentals.full_name <> ''
+		AND assets.name IS DISTINCT FROM fundamentals.full_name`).Error
+}
+
 func updateAsset(asset *models.Asset, updates map[string]interface{}) error {
 	tx := db.Begin()
 
@@ -10,6 +10,7 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/ledger"
 	"github.com/alpacahq/gobroker/service/op"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/alpacahq/gopaca/calendar"
@@ -161,7 +162,7 @@ func (tar *TradeActivityReport) Sync(asOf time.Time) (uint, uint) {
 		svc := account.Service().WithTx(tx)
 		svc.SetForUpdate()
 
-		_, err := svc.GetByApexAccount(apexAcct)
+		acct, err := svc.GetByApexAccount(apexAcct)
 		if err != nil {
 			errors = append(errors, tar.genError(asOf, trades, fmt.Errorf("account not found")))
 			tx.Rollback()
@@ -192,7 +193,15 @@ func (tar *TradeActivityReport) Sync(asOf time.Time) (uint, uint) {
 			continue
 		}
 
+		adjustments := []feeAdjustment{}
+
 		for _, pair := range pairs {
+			// fees charged when the execution was filled, if any. Our
+			// commission isn't reported by the clearing broker, so it's
+			// left as it was.
+			charged := pair.execution.HasFee()
+			estimate := pair.execution.ClearingFee()
+
 			patch := models.Execution{
 				FeeSec:  pair.report.FeeSec,
 				FeeMisc: pair.report.FeeMisc,
@@ -202,7 +211,44 @@ func (tar *TradeActivityReport) Sync(asOf time.Time) (uint, uint) {
 				Fee4:    pair.report.Fee4,
 				Fee5:    pair.report.Fee5,
 			}
-			if err := tx.Model(&pair.execution).Updates(patch).Error; err != nil {
+
+			// fees missing from the report are cleared too, which a
+			// struct update would skip
+			if err := tx.Model(&pair.execution).Updates(map[string]interface{}{
+				"fee_sec":  patch.FeeSec,
+				"fee_misc": patch.FeeMisc,
+				"fee1":     patch.Fee1,
+				"fee2":     patch.Fee2,
+				"fee3":     patch.Fee3,
+				"fee4":     patch.Fee4,
+				"fee5":     patch.Fee5,
+			}).Error; err != nil {
+				tx.Rollback()
+				log.Panic("start of day database error", "file", tar.ExtCode(), "error", err)
+			}
+
+			if !charged {
+				if err := ledger.Service().WithTx(tx).PostFee(acct.ID, &pair.execution, patch.ClearingFee()); err != nil {
+					tx.Rollback()
+					log.Panic("start of day database error", "file", tar.ExtCode(), "error", err)
+				}
+				continue
+			}
+
+			// the clearing broker's fees are authoritative, so book
+			// the difference
+			diff := patch.ClearingFee().Sub(estimate)
+			if diff.Equal(decimal.Zero) {
+				continue
+			}
+
+			adjustments = append(adjustments, feeAdjustment{
+				execution: pair.execution.ID,
+				charged:   estimate,
+				reported:  patch.ClearingFee(),
+			})
+
+			if err := ledger.Service().WithTx(tx).PostFeeAdjustment(acct.ID, &pair.execution, diff); err != nil {
 				tx.Rollback()
 				log.Panic("start of day database error", "file", tar.ExtCode(), "error", err)
 			}
@@ -237,6 +283,18 @@ func (tar *TradeActivityReport) Sync(asOf time.Time) (uint, uint) {
 		if err := tx.Commit().Error; err != nil {
 			log.Panic("start of day database error", "file", tar.ExtCode(), "error", err)
 		}
+
+		// the adjustments are booked, so they're only logged rather
+		// than stored as errors
+		for _, adj := range adjustments {
+			log.Warn(
+				"start of day fee adjustment",
+				"file", tar.ExtCode(),
+				"account", apexAcct,
+				"execution", adj.execution,
+				"charged", adj.charged,
+				"reported", adj.reported)
+		}
 	}
 
 	StoreErrors(errors)
@@ -258,6 +316,14 @@ func (tar *TradeActivityReport) genError(asOf time.Time, trades []SoDTradeActivi
 	}
 }
 
+// feeAdjustment is a difference between the fees charged for an
+// execution at fill time and the ones the clearing broker reported.
+type feeAdjustment struct {
+	execution string
+	charged   decimal.Decimal
+	reported  decimal.Decimal
+}
+
 type Pair struct {
 	report    SoDTradeActivity
 	execution models.Execution
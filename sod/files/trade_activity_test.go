@@ -2,8 +2,13 @@ package files
 
 import (
 	"io/ioutil"
+	"time"
 
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/sod/files/samples"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,3 +27,109 @@ func (s *FileTestSuite) TestTradeActivities() {
 	assert.Nil(s.T(), Parse(buf, sodFile))
 	assert.NotPanics(s.T(), func() { sodFile.Sync(s.asOf) })
 }
+
+func (s *FileTestSuite) TestTradeActivityFees() {
+	apexAcct := "apca_fees"
+	acct := &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			{
+				Email:   "fees@test.db",
+				Primary: true,
+			},
+		},
+	}
+	require.Nil(s.T(), db.DB().Create(acct).Error)
+
+	qty := decimal.NewFromFloat(100)
+	order := &models.Order{
+		Account:     apexAcct,
+		Qty:         qty.Mul(decimal.NewFromFloat(2)),
+		Symbol:      "FEES",
+		Type:        enum.Market,
+		Side:        enum.Sell,
+		TimeInForce: enum.Day,
+	}
+	require.Nil(s.T(), db.DB().Create(order).Error)
+
+	price := decimal.NewFromFloat(100)
+	genExecution := func(brokerExecID string, at time.Time, feeSec, feeMisc *decimal.Decimal) *models.Execution {
+		exec := &models.Execution{
+			Account:           apexAcct,
+			BrokerExecID:      brokerExecID,
+			Type:              enum.ExecutionPartialFill,
+			Symbol:            order.Symbol,
+			Side:              enum.Sell,
+			OrderID:           order.ID,
+			OrderType:         order.Type,
+			OrderStatus:       enum.OrderPartiallyFilled,
+			Price:             &price,
+			Qty:               &qty,
+			TransactionTime:   at,
+			BraggartTimestamp: &at,
+			FeeSec:            feeSec,
+			FeeMisc:           feeMisc,
+		}
+		require.Nil(s.T(), db.DB().Create(exec).Error)
+		return exec
+	}
+
+	chargedSec := decimal.NewFromFloat(0.14)
+	chargedTAF := decimal.NewFromFloat(0.02)
+
+	// one fill was charged at fill time, the other wasn't
+	charged := genExecution("fees_1", s.asOf.Add(10*time.Hour+30*time.Minute), &chargedSec, &chargedTAF)
+	uncharged := genExecution("fees_2", s.asOf.Add(11*time.Hour), nil, nil)
+
+	trade := func(exec *models.Execution, feeSec string) SoDTradeActivity {
+		q := exec.Qty.Mul(exec.Side.Coeff())
+		sec, _ := decimal.NewFromString(feeSec)
+		return SoDTradeActivity{
+			AccountNumber:    apexAcct,
+			BuySellCode:      Sell,
+			Symbol:           exec.Symbol,
+			Quantity:         &q,
+			Price:            exec.Price,
+			ExecutionTime:    exec.TransactionTime.In(s.asOf.Location()).Format("1504"),
+			FeeSec:           &sec,
+			FeeMisc:          &chargedTAF,
+			SecurityTypeCode: SoDSecurityTypeEquityCommonStock,
+		}
+	}
+
+	tar := &TradeActivityReport{}
+	tar.Append(trade(charged, "0.15"))
+	tar.Append(trade(uncharged, "0.14"))
+
+	_, errors := tar.Sync(s.asOf)
+	assert.Equal(s.T(), uint(0), errors)
+
+	// the reported fees replace the ones charged at fill time
+	require.Nil(s.T(), db.DB().Where("id = ?", charged.ID).First(charged).Error)
+	assert.Equal(s.T(), "0.15", charged.FeeSec.String())
+
+	cash := func(referenceID string) decimal.Decimal {
+		journal := &models.Journal{}
+		require.Nil(s.T(), db.DB().
+			Where("type = ? AND reference_id = ?", enum.JournalFee, referenceID).
+			Preload("Entries").
+			First(journal).Error)
+		for _, entry := range journal.Entries {
+			if entry.Ledger == enum.LedgerCash {
+				return entry.Amount
+			}
+		}
+		return decimal.Zero
+	}
+
+	// the charged fill is adjusted by the difference, and the
+	// uncharged one gets the reported fees in full
+	assert.Equal(s.T(), "-0.01", cash("adjustment:"+charged.ID).String())
+	assert.Equal(s.T(), "-0.16", cash(uncharged.ID).String())
+
+	require.Nil(s.T(), db.DB().Where("id = ?", order.ID).First(order).Error)
+	require.NotNil(s.T(), order.Fee)
+	assert.Equal(s.T(), "0.33", order.Fee.String())
+}
@@ -150,6 +150,17 @@ func (sp *SoDProcessor) Pull(asof time.Time, securitiesOnly bool, retries uint)
 		return err
 	}
 
+	if !securitiesOnly {
+		// the cash and trade activity need to be booked before
+		// the ledger can be compared to the buying power summary
+		processed, errors := files.ReconcileLedger(asof)
+
+		log.Info(
+			"reconciled cash ledger",
+			"processed", processed,
+			"errors", errors)
+	}
+
 	return sp.notifySlack(asof)
 }
 
@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/alpacahq/gopaca/rmq/pubsub"
+)
+
+var (
+	publishOnce   sync.Once
+	publisher     chan<- pubsub.Message
+	publishCancel context.CancelFunc
+)
+
+// Publish sends the message to the stream pubsub so that it
+// reaches the listeners of the target stream, regardless of which
+// process they are connected to.
+func Publish(msg OutboundMessage) error {
+	publishOnce.Do(func() {
+		publisher, publishCancel = pubsub.NewPubSub("stream").Publish()
+	})
+
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	publisher <- pubsub.Message(buf)
+
+	return nil
+}
+
+// StopPublish disconnects the publisher used by Publish, if any.
+func StopPublish() {
+	if publishCancel != nil {
+		publishCancel()
+	}
+}
@@ -9,6 +9,11 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/fee"
+	"github.com/alpacahq/gobroker/service/goodfaith"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
 	"github.com/alpacahq/gopaca/log"
 	"github.com/jinzhu/gorm"
 )
@@ -20,12 +25,67 @@ func ProcessExecution(tx *gorm.DB, acct *models.TradeAccount, exec *models.Execu
 	case enum.ExecutionPartialFill:
 		fallthrough
 	case enum.ExecutionFill:
-		err = handleFill(tx, acct, exec)
+		if err = handleFill(tx, acct, exec); err != nil {
+			return err
+		}
+
+		if err = ledger.Service().WithTx(tx).PostFill(acct.ID, exec); err != nil {
+			return err
+		}
+
+		err = chargeFees(tx, acct, exec)
 	}
 
 	return err
 }
 
+// chargeFees computes the fees for a fill and stores them on the
+// execution and its order, and books them to the ledger. They are
+// reconciled with the clearing broker's fees by the SoD trade
+// activity report.
+func chargeFees(tx *gorm.DB, acct *models.TradeAccount, exec *models.Execution) error {
+	tradeDate := tradingdate.TradeDate(exec.TransactionTime).Date()
+
+	// the order's earlier fills on the same trade date make up the
+	// same trade
+	fills := []models.Execution{}
+	if err := tx.
+		Where("order_id = ? AND id <> ? AND type IN (?) AND transaction_time <= ?",
+			exec.OrderID,
+			exec.ID,
+			[]enum.ExecutionType{enum.ExecutionFill, enum.ExecutionPartialFill},
+			exec.TransactionTime).
+		Find(&fills).Error; err != nil {
+		return err
+	}
+
+	earlier := []models.Execution{}
+	for _, e := range fills {
+		if tradingdate.TradeDate(e.TransactionTime).Date() == tradeDate {
+			earlier = append(earlier, e)
+		}
+	}
+
+	fees := fee.ForFill(acct.Plan, exec, earlier, tradeDate)
+	fees.Apply(exec)
+
+	if err := tx.Model(exec).Updates(models.Execution{
+		FeeSec:     exec.FeeSec,
+		FeeMisc:    exec.FeeMisc,
+		Commission: exec.Commission,
+	}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Order{}).
+		Where("id = ?", exec.OrderID).
+		UpdateColumn("fee", gorm.Expr("COALESCE(fee, 0) + ?", fees.Total())).Error; err != nil {
+		return err
+	}
+
+	return ledger.Service().WithTx(tx).PostFee(acct.ID, exec, fees.Total())
+}
+
 // handleFill handles fill and partial fill executions
 func handleFill(tx *gorm.DB, acct *models.TradeAccount, exec *models.Execution) (err error) {
 	switch exec.Side {
@@ -50,15 +110,30 @@ func handleBuyFill(tx *gorm.DB, acct *models.TradeAccount, exec *models.Executio
 
 	assetID, _ := uuid.FromString(asset.ID)
 
+	// remember when the funds used for the purchase settle, so the
+	// position isn't sold before then in a cash account
+	var fundsSettleDate *date.Date
+	if acct.CashAccount() {
+		d, err := goodfaith.Service().WithTx(tx).FundsSettleDate(
+			acct.IDAsUUID(),
+			tradingdate.TradeDate(exec.TransactionTime).Date(),
+			exec.Qty.Mul(*exec.Price))
+		if err != nil {
+			return err
+		}
+		fundsSettleDate = d
+	}
+
 	return tx.Create(&models.Position{
-		AccountID:      acct.ID,
-		EntryOrderID:   exec.OrderID,
-		Status:         models.Open,
-		Side:           models.Long,
-		AssetID:        assetID,
-		Qty:            *exec.Qty,
-		EntryPrice:     *exec.Price,
-		EntryTimestamp: exec.TransactionTime,
+		AccountID:       acct.ID,
+		EntryOrderID:    exec.OrderID,
+		Status:          models.Open,
+		Side:            models.Long,
+		AssetID:         assetID,
+		Qty:             *exec.Qty,
+		EntryPrice:      *exec.Price,
+		EntryTimestamp:  exec.TransactionTime,
+		FundsSettleDate: fundsSettleDate,
 	}).Error
 }
 
@@ -80,10 +155,19 @@ func handleSellFill(tx *gorm.DB, acct *models.TradeAccount, exec *models.Executi
 		asset.ID,
 		models.Long,
 		models.Open,
-	).Order("created_at").Find(&positions).Error; err != nil {
+	).Order("entry_timestamp, id").Find(&positions).Error; err != nil {
 		return
 	}
 
+	// the order may name specific lots to close before the
+	// account's lot relief method applies
+	order := &models.Order{}
+	if err = tx.Select("lot_ids").Where("id = ?", exec.OrderID).Find(order).Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		return
+	}
+
+	models.SortLots(positions, acct.LotMethod, order.LotIDs)
+
 	if len(positions) == 0 {
 		log.Error(
 			"received sell fill with no positions",
@@ -94,16 +178,31 @@ func handleSellFill(tx *gorm.DB, acct *models.TradeAccount, exec *models.Executi
 		return
 	}
 
+	tradeDate := tradingdate.TradeDate(exec.TransactionTime).Date()
+
+	// the latest settlement of the funds used to buy the closed shares,
+	// if any of them were sold before it
+	var unsettled *date.Date
+
 	for _, position := range positions {
-		switch {
 		// no more shares to process
-		case qtyToProcess.Equal(decimal.Zero):
-			return
+		if qtyToProcess.Equal(decimal.Zero) {
+			break
+		}
+
+		if position.FundsSettleDate != nil &&
+			position.FundsSettleDate.After(tradeDate.Date) &&
+			(unsettled == nil || position.FundsSettleDate.After(unsettled.Date)) {
+			unsettled = position.FundsSettleDate
+		}
+
+		switch {
 		// the quantity will completely close this position
 		case qtyToProcess.GreaterThanOrEqual(position.Qty):
 			position.ExitOrderID = &exec.OrderID
 			position.ExitPrice = exec.AvgPrice
 			position.ExitTimestamp = &exec.TransactionTime
+			position.GrossProfitLoss = grossProfitLoss(&position)
 			position.Status = models.Closed
 
 			qtyToProcess = qtyToProcess.Sub(position.Qty)
@@ -121,7 +220,28 @@ func handleSellFill(tx *gorm.DB, acct *models.TradeAccount, exec *models.Executi
 		}
 	}
 
-	return
+	if unsettled == nil || !acct.CashAccount() {
+		return
+	}
+
+	return goodfaith.Service().WithTx(tx).Record(&models.GoodFaithViolation{
+		AccountID:       acct.ID,
+		TradeDate:       tradeDate,
+		Source:          models.GoodFaithSourceFill,
+		OrderID:         &exec.OrderID,
+		Symbol:          &exec.Symbol,
+		FundsSettleDate: unsettled,
+	})
+}
+
+// grossProfitLoss is the realized profit or loss of a closed position,
+// before fees.
+func grossProfitLoss(p *models.Position) *decimal.Decimal {
+	if p.ExitPrice == nil {
+		return nil
+	}
+	pl := p.ExitPrice.Sub(p.EntryPrice).Mul(p.Qty)
+	return &pl
 }
 
 // splitPosition splits the position as needed when partial fills or
@@ -139,11 +259,14 @@ func splitPosition(
 		return nil, nil, fmt.Errorf("not enough qty for split (%v)", qty)
 	}
 
+	// both halves stay in the same tax lot
+	lotID := position.LotID()
+
 	// the closing position
 	p1 := &models.Position{
 		AccountID:          position.AccountID,
 		EntryOrderID:       position.EntryOrderID,
-		OriginalPositionID: position.OriginalPositionID,
+		OriginalPositionID: &lotID,
 		AssetID:            position.AssetID,
 		Side:               position.Side,
 		Qty:                qty,
@@ -153,6 +276,7 @@ func splitPosition(
 		ExitTimestamp:      &exec.TransactionTime,
 		ExitOrderID:        &exec.OrderID,
 		ExitPrice:          exec.AvgPrice,
+		FundsSettleDate:    position.FundsSettleDate,
 	}
 
 	// the split remaining position
@@ -160,14 +284,17 @@ func splitPosition(
 		Status:             models.Open,
 		AccountID:          position.AccountID,
 		EntryOrderID:       position.EntryOrderID,
-		OriginalPositionID: position.OriginalPositionID,
+		OriginalPositionID: &lotID,
 		AssetID:            position.AssetID,
 		Side:               position.Side,
 		Qty:                position.Qty.Sub(p1.Qty),
 		EntryPrice:         position.EntryPrice,
 		EntryTimestamp:     position.EntryTimestamp,
+		FundsSettleDate:    position.FundsSettleDate,
 	}
 
+	p1.GrossProfitLoss = grossProfitLoss(p1)
+
 	position.Status = models.Split
 
 	// store it all in the DB
@@ -6,8 +6,10 @@ import (
 	"github.com/alpacahq/gobroker/dbtest"
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
+	"github.com/gofrs/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -343,4 +345,109 @@ func (s *TradingTestSuite) TestTrading() {
 		db.DB().Where("account_id = ? AND status = ?", s.account.ID, models.Open).Find(&positions)
 		assert.Empty(s.T(), positions)
 	}
+
+	require.Nil(s.T(), db.DB().Exec("TRUNCATE TABLE positions").Error)
+
+	// sell naming a specific lot closes it first, and the split
+	// remainder stays in the same lot
+	{
+		for i := 0; i < 2; i++ {
+			order := s.genOrder(enum.Market, enum.Buy)
+			require.Nil(s.T(), ProcessExecution(db.DB(), s.account, s.genExecution(enum.ExecutionFill, enum.Buy, order)))
+		}
+
+		lots := []models.Position{}
+		require.Nil(s.T(), db.DB().Where("account_id = ? AND status = ?", s.account.ID, models.Open).Order("id").Find(&lots).Error)
+		require.Len(s.T(), lots, 2)
+
+		order := s.genOrder(enum.Market, enum.Sell)
+		order.LotIDs = []int64{int64(lots[1].ID)}
+		require.Nil(s.T(), db.DB().Save(order).Error)
+
+		require.Nil(s.T(), ProcessExecution(db.DB(), s.account, s.genExecution(enum.ExecutionPartialFill, enum.Sell, order)))
+
+		positions := []models.Position{}
+		db.DB().Where("account_id = ? AND status = ?", s.account.ID, models.Open).Order("id").Find(&positions)
+		require.Len(s.T(), positions, 2)
+		assert.Equal(s.T(), lots[0].ID, positions[0].ID)
+		assert.Equal(s.T(), lots[1].ID, positions[1].LotID())
+		assert.True(s.T(), positions[1].Qty.LessThan(lots[1].Qty))
+	}
+}
+
+func (s *TradingTestSuite) TestGoodFaithMargin() {
+	require.Nil(s.T(), db.DB().Exec("TRUNCATE TABLE positions").Error)
+
+	margin := *s.account
+	margin.Margin = true
+
+	// sells a lot bought with funds that haven't settled yet
+	sellUnsettled := func(acct *models.TradeAccount) int {
+		settles := date.DateOf(clock.Now().AddDate(0, 0, 2))
+		order := s.genOrder(enum.Market, enum.Buy)
+		require.Nil(s.T(), db.DB().Create(&models.Position{
+			AccountID:       acct.ID,
+			EntryOrderID:    order.ID,
+			Status:          models.Open,
+			Side:            models.Long,
+			AssetID:         uuid.FromStringOrNil(s.asset.ID),
+			Qty:             order.Qty,
+			EntryPrice:      decimal.NewFromFloat(100),
+			EntryTimestamp:  clock.Now(),
+			FundsSettleDate: &settles,
+		}).Error)
+
+		require.Nil(s.T(), ProcessExecution(db.DB(), acct, s.genExecution(enum.ExecutionFill, enum.Sell, order)))
+
+		count := 0
+		require.Nil(s.T(), db.DB().Model(&models.GoodFaithViolation{}).Where("account_id = ?", acct.ID).Count(&count).Error)
+		return count
+	}
+
+	// margin accounts aren't subject to good faith violations
+	assert.Equal(s.T(), 0, sellUnsettled(&margin))
+	assert.Equal(s.T(), 1, sellUnsettled(s.account))
+}
+
+func (s *TradingTestSuite) TestFees() {
+	require.Nil(s.T(), db.DB().Exec("TRUNCATE TABLE positions").Error)
+
+	buy := s.genOrder(enum.Market, enum.Buy)
+	require.Nil(s.T(), ProcessExecution(db.DB(), s.account, s.genExecution(enum.ExecutionFill, enum.Buy, buy)))
+
+	// sold in 4 partial fills of 25 @ $102.22
+	order := s.genOrder(enum.Limit, enum.Sell)
+	fills := []*models.Execution{}
+	for i := 0; i < 4; i++ {
+		exec := s.genExecution(enum.ExecutionPartialFill, enum.Sell, order)
+		require.Nil(s.T(), ProcessExecution(db.DB(), s.account, exec))
+		fills = append(fills, exec)
+	}
+
+	charged := decimal.Zero
+	for _, exec := range fills {
+		journal := &models.Journal{}
+		q := db.DB().
+			Where("type = ? AND reference_id = ?", enum.JournalFee, exec.ID).
+			Preload("Entries").
+			Find(journal)
+		if q.RecordNotFound() {
+			continue
+		}
+		require.Nil(s.T(), q.Error)
+
+		for _, entry := range journal.Entries {
+			if entry.Ledger == enum.LedgerCash {
+				charged = charged.Sub(entry.Amount)
+			}
+		}
+	}
+
+	// the fees are charged once on the whole trade, rounded up to
+	// $0.14 SEC and $0.02 TAF, rather than on each fill
+	assert.Equal(s.T(), "0.16", charged.String())
+
+	require.Nil(s.T(), db.DB().Where("id = ?", order.ID).First(order).Error)
+	require.NotNil(s.T(), order.Fee)
+	assert.Equal(s.T(), "0.16", order.Fee.String())
 }
@@ -27,8 +27,17 @@ func Initialize() {
 	env.RegisterDefault("ALE_TIMEOUT", "10s")
 	env.RegisterDefault("FUNDING_WORKER_INTERVAL", "1m")
 	env.RegisterDefault("BRAGGART_WORKER_INTERVAL", "5s")
+	env.RegisterDefault("EQUITY_WORKER_INTERVAL", "5m")
+	env.RegisterDefault("ALERT_WORKER_INTERVAL", "10s")
+	env.RegisterDefault("ORDER_RELEASE_WORKER_INTERVAL", "5s")
+	env.RegisterDefault("ORDER_RELEASE_BATCH", "100")
+	env.RegisterDefault("ORDER_RELEASE_LEAD", "1m")
+	env.RegisterDefault("ALGO_WORKER_INTERVAL", "30s")
+	env.RegisterDefault("ALGO_PROFILE_DAYS", "10")
 	env.RegisterDefault("PAPER_DB", "papertrader")
 	env.RegisterDefault("STANDBY_MODE", "FALSE")
+	// local market data directory, instead of marketstore and polycache
+	env.RegisterDefault("MARKET_DATA_DIR", "")
 
 	// Cognito (development pool)
 	env.RegisterDefault("COGNITO_REGION", "us-east-1")
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 )
@@ -43,6 +44,40 @@ func (t TradingDate) SessionClose() time.Time {
 	return mo
 }
 
+// PreMarketHours and AfterHoursHours are how long the extended sessions
+// run before the open and after the close.
+const (
+	PreMarketHours  = 5*time.Hour + 30*time.Minute
+	AfterHoursHours = 4 * time.Hour
+)
+
+// PreMarketOpen returns the start of the pre-market session, 4:00 ET
+func (t TradingDate) PreMarketOpen() time.Time {
+	return t.MarketOpen().Add(-PreMarketHours)
+}
+
+// AfterHoursClose returns the end of the after-hours session, 20:00 ET,
+// or 17:00 ET on early close days
+func (t TradingDate) AfterHoursClose() time.Time {
+	return t.MarketClose().Add(AfterHoursHours)
+}
+
+// SessionAt returns the trading session at t on this trading date
+func (t TradingDate) SessionAt(at time.Time) Session {
+	switch {
+	case at.Before(t.PreMarketOpen()):
+		return Closed
+	case at.Before(t.MarketOpen()):
+		return PreMarket
+	case at.Before(t.MarketClose()):
+		return Regular
+	case at.Before(t.AfterHoursClose()):
+		return AfterHours
+	default:
+		return Closed
+	}
+}
+
 // Prev returns previous trading date
 func (t TradingDate) Prev() TradingDate {
 	return TradingDate{timestamp: calendar.PrevClose(t.timestamp)}
@@ -65,6 +100,29 @@ func (t TradingDate) Equals(a TradingDate) bool {
 	return t.timestamp.Equal(a.timestamp)
 }
 
+// DaysLater returns N trading days after
+func (t TradingDate) DaysLater(days int) TradingDate {
+	current := t
+	for i := 0; i < days; i++ {
+		current = current.Next()
+	}
+	return current
+}
+
+// SettlementDays is the number of trading days equity trades
+// take to settle (T+2)
+const SettlementDays = 2
+
+// Settlement returns the date trades made on this trading date settle
+func (t TradingDate) Settlement() TradingDate {
+	return t.DaysLater(SettlementDays)
+}
+
+// Date returns the calendar date of the trading date
+func (t TradingDate) Date() date.Date {
+	return date.DateOf(t.timestamp.In(calendar.NY))
+}
+
 // DaysAgo returns N days ago trading day
 func (t TradingDate) DaysAgo(days int) TradingDate {
 	i := 0
@@ -109,6 +167,41 @@ func NewFromDate(y int, m time.Month, d int) (*TradingDate, error) {
 	return nil, fmt.Errorf("no trading day")
 }
 
+// TradeDate returns the trading date activity at t is booked on. Unlike
+// Last, anything on a market day belongs to that day regardless of the
+// session, and anything on a closed day rolls forward to the next one.
+func TradeDate(t time.Time) TradingDate {
+	if d, err := New(t.In(calendar.NY)); err == nil {
+		return *d
+	}
+	return Last(t).Next()
+}
+
+// Session is a part of the trading day orders can execute in.
+type Session string
+
+const (
+	PreMarket  Session = "pre_market"
+	Regular    Session = "regular"
+	AfterHours Session = "after_hours"
+	Closed     Session = "closed"
+)
+
+// Extended returns true for the pre-market and after-hours sessions
+func (s Session) Extended() bool {
+	return s == PreMarket || s == AfterHours
+}
+
+// SessionAt returns the trading session at t, which is Closed all day
+// on days the market doesn't open.
+func SessionAt(t time.Time) Session {
+	d, err := New(t.In(calendar.NY))
+	if err != nil {
+		return Closed
+	}
+	return d.SessionAt(t)
+}
+
 // Current returns current trading date. Trading date changes on the time of market open.
 func Current() TradingDate {
 	t := clock.Now().In(calendar.NY)
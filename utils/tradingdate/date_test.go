@@ -89,3 +89,54 @@ func (s *TradingDateTestSuite) TestPrev() {
 	assert.Equal(s.T(), t.String(), "2018-01-22")
 	assert.Equal(s.T(), t.Prev().String(), "2018-01-19")
 }
+
+func (s *TradingDateTestSuite) TestTradeDate() {
+	// pre-market on a trading day
+	d := time.Date(2018, 1, 25, 8, 0, 0, 0, calendar.NY)
+	assert.Equal(s.T(), "2018-01-25", TradeDate(d).String())
+
+	// after hours
+	d = time.Date(2018, 1, 25, 18, 0, 0, 0, calendar.NY)
+	assert.Equal(s.T(), "2018-01-25", TradeDate(d).String())
+
+	// saturday rolls to monday
+	d = time.Date(2018, 1, 20, 12, 0, 0, 0, calendar.NY)
+	assert.Equal(s.T(), "2018-01-22", TradeDate(d).String())
+}
+
+func (s *TradingDateTestSuite) TestSettlement() {
+	// thursday settles on monday
+	t, _ := NewFromDate(2018, 1, 25)
+	assert.Equal(s.T(), "2018-01-29", t.Settlement().String())
+	assert.Equal(s.T(), 29, t.Settlement().Date().Day)
+
+	// over a holiday (presidents day)
+	t, _ = NewFromDate(2018, 2, 16)
+	assert.Equal(s.T(), "2018-02-21", t.Settlement().String())
+}
+
+func (s *TradingDateTestSuite) TestSessionAt() {
+	for _, c := range []struct {
+		at      time.Time
+		session Session
+	}{
+		{time.Date(2018, 1, 25, 3, 59, 0, 0, calendar.NY), Closed},
+		{time.Date(2018, 1, 25, 4, 0, 0, 0, calendar.NY), PreMarket},
+		{time.Date(2018, 1, 25, 9, 29, 0, 0, calendar.NY), PreMarket},
+		{time.Date(2018, 1, 25, 9, 30, 0, 0, calendar.NY), Regular},
+		{time.Date(2018, 1, 25, 16, 0, 0, 0, calendar.NY), AfterHours},
+		{time.Date(2018, 1, 25, 19, 59, 0, 0, calendar.NY), AfterHours},
+		{time.Date(2018, 1, 25, 20, 0, 0, 0, calendar.NY), Closed},
+		// saturday
+		{time.Date(2018, 1, 20, 12, 0, 0, 0, calendar.NY), Closed},
+		// early close the day after thanksgiving
+		{time.Date(2018, 11, 23, 13, 0, 0, 0, calendar.NY), AfterHours},
+		{time.Date(2018, 11, 23, 17, 0, 0, 0, calendar.NY), Closed},
+	} {
+		assert.Equal(s.T(), c.session, SessionAt(c.at), c.at.String())
+	}
+
+	assert.True(s.T(), PreMarket.Extended())
+	assert.True(s.T(), AfterHours.Extended())
+	assert.False(s.T(), Regular.Extended())
+}
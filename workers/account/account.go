@@ -18,8 +18,6 @@ import (
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/alpacahq/gobroker/workers/account/form"
 	"github.com/alpacahq/gobroker/workers/common"
-	"github.com/alpacahq/gopaca/calendar"
-	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/alpacahq/gopaca/log"
 	"github.com/alpacahq/gopaca/rmq/pubsub"
@@ -163,22 +161,6 @@ func (w *accountWorker) processAccount(tx *gorm.DB, id uuid.UUID) (err error) {
 		return err
 	}
 
-	if acct.MarkedRiskyTransfersAt != nil {
-		markedAt := acct.MarkedRiskyTransfersAt.In(calendar.NY)
-		// if markedAt is later than 90 days, de-flag the account
-		if (clock.Now().Sub(markedAt).Hours() / 24) > 90 {
-			updates := map[string]interface{}{
-				"risky_transfers":           false,
-				"marked_risky_transfers_at": nil,
-			}
-			if err = tx.Model(acct).Updates(updates).Error; err != nil {
-				tx.Rollback()
-				log.Error("error updating transfers blocked to false", "error", err, "acct", acct.ID)
-				return err
-			}
-		}
-	}
-
 	switch acct.ApexApprovalStatus {
 	case enum.ActionRequired:
 	case enum.Suspended:
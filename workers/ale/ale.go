@@ -12,14 +12,21 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/ledger"
+	"github.com/alpacahq/gobroker/service/recurring"
+	"github.com/alpacahq/gobroker/service/relationship"
+	"github.com/alpacahq/gobroker/service/transfer"
 	"github.com/alpacahq/gobroker/utils"
+	"github.com/alpacahq/gobroker/utils/date"
 	"github.com/alpacahq/gobroker/workers/common"
+	"github.com/alpacahq/gopaca/calendar"
 	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/alpacahq/gopaca/env"
 	"github.com/alpacahq/gopaca/log"
 	"github.com/gofrs/uuid"
 	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
 	try "gopkg.in/matryer/try.v1"
 )
 
@@ -29,6 +36,7 @@ type aleWorker struct {
 	apexGetSketch      func(id string) (*apex.GetSketchInvestigationResponse, error)
 	apexGetRel         func(id string) (*apex.GetRelationshipResponse, error)
 	apexTransferStatus func(id string) (*apex.TransferStatusResponse, error)
+	cancelRelationship func(tx *gorm.DB, accountID uuid.UUID, relID string) error
 	done               chan struct{}
 }
 
@@ -54,7 +62,10 @@ func Work() {
 			},
 			apexGetRel:         apex.Client().GetRelationship,
 			apexTransferStatus: apex.Client().TransferStatus,
-			done:               make(chan struct{}, 1),
+			cancelRelationship: func(tx *gorm.DB, accountID uuid.UUID, relID string) error {
+				return relationship.Service().WithTx(tx).Cancel(accountID, relID)
+			},
+			done: make(chan struct{}, 1),
 		}
 		worker.done <- struct{}{}
 	}
@@ -379,7 +390,15 @@ func (w *aleWorker) transferUpdateHandler(tx *gorm.DB, msg apex.ALEMessage) erro
 			transfer.ReasonCode = *update.Reason
 		}
 
-		return tx.Save(transfer).Error
+		if err = tx.Save(transfer).Error; err != nil {
+			return err
+		}
+
+		if transfer.Status == enum.TransferReturned {
+			return w.handleReturn(tx, transfer)
+		}
+
+		return nil
 	}
 
 	var (
@@ -409,8 +428,177 @@ func (w *aleWorker) transferUpdateHandler(tx *gorm.DB, msg apex.ALEMessage) erro
 		"status_new", status,
 		"reason", reason)
 
-	return tx.Model(transfer).Update(
-		"status", status).Error
+	returned := status == apex.TransferReturned &&
+		transfer.Status != enum.TransferReturned
+
+	if err = tx.Model(transfer).Update(
+		"status", status).Error; err != nil {
+		return err
+	}
+
+	if returned {
+		return w.handleReturn(tx, transfer)
+	}
+
+	return nil
+}
+
+// handleReturn applies the policy for the transfer's NACHA return
+// code, reverses the cash credited for a returned deposit, and lets
+// the account owner know what happened.
+func (w *aleWorker) handleReturn(tx *gorm.DB, xfer *models.Transfer) error {
+	if xfer.RecurringTransferID != nil {
+		if err := w.pauseRecurring(tx, xfer, xfer.Reason); err != nil {
+			return err
+		}
+	}
+
+	srv := account.Service().WithTx(tx)
+	srv.SetForUpdate()
+
+	acct, err := srv.GetByID(xfer.AccountIDAsUUID())
+	if err != nil {
+		return err
+	}
+
+	notice := mailer.TransferReturnNotice{
+		Direction: xfer.Direction,
+		Amount:    xfer.Amount,
+		Reason:    xfer.Reason,
+	}
+
+	patches := map[string]interface{}{}
+
+	// the deposit was already credited by the clearing broker, so
+	// take back the buying power until the next cash sync reflects
+	// the return
+	if xfer.Direction == apex.Incoming && xfer.BatchProcessedAt != nil {
+		patches["cash"] = acct.Cash.Sub(xfer.Amount)
+		patches["cash_withdrawable"] = decimal.Max(acct.CashWithdrawable.Sub(xfer.Amount), decimal.Zero)
+		notice.Reversed = true
+
+		if err = ledger.Service().WithTx(tx).PostTransferReturn(xfer, date.DateOf(clock.Now().In(calendar.NY))); err != nil {
+			return err
+		}
+	}
+
+	for _, action := range returnPolicies[xfer.ReasonCode] {
+		switch action {
+		case blockDeposits:
+			until := clock.Now().Add(depositBlockPeriod)
+			patches["deposits_blocked_until"] = until
+			notice.DepositsBlockedUntil = &until
+		case freezeWithdrawals:
+			patches["withdrawals_blocked"] = true
+			notice.WithdrawalsBlocked = true
+		case cancelRelationship:
+			if xfer.RelationshipID == nil {
+				continue
+			}
+
+			if err = w.cancelRelationship(tx, acct.IDAsUUID(), *xfer.RelationshipID); err != nil {
+				// don't hold up the rest of the ALE messages, it
+				// can be canceled manually
+				log.Error(
+					"ale worker failed to cancel relationship for returned transfer",
+					"transfer", xfer.ID,
+					"relationship", *xfer.RelationshipID,
+					"error", err)
+				continue
+			}
+
+			notice.RelationshipCanceled = true
+		}
+	}
+
+	if len(patches) > 0 {
+		if acct, err = srv.PatchInternal(acct.IDAsUUID(), patches); err != nil {
+			return err
+		}
+	}
+
+	log.Info(
+		"ale worker handled returned transfer",
+		"transfer", xfer.ID,
+		"account", acct.ID,
+		"reason_code", xfer.ReasonCode,
+		"actions", returnPolicies[xfer.ReasonCode])
+
+	// unauthorized returns need to be reviewed
+	if notice.WithdrawalsBlocked {
+		msg := slack.NewFundingActivity()
+
+		msg.SetBody(struct {
+			Type        string `json:"type"`
+			ApexAccount string `json:"apex_account"`
+			Transfer    string `json:"transfer"`
+			ReasonCode  string `json:"reason_code"`
+			Reason      string `json:"reason"`
+		}{
+			"account_flagged_for_review",
+			*acct.ApexAccount,
+			xfer.ID,
+			xfer.ReasonCode,
+			xfer.Reason,
+		})
+
+		slack.Notify(msg)
+	}
+
+	o := acct.PrimaryOwner()
+
+	go mailer.SendTransferReturned(
+		*acct.ApexAccount,
+		*o.Details.GivenName,
+		o.Email,
+		notice,
+	)
+
+	return nil
+}
+
+// pauseRecurring stops the schedule a returned transfer was created
+// by, so that it doesn't keep pulling from a bad bank account.
+func (w *aleWorker) pauseRecurring(tx *gorm.DB, xfer *models.Transfer, reason string) error {
+	srv := recurring.Service(transfer.Service()).WithTx(tx)
+
+	rt, err := srv.GetByID(xfer.AccountIDAsUUID(), *xfer.RecurringTransferID)
+	if err != nil {
+		return err
+	}
+
+	if rt.Status != enum.RecurringActive {
+		log.Info(
+			"ale worker skipped pausing inactive recurring transfer",
+			"transfer", xfer.ID,
+			"recurring_transfer", rt.ID,
+			"status", rt.Status)
+		return nil
+	}
+
+	if rt, err = srv.Pause(
+		xfer.AccountIDAsUUID(),
+		rt.ID,
+		fmt.Sprintf("transfer returned: %v", reason)); err != nil {
+		return err
+	}
+
+	acct, err := account.Service().WithTx(tx).GetByID(xfer.AccountIDAsUUID())
+	if err != nil {
+		return err
+	}
+
+	o := acct.PrimaryOwner()
+
+	go mailer.SendRecurringTransferPaused(
+		*acct.ApexAccount,
+		*o.Details.GivenName,
+		o.Email,
+		rt.Amount,
+		reason,
+	)
+
+	return nil
 }
 
 func (w *aleWorker) microUpdateHandler(tx *gorm.DB, msg apex.ALEMessage) error {
@@ -12,8 +12,10 @@ import (
 	"github.com/alpacahq/gobroker/service/account"
 	"github.com/alpacahq/gobroker/service/relationship"
 	"github.com/alpacahq/gobroker/service/transfer"
+	"github.com/alpacahq/gopaca/clock"
 	"github.com/alpacahq/gopaca/db"
 	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -336,3 +338,111 @@ func (s *ALEWorkerTestSuite) TestALEWorker() {
 		assert.Nil(s.T(), err)
 	}
 }
+
+func (s *ALEWorkerTestSuite) TestTransferReturn() {
+	amt := decimal.NewFromFloat(1000)
+	apexAcct := "apca_return"
+	givenName := "First"
+	acct := &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		Cash:               amt,
+		CashWithdrawable:   amt,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			models.Owner{
+				Email:   "returned@test.db",
+				Primary: true,
+				Details: models.OwnerDetails{
+					GivenName: &givenName,
+				},
+			},
+		},
+	}
+	require.Nil(s.T(), db.DB().Create(acct).Error)
+
+	relID := uuid.Must(uuid.NewV4()).String()
+	canceled := []string{}
+
+	w := &aleWorker{
+		cancelRelationship: func(tx *gorm.DB, accountID uuid.UUID, id string) error {
+			canceled = append(canceled, id)
+			return nil
+		},
+	}
+
+	acctSrv := account.Service().WithTx(db.DB())
+
+	// insufficient funds - deposits blocked, credited cash reversed
+	{
+		processed := clock.Now().Format("2006-01-02")
+		xfer := &models.Transfer{
+			AccountID:        acct.ID,
+			RelationshipID:   &relID,
+			Type:             enum.ACH,
+			Direction:        apex.Incoming,
+			Status:           enum.TransferReturned,
+			Amount:           decimal.NewFromFloat(400),
+			ReasonCode:       "R01",
+			Reason:           "Insufficient Funds",
+			BatchProcessedAt: &processed,
+		}
+		require.Nil(s.T(), db.DB().Create(xfer).Error)
+
+		require.Nil(s.T(), w.handleReturn(db.DB(), xfer))
+
+		acct, err := acctSrv.GetByID(acct.IDAsUUID())
+		require.Nil(s.T(), err)
+		assert.True(s.T(), acct.Cash.Equal(decimal.NewFromFloat(600)))
+		assert.True(s.T(), acct.CashWithdrawable.Equal(decimal.NewFromFloat(600)))
+		require.NotNil(s.T(), acct.DepositsBlockedUntil)
+		assert.True(s.T(), acct.DepositsBlockedUntil.After(clock.Now()))
+		assert.False(s.T(), acct.WithdrawalsBlocked)
+		assert.Empty(s.T(), canceled)
+	}
+
+	// account closed - relationship canceled
+	{
+		xfer := &models.Transfer{
+			AccountID:      acct.ID,
+			RelationshipID: &relID,
+			Type:           enum.ACH,
+			Direction:      apex.Outgoing,
+			Status:         enum.TransferReturned,
+			Amount:         decimal.NewFromFloat(100),
+			ReasonCode:     "R02",
+			Reason:         "Account Closed",
+		}
+		require.Nil(s.T(), db.DB().Create(xfer).Error)
+
+		require.Nil(s.T(), w.handleReturn(db.DB(), xfer))
+		assert.Equal(s.T(), []string{relID}, canceled)
+
+		acct, err := acctSrv.GetByID(acct.IDAsUUID())
+		require.Nil(s.T(), err)
+		assert.True(s.T(), acct.Cash.Equal(decimal.NewFromFloat(600)))
+	}
+
+	// unauthorized - withdrawals frozen
+	{
+		xfer := &models.Transfer{
+			AccountID:      acct.ID,
+			RelationshipID: &relID,
+			Type:           enum.ACH,
+			Direction:      apex.Incoming,
+			Status:         enum.TransferReturned,
+			Amount:         decimal.NewFromFloat(100),
+			ReasonCode:     "R10",
+			Reason:         "Customer Advises Not Authorized",
+		}
+		require.Nil(s.T(), db.DB().Create(xfer).Error)
+
+		require.Nil(s.T(), w.handleReturn(db.DB(), xfer))
+
+		acct, err := acctSrv.GetByID(acct.IDAsUUID())
+		require.Nil(s.T(), err)
+		assert.True(s.T(), acct.WithdrawalsBlocked)
+		// not yet credited, so nothing to reverse
+		assert.True(s.T(), acct.Cash.Equal(decimal.NewFromFloat(600)))
+	}
+}
@@ -3,6 +3,7 @@ package ale
 import (
 	"log"
 
+	"github.com/alpacahq/gopaca/calendar"
 	"github.com/gocarina/gocsv"
 )
 
@@ -20,6 +21,37 @@ func init() {
 
 var nachaCodes map[string]string
 
+// returnAction is a restriction applied to an account when one
+// of its ACH transfers is returned.
+type returnAction string
+
+const (
+	// block new deposits for depositBlockPeriod
+	blockDeposits returnAction = "block_deposits"
+	// cancel the relationship the transfer was made with
+	cancelRelationship returnAction = "cancel_relationship"
+	// freeze withdrawals and flag the account for review
+	freezeWithdrawals returnAction = "freeze_withdrawals"
+)
+
+var depositBlockPeriod = 30 * calendar.Day
+
+// returnPolicies maps the NACHA return codes to the actions to take,
+// any code not listed only has the transfer marked as returned.
+var returnPolicies = map[string][]returnAction{
+	// insufficient / uncollected funds
+	"R01": {blockDeposits},
+	"R09": {blockDeposits},
+	// account closed, missing or invalid
+	"R02": {cancelRelationship},
+	"R03": {cancelRelationship},
+	"R04": {cancelRelationship},
+	// unauthorized
+	"R05": {freezeWithdrawals},
+	"R07": {freezeWithdrawals},
+	"R10": {freezeWithdrawals},
+}
+
 type NachaCode struct {
 	Code           string `csv:"Code"`
 	SenDescription string `csv:"Sentinel Text Description"`
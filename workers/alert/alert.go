@@ -0,0 +1,191 @@
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/gobroker/external/webhook"
+	"github.com/alpacahq/gobroker/gbreg"
+	"github.com/alpacahq/gobroker/mailer"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/stream"
+	"github.com/alpacahq/gobroker/workers/common"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+	"github.com/shopspring/decimal"
+)
+
+// EventAlertTriggered is sent on account_updates when a price alert
+// fires
+const EventAlertTriggered = "alert_triggered"
+
+type alertWorker struct {
+	done chan struct{}
+}
+
+var worker *alertWorker
+
+// Work checks the active alerts against the latest trades while the
+// market is open, and delivers the ones which fired over the stream,
+// email and their webhook.
+func Work() {
+	if worker == nil {
+		worker = &alertWorker{done: make(chan struct{}, 1)}
+		worker.done <- struct{}{}
+	}
+
+	// make sure not to overlap if the work routine is taking long
+	if common.WaitTimeout(worker.done, time.Second) {
+		return
+	}
+
+	defer func() {
+		worker.done <- struct{}{}
+	}()
+
+	if !calendar.IsMarketOpen(clock.Now().In(calendar.NY)) {
+		return
+	}
+
+	alerts, err := gbreg.Services.Alert().WithTx(db.DB()).Active()
+	if err != nil {
+		log.Error("alert worker database error", "error", err)
+		return
+	}
+
+	if len(alerts) == 0 {
+		return
+	}
+
+	symbols := []string{}
+	seen := map[string]bool{}
+
+	// only pct_change alerts need the previous close
+	pctChange := []string{}
+	seenPctChange := map[string]bool{}
+
+	for _, a := range alerts {
+		if !seen[a.Symbol] {
+			seen[a.Symbol] = true
+			symbols = append(symbols, a.Symbol)
+		}
+
+		if a.Type == enum.AlertPctChange && !seenPctChange[a.Symbol] {
+			seenPctChange[a.Symbol] = true
+			pctChange = append(pctChange, a.Symbol)
+		}
+	}
+
+	md := gbreg.Services.MarketData()
+
+	trades, err := md.LastTrades(symbols)
+	if err != nil {
+		log.Error("alert worker market data error", "error", err)
+		return
+	}
+
+	closes, err := md.LastClose(pctChange, nil)
+	if err != nil {
+		log.Error("alert worker market data error", "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range alerts {
+		a := &alerts[i]
+
+		trade, ok := trades[a.Symbol]
+		if !ok {
+			continue
+		}
+
+		px := decimal.NewFromFloat(trade.Price)
+		prevClose := decimal.Zero
+
+		if c, ok := closes[a.Symbol]; ok {
+			prevClose = decimal.NewFromFloat(c.Price)
+		}
+
+		if !a.Crossed(px, prevClose) {
+			continue
+		}
+
+		fired, err := gbreg.Services.Alert().WithTx(db.DB()).Trigger(a, px)
+		if err != nil {
+			log.Error("failed to trigger alert", "alert", a.ID, "error", err)
+			continue
+		}
+
+		if !fired {
+			continue
+		}
+
+		log.Info("alert triggered", "alert", a.ID, "account", a.AccountID, "symbol", a.Symbol, "price", px)
+
+		// a slow webhook shouldn't hold up the other alerts
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			deliver(a)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func deliver(a *models.Alert) {
+	update := map[string]interface{}{
+		"event":     EventAlertTriggered,
+		"alert":     a,
+		"timestamp": clock.Now(),
+	}
+
+	if err := stream.Publish(stream.OutboundMessage{
+		Stream: stream.AccountUpdatesStream(a.AccountIDAsUUID()),
+		Data:   update,
+	}); err != nil {
+		log.Error("failed to publish alert", "alert", a.ID, "error", err)
+	}
+
+	if a.WebhookURL != nil {
+		if a.WebhookSecret == nil {
+			log.Warn("alert webhook has no secret to sign with", "alert", a.ID)
+		} else if err := webhook.Post(*a.WebhookURL, *a.WebhookSecret, update); err != nil {
+			log.Warn("failed to deliver alert webhook", "alert", a.ID, "error", err)
+		}
+	}
+
+	acct, err := account.Service().WithTx(db.DB()).GetByID(a.AccountIDAsUUID())
+	if err != nil {
+		log.Error("alert worker database error", "account", a.AccountID, "error", err)
+		return
+	}
+
+	owner := acct.PrimaryOwner()
+	if owner == nil || owner.Details.GivenName == nil {
+		return
+	}
+
+	mailer.SendPriceAlert(a.AccountID, *owner.Details.GivenName, owner.Email, a.Symbol, condition(a), *a.TriggeredPrice)
+}
+
+// condition describes what the alert was waiting for.
+func condition(a *models.Alert) string {
+	switch a.Type {
+	case enum.AlertAbove:
+		return fmt.Sprintf("%v rose to $%v or above", a.Symbol, a.Price.StringFixed(2))
+	case enum.AlertBelow:
+		return fmt.Sprintf("%v fell to $%v or below", a.Symbol, a.Price.StringFixed(2))
+	default:
+		if a.Percent.IsNegative() {
+			return fmt.Sprintf("%v is down %v%% or more today", a.Symbol, a.Percent.Abs().String())
+		}
+		return fmt.Sprintf("%v is up %v%% or more today", a.Symbol, a.Percent.String())
+	}
+}
@@ -0,0 +1,48 @@
+package algo
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/gbreg"
+	"github.com/alpacahq/gobroker/workers/common"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+)
+
+type algoWorker struct {
+	done chan struct{}
+}
+
+var worker *algoWorker
+
+// Work sends the child orders which are due for the working algo
+// orders, and expires the ones whose window is over.
+func Work() {
+	if worker == nil {
+		worker = &algoWorker{done: make(chan struct{}, 1)}
+		worker.done <- struct{}{}
+	}
+
+	// make sure not to overlap if the work routine is taking long
+	if common.WaitTimeout(worker.done, time.Second) {
+		return
+	}
+
+	defer func() {
+		worker.done <- struct{}{}
+	}()
+
+	orders, err := gbreg.Services.Algo().WithTx(db.DB()).Working()
+	if err != nil {
+		log.Error("algo worker database error", "error", err)
+		return
+	}
+
+	for i := range orders {
+		o := &orders[i]
+
+		if err := gbreg.Services.Algo().WithTx(db.DB()).Work(o); err != nil {
+			log.Error("failed to work algo order", "order", o.ID, "symbol", o.Symbol, "error", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package equity
+
+import (
+	"time"
+
+	"github.com/alpacahq/gobroker/gbreg"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gobroker/workers/common"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+	"github.com/pkg/errors"
+)
+
+// Resolution is the interval snapshots are bucketed into, the same as
+// the intraday portfolio history.
+const Resolution = 5 * time.Minute
+
+type equityWorker struct {
+	done chan struct{}
+}
+
+var worker *equityWorker
+
+// Work snapshots the equity of every active account while the market
+// is open. Snapshots are keyed by the start of their 5 minute bucket,
+// so a run in the same bucket updates the snapshot rather than adding
+// another one.
+func Work() {
+	if worker == nil {
+		worker = &equityWorker{done: make(chan struct{}, 1)}
+		worker.done <- struct{}{}
+	}
+
+	// make sure not to overlap if the work routine is taking long
+	if common.WaitTimeout(worker.done, time.Second) {
+		return
+	}
+
+	defer func() {
+		worker.done <- struct{}{}
+	}()
+
+	now := clock.Now().In(calendar.NY)
+
+	if !calendar.IsMarketOpen(now) {
+		return
+	}
+
+	today, err := tradingdate.New(now)
+	if err != nil {
+		log.Error("equity worker error", "error", err)
+		return
+	}
+
+	accounts := []models.Account{}
+
+	if err := db.DB().
+		Where("status = ? AND apex_account IS NOT NULL", enum.Active).
+		Find(&accounts).Error; err != nil {
+		log.Error("equity worker database error", "error", err)
+		return
+	}
+
+	at := now.Truncate(Resolution)
+
+	for i := range accounts {
+		if err := worker.snapshot(&accounts[i], *today, at); err != nil {
+			log.Error("failed to snapshot equity", "account", accounts[i].ID, "error", err)
+		}
+	}
+}
+
+func (w *equityWorker) snapshot(acct *models.Account, today tradingdate.TradingDate, at time.Time) error {
+	tx := db.RepeatableRead()
+
+	balances, err := gbreg.Services.Account().WithTx(tx).GetBalancesByID(acct.IDAsUUID(), today.MarketOpen())
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to get balances")
+	}
+
+	// the market value at the close of today is at the latest price
+	// while the market is open
+	value, err := gbreg.Services.Position().WithTx(tx).MarketValueAt(acct.IDAsUUID(), today)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to calculate market value")
+	}
+
+	snapshot := models.EquitySnapshot{
+		AccountID: acct.ID,
+		Time:      at,
+		Equity:    balances.Cash.Add(value),
+	}
+
+	if err = tx.Save(&snapshot).Error; err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "database error")
+	}
+
+	return tx.Commit().Error
+}
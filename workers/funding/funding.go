@@ -267,6 +267,16 @@ func (w *fundingWorker) handleTransfer(transfer *models.Transfer) {
 		return
 	}
 
+	// approved wires are sent by operations, so hand them off
+	if transfer.Type == enum.Wire {
+		if acct.Fundable() {
+			w.handleWire(tx, acct, transfer)
+		} else {
+			tx.Rollback()
+		}
+		return
+	}
+
 	// ready to send to apex
 	if acct.Fundable() &&
 		transfer.Relationship != nil &&
@@ -382,6 +392,73 @@ func (w *fundingWorker) handleTransfer(transfer *models.Transfer) {
 	tx.Rollback()
 }
 
+// handleWire releases an approved outbound wire to operations to be
+// sent. It is marked complete once it shows up in the cash activity
+// file from the clearing broker.
+func (w *fundingWorker) handleWire(tx *gorm.DB, acct *models.Account, transfer *models.Transfer) {
+	b := &models.WireBeneficiary{}
+
+	if transfer.BeneficiaryID == nil {
+		tx.Rollback()
+		log.Error("wire transfer missing beneficiary", "transfer", transfer.ID)
+		return
+	}
+
+	if err := tx.Where("id = ?", *transfer.BeneficiaryID).First(b).Error; err != nil {
+		tx.Rollback()
+		log.Error(
+			"failed to retrieve wire beneficiary",
+			"transfer", transfer.ID,
+			"beneficiary", *transfer.BeneficiaryID,
+			"error", err)
+		return
+	}
+
+	if err := tx.
+		Model(transfer).
+		Update("status", enum.TransferPending).Error; err != nil {
+
+		tx.Rollback()
+		log.Error(
+			"failed to release wire transfer",
+			"transfer", transfer.ID,
+			"error", err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Error("funding worker database error", "error", err)
+		return
+	}
+
+	// notify via slack
+	{
+		msg := slack.NewFundingActivity()
+
+		msg.SetBody(struct {
+			Type        string `json:"type"`
+			TransferID  string `json:"transfer_id"`
+			ApexAccount string `json:"apex_account"`
+			Name        string `json:"name"`
+			Email       string `json:"email"`
+			Amount      string `json:"amount"`
+			Bank        string `json:"bank"`
+			Mask        string `json:"mask"`
+		}{
+			"wire_ready_to_send",
+			transfer.ID,
+			*acct.ApexAccount,
+			*acct.PrimaryOwner().Details.LegalName,
+			acct.PrimaryOwner().Email,
+			transfer.Amount.String(),
+			b.BankName,
+			b.Mask,
+		})
+
+		slack.Notify(msg)
+	}
+}
+
 // Handles balance errors
 func handleBalanceError(
 	tx *gorm.DB,
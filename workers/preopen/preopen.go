@@ -0,0 +1,256 @@
+package preopen
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/gobroker/gbreg"
+	"github.com/alpacahq/gobroker/mailer"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	api "github.com/alpacahq/gobroker/rest/api/controller/order"
+	"github.com/alpacahq/gobroker/service/account"
+	"github.com/alpacahq/gobroker/service/corporateaction"
+	"github.com/alpacahq/gobroker/service/order"
+	"github.com/alpacahq/gobroker/stream"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gobroker/utils/tradingdate"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+)
+
+type preopenWorker struct {
+	orders  order.OrderService
+	publish func(msg stream.OutboundMessage) error
+	send    func(acct, givenName, email string, orders []mailer.OrderAdjustment) error
+}
+
+// Work cancels or cancel/replaces the open orders in securities with a
+// split or symbol change taking effect today, before the market opens.
+// The actions are the ones the start of day mandatory actions report
+// recorded for the previous trading date.
+func Work() {
+	w := &preopenWorker{
+		orders:  gbreg.Services.Order(),
+		publish: stream.Publish,
+		send:    mailer.SendOrdersAdjusted,
+	}
+
+	w.work(clock.Now().In(calendar.NY))
+}
+
+func (w *preopenWorker) work(now time.Time) {
+	if !calendar.IsMarketDay(now) {
+		return
+	}
+
+	asof := tradingdate.Last(now)
+
+	actions, err := corporateaction.Service().WithTx(db.DB()).List(asof.String())
+	if err != nil {
+		log.Error("pre-open worker database error", "error", err)
+		return
+	}
+
+	assetIDs := []string{}
+	byAsset := map[string][]models.CorporateAction{}
+
+	for _, action := range actions {
+		id := action.AssetID.String()
+		if _, ok := byAsset[id]; !ok {
+			assetIDs = append(assetIDs, id)
+		}
+		byAsset[id] = append(byAsset[id], action)
+	}
+
+	// orders placed today already know about the actions, and that
+	// includes the replacements from an earlier run
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, calendar.NY)
+
+	notices := map[string][]mailer.OrderAdjustment{}
+
+	for _, assetID := range assetIDs {
+		if !handled(byAsset[assetID]) {
+			continue
+		}
+
+		asset := &models.Asset{}
+
+		if err := db.DB().Where("id = ?", assetID).First(asset).Error; err != nil {
+			log.Error("pre-open worker database error", "asset", assetID, "error", err)
+			continue
+		}
+
+		orders := []models.Order{}
+
+		if err := db.DB().
+			Where("asset_id = ? AND status IN (?) AND cancel_requested_at IS NULL AND replaced_at IS NULL AND created_at < ?",
+				asset.ID, enum.OrderOpen, since).
+			Find(&orders).Error; err != nil {
+			log.Error("pre-open worker database error", "asset", assetID, "error", err)
+			continue
+		}
+
+		for i := range orders {
+			notice, err := w.handle(&orders[i], asset, byAsset[assetID], asof.Date())
+			if err != nil {
+				log.Error(
+					"failed to adjust order for corporate action",
+					"order", orders[i].ID,
+					"symbol", orders[i].Symbol,
+					"error", err)
+				continue
+			}
+
+			notices[orders[i].Account] = append(notices[orders[i].Account], *notice)
+		}
+	}
+
+	for apexAcct, orders := range notices {
+		w.notify(apexAcct, orders)
+	}
+}
+
+// handled returns true if the pre-open job has a policy for any of the
+// asset's actions. Orders on assets without one were already canceled
+// by the start of day process.
+func handled(actions []models.CorporateAction) bool {
+	for _, action := range actions {
+		if _, ok := corporateaction.Policy(action.Type); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// handle cancels the order, and marks it for replacement with the
+// adjusted order if the policy allows for it. The replacement is only
+// made once the cancel is confirmed, for whatever was left unfilled,
+// so the two never hold buying power at once. Queued orders are
+// canceled right away, so theirs is queued in their place now. The
+// account owner is notified over trade_updates when the order is
+// canceled or replaced.
+func (w *preopenWorker) handle(
+	o *models.Order,
+	asset *models.Asset,
+	actions []models.CorporateAction,
+	asof date.Date) (*mailer.OrderAdjustment, error) {
+
+	acct, err := account.Service().WithTx(db.DB()).GetByApexAccount(o.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	notice := &mailer.OrderAdjustment{
+		Symbol:   o.Symbol,
+		Side:     string(o.Side),
+		Qty:      o.Qty,
+		Canceled: true,
+		Reason:   reason(actions),
+	}
+
+	o.CorporateActionDate = &asof
+
+	// what the order would be replaced with as it stands, which is
+	// what the owner is told about
+	replacement, target, err := corporateaction.Service().WithTx(db.DB()).Replacement(acct.IDAsUUID(), o)
+	if err != nil {
+		return nil, err
+	}
+
+	queued := o.Status == enum.OrderQueued
+
+	tx := db.Begin()
+
+	if err = w.orders.WithTx(tx).Cancel(acct.IDAsUUID(), o.IDAsUUID()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if replacement != nil {
+		if err = tx.Model(o).Update("corporate_action_date", asof).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	if replacement != nil && !queued {
+		// the trade worker replaces it once the cancel is confirmed
+		notice.Canceled = false
+		notice.NewSymbol = replacement.Symbol
+		notice.NewQty = replacement.Qty
+
+		return notice, nil
+	}
+
+	if replacement != nil {
+		replacement, target, err = corporateaction.Service().
+			WithTx(db.DB()).
+			ReplaceOrder(w.orders, acct.IDAsUUID(), o)
+
+		if err != nil {
+			log.Warn(
+				"failed to replace order for corporate action",
+				"order", o.ID,
+				"symbol", o.Symbol,
+				"error", err)
+
+			notice.Reason = fmt.Sprintf("%v, the replacement order was rejected: %v", notice.Reason, err)
+		}
+	}
+
+	update := map[string]interface{}{
+		"event":     corporateaction.EventOrderCanceled,
+		"order":     api.OrderToEntity(o, asset),
+		"reason":    notice.Reason,
+		"timestamp": clock.Now(),
+	}
+
+	if replacement != nil {
+		notice.Canceled = false
+		notice.NewSymbol = replacement.Symbol
+		notice.NewQty = replacement.Qty
+
+		update["event"] = corporateaction.EventOrderReplaced
+		update["replaced_by"] = api.OrderToEntity(replacement, target)
+	}
+
+	if err = w.publish(stream.OutboundMessage{
+		Stream: stream.TradeUpdatesStream(acct.IDAsUUID()),
+		Data:   update,
+	}); err != nil {
+		log.Error("failed to publish order adjustment", "order", o.ID, "error", err)
+	}
+
+	return notice, nil
+}
+
+func reason(actions []models.CorporateAction) string {
+	types := make([]string, len(actions))
+	for i, action := range actions {
+		types[i] = strings.Replace(string(action.Type), "_", " ", -1)
+	}
+	return strings.Join(types, ", ")
+}
+
+func (w *preopenWorker) notify(apexAcct string, orders []mailer.OrderAdjustment) {
+	acct, err := account.Service().WithTx(db.DB()).GetByApexAccount(apexAcct)
+	if err != nil {
+		log.Error("pre-open worker database error", "account", apexAcct, "error", err)
+		return
+	}
+
+	owner := acct.PrimaryOwner()
+	if owner == nil || owner.Details.GivenName == nil {
+		return
+	}
+
+	w.send(apexAcct, *owner.Details.GivenName, owner.Email, orders)
+}
@@ -0,0 +1,246 @@
+package preopen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alpacahq/gobroker/dbtest"
+	"github.com/alpacahq/gobroker/mailer"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	"github.com/alpacahq/gobroker/service/assetcache"
+	"github.com/alpacahq/gobroker/service/corporateaction"
+	"github.com/alpacahq/gobroker/service/marketdata"
+	"github.com/alpacahq/gobroker/service/order"
+	"github.com/alpacahq/gobroker/service/position"
+	"github.com/alpacahq/gobroker/service/tradeaccount"
+	"github.com/alpacahq/gobroker/stream"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/polycache/structures"
+	"github.com/gofrs/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PreopenTestSuite struct {
+	dbtest.Suite
+	account *models.Account
+}
+
+func TestPreopenTestSuite(t *testing.T) {
+	suite.Run(t, new(PreopenTestSuite))
+}
+
+// a Thursday morning before the open, the day after the actions
+var preopen = time.Date(2019, 2, 14, 9, 0, 0, 0, calendar.NY)
+
+func (s *PreopenTestSuite) SetupSuite() {
+	clock.Set(preopen)
+
+	s.SetupDB()
+
+	amt := decimal.New(1000000, 0)
+	apexAcct := "apca_test"
+	legalName := "Test Trader"
+	givenName := "Test"
+	s.account = &models.Account{
+		ApexAccount:        &apexAcct,
+		Status:             enum.Active,
+		Cash:               amt,
+		CashWithdrawable:   amt,
+		ApexApprovalStatus: enum.Complete,
+		Owners: []models.Owner{
+			models.Owner{
+				Email:   "trader@test.db",
+				Primary: true,
+			},
+		},
+	}
+	if err := db.DB().Create(s.account).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+	details := &models.OwnerDetails{
+		OwnerID:   s.account.Owners[0].ID,
+		LegalName: &legalName,
+		GivenName: &givenName,
+	}
+	if err := db.DB().Create(details).Error; err != nil {
+		assert.FailNow(s.T(), err.Error())
+	}
+}
+
+func (s *PreopenTestSuite) TearDownSuite() {
+	clock.Set(time.Now())
+	s.TeardownDB()
+}
+
+func (s *PreopenTestSuite) genAsset(symbol string, action enum.CorporateActionType, factor decimal.Decimal) *models.Asset {
+	asset := &models.Asset{
+		Class:    enum.AssetClassUSEquity,
+		Exchange: "NYSE",
+		Symbol:   symbol,
+		Status:   enum.AssetActive,
+		Tradable: true,
+	}
+	require.Nil(s.T(), db.DB().Create(asset).Error)
+
+	require.Nil(s.T(), db.DB().Create(&models.CorporateAction{
+		AssetID:     asset.IDAsUUID(),
+		Type:        action,
+		Date:        "2019-02-13",
+		StockFactor: &factor,
+	}).Error)
+
+	return asset
+}
+
+func (s *PreopenTestSuite) genOrder(asset *models.Asset, status enum.OrderStatus, qty int64) *models.Order {
+	limit := decimal.New(100, 0)
+	o := &models.Order{
+		Account:         *s.account.ApexAccount,
+		AssetID:         asset.ID,
+		Symbol:          asset.Symbol,
+		Qty:             decimal.New(qty, 0),
+		Side:            enum.Buy,
+		Type:            enum.Limit,
+		ClientOrderType: enum.Limit,
+		LimitPrice:      &limit,
+		TimeInForce:     enum.GTC,
+		Status:          status,
+		CreatedAt:       preopen.AddDate(0, 0, -2),
+		SubmittedAt:     preopen.AddDate(0, 0, -2),
+	}
+	require.Nil(s.T(), db.DB().Create(o).Error)
+	return o
+}
+
+func (s *PreopenTestSuite) TestWork() {
+	split := s.genAsset("PRES", enum.StockSplit, decimal.New(2, 0))
+	reverse := s.genAsset("PRER", enum.ReverseSplit, decimal.NewFromFloat(0.1))
+
+	md := marketdata.NewStore()
+	md.SetTrade(split.Symbol, structures.Trade{Price: 50, Size: 100, Timestamp: clock.Now()})
+
+	cancels := 0
+	orders := order.Service(
+		func(accountID uuid.UUID, msg interface{}) error {
+			cancels++
+			return nil
+		},
+		position.Service(assetcache.GetAssetCache(), md),
+		tradeaccount.Service(),
+		md)
+
+	published := []stream.OutboundMessage{}
+	notices := []mailer.OrderAdjustment{}
+
+	w := &preopenWorker{
+		orders: orders,
+		publish: func(msg stream.OutboundMessage) error {
+			published = append(published, msg)
+			return nil
+		},
+		send: func(acct, givenName, email string, orders []mailer.OrderAdjustment) error {
+			notices = append(notices, orders...)
+			return nil
+		},
+	}
+
+	live := s.genOrder(split, enum.OrderNew, 10)
+	queued := s.genOrder(split, enum.OrderQueued, 5)
+	canceled := s.genOrder(reverse, enum.OrderNew, 10)
+
+	w.work(preopen)
+
+	reload := func(o *models.Order) *models.Order {
+		require.Nil(s.T(), db.DB().Where("id = ?", o.ID).First(o).Error)
+		return o
+	}
+
+	replacementOf := func(o *models.Order) *models.Order {
+		replacement := &models.Order{}
+		q := db.DB().Where("replaces = ?", o.ID).First(replacement)
+		if q.RecordNotFound() {
+			return nil
+		}
+		require.Nil(s.T(), q.Error)
+		return replacement
+	}
+
+	assert.Equal(s.T(), 2, cancels)
+	assert.Len(s.T(), notices, 3)
+
+	// the live order is only asked to cancel, and replaced later on
+	{
+		live = reload(live)
+		assert.NotNil(s.T(), live.CancelRequestedAt)
+		require.NotNil(s.T(), live.CorporateActionDate)
+		assert.Equal(s.T(), "2019-02-13", live.CorporateActionDate.String())
+		assert.Nil(s.T(), replacementOf(live))
+	}
+
+	// the queued order is replaced right away, split 2:1
+	{
+		queued = reload(queued)
+		assert.Equal(s.T(), enum.OrderCanceled, queued.Status)
+
+		replacement := replacementOf(queued)
+		require.NotNil(s.T(), replacement)
+		assert.Equal(s.T(), enum.OrderQueued, replacement.Status)
+		assert.True(s.T(), replacement.Qty.Equal(decimal.New(10, 0)))
+		assert.True(s.T(), replacement.LimitPrice.Equal(decimal.New(50, 0)))
+		require.NotNil(s.T(), queued.ReplacedBy)
+		assert.Equal(s.T(), replacement.ID, *queued.ReplacedBy)
+	}
+
+	// reverse splits are canceled without a replacement
+	{
+		canceled = reload(canceled)
+		assert.NotNil(s.T(), canceled.CancelRequestedAt)
+		assert.Nil(s.T(), canceled.CorporateActionDate)
+	}
+
+	events := map[interface{}]int{}
+	for _, msg := range published {
+		events[msg.Data.(map[string]interface{})["event"]]++
+	}
+	assert.Equal(s.T(), 1, events[corporateaction.EventOrderReplaced])
+	assert.Equal(s.T(), 1, events[corporateaction.EventOrderCanceled])
+
+	// once the cancel is confirmed with 3 shares filled, the rest is
+	// replaced
+	{
+		filled := decimal.New(3, 0)
+		require.Nil(s.T(), db.DB().Model(live).Updates(models.Order{
+			Status:    enum.OrderCanceled,
+			FilledQty: &filled,
+		}).Error)
+
+		replacement, _, err := corporateaction.Service().
+			WithTx(db.DB()).
+			ReplaceOrder(orders, s.account.IDAsUUID(), reload(live))
+		assert.Nil(s.T(), err)
+		require.NotNil(s.T(), replacement)
+		assert.True(s.T(), replacement.Qty.Equal(decimal.New(14, 0)))
+
+		live = reload(live)
+		require.NotNil(s.T(), live.ReplacedBy)
+		assert.Equal(s.T(), replacement.ID, *live.ReplacedBy)
+
+		// and only once
+		replacement, _, err = corporateaction.Service().
+			WithTx(db.DB()).
+			ReplaceOrder(orders, s.account.IDAsUUID(), live)
+		assert.Nil(s.T(), err)
+		assert.Nil(s.T(), replacement)
+	}
+
+	// a second run leaves the orders alone
+	cancels = 0
+	w.work(preopen)
+	assert.Equal(s.T(), 0, cancels)
+}
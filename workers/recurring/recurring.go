@@ -0,0 +1,87 @@
+package recurring
+
+import (
+	"fmt"
+
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/service/recurring"
+	"github.com/alpacahq/gobroker/service/transfer"
+	"github.com/alpacahq/gobroker/utils/date"
+	"github.com/alpacahq/gopaca/calendar"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/log"
+)
+
+// Work creates the transfers for the recurring schedules which
+// are due today. It only runs on business days, so runs scheduled
+// over a weekend or holiday go out on the next one.
+func Work() {
+	now := clock.Now().In(calendar.NY)
+
+	if !calendar.IsMarketDay(now) {
+		return
+	}
+
+	asof := date.DateOf(now)
+
+	rts, err := recurring.Service(transfer.Service()).WithTx(db.DB()).Due(asof)
+	if err != nil {
+		log.Error("recurring transfer worker database error", "error", err)
+		return
+	}
+
+	for i := range rts {
+		handle(&rts[i], asof)
+	}
+}
+
+func handle(rt *models.RecurringTransfer, asof date.Date) {
+	tx := db.Begin()
+
+	// handle panics to not leak DB transactions
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			log.Error("panicked while handling recurring transfer", "recurring_transfer", rt.ID, "account", rt.AccountID)
+		}
+	}()
+
+	xfer, err := recurring.Service(transfer.Service()).WithTx(tx).Execute(rt, asof)
+	if err != nil {
+		tx.Rollback()
+
+		log.Warn(
+			"pausing recurring transfer that failed to run",
+			"recurring_transfer", rt.ID,
+			"account", rt.AccountID,
+			"error", err)
+
+		// pause it so it doesn't keep failing silently
+		tx = db.Begin()
+
+		if _, err = recurring.Service(transfer.Service()).WithTx(tx).Pause(
+			rt.AccountIDAsUUID(), rt.ID, fmt.Sprintf("failed to run: %v", err)); err != nil {
+
+			tx.Rollback()
+			log.Error(
+				"failed to pause recurring transfer",
+				"recurring_transfer", rt.ID,
+				"error", err)
+			return
+		}
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		log.Error("recurring transfer worker database error", "error", err)
+		return
+	}
+
+	if xfer != nil {
+		log.Info(
+			"created recurring transfer",
+			"recurring_transfer", rt.ID,
+			"transfer", xfer.ID,
+			"amount", xfer.Amount)
+	}
+}
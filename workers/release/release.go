@@ -0,0 +1,100 @@
+package release
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/gobroker/gberrors"
+	"github.com/alpacahq/gobroker/gbreg"
+	"github.com/alpacahq/gobroker/models"
+	"github.com/alpacahq/gobroker/models/enum"
+	api "github.com/alpacahq/gobroker/rest/api/controller/order"
+	"github.com/alpacahq/gobroker/stream"
+	"github.com/alpacahq/gobroker/workers/common"
+	"github.com/alpacahq/gopaca/clock"
+	"github.com/alpacahq/gopaca/db"
+	"github.com/alpacahq/gopaca/env"
+	"github.com/alpacahq/gopaca/log"
+)
+
+// EventQueuedOrderRejected is sent on trade_updates when a queued order
+// no longer passes its checks at release
+const EventQueuedOrderRejected = "queued_order_rejected"
+
+type releaseWorker struct {
+	done chan struct{}
+}
+
+var worker *releaseWorker
+
+// Work sends the queued orders which are due to the order queue, at
+// most ORDER_RELEASE_BATCH of them a run, so the orders held overnight
+// go out in a steady burst ahead of the open rather than all at once.
+func Work() {
+	if worker == nil {
+		worker = &releaseWorker{done: make(chan struct{}, 1)}
+		worker.done <- struct{}{}
+	}
+
+	// make sure not to overlap if the work routine is taking long
+	if common.WaitTimeout(worker.done, time.Second) {
+		return
+	}
+
+	defer func() {
+		worker.done <- struct{}{}
+	}()
+
+	batch, err := strconv.Atoi(env.GetVar("ORDER_RELEASE_BATCH"))
+	if err != nil || batch <= 0 {
+		log.Error("invalid order release batch", "batch", env.GetVar("ORDER_RELEASE_BATCH"))
+		return
+	}
+
+	orders, err := gbreg.Services.Order().WithTx(db.DB()).Releasable(batch)
+	if err != nil {
+		log.Error("release worker database error", "error", err)
+		return
+	}
+
+	for i := range orders {
+		o := &orders[i]
+
+		err := gbreg.Services.Order().WithTx(db.DB()).Release(o)
+
+		switch {
+		case err == nil:
+			log.Info("queued order released", "order", o.ID, "symbol", o.Symbol)
+		case o.Status == enum.OrderRejected:
+			log.Info("queued order rejected", "order", o.ID, "symbol", o.Symbol, "reason", err)
+			reject(o, err)
+		default:
+			log.Error("failed to release queued order", "order", o.ID, "symbol", o.Symbol, "error", err)
+		}
+	}
+}
+
+func reject(o *models.Order, reason error) {
+	acct, err := gbreg.Services.Account().WithTx(db.DB()).GetByApexAccount(o.Account)
+	if err != nil {
+		log.Error("release worker database error", "order", o.ID, "error", err)
+		return
+	}
+
+	msg := reason.Error()
+	if gberr, ok := reason.(*gberrors.Error); ok {
+		msg = gberr.Message
+	}
+
+	if err = stream.Publish(stream.OutboundMessage{
+		Stream: stream.TradeUpdatesStream(acct.IDAsUUID()),
+		Data: map[string]interface{}{
+			"event":     EventQueuedOrderRejected,
+			"order":     api.OrderToEntity(o, gbreg.Services.AssetCache().Get(o.AssetID)),
+			"reason":    msg,
+			"timestamp": clock.Now(),
+		},
+	}); err != nil {
+		log.Error("failed to publish queued order rejection", "order", o.ID, "error", err)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/alpacahq/apex"
 	"github.com/alpacahq/gobroker/gbreg"
 	"github.com/alpacahq/gobroker/models"
-	"github.com/alpacahq/gobroker/price"
 	"github.com/alpacahq/gobroker/service/assetcache"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/alpacahq/gobroker/utils/tradingdate"
@@ -133,13 +132,16 @@ func computeDayPL(tx *gorm.DB, acc *models.Account, target tradingdate.TradingDa
 		}
 	}
 
-	until := target.MarketClose().Add(-1 * time.Millisecond)
-	clp, err := price.GetLatest(currentClosingSymbols, &until)
+	md := gbreg.Services.MarketData()
+
+	// the closes on target are the last closes as of the day after
+	next := target.Next()
+	clp, err := md.LastClose(currentClosingSymbols, &next)
 	if err != nil {
 		panic(err)
 	}
 
-	llp, err := price.LastDayClosing(lastClosingSymbols, &target)
+	llp, err := md.LastClose(lastClosingSymbols, &target)
 	if err != nil {
 		panic(err)
 	}
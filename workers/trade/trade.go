@@ -12,6 +12,7 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	api "github.com/alpacahq/gobroker/rest/api/controller/order"
+	"github.com/alpacahq/gobroker/service/corporateaction"
 	"github.com/alpacahq/gobroker/service/registry"
 	"github.com/alpacahq/gobroker/trading"
 	"github.com/alpacahq/gopaca/clock"
@@ -195,7 +196,21 @@ func (w *TradeWorker) workExecutions() {
 			}
 		}
 
-		return tx.Commit().Error
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+
+		switch e.Type {
+		case enum.ExecutionCanceled, enum.ExecutionExpired:
+			order, err := w.services.Order().WithTx(w.db).GetByID(acct.IDAsUUID(), e.OrderIDAsUUID())
+			if err != nil {
+				log.Error("trade worker failed to find order for execution", "order", e.OrderID, "error", err)
+				return nil
+			}
+			w.replace(acct, order)
+		}
+
+		return nil
 	}
 	w.consume(w.consumerName, w.queueExecutions, handler)
 }
@@ -313,9 +328,24 @@ func (w *TradeWorker) workCancelRejections() {
 				return errors.Wrap(err, "failed to commit order status to canceled")
 			}
 
+			w.replace(acct, order)
+
 			return nil
 		}
 
+		// the order is still live, so it isn't replaced for the corporate
+		// action it was canceled for if it's canceled later on
+		if err = tx.Model(order).Update("corporate_action_date", nil).Error; err != nil {
+			tx.Rollback()
+			w.storeFailure(&models.TradeFailure{
+				Queue:  w.queueCancelRejection,
+				Body:   msg,
+				Reason: models.DatabaseFailure,
+				Error:  err.Error(),
+			})
+			return err
+		}
+
 		if err = tx.Commit().Error; err != nil {
 			w.storeFailure(&models.TradeFailure{
 				Queue:  w.queueCancelRejection,
@@ -356,6 +386,13 @@ func (w *TradeWorker) handleExecution(tx *gorm.DB, acct *models.TradeAccount, e
 		return nil, err
 	}
 
+	// fills of child orders roll up into their algo order
+	if order.ParentID != nil {
+		if err := srv.RollUp(*order.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := w.processExecution(tx, acct, e); err != nil {
 		log.Error(
 			"trade worker process failure",
@@ -386,6 +423,48 @@ func (w *TradeWorker) handleExecution(tx *gorm.DB, acct *models.TradeAccount, e
 	return report, nil
 }
 
+// replace queues the replacement for an order canceled for corporate
+// actions now that the cancel is confirmed. The execution is already
+// handled by then, so failures are only reported to the account.
+func (w *TradeWorker) replace(acct *models.TradeAccount, order *models.Order) {
+	if order.CorporateActionDate == nil {
+		return
+	}
+
+	replacement, target, err := corporateaction.Service().
+		WithTx(w.db).
+		ReplaceOrder(w.services.Order(), acct.IDAsUUID(), order)
+
+	update := map[string]interface{}{
+		"event":     corporateaction.EventOrderReplaced,
+		"order":     api.OrderToEntity(order, w.services.AssetCache().Get(order.AssetID)),
+		"timestamp": clock.Now(),
+	}
+
+	switch {
+	case err != nil:
+		log.Warn(
+			"failed to replace order for corporate action",
+			"order", order.ID,
+			"symbol", order.Symbol,
+			"error", err)
+
+		update["event"] = corporateaction.EventOrderCanceled
+		update["reason"] = fmt.Sprintf("the replacement order was rejected: %v", err)
+	case replacement == nil:
+		return
+	default:
+		update["replaced_by"] = api.OrderToEntity(replacement, target)
+	}
+
+	if err = w.streamPush(stream.OutboundMessage{
+		Stream: stream.TradeUpdatesStream(acct.IDAsUUID()),
+		Data:   update,
+	}); err != nil {
+		log.Error("failed to publish order replacement", "order", order.ID, "error", err)
+	}
+}
+
 func (w *TradeWorker) streamPush(msg stream.OutboundMessage) error {
 	buf, err := json.Marshal(msg)
 	if err != nil {
@@ -15,17 +15,24 @@ import (
 	"github.com/alpacahq/gobroker/models"
 	"github.com/alpacahq/gobroker/models/enum"
 	"github.com/alpacahq/gobroker/sod"
+	"github.com/alpacahq/gobroker/stream"
 	"github.com/alpacahq/gobroker/utils"
 	"github.com/alpacahq/gobroker/utils/gbevents"
 	"github.com/alpacahq/gobroker/utils/initializer"
 	"github.com/alpacahq/gobroker/utils/signalman"
 	"github.com/alpacahq/gobroker/workers/account"
 	"github.com/alpacahq/gobroker/workers/ale"
+	"github.com/alpacahq/gobroker/workers/alert"
+	"github.com/alpacahq/gobroker/workers/algo"
 	"github.com/alpacahq/gobroker/workers/backup"
 	"github.com/alpacahq/gobroker/workers/braggart"
+	"github.com/alpacahq/gobroker/workers/equity"
 	"github.com/alpacahq/gobroker/workers/funding"
 	"github.com/alpacahq/gobroker/workers/gbtrade"
 	"github.com/alpacahq/gobroker/workers/gc"
+	"github.com/alpacahq/gobroker/workers/preopen"
+	"github.com/alpacahq/gobroker/workers/recurring"
+	"github.com/alpacahq/gobroker/workers/release"
 	sodWorker "github.com/alpacahq/gobroker/workers/sod"
 	"github.com/alpacahq/gobroker/workers/trade"
 	"github.com/alpacahq/gopaca/calendar"
@@ -57,6 +64,7 @@ func shutdown() error {
 	// stop the RMQ related tasks explicitly
 	account.Stop()
 	tradeWorker.Stop()
+	stream.StopPublish()
 
 	// sleep a second to let things cleanup
 	<-time.After(time.Second)
@@ -132,6 +140,20 @@ func main() {
 		funding.Work()
 	})
 
+	// recurring transfers - weekdays @ 7 AM central
+	c.AddFunc("0 0 7 * * MON-FRI", func() {
+		cronWg.Add(1)
+		defer cronWg.Done()
+		recurring.Work()
+	})
+
+	// pre-open order adjustments for corporate actions - weekdays @ 8 AM central
+	c.AddFunc("0 0 8 * * MON-FRI", func() {
+		cronWg.Add(1)
+		defer cronWg.Done()
+		preopen.Work()
+	})
+
 	// braggart worker
 	log.Info(
 		"starting braggart worker",
@@ -144,6 +166,54 @@ func main() {
 		braggart.Work()
 	})
 
+	// intraday equity snapshots
+	log.Info(
+		"starting equity worker",
+		"interval",
+		env.GetVar("EQUITY_WORKER_INTERVAL"))
+
+	c.AddFunc(fmt.Sprintf("@every %v", env.GetVar("EQUITY_WORKER_INTERVAL")), func() {
+		cronWg.Add(1)
+		defer cronWg.Done()
+		equity.Work()
+	})
+
+	// price alerts
+	log.Info(
+		"starting alert worker",
+		"interval",
+		env.GetVar("ALERT_WORKER_INTERVAL"))
+
+	c.AddFunc(fmt.Sprintf("@every %v", env.GetVar("ALERT_WORKER_INTERVAL")), func() {
+		cronWg.Add(1)
+		defer cronWg.Done()
+		alert.Work()
+	})
+
+	// queued order release
+	log.Info(
+		"starting order release worker",
+		"interval",
+		env.GetVar("ORDER_RELEASE_WORKER_INTERVAL"))
+
+	c.AddFunc(fmt.Sprintf("@every %v", env.GetVar("ORDER_RELEASE_WORKER_INTERVAL")), func() {
+		cronWg.Add(1)
+		defer cronWg.Done()
+		release.Work()
+	})
+
+	// algo orders
+	log.Info(
+		"starting algo worker",
+		"interval",
+		env.GetVar("ALGO_WORKER_INTERVAL"))
+
+	c.AddFunc(fmt.Sprintf("@every %v", env.GetVar("ALGO_WORKER_INTERVAL")), func() {
+		cronWg.Add(1)
+		defer cronWg.Done()
+		algo.Work()
+	})
+
 	// sod sync - tue-sat @ 6 AM central
 	c.AddFunc("0 0 6 * * TUE-SAT", func() {
 		cronWg.Add(1)